@@ -0,0 +1,184 @@
+package rebirth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// installAgent puts __rebirth for the architecture of host.docker to .rebirth/agents/<os>-<arch> directory.
+func (r *Reloader) installAgent() error {
+	if _, err := r.installAgentFor(r.host.Docker); err != nil {
+		return err
+	}
+	return nil
+}
+
+// agentPath returns the path of __rebirth for the platform relative to the project directory.
+// The project directory is mounted on containers, so the path is available on them too.
+func agentPath(platform string) string {
+	return filepath.Join(agentsDir, platform, "__rebirth")
+}
+
+// installAgentFor returns __rebirth for the container's architecture.
+func (r *Reloader) installAgentFor(container string) (string, error) {
+	r.agentMu.Lock()
+	platform, exists := r.agentPlatforms[container]
+	r.agentMu.Unlock()
+	if !exists {
+		goos, goarch, err := containerPlatform(container)
+		if err != nil {
+			return "", xerrors.Errorf("failed to get platform of container: %w", err)
+		}
+		platform = fmt.Sprintf("%s-%s", goos, goarch)
+		r.agentMu.Lock()
+		r.agentPlatforms[container] = platform
+		r.agentMu.Unlock()
+	}
+	return r.installAgentForPlatform(platform)
+}
+
+// installAgentForPlatform returns __rebirth for platform ( <os>-<arch> ).
+// Agents are cross compiled ( or fetched if host.agent is specified ) once per architecture and cached by <os>-<arch> ,
+// so targets with the same architecture share the agent and targets with different architectures get the matching one.
+func (r *Reloader) installAgentForPlatform(platform string) (string, error) {
+	r.agentMu.Lock()
+	defer r.agentMu.Unlock()
+	path := agentPath(platform)
+	if r.agentInstalled[platform] {
+		return path, nil
+	}
+	target := filepath.Join(cwd, path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", xerrors.Errorf("failed to create directory for agent: %w", err)
+	}
+	agent := r.host.Agent
+	if agent == nil || (agent.Path == "" && agent.URL == "") {
+		r.logger.Infof("Building agent for %s", platform)
+		if err := r.xbuildAgent(platform, target); err != nil {
+			return "", xerrors.Errorf("failed to cross compile for rebirth agent: %w", err)
+		}
+	} else if err := installPrebuiltAgent(agent, platform, target); err != nil {
+		return "", err
+	}
+	r.agentInstalled[platform] = true
+	return path, nil
+}
+
+// installPrebuiltAgent puts the prebuilt agent for platform to target by host.agent.path or host.agent.url .
+func installPrebuiltAgent(agent *Agent, platform, target string) error {
+	checksum := agent.Checksums[platform]
+	if checksum != "" && verifyChecksum(target, checksum) == nil {
+		// already installed
+		return nil
+	}
+	if agent.Path != "" {
+		logger().Infof("Installing agent from %s", agent.Path)
+		if err := copyFile(target, ExpandPath(agent.Path), 0755); err != nil {
+			return xerrors.Errorf("failed to copy agent: %w", err)
+		}
+	} else {
+		if checksum == "" {
+			return xerrors.Errorf("host.agent.checksums must have checksum for %s", platform)
+		}
+		goos, goarch := splitPlatform(platform)
+		url := strings.NewReplacer("{os}", goos, "{arch}", goarch).Replace(agent.URL)
+		logger().Infof("Downloading agent from %s", url)
+		if err := downloadAgent(target, url); err != nil {
+			return xerrors.Errorf("failed to download agent: %w", err)
+		}
+	}
+	if checksum == "" {
+		return nil
+	}
+	if err := verifyChecksum(target, checksum); err != nil {
+		os.Remove(target)
+		return xerrors.Errorf("failed to verify agent: %w", err)
+	}
+	return nil
+}
+
+func splitPlatform(platform string) (string, string) {
+	parts := strings.SplitN(platform, "-", 2)
+	if len(parts) != 2 {
+		return platform, ""
+	}
+	return parts[0], parts[1]
+}
+
+func containerPlatform(container string) (string, string, error) {
+	platform, err := detectContainerPlatform(container)
+	if err != nil {
+		return "", "", xerrors.Errorf("failed to get platform: %w", err)
+	}
+	return platform.goos, platform.goarch, nil
+}
+
+func downloadAgent(target, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return xerrors.Errorf("failed to request to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("unexpected status code from %s: %d", url, resp.StatusCode)
+	}
+	if err := writeAgent(target, resp.Body); err != nil {
+		return xerrors.Errorf("failed to write agent: %w", err)
+	}
+	return nil
+}
+
+func writeAgent(target string, src io.Reader) error {
+	tmpfile, err := ioutil.TempFile(filepath.Dir(target), "agent")
+	if err != nil {
+		return xerrors.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := io.Copy(tmpfile, src); err != nil {
+		tmpfile.Close()
+		return xerrors.Errorf("failed to copy agent: %w", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return xerrors.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpfile.Name(), 0755); err != nil {
+		return xerrors.Errorf("failed to change mode: %w", err)
+	}
+	if err := os.Rename(tmpfile.Name(), target); err != nil {
+		return xerrors.Errorf("failed to rename %s to %s: %w", tmpfile.Name(), target, err)
+	}
+	return nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", xerrors.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func verifyChecksum(path, expected string) error {
+	actual, err := fileChecksum(path)
+	if err != nil {
+		return xerrors.Errorf("failed to get checksum: %w", err)
+	}
+	if !strings.EqualFold(actual, expected) {
+		return xerrors.Errorf("checksum mismatch for %s: expected %s but got %s", path, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package rebirth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/goccy/rebirth/internal/agent"
+	"golang.org/x/xerrors"
+)
+
+// AgentClient talks to the agent running on the container by the agent protocol.
+type AgentClient struct {
+	w       io.WriteCloser
+	dec     *json.Decoder
+	mu      sync.Mutex
+	id      int
+	pending map[int]chan *agent.Response
+	err     error
+	closed  bool
+	done    chan struct{}
+	tail    *outputTail
+	onExit  func(*agent.Response)
+	stdout  io.Writer
+	stderr  io.Writer
+
+	stopSignal  string
+	stopTimeout time.Duration
+}
+
+// StartAgent executes the agent on the container and connects to it.
+func StartAgent(container, path string) (*AgentClient, error) {
+	w, r, err := NewDockerCommand(container, path).Attach(context.Background())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to attach agent on container: %w", err)
+	}
+	client := NewAgentClient(w, r)
+	if err := client.Hello(); err != nil {
+		client.Close()
+		return nil, xerrors.Errorf("failed to handshake with agent: %w", err)
+	}
+	return client, nil
+}
+
+func NewAgentClient(w io.WriteCloser, r io.Reader) *AgentClient {
+	c := &AgentClient{
+		w:       w,
+		dec:     json.NewDecoder(r),
+		pending: map[int]chan *agent.Response{},
+		done:    make(chan struct{}),
+		tail:    newOutputTail(crashTailLines),
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+	go c.receive()
+	return c
+}
+
+func (c *AgentClient) receive() {
+	defer close(c.done)
+	for {
+		var res agent.Response
+		if err := c.dec.Decode(&res); err != nil {
+			c.mu.Lock()
+			c.err = err
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			return
+		}
+		switch res.Type {
+		case agent.ResponseOutput:
+			c.output(&res)
+		case agent.ResponseExit:
+			if res.Stopped {
+				break
+			}
+			if res.Next {
+				// reported by the reload switching to it
+				logger().Warnf("new process(%d) on container exited with status %d before switching", res.Pid, res.ExitStatus)
+				break
+			}
+			logger().Infof("process(%d) on container exited with status %d", res.Pid, res.ExitStatus)
+			if c.onExit != nil {
+				c.onExit(&res)
+			}
+		case agent.ResponseResult:
+			c.mu.Lock()
+			ch, exists := c.pending[res.ID]
+			delete(c.pending, res.ID)
+			c.mu.Unlock()
+			if exists {
+				ch <- &res
+			}
+		}
+	}
+}
+
+// OnExit sets callback called when the process on the container exits without stop request.
+func (c *AgentClient) OnExit(callback func(*agent.Response)) {
+	c.onExit = callback
+}
+
+// SetOutput changes the destination of the application's output ( default: os.Stdout and os.Stderr ).
+func (c *AgentClient) SetOutput(stdout, stderr io.Writer) {
+	c.stdout = stdout
+	c.stderr = stderr
+}
+
+func (c *AgentClient) output(res *agent.Response) {
+	c.tail.Write([]byte(res.Line))
+	if res.Stream == "stderr" {
+		io.WriteString(c.stderr, res.Line)
+		return
+	}
+	io.WriteString(c.stdout, res.Line)
+}
+
+func (c *AgentClient) request(req *agent.Request) (*agent.Response, error) {
+	ch := make(chan *agent.Response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, xerrors.Errorf("agent connection is closed: %w", err)
+	}
+	c.id++
+	req.ID = c.id
+	c.pending[req.ID] = ch
+	err := json.NewEncoder(c.w).Encode(req)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to send %s request: %w", req.Type, err)
+	}
+	res, ok := <-ch
+	if !ok {
+		return nil, xerrors.Errorf("agent connection is closed while waiting for %s request", req.Type)
+	}
+	if !res.OK {
+		return res, xerrors.Errorf("failed to %s: %s", req.Type, res.Error)
+	}
+	return res, nil
+}
+
+// Hello checks the protocol version of the agent.
+func (c *AgentClient) Hello() error {
+	res, err := c.request(&agent.Request{Type: agent.RequestHello, Version: agent.Version})
+	if err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	if res.Version != agent.Version {
+		return xerrors.Errorf(
+			"unsupported agent protocol version %d ( rebirth supports version %d ). please update agent",
+			res.Version,
+			agent.Version,
+		)
+	}
+	return nil
+}
+
+// Start stops the current process and starts path as a new process on the container.
+// The agent reports the process is ready if it is still running after wait.
+func (c *AgentClient) Start(path string, args, env []string, wait time.Duration) (*agent.Response, error) {
+	res, err := c.request(&agent.Request{
+		Type:        agent.RequestStart,
+		Path:        path,
+		Args:        args,
+		Env:         env,
+		Wait:        int(wait / time.Millisecond),
+		StopSignal:  c.stopSignal,
+		StopTimeout: int(c.stopTimeout / time.Millisecond),
+	})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// StartNext starts path as the next process alongside the current process on the container.
+// The current process keeps running until Switch . The readiness is checked like Start .
+func (c *AgentClient) StartNext(path string, args, env []string, wait time.Duration) (*agent.Response, error) {
+	res, err := c.request(&agent.Request{
+		Type:        agent.RequestStart,
+		Path:        path,
+		Args:        args,
+		Env:         env,
+		Wait:        int(wait / time.Millisecond),
+		StopSignal:  c.stopSignal,
+		StopTimeout: int(c.stopTimeout / time.Millisecond),
+		Next:        true,
+	})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Switch stops the current process on the container and makes the process started by StartNext current.
+func (c *AgentClient) Switch() (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestSwitch})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// StopNext stops the process started by StartNext , and keeps the current process.
+func (c *AgentClient) StopNext() (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestStop, Next: true})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// SetStopSignal specifies the signal and the timeout for stopping processes started by Start.
+func (c *AgentClient) SetStopSignal(sig string, timeout time.Duration) {
+	c.stopSignal = sig
+	c.stopTimeout = timeout
+}
+
+// Stop stops the current process on the container.
+func (c *AgentClient) Stop() (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestStop})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Status returns the state of the current process on the container.
+func (c *AgentClient) Status() (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestStatus})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// StatusNext returns the state of the process started by StartNext on the container.
+func (c *AgentClient) StatusNext() (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestStatus, Next: true})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Dial checks connectivity from the container to addr ( host:port ).
+func (c *AgentClient) Dial(addr string) (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestDial, Addr: addr})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Lookup resolves host by DNS of the container.
+func (c *AgentClient) Lookup(host string) (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestLookup, Addr: host})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Signal sends the signal to the current process on the container.
+func (c *AgentClient) Signal(name string) (*agent.Response, error) {
+	res, err := c.request(&agent.Request{Type: agent.RequestSignal, Signal: name})
+	if err != nil {
+		return res, xerrors.Errorf("failed to request: %w", err)
+	}
+	return res, nil
+}
+
+// Copy writes data to path on the container.
+func (c *AgentClient) Copy(path string, data []byte, mode os.FileMode) error {
+	if _, err := c.request(&agent.Request{
+		Type: agent.RequestCopy,
+		Path: path,
+		Data: data,
+		Mode: uint32(mode),
+	}); err != nil {
+		return xerrors.Errorf("failed to request: %w", err)
+	}
+	return nil
+}
+
+// Close closes connection to the agent. The agent stops the current process and exits.
+func (c *AgentClient) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	if cw, ok := c.w.(interface{ CloseWrite() error }); ok {
+		// close stdin of the agent only and wait for the remaining output
+		if err := cw.CloseWrite(); err == nil {
+			<-c.done
+		}
+	}
+	if err := c.w.Close(); err != nil {
+		return xerrors.Errorf("failed to close agent connection: %w", err)
+	}
+	<-c.done
+	return nil
+}
+
+func (c *AgentClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// isDisconnected returns true if the connection to the agent is lost ( e.g. the container is stopped ).
+func (c *AgentClient) isDisconnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
@@ -0,0 +1,200 @@
+package rebirth
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultArtifactKeep = 5
+
+// artifactStore keeps built binaries by generation under .rebirth/artifacts .
+type artifactStore struct {
+	dir  string
+	keep int
+}
+
+func newArtifactStore() *artifactStore {
+	return &artifactStore{
+		dir:  filepath.Join(cwd, configDir, "artifacts"),
+		keep: defaultArtifactKeep,
+	}
+}
+
+func (s *artifactStore) path(gen int) string {
+	return filepath.Join(s.dir, fmt.Sprint(gen))
+}
+
+func (s *artifactStore) exists(gen int) bool {
+	_, err := os.Stat(s.path(gen))
+	return err == nil
+}
+
+// save copies binary as the generation and removes old generations.
+// protected generation isn't removed even if it is old.
+func (s *artifactStore) save(gen int, binary string, protected int) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", s.dir, err)
+	}
+	if err := copyFile(s.path(gen), binary, 0755); err != nil {
+		return xerrors.Errorf("failed to copy binary: %w", err)
+	}
+	gens, err := s.generations()
+	if err != nil {
+		return xerrors.Errorf("failed to get generations: %w", err)
+	}
+	if len(gens) <= s.keep {
+		return nil
+	}
+	for _, old := range gens[:len(gens)-s.keep] {
+		if old == protected {
+			continue
+		}
+		os.Remove(s.path(old))
+	}
+	return nil
+}
+
+func (s *artifactStore) generations() ([]int, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", s.dir, err)
+	}
+	gens := []int{}
+	for _, file := range files {
+		gen, err := strconv.Atoi(file.Name())
+		if err != nil {
+			continue
+		}
+		gens = append(gens, gen)
+	}
+	sort.Ints(gens)
+	return gens, nil
+}
+
+func copyFile(dst, src string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+	tmpfile, err := ioutil.TempFile(filepath.Dir(dst), ".copy")
+	if err != nil {
+		return xerrors.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := io.Copy(tmpfile, in); err != nil {
+		tmpfile.Close()
+		return xerrors.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return xerrors.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpfile.Name(), mode); err != nil {
+		return xerrors.Errorf("failed to change mode: %w", err)
+	}
+	if err := os.Rename(tmpfile.Name(), dst); err != nil {
+		return xerrors.Errorf("failed to rename to %s: %w", dst, err)
+	}
+	return nil
+}
+
+// buildApp builds the application and saves the binary to the artifact store as a new generation.
+// The build result is recorded to the history store.
+func (r *Reloader) buildApp() error {
+	if !r.run.usesBinary() {
+		return r.runCommandHooks()
+	}
+	start := time.Now()
+	r.setState(StateBuilding, "")
+	r.emitBuildStart()
+	err := r.xbuild(buildPath, r.build.main())
+	if err == nil {
+		err = r.transformBinary(buildPath)
+	}
+	if err != nil {
+		if err := r.recordBuild(start, err); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+		r.recordBuildResult(err)
+		r.setState(StateFailed, buildErrorSummary(r.buildTail.Lines(), err))
+		r.showBuildFailure(r.buildTail.Lines(), err)
+		r.emitBuildResult(start, err)
+		return xerrors.Errorf("failed to build: %w", err)
+	}
+	r.generation++
+	if err := r.recordBuild(start, nil); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	r.recordBuildResult(nil)
+	r.clearBuildFailure()
+	r.emitBuildResult(start, nil)
+	if err := r.writeManifest(start, buildPath, r.build.main()); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	if err := r.artifacts.save(r.generation, buildPath, r.goodGeneration); err != nil {
+		return xerrors.Errorf("failed to save artifact: %w", err)
+	}
+	return nil
+}
+
+// checkHealth checks health of the restarted generation by run.healthcheck .
+// If the generation is unhealthy, it rolls back to the previous good generation.
+func (r *Reloader) checkHealth() error {
+	if r.run == nil || r.run.Healthcheck == nil {
+		r.markGoodGeneration()
+		return nil
+	}
+	err := r.run.Healthcheck.wait()
+	if err == nil {
+		r.markGoodGeneration()
+		return nil
+	}
+	r.logger.Warnf("generation %d is unhealthy: %v", r.generation, err)
+	if r.goodGeneration == 0 || !r.artifacts.exists(r.goodGeneration) {
+		return xerrors.Errorf("no previous good generation for rollback: %w", err)
+	}
+	r.logger.Infof("Rolling back to generation %d...", r.goodGeneration)
+	if err := r.restart(r.artifacts.path(r.goodGeneration)); err != nil {
+		return xerrors.Errorf("failed to rollback to generation %d: %w", r.goodGeneration, err)
+	}
+	r.rolledBack()
+	if err := r.run.Healthcheck.wait(); err != nil {
+		return xerrors.Errorf("generation %d is unhealthy after rollback: %w", r.goodGeneration, err)
+	}
+	r.logger.Infof("Rolled back to generation %d", r.goodGeneration)
+	return nil
+}
+
+// markGoodGeneration records the running generation as the good generation to roll back to.
+func (r *Reloader) markGoodGeneration() {
+	r.failureMu.Lock()
+	r.goodGeneration = r.generation
+	r.failureMu.Unlock()
+	r.runningGeneration = r.generation
+	r.manifestMu.Lock()
+	r.goodManifest = r.manifest
+	r.manifestMu.Unlock()
+}
+
+// rolledBack records the good generation as the running generation and puts back its manifest,
+// so the manifest and snapshots describe the binary which is actually running.
+func (r *Reloader) rolledBack() {
+	r.runningGeneration = r.goodGeneration
+	r.manifestMu.Lock()
+	manifest := r.goodManifest
+	r.manifestMu.Unlock()
+	if manifest == nil {
+		return
+	}
+	if err := r.saveManifest(manifest); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package rebirth
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	restartNever     = "never"
+	restartOnFailure = "on-failure"
+	restartAlways    = "always"
+
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = 30 * time.Second
+	// restartResetAfter is the running duration for regarding the restarted process as recovered.
+	restartResetAfter = time.Minute
+)
+
+func (r *Run) restartPolicy() string {
+	if r == nil || r.Restart == "" {
+		return restartNever
+	}
+	return r.Restart
+}
+
+// restartBackoff returns the delay before the retry-th restart. It doubles from run.restart_backoff up to run.restart_max_backoff .
+func (r *Run) restartBackoff(retry int) time.Duration {
+	backoff := parseDurationOr(r.RestartBackoff, defaultRestartBackoff)
+	max := parseDurationOr(r.RestartMaxBackoff, defaultRestartMaxBackoff)
+	for i := 1; i < retry && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// showRestartPolicy validates run.restart and shows it if auto restart is enabled.
+func (r *Reloader) showRestartPolicy() error {
+	policy := r.run.restartPolicy()
+	switch policy {
+	case restartNever:
+		return nil
+	case restartOnFailure, restartAlways:
+	default:
+		return xerrors.Errorf("unknown restart policy %s. never, on-failure and always are available", policy)
+	}
+	retries := "unlimited retries"
+	if r.run.RestartMaxRetries > 0 {
+		retries = fmt.Sprintf("max %d retries", r.run.RestartMaxRetries)
+	}
+	r.logger.Infof(
+		"Restart policy: %s ( backoff %s to %s, %s )",
+		policy,
+		r.run.restartBackoff(1),
+		parseDurationOr(r.run.RestartMaxBackoff, defaultRestartMaxBackoff),
+		retries,
+	)
+	return nil
+}
+
+// scheduleAutoRestart restarts the exited process by restart after the backoff if run.restart allows it.
+// Consecutive retries are counted until the process keeps running for restartResetAfter or rebirth restarts it.
+func (r *Reloader) scheduleAutoRestart(pid int, status string, failed bool, uptime time.Duration, restart func() error) {
+	policy := r.run.restartPolicy()
+	if policy == restartNever || (policy == restartOnFailure && !failed) {
+		return
+	}
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	if r.restartClosed {
+		return
+	}
+	if uptime >= restartResetAfter {
+		r.restartRetries = 0
+	}
+	max := r.run.RestartMaxRetries
+	if max > 0 && r.restartRetries >= max {
+		r.logger.Warnf("process(%d) exited ( %s ). gave up restarting after %d retries by restart policy %s", pid, status, max, policy)
+		return
+	}
+	r.restartRetries++
+	retry := fmt.Sprint(r.restartRetries)
+	if max > 0 {
+		retry = fmt.Sprintf("%d/%d", r.restartRetries, max)
+	}
+	delay := r.run.restartBackoff(r.restartRetries)
+	r.logger.Infof("process(%d) exited ( %s ). restarting in %s by restart policy %s ( retry %s )", pid, status, delay, policy, retry)
+	if r.restartTimer != nil {
+		r.restartTimer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		r.lockReload()
+		defer r.unlockReload()
+		r.restartMu.Lock()
+		canceled := r.restartTimer != timer || r.restartClosed
+		r.restartTimer = nil
+		r.restartMu.Unlock()
+		if canceled {
+			return
+		}
+		if err := restart(); err != nil {
+			r.logger.Errorf("failed to restart process by restart policy %s: %v", policy, err)
+			return
+		}
+		r.setState(StateRunning, "")
+	})
+	r.restartTimer = timer
+}
+
+// resetAutoRestart cancels the scheduled auto restart and the count of retries
+// because rebirth restarted the application ( e.g. by file changes ).
+func (r *Reloader) resetAutoRestart() {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	if r.restartTimer != nil {
+		r.restartTimer.Stop()
+		r.restartTimer = nil
+	}
+	r.restartRetries = 0
+}
+
+// closeAutoRestart stops auto restart on shutdown.
+func (r *Reloader) closeAutoRestart() {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	r.restartClosed = true
+	if r.restartTimer != nil {
+		r.restartTimer.Stop()
+		r.restartTimer = nil
+	}
+}
@@ -0,0 +1,53 @@
+package rebirth
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localCacheDir returns build.cache_dir as the absolute path on localhost. It's empty if build.cache_dir isn't specified.
+func (r *Reloader) localCacheDir() string {
+	if r.build.CacheDir == "" {
+		return ""
+	}
+	dir := ExpandPath(r.build.CacheDir)
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(cwd, dir)
+}
+
+// remoteCacheDir returns build.cache_dir ( default: .rebirth/cache ) on the remote machine.
+// The relative path is resolved from build.remote.dir , and kept across builds because it isn't sent or cleaned.
+func (r *Reloader) remoteCacheDir(remote *RemoteBuild) string {
+	dir := r.remoteCacheRelDir()
+	if path.IsAbs(dir) {
+		return dir
+	}
+	return path.Join(remote.dir(), dir)
+}
+
+func (r *Reloader) remoteCacheRelDir() string {
+	if r.build.CacheDir == "" {
+		// default for builds on the remote machine and the container
+		return path.Join(configDir, "cache")
+	}
+	return path.Clean(filepath.ToSlash(r.build.CacheDir))
+}
+
+// remoteExcludes returns files not sent to the remote machine. build.cache_dir in the project is excluded too.
+// configDir is set by init, so the list is created on each call.
+func (r *Reloader) remoteExcludes() []string {
+	excludes := []string{".git", configDir}
+	dir := r.remoteCacheRelDir()
+	if path.IsAbs(dir) || strings.HasPrefix(dir, "../") {
+		return excludes
+	}
+	for _, exclude := range excludes {
+		if dir == exclude || strings.HasPrefix(dir, exclude+"/") {
+			return excludes
+		}
+	}
+	return append(excludes, dir)
+}
@@ -0,0 +1,29 @@
+package rebirth
+
+import "golang.org/x/xerrors"
+
+func (c *Cgo) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// isCgoInContainer returns true if the application is built inside host.docker container by build.cgo.in_container .
+// build.remote takes precedence over it.
+func (r *Reloader) isCgoInContainer() bool {
+	return r.build.Cgo != nil && r.build.Cgo.InContainer && r.build.Cgo.enabled() &&
+		r.build.Remote == nil && r.isDockerMode()
+}
+
+// validateCgo validates build.cgo for the mode.
+func (r *Reloader) validateCgo() error {
+	cgo := r.build.Cgo
+	if cgo == nil || !cgo.InContainer {
+		return nil
+	}
+	if !r.isUsedDocker() {
+		return xerrors.New("build.cgo.in_container requires host.docker")
+	}
+	if !cgo.enabled() {
+		return xerrors.New("build.cgo.in_container can't be used with build.cgo.enabled: false")
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/goccy/rebirth/internal/agent"
+)
+
+func main() {
+	server := agent.NewServer(os.Stdin, os.Stdout)
+	// the agent is stopped by signals if rebirth is killed or the container is stopped.
+	// the application must be stopped with it
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-sig
+		server.Close()
+		os.Exit(1)
+	}()
+	if err := server.Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"os/signal"
+	"runtime/debug"
+	"sort"
 	"strings"
-	"syscall"
+	"time"
 
 	"github.com/goccy/rebirth"
 	"github.com/goccy/rebirth/internal/errors"
@@ -17,28 +21,119 @@ import (
 
 type Option struct {
 	Watch WatchCommand `description:"" command:"watch" hidden:"true"`
-	Init  InitCommand  `description:"create rebirth.yml for configuration" command:"init"`
+	Init  InitCommand  `description:"create .rebirth.yml for configuration" command:"init"`
 	Run   RunCommand   `description:"execute 'go run'   command"           command:"run"`
 	Test  TestCommand  `description:"execute 'go test'  command"           command:"test"`
+	Fuzz  FuzzCommand  `description:"run fuzz test and restart it on each change ( e.g. rebirth fuzz ./parser --fuzz FuzzParse )" command:"fuzz"`
 	Build BuildCommand `description:"execute 'go build' command"           command:"build"`
+
+	Debug    DebugCommand    `description:"run the application under the headless Delve server restarted on each reload ( e.g. rebirth debug --listen :2345 )" command:"debug"`
+	Observe  ObserveCommand  `description:"check builds on each change for the process owned by another tool without restarting it ( e.g. rebirth observe --pid 1234 )" command:"observe"`
+	Snapshot SnapshotCommand `description:"save or restore the running binary, env and config ( e.g. rebirth snapshot save good )" command:"snapshot"`
+
+	RunTask RunTaskCommand `description:"build and execute companion binary" command:"run-task"`
+	Task    TaskCommand    `description:"run task defined in tasks with its dependencies ( e.g. rebirth task generate )" command:"task"`
+	Freeze  FreezeCommand  `description:"suppress reloading for the duration ( e.g. 10m or off )" command:"freeze"`
+	Status  StatusCommand  `description:"show status of the running rebirth" command:"status"`
+	Doctor  DoctorCommand  `description:"check the environment for rebirth and show fixes for problems" command:"doctor"`
+	Stats   StatsCommand   `description:"show build results and reload logs of generations" command:"stats"`
+	Logs    LogsCommand    `description:"show output of the application captured around the restart ( e.g. rebirth logs --around gen17 )" command:"logs"`
+	Focus   FocusCommand   `description:"start building for the saved file immediately ( for editor plugins )" command:"focus"`
+	Up      UpCommand      `description:"build and start services ( e.g. rebirth up api worker )" command:"up"`
+	Service ServiceCommand `description:"install or uninstall launchd agent for background session ( macOS only )" command:"service"`
+	Profile ProfileCommand `description:"capture profile from net/http/pprof of the application ( e.g. rebirth profile api --seconds 30 )" command:"profile"`
+	Config  ConfigCommand  `description:"lint rebirth.yml or print JSON Schema of it ( e.g. rebirth config lint , rebirth config schema )" command:"config"`
+
+	// ConfigPath and ConfigProfile are extracted by main before parsing, and they're declared for help
+	ConfigPath    string `short:"c" long:"config" description:"path of config file ( default: searched from .rebirth.yml, rebirth.yml, configs/rebirth.yml and $XDG_CONFIG_HOME/rebirth/rebirth.yml )" env:"REBIRTH_CONFIG"`
+	ConfigProfile string `long:"profile" description:"name of profile in config file ( default: default )" env:"REBIRTH_PROFILE"`
 }
 
 type InitCommand struct{}
+
+type InitOption struct {
+	From string `long:"from" description:"translate the config of another tool ( air, realize, fresh or compiledaemon ) to .rebirth.yml"`
+	File string `long:"file" description:"path of the config of the tool specified by --from ( default: e.g. .air.toml for air )"`
+}
 type RunCommand struct{}
 type TestCommand struct{}
+
+type TestOption struct {
+	Watch   bool `long:"watch" description:"run tests of packages affected by changed files on each save"`
+	Explain bool `long:"explain" description:"show why each package is tested and which tests ran or were cached"`
+}
+type FuzzCommand struct{}
+
+type FuzzOption struct {
+	Fuzz string `long:"fuzz" description:"name of the fuzz test ( e.g. FuzzParse )"`
+}
 type BuildCommand struct{}
 type WatchCommand struct{}
 
+type WatchOption struct {
+	StdinFiles bool `long:"stdin-files" description:"watch files listed in stdin ( e.g. find . -name '*.go' | rebirth --stdin-files )"`
+	UI         bool `long:"ui" description:"render the terminal dashboard with panes of the build output, the application's output, messages of rebirth and targets"`
+}
+type DebugCommand struct{}
+
+type DebugOption struct {
+	Listen string `long:"listen" description:"address of the Delve server ( default: :2345 )"`
+	Dlv    string `long:"dlv" description:"path of dlv on the place running the application ( default: dlv )"`
+}
+type ObserveCommand struct{}
+
+type ObserveOption struct {
+	Pid       int    `long:"pid" description:"pid of the running process"`
+	Container string `long:"container" description:"name or id of the running container"`
+}
+type RunTaskCommand struct{}
+type FreezeCommand struct{}
+type SnapshotCommand struct{}
+type StatusCommand struct{}
+
+type StatusOption struct {
+	Short bool `long:"short" description:"print the state in a line from the status file ( for tmux status lines and shell prompts )"`
+}
+type DoctorCommand struct{}
+type FocusCommand struct{}
+type StatsCommand struct{}
+type LogsCommand struct{}
+
+type LogsOption struct {
+	Around string `long:"around" description:"generation of the restart ( e.g. gen17 ). default is the latest restart"`
+}
+type UpCommand struct{}
+type ServiceCommand struct{}
+type ProfileCommand struct{}
+type ConfigCommand struct{}
+
+type ProfileOption struct {
+	Seconds int  `long:"seconds" default:"30" description:"duration for capturing cpu profile"`
+	Heap    bool `long:"heap" description:"capture heap profile instead of cpu profile"`
+	NoOpen  bool `long:"no-open" description:"don't open the profile by 'go tool pprof'"`
+}
+
 type TaskCommand struct {
-	tasks []string
+	cfg  *rebirth.Config
+	name string
 }
 
 func (cmd *InitCommand) Execute(args []string) error {
-	if rebirth.ExistsConfig() {
-		return xerrors.New("already exists rebirth.yml")
+	var opt InitOption
+	if _, err := flags.ParseArgs(&opt, args); err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	if opt.From != "" {
+		if err := rebirth.ImportConfig(opt.From, opt.File); err != nil {
+			return xerrors.Errorf("failed to import config of %s: %w", opt.From, err)
+		}
+		return nil
 	}
-	if err := ioutil.WriteFile("rebirth.yml", []byte{}, 0644); err != nil {
-		return xerrors.Errorf("failed to create rebirth.yml: %w", err)
+	if opt.File != "" {
+		return xerrors.New("--file requires --from ( e.g. rebirth init --from air --file .air.toml )")
+	}
+	if err := rebirth.InitConfig(); err != nil {
+		return xerrors.Errorf("failed to initialize: %w", err)
 	}
 	return nil
 }
@@ -47,7 +142,7 @@ func (cmd *RunCommand) Execute(args []string) error {
 	if !rebirth.ExistsConfig() {
 		return xerrors.New("`rebirth init` must be executed before `rebirth run`")
 	}
-	cfg, err := rebirth.LoadConfig("rebirth.yml")
+	cfg, err := loadConfig()
 	if err != nil {
 		return xerrors.Errorf("failed to load config: %w", err)
 	}
@@ -59,6 +154,13 @@ func (cmd *RunCommand) Execute(args []string) error {
 		}
 		gocmd.AddEnv(env)
 	}
+	if cfg.Build != nil {
+		gocmd.SetCompiler(cfg.Build.Compiler, cfg.Build.TinygoTarget)
+		gocmd.SetBuildFlags(cfg.Build.Tags, cfg.Build.LDFlags, cfg.Build.GCFlags)
+	}
+	if err := rebirth.ResolveComposeService(cfg.Host); err != nil {
+		return xerrors.Errorf("failed to resolve compose service: %w", err)
+	}
 	if cfg.Host != nil && cfg.Host.Docker != "" {
 		gocmd.EnableCrossBuild(cfg.Host.Docker)
 	}
@@ -72,7 +174,7 @@ func (cmd *TestCommand) Execute(args []string) error {
 	if !rebirth.ExistsConfig() {
 		return xerrors.New("`rebirth init` must be executed before `rebirth test`")
 	}
-	cfg, err := rebirth.LoadConfig("rebirth.yml")
+	cfg, err := loadConfig()
 	if err != nil {
 		return xerrors.Errorf("failed to load config: %w", err)
 	}
@@ -83,21 +185,117 @@ func (cmd *TestCommand) Execute(args []string) error {
 			env = append(env, fmt.Sprintf("%s=%s", k, rebirth.ExpandPath(v)))
 		}
 		gocmd.AddEnv(env)
+		gocmd.SetCompiler(cfg.Build.Compiler, cfg.Build.TinygoTarget)
+		gocmd.SetBuildFlags(cfg.Build.Tags, cfg.Build.LDFlags, cfg.Build.GCFlags)
+	}
+	if err := rebirth.ResolveComposeService(cfg.Host); err != nil {
+		return xerrors.Errorf("failed to resolve compose service: %w", err)
 	}
 	if cfg.Host != nil && cfg.Host.Docker != "" {
 		gocmd.EnableCrossBuild(cfg.Host.Docker)
 	}
-	if err := gocmd.Test(args...); err != nil {
+	var opt TestOption
+	// unknown flags are passed to go test
+	testArgs, err := flags.NewParser(&opt, flags.IgnoreUnknown).ParseArgs(args)
+	if err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	var pkgs []string
+	if cfg.Test != nil {
+		env := []string{}
+		for k, v := range cfg.Test.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, rebirth.ExpandPath(v)))
+		}
+		gocmd.SetTestEnv(env)
+		// flags of the command line take precedence over test.flags
+		testArgs = append(append([]string{}, cfg.Test.Flags...), testArgs...)
+		pkgs = cfg.Test.Packages
+	}
+	if opt.Watch {
+		runner := rebirth.NewTestRunner(gocmd, testArgs, opt.Explain)
+		runner.SetPackages(pkgs)
+		return watchTests(cfg, runner)
+	}
+	if _, argPkgs := rebirth.SplitTestArgs(testArgs); len(argPkgs) == 0 {
+		// packages must be before -args
+		testArgs = append(append([]string{}, pkgs...), testArgs...)
+	}
+	if err := gocmd.Test(testArgs...); err != nil {
 		return xerrors.Errorf("failed to test: %w", err)
 	}
 	return nil
 }
 
+// watchTests runs all tests, and runs tests affected by changed files on each save.
+func watchTests(cfg *rebirth.Config, runner *rebirth.TestRunner) error {
+	if err := runner.RunFiles(nil); err != nil {
+		fmt.Println(err)
+	}
+	if err := rebirth.NewWatcher(cfg).Run(func(files []string) {
+		if err := runner.RunFiles(files); err != nil {
+			fmt.Println(err)
+		}
+	}); err != nil {
+		return xerrors.Errorf("failed to watch files: %w", err)
+	}
+	select {}
+}
+
+func (cmd *FuzzCommand) Execute(args []string) error {
+	if !rebirth.ExistsConfig() {
+		return xerrors.New("`rebirth init` must be executed before `rebirth fuzz`")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Host != nil && (cfg.Host.Docker != "" || cfg.Host.ComposeService != "") {
+		return xerrors.New("`rebirth fuzz` doesn't support host.docker and host.compose_service")
+	}
+	var opt FuzzOption
+	// unknown flags are passed to go test ( e.g. -fuzztime 30s )
+	fuzzArgs, err := flags.NewParser(&opt, flags.IgnoreUnknown).ParseArgs(args)
+	if err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	if opt.Fuzz == "" {
+		return xerrors.New("--fuzz must be specified ( e.g. rebirth fuzz ./parser --fuzz FuzzParse )")
+	}
+	pkg := "."
+	if len(fuzzArgs) > 0 && !strings.HasPrefix(fuzzArgs[0], "-") {
+		pkg = fuzzArgs[0]
+		fuzzArgs = fuzzArgs[1:]
+	}
+	gocmd := rebirth.NewGoCommand()
+	if cfg.Build != nil {
+		env := []string{}
+		for k, v := range cfg.Build.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, rebirth.ExpandPath(v)))
+		}
+		gocmd.AddEnv(env)
+		gocmd.SetCompiler(cfg.Build.Compiler, cfg.Build.TinygoTarget)
+		gocmd.SetBuildFlags(cfg.Build.Tags, cfg.Build.LDFlags, cfg.Build.GCFlags)
+	}
+	runner := rebirth.NewFuzzRunner(gocmd, pkg, opt.Fuzz, fuzzArgs)
+	rebirth.OnShutdown(runner.Stop)
+	if err := runner.Start(); err != nil {
+		return xerrors.Errorf("failed to start fuzzer: %w", err)
+	}
+	if err := rebirth.NewWatcher(cfg).Run(func(files []string) {
+		if err := runner.RunFiles(files); err != nil {
+			fmt.Println(err)
+		}
+	}); err != nil {
+		return xerrors.Errorf("failed to watch files: %w", err)
+	}
+	select {}
+}
+
 func (cmd *BuildCommand) Execute(args []string) error {
 	if !rebirth.ExistsConfig() {
 		return xerrors.New("`rebirth init` must be executed before `rebirth build`")
 	}
-	cfg, err := rebirth.LoadConfig("rebirth.yml")
+	cfg, err := loadConfig()
 	if err != nil {
 		return xerrors.Errorf("failed to load config: %w", err)
 	}
@@ -108,9 +306,17 @@ func (cmd *BuildCommand) Execute(args []string) error {
 			env = append(env, fmt.Sprintf("%s=%s", k, rebirth.ExpandPath(v)))
 		}
 		gocmd.AddEnv(env)
+		gocmd.SetCompiler(cfg.Build.Compiler, cfg.Build.TinygoTarget)
+		gocmd.SetBuildFlags(cfg.Build.Tags, cfg.Build.LDFlags, cfg.Build.GCFlags)
+	}
+	if err := rebirth.ResolveComposeService(cfg.Host); err != nil {
+		return xerrors.Errorf("failed to resolve compose service: %w", err)
 	}
 	if cfg.Host != nil && cfg.Host.Docker != "" {
 		gocmd.EnableCrossBuild(cfg.Host.Docker)
+		if cfg.Build != nil {
+			gocmd.SetMicroarch(cfg.Build.Microarch)
+		}
 	}
 	if err := gocmd.Build(args...); err != nil {
 		return xerrors.Errorf("failed to build: %w", err)
@@ -118,48 +324,105 @@ func (cmd *BuildCommand) Execute(args []string) error {
 	return nil
 }
 
-func (cmd *WatchCommand) run() error {
-	cfg, err := rebirth.LoadConfig("rebirth.yml")
+func readStdinFiles() ([]string, error) {
+	files := []string{}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		file := strings.TrimSpace(scanner.Text())
+		if file == "" {
+			continue
+		}
+		files = append(files, file)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("failed to read stdin: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, xerrors.New("no files are listed in stdin")
+	}
+	return files, nil
+}
+
+func (cmd *WatchCommand) run(args []string) error {
+	var opt WatchOption
+	appArgs, err := flags.ParseArgs(&opt, args)
+	if err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	return watch(opt, appArgs, nil)
+}
+
+// watch runs the reloader with the watcher. setup configures the reloader before running ( e.g. debug mode ).
+func watch(opt WatchOption, appArgs []string, setup func(*rebirth.Reloader)) error {
+	cfg, err := loadConfig()
 	if err != nil {
 		return xerrors.Errorf("failed to load config: %w", err)
 	}
+	if err := rebirth.VerifyHooks(cfg); err != nil {
+		return xerrors.Errorf("failed to verify hooks: %w", err)
+	}
+	watcher := rebirth.NewWatcher(cfg)
+	if opt.StdinFiles {
+		files, err := readStdinFiles()
+		if err != nil {
+			return xerrors.Errorf("failed to get files for watching: %w", err)
+		}
+		watcher.SetFiles(files)
+	}
 
 	reloader := rebirth.NewReloader(cfg)
-
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGQUIT)
+	// arguments after -- are passed to the application
+	reloader.SetArgs(appArgs)
+	watcher.WatchSelfConfig()
+	reloader.OnConfigReload(watcher.UpdateConfig)
+	reloader.SetWatchedFilesCounter(watcher.WatchedFiles)
+	if setup != nil {
+		setup(reloader)
+	}
+	if opt.UI {
+		if err := reloader.EnableDashboard(); err != nil {
+			return xerrors.Errorf("failed to enable dashboard: %w", err)
+		}
+	}
 
 	go func() {
-		for {
-			<-sig
-			fmt.Println("close...")
-			if err := reloader.Close(); err != nil {
-				log.Printf("%+v", err)
+		if err := watcher.Run(func(files []string) {
+			if err := reloader.ReloadFiles(files); err != nil {
+				fmt.Println(err)
 			}
-			os.Exit(0)
+		}); err != nil {
+			log.Printf("%+v", err)
+			rebirth.Exit(1)
 		}
 	}()
-
-	if reloader.IsEnabledReload() {
-		go func() {
-			if err := rebirth.NewWatcher(cfg).Run(func() {
-				if err := reloader.Reload(); err != nil {
-					fmt.Println(err)
-				}
-			}); err != nil {
-				log.Printf("%+v", err)
-				os.Exit(1)
-			}
-		}()
-	}
-	if err := reloader.Run(); err != nil {
+	defer watcher.Close()
+	if err := runUntilShutdown(reloader.Run); err != nil {
 		return xerrors.Errorf("failed to run reloader: %w", err)
 	}
 	return nil
 }
 
+// runUntilShutdown calls run with the context canceled by the first signal of rebirth.HandleShutdown ,
+// and waits for its result as graceful shutdown. rebirth.HandleShutdown exits after it.
+func runUntilShutdown(run func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	rebirth.OnShutdown(func() error {
+		cancel()
+		return <-done
+	})
+	err := run(ctx)
+	done <- err
+	if ctx.Err() != nil {
+		// wait for killing process trees and cleanups by rebirth.HandleShutdown
+		select {}
+	}
+	return err
+}
+
 func (cmd *WatchCommand) Execute(args []string) error {
-	if err := cmd.run(); err != nil {
+	if err := cmd.run(args); err != nil {
 		if xerrors.Is(err, errors.ErrCrossCompiler) {
 			return errors.ErrCrossCompiler
 		}
@@ -168,38 +431,477 @@ func (cmd *WatchCommand) Execute(args []string) error {
 	return nil
 }
 
+func (cmd *DebugCommand) Execute(args []string) error {
+	if !rebirth.ExistsConfig() {
+		return xerrors.New("`rebirth init` must be executed before `rebirth debug`")
+	}
+	var opt DebugOption
+	appArgs, err := flags.ParseArgs(&opt, args)
+	if err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	if err := watch(WatchOption{}, appArgs, func(reloader *rebirth.Reloader) {
+		reloader.Debug(opt.Listen, opt.Dlv)
+	}); err != nil {
+		if xerrors.Is(err, errors.ErrCrossCompiler) {
+			return errors.ErrCrossCompiler
+		}
+		log.Printf("%+v", xerrors.Unwrap(err))
+	}
+	return nil
+}
+
+func (cmd *ObserveCommand) Execute(args []string) error {
+	if !rebirth.ExistsConfig() {
+		return xerrors.New("`rebirth init` must be executed before `rebirth observe`")
+	}
+	var opt ObserveOption
+	if _, err := flags.ParseArgs(&opt, args); err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	if (opt.Pid == 0) == (opt.Container == "") {
+		return xerrors.New("either --pid or --container must be specified. e.g. `rebirth observe --pid 1234`")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %w", err)
+	}
+	if err := rebirth.VerifyHooks(cfg); err != nil {
+		return xerrors.Errorf("failed to verify hooks: %w", err)
+	}
+	watcher := rebirth.NewWatcher(cfg)
+	reloader := rebirth.NewReloader(cfg)
+	reloader.Observe(opt.Pid, opt.Container)
+	watcher.WatchSelfConfig()
+	reloader.OnConfigReload(watcher.UpdateConfig)
+	reloader.SetWatchedFilesCounter(watcher.WatchedFiles)
+
+	go func() {
+		if err := watcher.Run(func(files []string) {
+			if err := reloader.ReloadFiles(files); err != nil {
+				fmt.Println(err)
+			}
+		}); err != nil {
+			log.Printf("%+v", err)
+			rebirth.Exit(1)
+		}
+	}()
+	defer watcher.Close()
+	if err := runUntilShutdown(reloader.Run); err != nil {
+		return xerrors.Errorf("failed to observe: %w", err)
+	}
+	return nil
+}
+
+func (cmd *RunTaskCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return xerrors.New("companion name must be specified. e.g. `rebirth run-task seed`")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %w", err)
+	}
+	if err := rebirth.NewReloader(cfg).RunCompanion(args[0], args[1:]); err != nil {
+		return xerrors.Errorf("failed to run companion: %w", err)
+	}
+	return nil
+}
+
+func (cmd *FreezeCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return xerrors.New("duration must be specified. e.g. `rebirth freeze 10m`")
+	}
+	var res struct {
+		Until time.Time `json:"until"`
+	}
+	query := url.Values{"duration": []string{args[0]}}
+	if err := rebirth.NewControlClient().Do(http.MethodPost, "/freeze", query, &res); err != nil {
+		return xerrors.Errorf("failed to freeze: %w", err)
+	}
+	if res.Until.IsZero() {
+		fmt.Println("unfrozen reloading")
+		return nil
+	}
+	fmt.Printf("reloading is frozen until %s\n", res.Until.Format(time.RFC3339))
+	return nil
+}
+
+func (cmd *SnapshotCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		snapshots, err := rebirth.Snapshots()
+		if err != nil {
+			return xerrors.Errorf("failed to get snapshots: %w", err)
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("no snapshots. save the running state by `rebirth snapshot save <name>`")
+			return nil
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s\tgen%d\t%s\n", snapshot.Name, snapshot.Generation, snapshot.CreatedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+	if len(args) != 2 || (args[0] != "save" && args[0] != "restore") {
+		return xerrors.New("usage: `rebirth snapshot save <name>` or `rebirth snapshot restore <name>`")
+	}
+	action, name := args[0], args[1]
+	var snapshot rebirth.Snapshot
+	client := rebirth.NewControlClient()
+	// restoring waits for restarting the application and run.healthcheck
+	client.SetTimeout(time.Minute)
+	query := url.Values{"action": []string{action}, "name": []string{name}}
+	if err := client.Do(http.MethodPost, "/snapshot", query, &snapshot); err != nil {
+		return xerrors.Errorf("failed to %s snapshot: %w", action, err)
+	}
+	if action == "save" {
+		fmt.Printf("saved generation %d as snapshot %s\n", snapshot.Generation, snapshot.Name)
+		return nil
+	}
+	fmt.Printf("restored snapshot %s ( generation %d )\n", snapshot.Name, snapshot.Generation)
+	return nil
+}
+
+func (cmd *DoctorCommand) Execute(args []string) error {
+	failed := 0
+	for _, diagnosis := range rebirth.Diagnose() {
+		fmt.Printf("[%s] %s: %s\n", diagnosis.Level, diagnosis.Name, diagnosis.Message)
+		if diagnosis.Fix != "" {
+			fmt.Printf("  fix: %s\n", diagnosis.Fix)
+		}
+		if diagnosis.Level == rebirth.DiagnosisFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return xerrors.Errorf("%d problems are found", failed)
+	}
+	return nil
+}
+
+func (cmd *StatusCommand) Execute(args []string) error {
+	var opt StatusOption
+	if _, err := flags.ParseArgs(&opt, args); err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	if opt.Short {
+		status, err := rebirth.ReadStatusFile()
+		if err != nil {
+			return xerrors.Errorf("failed to get status: %w", err)
+		}
+		fmt.Println(status.Short())
+		return nil
+	}
+	var status rebirth.Status
+	if err := rebirth.NewControlClient().Do(http.MethodGet, "/status", nil, &status); err != nil {
+		return xerrors.Errorf("failed to get status: %w", err)
+	}
+	fmt.Printf("pid: %d\n", status.Pid)
+	fmt.Printf("state: %s\n", status.State)
+	fmt.Printf("generation: %d\n", status.Generation)
+	if status.Error != "" {
+		fmt.Printf("error: %s\n", status.Error)
+	}
+	if len(status.Ports) == 0 {
+		return nil
+	}
+	names := []string{}
+	for name := range status.Ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("ports:")
+	for _, name := range names {
+		fmt.Printf("  %s: %d\n", name, status.Ports[name])
+	}
+	return nil
+}
+
+func (cmd *StatsCommand) Execute(args []string) error {
+	if err := rebirth.PrintStats(os.Stdout); err != nil {
+		return xerrors.Errorf("failed to show stats: %w", err)
+	}
+	return nil
+}
+
+func (cmd *LogsCommand) Execute(args []string) error {
+	var opt LogsOption
+	if _, err := flags.ParseArgs(&opt, args); err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	generation := 0
+	if opt.Around != "" {
+		gen, err := rebirth.ParseGeneration(opt.Around)
+		if err != nil {
+			return xerrors.Errorf("failed to parse --around: %w", err)
+		}
+		generation = gen
+	}
+	log, err := rebirth.FindReloadLog(generation)
+	if err != nil {
+		return xerrors.Errorf("failed to find reload log: %w", err)
+	}
+	rebirth.PrintReloadLog(os.Stdout, log)
+	return nil
+}
+
+func (cmd *FocusCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return xerrors.New("file path must be specified. e.g. `rebirth focus main.go`")
+	}
+	query := url.Values{"path": []string{args[0]}}
+	if err := rebirth.NewControlClient().Do(http.MethodPost, "/focus", query, nil); err != nil {
+		return xerrors.Errorf("failed to focus: %w", err)
+	}
+	return nil
+}
+
+func (cmd *UpCommand) Execute(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %w", err)
+	}
+	if err := rebirth.VerifyHooks(cfg); err != nil {
+		return xerrors.Errorf("failed to verify hooks: %w", err)
+	}
+	reloader := rebirth.NewReloader(cfg)
+	if err := runUntilShutdown(func(ctx context.Context) error {
+		return reloader.Up(ctx, args)
+	}); err != nil {
+		reloader.Close()
+		return xerrors.Errorf("failed to start services: %w", err)
+	}
+	return nil
+}
+
+func (cmd *ServiceCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return xerrors.New("subcommand must be specified. e.g. `rebirth service install`")
+	}
+	switch args[0] {
+	case "install":
+		if !rebirth.ExistsConfig() {
+			return xerrors.New("`rebirth init` must be executed before `rebirth service install`")
+		}
+		if err := rebirth.InstallLaunchAgent(); err != nil {
+			return xerrors.Errorf("failed to install launchd agent: %w", err)
+		}
+	case "uninstall":
+		if err := rebirth.UninstallLaunchAgent(); err != nil {
+			return xerrors.Errorf("failed to uninstall launchd agent: %w", err)
+		}
+	default:
+		return xerrors.Errorf("unknown subcommand %s. install or uninstall is available", args[0])
+	}
+	return nil
+}
+
+func (cmd *ConfigCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return xerrors.New("subcommand must be specified. e.g. `rebirth config lint`")
+	}
+	switch args[0] {
+	case "lint":
+		paths := args[1:]
+		if len(paths) == 0 {
+			paths = []string{rebirth.ConfigPath()}
+		}
+		failed := false
+		for _, path := range paths {
+			if err := rebirth.LintConfig(path); err != nil {
+				fmt.Println(err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s is valid\n", path)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	case "schema":
+		schema, err := rebirth.ConfigSchema()
+		if err != nil {
+			return xerrors.Errorf("failed to generate schema: %w", err)
+		}
+		fmt.Println(string(schema))
+	default:
+		return xerrors.Errorf("unknown subcommand %s. lint or schema is available", args[0])
+	}
+	return nil
+}
+
+func (cmd *ProfileCommand) Execute(args []string) error {
+	var opt ProfileOption
+	names, err := flags.ParseArgs(&opt, args)
+	if err != nil {
+		return xerrors.Errorf("failed to parse options: %w", err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %w", err)
+	}
+	name := ""
+	if len(names) > 0 {
+		name = names[0]
+	}
+	kind := rebirth.ProfileCPU
+	if opt.Heap {
+		kind = rebirth.ProfileHeap
+	}
+	path, err := rebirth.CaptureProfile(cfg, name, kind, opt.Seconds)
+	if err != nil {
+		return xerrors.Errorf("failed to capture profile: %w", err)
+	}
+	fmt.Printf("Saved %s\n", path)
+	if opt.NoOpen {
+		return nil
+	}
+	if err := rebirth.OpenProfile(path); err != nil {
+		return xerrors.Errorf("failed to open profile: %w", err)
+	}
+	return nil
+}
+
 func (cmd *TaskCommand) Execute(args []string) error {
-	for _, task := range cmd.tasks {
-		gocmd := rebirth.NewGoCommand()
-		if err := gocmd.RunInGoContext(strings.Split(task, " ")...); err != nil {
-			return xerrors.Errorf("failed to run command %s: %w", cmd, err)
+	cfg := cmd.cfg
+	if cfg == nil {
+		if !rebirth.ExistsConfig() {
+			return xerrors.New("`rebirth init` must be executed before `rebirth task`")
 		}
+		loaded, err := loadConfig()
+		if err != nil {
+			return xerrors.Errorf("failed to load config: %w", err)
+		}
+		cfg = loaded
+	}
+	name := cmd.name
+	if name == "" {
+		if len(args) == 0 {
+			tasks := cfg.AllTasks()
+			for _, name := range cfg.TaskNames() {
+				fmt.Printf("%s\t%s\n", name, tasks[name].Desc)
+			}
+			return nil
+		}
+		name = args[0]
+	}
+	if err := rebirth.VerifyHooks(cfg); err != nil {
+		return xerrors.Errorf("failed to verify hooks: %w", err)
+	}
+	if err := rebirth.ResolveComposeService(cfg.Host); err != nil {
+		return xerrors.Errorf("failed to resolve compose service: %w", err)
+	}
+	if err := rebirth.NewTaskRunner(cfg).Run(name); err != nil {
+		return xerrors.Errorf("failed to run task: %w", err)
 	}
 	return nil
 }
 
 var opts Option
 
+// loadConfig loads rebirth.yml and sets up the logger by log config.
+func loadConfig() (*rebirth.Config, error) {
+	cfg, err := rebirth.LoadConfig(rebirth.ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Host != nil {
+		if err := rebirth.SetContainerRuntime(cfg.Host.Runtime); err != nil {
+			return nil, err
+		}
+		if err := rebirth.SetDockerEndpoint(cfg.Host); err != nil {
+			return nil, err
+		}
+	}
+	logger, err := rebirth.NewLogger(os.Stdout, cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+	rebirth.SetLogger(logger)
+	return cfg, nil
+}
+
+// isWatchOption returns true if arg is an option for rebirth without command ( e.g. rebirth --stdin-files ).
+func isWatchOption(arg string) bool {
+	if arg == "-h" || arg == "--help" {
+		return false
+	}
+	return strings.HasPrefix(arg, "-")
+}
+
+// extractGlobalOptions removes --config ( -c ) and --profile from options before the command and returns their values.
+// Options after the command aren't touched because some commands pass them to go ( e.g. rebirth test -c ).
+func extractGlobalOptions(args []string) ([]string, string, string, error) {
+	rest := []string{}
+	path := ""
+	profile := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--" || !strings.HasPrefix(arg, "-"):
+			return append(rest, args[i:]...), path, profile, nil
+		case arg == "-c" || arg == "--config":
+			if i+1 >= len(args) {
+				return nil, "", "", xerrors.Errorf("%s requires the path of config file", arg)
+			}
+			i++
+			path = args[i]
+		case strings.HasPrefix(arg, "--config="):
+			path = strings.TrimPrefix(arg, "--config=")
+		case arg == "--profile":
+			if i+1 >= len(args) {
+				return nil, "", "", xerrors.Errorf("%s requires the name of profile", arg)
+			}
+			i++
+			profile = args[i]
+		case strings.HasPrefix(arg, "--profile="):
+			profile = strings.TrimPrefix(arg, "--profile=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, path, profile, nil
+}
+
 func main() {
+	cmdArgs, configPath, profile, err := extractGlobalOptions(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	rebirth.SetConfigPath(rebirth.FindConfig(configPath))
+	rebirth.SetConfigProfile(profile)
+	os.Args = append([]string{os.Args[0]}, cmdArgs...)
+
 	args := []string{os.Args[0]}
 	if len(os.Args) == 1 {
-		args = append(args, "watch")
+		args = append(args, "watch", "--")
+	} else if isWatchOption(os.Args[1]) {
+		args = append(args, "watch", "--")
+		args = append(args, os.Args[1:]...)
 	} else {
-		args = append(args, os.Args[1])
-	}
-	args = append(args, "--")
-	if len(os.Args) > 2 {
+		args = append(args, os.Args[1], "--")
 		args = append(args, os.Args[2:]...)
 	}
 	os.Args = args
+	rebirth.HandleShutdown()
+	// the terminal and the files of rebirth are cleaned up even if the command fails or panics
+	defer func() {
+		code := 0
+		if err := recover(); err != nil {
+			log.Printf("panic: %v\n%s", err, debug.Stack())
+			code = 2
+		}
+		rebirth.Exit(code)
+	}()
 	parser := flags.NewParser(&opts, flags.Default)
 	if rebirth.ExistsConfig() {
-		cfg, err := rebirth.LoadConfig("rebirth.yml")
+		cfg, err := rebirth.LoadConfig(rebirth.ConfigPath())
 		if err == nil {
-			for name, task := range cfg.Task {
+			tasks := cfg.AllTasks()
+			for _, name := range cfg.TaskNames() {
 				var cmd TaskCommand
-				cmd.tasks = task.Commands
-				if _, err := parser.AddCommand(name, task.Desc, task.Desc, &cmd); err != nil {
+				cmd.cfg = cfg
+				cmd.name = name
+				if _, err := parser.AddCommand(name, tasks[name].Desc, tasks[name].Desc, &cmd); err != nil {
 					log.Fatal(err)
 				}
 			}
@@ -1,7 +1,6 @@
 package rebirth
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -9,32 +8,52 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
-	"github.com/goccy/rebirth/internal/errors"
 	"github.com/mitchellh/go-ps"
 	"golang.org/x/xerrors"
 )
 
 type Command struct {
-	cmd  *exec.Cmd
-	args []string
+	cmd     *exec.Cmd
+	args    []string
+	stdout  io.Writer
+	stderr  io.Writer
+	tail    io.Writer
+	onExit  func(error)
+	stopped int32
+	done    chan struct{}
+
+	// foreground is true if the command runs in the process group of rebirth by SetForeground .
+	foreground bool
 }
 
 func NewCommand(args ...string) *Command {
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Env = os.Environ()
+	setProcessGroup(cmd)
 	return &Command{
-		cmd:  cmd,
-		args: args,
+		cmd:    cmd,
+		args:   args,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		done:   make(chan struct{}),
 	}
 }
 
+// SetOutput changes the destination of stdout and stderr ( default: os.Stdout and os.Stderr ).
+func (c *Command) SetOutput(stdout, stderr io.Writer) {
+	c.stdout = stdout
+	c.stderr = stderr
+}
+
 func (c *Command) SetDir(dir string) {
 	c.cmd.Dir = dir
 }
@@ -43,6 +62,70 @@ func (c *Command) AddEnv(env []string) {
 	c.cmd.Env = append(c.cmd.Env, env...)
 }
 
+// ClearEnv drops env inherited from the current process.
+func (c *Command) ClearEnv() {
+	c.cmd.Env = []string{}
+}
+
+// SetForeground runs the command with stdin of rebirth in the process group of rebirth instead of its own process group,
+// so it can read the terminal in the foreground without being stopped by SIGTTIN . Only the process is killed on stopping.
+func (c *Command) SetForeground() {
+	c.cmd.Stdin = os.Stdin
+	c.cmd.SysProcAttr = nil
+	c.foreground = true
+}
+
+// SetExtraFiles makes the command inherit files as fd 3 and later.
+func (c *Command) SetExtraFiles(files []*os.File) {
+	c.cmd.ExtraFiles = files
+}
+
+// SetOutputTail writes stdout and stderr of the command to tail too.
+func (c *Command) SetOutputTail(tail io.Writer) {
+	c.tail = tail
+}
+
+// OnExit sets callback called when the command started by RunAsync exits.
+func (c *Command) OnExit(callback func(error)) {
+	c.onExit = callback
+}
+
+// IsStopped returns true if the command is stopped by Stop.
+func (c *Command) IsStopped() bool {
+	return atomic.LoadInt32(&c.stopped) == 1
+}
+
+// Signal sends sig to the running command.
+func (c *Command) Signal(sig os.Signal) error {
+	if c.cmd.Process == nil {
+		return xerrors.New("process isn't started")
+	}
+	if err := c.cmd.Process.Signal(sig); err != nil {
+		return xerrors.Errorf("failed to send signal %s: %w", sig, err)
+	}
+	return nil
+}
+
+func (c *Command) Pid() int {
+	if c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+// IsRunning returns true if the command is started and doesn't exit yet.
+func (c *Command) IsRunning() bool {
+	if c.cmd.Process == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
 func (c *Command) String() string {
 	return fmt.Sprintf("%s; %s",
 		strings.Join(c.cmd.Env, " "),
@@ -66,13 +149,74 @@ func (c *Command) Stop() error {
 		return xerrors.Errorf("failed to find process by pid(%d): %w", pid, err)
 	}
 	if process != nil {
-		if err := c.cmd.Process.Kill(); err != nil {
+		atomic.StoreInt32(&c.stopped, 1)
+		if err := c.kill(pid); err != nil {
 			return xerrors.Errorf("failed to kill process: %w", err)
 		}
 	}
 	return nil
 }
 
+// StopGracefully sends sig to the command and waits for exiting up to timeout.
+// If the command doesn't exit within timeout, it is killed.
+func (c *Command) StopGracefully(sig os.Signal, timeout time.Duration) error {
+	return c.stopGracefully(sig, timeout, func(pid int) error {
+		return c.cmd.Process.Signal(sig)
+	})
+}
+
+// StopGroupGracefully is the same as StopGracefully except that sig is sent to all processes in the process group
+// ( e.g. the test binary run by go test ).
+func (c *Command) StopGroupGracefully(sig syscall.Signal, timeout time.Duration) error {
+	return c.stopGracefully(sig, timeout, func(pid int) error {
+		if c.foreground {
+			return c.cmd.Process.Signal(sig)
+		}
+		return signalProcessGroup(pid, sig)
+	})
+}
+
+// kill kills the process group of the command, or the process in the foreground.
+func (c *Command) kill(pid int) error {
+	if c.foreground {
+		return killProcess(pid)
+	}
+	return killProcessGroup(pid)
+}
+
+func (c *Command) stopGracefully(sig os.Signal, timeout time.Duration, signal func(pid int) error) error {
+	if c == nil || c.cmd == nil || c.cmd.Process == nil {
+		return nil
+	}
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	atomic.StoreInt32(&c.stopped, 1)
+	pid := c.cmd.Process.Pid
+	if err := signal(pid); err != nil {
+		return xerrors.Errorf("failed to send %s to process(%d): %w", sig, pid, err)
+	}
+	select {
+	case <-c.done:
+		// children left by the process must not remain as orphans
+		c.kill(pid)
+		return nil
+	case <-time.After(timeout):
+	}
+	logger().Warnf("process(%d) didn't exit within %s. killing it", pid, timeout)
+	if err := c.kill(pid); err != nil {
+		return xerrors.Errorf("failed to kill process: %w", err)
+	}
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		// child processes may keep the output pipes open
+	}
+	return nil
+}
+
 func (c *Command) Run() error {
 	if err := c.run(); err != nil {
 		return xerrors.Errorf("failed to run: %w", err)
@@ -82,13 +226,19 @@ func (c *Command) Run() error {
 
 func (c *Command) RunAsync() {
 	go func() {
-		if err := c.run(); err != nil {
-			fmt.Println(err)
+		err := c.run()
+		if c.onExit != nil {
+			c.onExit(err)
+			return
+		}
+		if err != nil {
+			logger().Errorf("%v", err)
 		}
 	}()
 }
 
 func (c *Command) run() error {
+	defer close(c.done)
 	stdout, err := c.cmd.StdoutPipe()
 	if err != nil {
 		return xerrors.Errorf("failed to pipe stdout: %w", err)
@@ -100,18 +250,45 @@ func (c *Command) run() error {
 	if err := c.cmd.Start(); err != nil {
 		return xerrors.Errorf("failed to run build command: %w", err)
 	}
-	go io.Copy(os.Stdout, stdout)
-	go io.Copy(os.Stderr, stderr)
+	pid := c.cmd.Process.Pid
+	if c.foreground {
+		trackProcess(pid)
+	} else {
+		trackProcessGroup(pid)
+	}
+	defer untrackProcessGroup(pid)
+	stdoutWriter, stderrWriter := c.stdout, c.stderr
+	if c.tail != nil {
+		stdoutWriter = io.MultiWriter(c.stdout, c.tail)
+		stderrWriter = io.MultiWriter(c.stderr, c.tail)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(stdoutWriter, stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(stderrWriter, stderr)
+	}()
+	// all reads from the pipes must be completed before Wait
+	wg.Wait()
 	if err := c.cmd.Wait(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// DockerCommand runs command on the container by the runtime of host.runtime .
 type DockerCommand struct {
 	container string
 	cmd       []string
-	execID    string
+	env       []string
+	executed  bool
+	exitCode  int
+	stdout    io.Writer
+	stderr    io.Writer
 }
 
 func NewDockerCommand(container string, cmd ...string) *DockerCommand {
@@ -121,81 +298,65 @@ func NewDockerCommand(container string, cmd ...string) *DockerCommand {
 	}
 }
 
-/*
-type DockerProcess struct {
-	Pid int
+func (c *DockerCommand) AddEnv(env []string) {
+	c.env = append(c.env, env...)
 }
 
-func (c *DockerCommand) Process() *DockerProcess {
-	if c.execID == "" {
-		return nil
-	}
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		return nil
-	}
+// SetOutput changes the destination of stdout and stderr of Run ( default: os.Stdout and os.Stderr ).
+func (c *DockerCommand) SetOutput(stdout, stderr io.Writer) {
+	c.stdout = stdout
+	c.stderr = stderr
+}
 
-	resp, err := cli.ContainerExecInspect(context.Background(), c.execID)
-	if err != nil {
-		return nil
-	}
-	// resp.Pid is number on host
-	return &DockerProcess{
-		Pid: resp.Pid,
+// ExitCode returns exit code of the command executed by Run or Output.
+func (c *DockerCommand) ExitCode() (int, error) {
+	if !c.executed {
+		return 0, xerrors.New("command isn't executed")
 	}
+	return c.exitCode, nil
 }
-*/
 
 func (c *DockerCommand) Output() ([]byte, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
-	if err := c.run(context.Background(), func(reader *bufio.Reader) error {
-		if _, err := stdcopy.StdCopy(stdout, stderr, reader); err != nil {
-			return xerrors.Errorf("failed to copy stdout/stderr: %w", err)
-		}
-		return nil
-	}); err != nil {
+	if err := c.run(context.Background(), stdout, stderr); err != nil {
 		return nil, xerrors.Errorf("failed to run: %w", err)
 	}
 	return []byte(c.chomp(stdout.String())), nil
 }
 
 func (c *DockerCommand) Run() error {
-	if err := c.run(context.Background(), func(reader *bufio.Reader) error {
-		if _, err := stdcopy.StdCopy(os.Stdout, os.Stderr, reader); err != nil {
-			return xerrors.Errorf("failed to copy stdout/stderr: %w", err)
-		}
-		return nil
-	}); err != nil {
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if c.stdout != nil {
+		stdout = c.stdout
+	}
+	if c.stderr != nil {
+		stderr = c.stderr
+	}
+	if err := c.run(context.Background(), stdout, stderr); err != nil {
 		return xerrors.Errorf("failed to run: %w", err)
 	}
 	return nil
 }
 
-func (c *DockerCommand) run(ctx context.Context, ioCallback func(reader *bufio.Reader) error) error {
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		return xerrors.Errorf("failed to create docker client: %w", err)
-	}
-	cfg := types.ExecConfig{
-		AttachStdout: true,
-		AttachStderr: true,
-		Cmd:          c.cmd,
-	}
-	execResp, err := cli.ContainerExecCreate(ctx, c.container, cfg)
+// Attach executes command on the container with stdin.
+// It returns writer for stdin and reader for stdout. stderr is written to os.Stderr.
+func (c *DockerCommand) Attach(ctx context.Context) (io.WriteCloser, io.Reader, error) {
+	w, r, err := containerRuntime().Attach(ctx, c.container, c.cmd, c.env)
 	if err != nil {
-		return xerrors.Errorf("failed to ContainerExecCreate: %w", err)
+		return nil, nil, xerrors.Errorf("failed to attach: %w", err)
 	}
-	execID := execResp.ID
-	c.execID = execID
-	attachResp, err := cli.ContainerExecAttach(ctx, execID, cfg)
+	c.executed = true
+	return w, r, nil
+}
+
+func (c *DockerCommand) run(ctx context.Context, stdout, stderr io.Writer) error {
+	code, err := containerRuntime().Exec(ctx, c.container, c.cmd, c.env, stdout, stderr)
 	if err != nil {
-		return xerrors.Errorf("failed to ContainerExecAttach: %w", err)
-	}
-	defer attachResp.Close()
-	if err := ioCallback(attachResp.Reader); err != nil {
-		return xerrors.Errorf("failed to i/o callback: %w", err)
+		return xerrors.Errorf("failed to exec: %w", err)
 	}
+	c.executed = true
+	c.exitCode = code
 	return nil
 }
 
@@ -207,9 +368,31 @@ type GoCommand struct {
 	cmd          []string
 	container    string
 	isCrossBuild bool
+	goos         string
+	goarch       string
+	disableCgo   bool
+	cgo          *Cgo
+	onTarget     bool
+	cacheDir     string
+	microarch    *Microarch
+	compiler     string
+	tinygoTarget string
 	extEnv       []string
 	dir          string
-}
+	tail         io.Writer
+	stdout       io.Writer
+	stderr       io.Writer
+	tags         []string
+	ldflags      string
+	gcflags      string
+	testEnv      []string
+}
+
+const (
+	compilerGC     = "gc"
+	compilerGccgo  = "gccgo"
+	compilerTinyGo = "tinygo"
+)
 
 func NewGoCommand() *GoCommand {
 	return &GoCommand{
@@ -222,6 +405,112 @@ func (c *GoCommand) EnableCrossBuild(container string) {
 	c.isCrossBuild = true
 }
 
+// SetTarget specifies GOOS and GOARCH for cross build instead of detecting them from the container ( e.g. for host.ssh and wasm ).
+func (c *GoCommand) SetTarget(goos, goarch string) {
+	c.goos = goos
+	c.goarch = goarch
+}
+
+// DisableCgo builds without cgo. In this case, cross compiler for C isn't required.
+func (c *GoCommand) DisableCgo() {
+	c.disableCgo = true
+}
+
+// SetCgo specifies the toolchain of cgo by build.cgo .
+func (c *GoCommand) SetCgo(cgo *Cgo) {
+	if cgo == nil {
+		return
+	}
+	c.cgo = cgo
+	if !cgo.enabled() {
+		c.disableCgo = true
+	}
+}
+
+// BuildOnTarget tells the command runs on the target ( e.g. inside the container ),
+// so C compiler of the target is used instead of the cross compiler.
+func (c *GoCommand) BuildOnTarget() {
+	c.onTarget = true
+}
+
+// SetCacheDir sets GOCACHE and GOMODCACHE under dir. dir is the path where the command runs.
+func (c *GoCommand) SetCacheDir(dir string) {
+	c.cacheDir = dir
+}
+
+// cachePath returns the path of name under the cache directory.
+// Slash separated paths are kept for the remote machine even if rebirth runs on Windows.
+func (c *GoCommand) cachePath(name string) string {
+	if path.IsAbs(c.cacheDir) {
+		return path.Join(c.cacheDir, name)
+	}
+	return filepath.Join(c.cacheDir, name)
+}
+
+// SetMicroarch specifies microarchitecture level for cross build.
+func (c *GoCommand) SetMicroarch(microarch *Microarch) {
+	c.microarch = microarch
+}
+
+// SetBuildFlags specifies -tags , -ldflags and -gcflags .
+func (c *GoCommand) SetBuildFlags(tags []string, ldflags, gcflags string) {
+	c.tags = tags
+	c.ldflags = ldflags
+	c.gcflags = gcflags
+}
+
+// SetCompiler specifies the compiler ( gc, gccgo or tinygo ) and -target for tinygo.
+func (c *GoCommand) SetCompiler(compiler, tinygoTarget string) {
+	c.compiler = compiler
+	c.tinygoTarget = tinygoTarget
+}
+
+// toolCommand returns command for the subcommand ( build, run or test ) of the compiler.
+func (c *GoCommand) toolCommand(sub string) ([]string, error) {
+	switch c.compiler {
+	case "", compilerGC:
+		return []string{"go", sub}, nil
+	case compilerGccgo:
+		return []string{"go", sub, "-compiler", compilerGccgo}, nil
+	case compilerTinyGo:
+		cmd := []string{"tinygo", sub}
+		target := c.tinygoTarget
+		if target == "" {
+			goos, err := c.buildGOOS()
+			if err != nil {
+				return nil, xerrors.Errorf("failed to get GOOS: %w", err)
+			}
+			goarch, err := c.buildGOARCH()
+			if err != nil {
+				return nil, xerrors.Errorf("failed to get GOARCH: %w", err)
+			}
+			if goos == "js" && goarch == "wasm" {
+				target = "wasm"
+			}
+		}
+		if target != "" {
+			cmd = append(cmd, "-target", target)
+		}
+		return cmd, nil
+	}
+	return nil, xerrors.Errorf("unsupported compiler %s. gc, gccgo or tinygo is available", c.compiler)
+}
+
+// SetOutputTail writes the output of the go command to tail too ( e.g. for finding the location of build errors ).
+func (c *GoCommand) SetOutputTail(tail io.Writer) {
+	c.tail = tail
+}
+
+// SetStdout changes the destination of stdout of the go command ( e.g. for parsing go test -json ).
+func (c *GoCommand) SetStdout(stdout io.Writer) {
+	c.stdout = stdout
+}
+
+// SetStderr changes the destination of stderr of the go command ( e.g. for prefixing build errors ).
+func (c *GoCommand) SetStderr(stderr io.Writer) {
+	c.stderr = stderr
+}
+
 func (c *GoCommand) AddEnv(env []string) {
 	c.extEnv = append(c.extEnv, env...)
 }
@@ -230,8 +519,9 @@ func (c *GoCommand) SetDir(dir string) {
 	c.dir = dir
 }
 
+// RunInGoContext runs the hook command in the context of GOPATH. Env of the command is restricted by REBIRTH_HOOKS_ENV .
 func (c *GoCommand) RunInGoContext(args ...string) error {
-	cmd := NewCommand(args...)
+	cmd := newHookCommand(args...)
 	env := []string{}
 	if c.dir == "" {
 		symlinkPath, err := c.getOrCreateSymlink()
@@ -256,8 +546,11 @@ func (c *GoCommand) RunInGoContext(args ...string) error {
 }
 
 func (c *GoCommand) Build(args ...string) error {
-	cmd := []string{"go", "build"}
-	cmd = append(cmd, c.linkerFlags()...)
+	cmd, err := c.toolCommand("build")
+	if err != nil {
+		return xerrors.Errorf("failed to get command: %w", err)
+	}
+	cmd = append(cmd, c.buildFlags()...)
 	cmd = append(cmd, args...)
 	if err := c.run(cmd...); err != nil {
 		return xerrors.Errorf("failed to run: %w", err)
@@ -267,8 +560,11 @@ func (c *GoCommand) Build(args ...string) error {
 
 func (c *GoCommand) Run(args ...string) error {
 	if !c.isCrossBuild {
-		cmd := []string{"go", "run"}
-		cmd = append(cmd, c.linkerFlags()...)
+		cmd, err := c.toolCommand("run")
+		if err != nil {
+			return xerrors.Errorf("failed to get command: %w", err)
+		}
+		cmd = append(cmd, c.buildFlags()...)
 		cmd = append(cmd, args...)
 		if err := c.run(cmd...); err != nil {
 			return xerrors.Errorf("failed to run: %w", err)
@@ -293,7 +589,7 @@ func (c *GoCommand) Run(args ...string) error {
 		goargs = args[1:]
 	}
 	cmd := []string{"go", "build", "-o", tmpfile.Name()}
-	cmd = append(cmd, c.linkerFlags()...)
+	cmd = append(cmd, c.buildFlags()...)
 	cmd = append(cmd, gofile)
 	if err := c.run(cmd...); err != nil {
 		return xerrors.Errorf("failed to run: %w", err)
@@ -306,64 +602,103 @@ func (c *GoCommand) Run(args ...string) error {
 	return nil
 }
 
+// Generate runs go generate for pkgs with build tags.
+func (c *GoCommand) Generate(pkgs ...string) error {
+	cmd := []string{"go", "generate"}
+	if len(c.tags) > 0 {
+		cmd = append(cmd, "-tags", strings.Join(c.tags, ","))
+	}
+	cmd = append(cmd, pkgs...)
+	if err := c.run(cmd...); err != nil {
+		return xerrors.Errorf("failed to run: %w", err)
+	}
+	return nil
+}
+
 func (c *GoCommand) Test(args ...string) error {
 	if !c.isCrossBuild {
-		cmd := []string{"go", "test"}
-		cmd = append(cmd, c.linkerFlags()...)
+		cmd, err := c.toolCommand("test")
+		if err != nil {
+			return xerrors.Errorf("failed to get command: %w", err)
+		}
+		cmd = append(cmd, c.buildFlags()...)
 		cmd = append(cmd, args...)
-		if err := c.run(cmd...); err != nil {
+		command, err := c.command(cmd...)
+		if err != nil {
+			return xerrors.Errorf("failed to create command: %w", err)
+		}
+		command.AddEnv(c.testEnv)
+		if err := command.Run(); err != nil {
 			return xerrors.Errorf("failed to run: %w", err)
 		}
 		return nil
 	}
+	flags, pkgs := SplitTestArgs(args)
+	if err := c.testOnContainer(flags, pkgs); err != nil {
+		return xerrors.Errorf("failed to test on docker container: %w", err)
+	}
+	return nil
+}
 
-	cmd := []string{"go", "test", "-c", "-o", filepath.Join(configDir, "app.test")}
-	cmd = append(cmd, c.linkerFlags()...)
-	cmd = append(cmd, args...)
-	if err := c.run(cmd...); err != nil {
-		return xerrors.Errorf("failed to run: %w", err)
+// SetTestEnv specifies env of tests. Tests on the container ( host.docker ) get it too unlike the build env.
+func (c *GoCommand) SetTestEnv(env []string) {
+	c.testEnv = env
+}
+
+// buildFlags returns flags for building by build.tags , build.ldflags , build.gcflags
+// and static linking for cross compile with cgo.
+func (c *GoCommand) buildFlags() []string {
+	flags := []string{}
+	if len(c.tags) > 0 {
+		flags = append(flags, "-tags", strings.Join(c.tags, ","))
 	}
-	dockerCmd := []string{filepath.Join(configDir, "app.test")}
-	testArgs := []string{}
-	for idx, arg := range args {
-		switch arg {
-		case "-v":
-			testArgs = append(testArgs, "-test.v")
-		case "-run":
-			testArgs = append(testArgs, "-test.run", args[idx+1])
+	if c.gcflags != "" {
+		flags = append(flags, "-gcflags", c.gcflags)
+	}
+	ldflags := c.ldflags
+	if c.isCrossBuild && !c.disableCgo {
+		switch c.compiler {
+		case compilerGccgo:
+			flags = append(flags, "-gccgoflags", "-static")
+		case compilerTinyGo:
+			// tinygo links statically by default
+		default:
+			// go build uses only the last -ldflags
+			ldflags = strings.TrimSpace(ldflags + ` -linkmode external -extldflags "-static"`)
 		}
 	}
-	dockerCmd = append(dockerCmd, testArgs...)
-	if err := NewDockerCommand(c.container, dockerCmd...).Run(); err != nil {
-		return xerrors.Errorf("failed to run on docker container: %w", err)
+	if ldflags != "" {
+		flags = append(flags, "--ldflags", ldflags)
 	}
-	return nil
+	return flags
 }
 
-func (c *GoCommand) linkerFlags() []string {
-	if c.isCrossBuild {
-		return []string{
-			"--ldflags",
-			`-linkmode external -extldflags "-static"`,
-		}
+func (c *GoCommand) run(args ...string) error {
+	cmd, err := c.command(args...)
+	if err != nil {
+		return xerrors.Errorf("failed to create command: %w", err)
+	}
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("failed to command: %w", err)
 	}
-	return []string{}
+	return nil
 }
 
-func (c *GoCommand) run(args ...string) error {
+// command creates the command running in the go context with build env without starting it ( e.g. for RunAsync ).
+func (c *GoCommand) command(args ...string) (*Command, error) {
 	env, err := c.buildEnv()
 	if err != nil {
-		return xerrors.Errorf("failed to get build env: %w", err)
+		return nil, xerrors.Errorf("failed to get build env: %w", err)
 	}
 	cmd := NewCommand(args...)
 	if c.dir == "" {
 		symlinkPath, err := c.getOrCreateSymlink()
 		if err != nil {
-			return xerrors.Errorf("failed to get symlink path: %w", err)
+			return nil, xerrors.Errorf("failed to get symlink path: %w", err)
 		}
 		gopath, err := c.gopath()
 		if err != nil {
-			return xerrors.Errorf("failed to get GOPATH: %w", err)
+			return nil, xerrors.Errorf("failed to get GOPATH: %w", err)
 		}
 		env = append(env, fmt.Sprintf("GOPATH=%s", gopath))
 		env = append(env, fmt.Sprintf("PATH=%s:%s/bin", os.Getenv("PATH"), gopath))
@@ -372,10 +707,20 @@ func (c *GoCommand) run(args ...string) error {
 		cmd.SetDir(c.dir)
 	}
 	cmd.AddEnv(env)
-	if err := cmd.Run(); err != nil {
-		return xerrors.Errorf("failed to command: %w", err)
+	if c.stdout != nil || c.stderr != nil {
+		stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+		if c.stdout != nil {
+			stdout = c.stdout
+		}
+		if c.stderr != nil {
+			stderr = c.stderr
+		}
+		cmd.SetOutput(stdout, stderr)
 	}
-	return nil
+	if c.tail != nil {
+		cmd.SetOutputTail(c.tail)
+	}
+	return cmd, nil
 }
 
 func (c *GoCommand) buildEnv() ([]string, error) {
@@ -387,42 +732,127 @@ func (c *GoCommand) buildEnv() ([]string, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("failed to get GOARCH for build: %w", err)
 	}
+	cgoEnabled := 1
+	if c.disableCgo {
+		cgoEnabled = 0
+	}
 	env := []string{
-		"CGO_ENABLED=1",
+		fmt.Sprintf("CGO_ENABLED=%d", cgoEnabled),
 		fmt.Sprintf("GOOS=%s", goos),
 		fmt.Sprintf("GOARCH=%s", goarch),
 	}
+	if c.isCrossBuild {
+		cpu, err := containerCPU(c.container)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get cpu of container: %w", err)
+		}
+		env = append(env, microarchEnv(c.microarch, cpu)...)
+	}
+	if c.cacheDir != "" {
+		env = append(env,
+			fmt.Sprintf("GOCACHE=%s", c.cachePath("go-build")),
+			fmt.Sprintf("GOMODCACHE=%s", c.cachePath("mod")),
+		)
+	}
 	env = append(env, c.extEnv...)
-	if c.isCrossBuild && runtime.GOOS == "darwin" {
-		if _, err := exec.LookPath("x86_64-linux-musl-cc"); err != nil {
-			return nil, errors.ErrCrossCompiler
+	if c.disableCgo || c.onTarget {
+		return env, nil
+	}
+	ccEnv, err := c.cgoEnv(goos, goarch)
+	if err != nil {
+		if !c.isCrossBuild || c.cgoRequested() {
+			return nil, xerrors.Errorf("failed to get env for cgo: %w", err)
+		}
+		// most applications don't need cgo, so cross build works without the cross compiler
+		crossCompilerWarning.Do(func() {
+			logger().Warnf("cross compiler for %s/%s isn't found. building with CGO_ENABLED=0 ( set build.cgo or CGO_ENABLED: 1 to build.env to require cgo )", goos, goarch)
+		})
+		env[0] = "CGO_ENABLED=0"
+		return env, nil
+	}
+	return append(env, ccEnv...), nil
+}
+
+// crossCompilerWarning warns falling back to CGO_ENABLED=0 once instead of every build.
+var crossCompilerWarning sync.Once
+
+// cgoRequested returns true if cgo is required explicitly by build.cgo or CGO_ENABLED=1 of build.env .
+func (c *GoCommand) cgoRequested() bool {
+	return c.cgo != nil || c.extEnvValue("CGO_ENABLED") == "1"
+}
+
+// cgoEnv returns CC and CXX by build.cgo or the cross compiler detected for the container, and flags for build.cgo.sysroot .
+// CC in build.env takes precedence.
+func (c *GoCommand) cgoEnv(goos, goarch string) ([]string, error) {
+	env := []string{}
+	switch {
+	case c.hasExtEnv("CC"):
+	case c.cgo != nil && c.cgo.CC != "":
+		env = append(env, fmt.Sprintf("CC=%s", c.cgo.CC))
+		if c.cgo.CXX != "" {
+			env = append(env, fmt.Sprintf("CXX=%s", c.cgo.CXX))
+		}
+	case c.isCrossBuild:
+		ccEnv, err := crossCompilerEnv(goos, goarch)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get cross compiler: %w", err)
+		}
+		env = append(env, ccEnv...)
+	}
+	if c.cgo != nil && c.cgo.Sysroot != "" {
+		sysroot := fmt.Sprintf("--sysroot=%s", ExpandPath(c.cgo.Sysroot))
+		for _, name := range []string{"CGO_CFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS"} {
+			env = append(env, fmt.Sprintf("%s=%s", name, strings.TrimSpace(sysroot+" "+c.extEnvValue(name))))
 		}
-		env = append(env, []string{
-			"CC=x86_64-linux-musl-cc",
-			"CXX=x86_64-linux-musl-c++",
-		}...)
 	}
 	return env, nil
 }
 
+// hasExtEnv returns true if the environment variable is specified by AddEnv ( e.g. CC of build.env ).
+func (c *GoCommand) hasExtEnv(name string) bool {
+	for _, kv := range c.extEnv {
+		if strings.HasPrefix(kv, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// extEnvValue returns the last value of the environment variable specified by AddEnv .
+func (c *GoCommand) extEnvValue(name string) string {
+	value := ""
+	for _, kv := range c.extEnv {
+		if strings.HasPrefix(kv, name+"=") {
+			value = strings.TrimPrefix(kv, name+"=")
+		}
+	}
+	return value
+}
+
 func (c *GoCommand) buildGOOS() (string, error) {
+	if c.goos != "" {
+		return c.goos, nil
+	}
 	if c.isCrossBuild {
-		goos, err := NewDockerCommand(c.container, "go", "env", "GOOS").Output()
+		platform, err := detectContainerPlatform(c.container)
 		if err != nil {
-			return "", xerrors.Errorf("failed to get GOOS env on container: %w", err)
+			return "", xerrors.Errorf("failed to get GOOS of container: %w", err)
 		}
-		return string(goos), nil
+		return platform.goos, nil
 	}
 	return runtime.GOOS, nil
 }
 
 func (c *GoCommand) buildGOARCH() (string, error) {
+	if c.goarch != "" {
+		return c.goarch, nil
+	}
 	if c.isCrossBuild {
-		goarch, err := NewDockerCommand(c.container, "go", "env", "GOARCH").Output()
+		platform, err := detectContainerPlatform(c.container)
 		if err != nil {
-			return "", xerrors.Errorf("failed to get GOARCH env on container: %w", err)
+			return "", xerrors.Errorf("failed to get GOARCH of container: %w", err)
 		}
-		return string(goarch), nil
+		return platform.goarch, nil
 	}
 	return runtime.GOARCH, nil
 }
@@ -0,0 +1,48 @@
+package rebirth
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+func companionPath(name string) string {
+	return filepath.Join(configDir, "companions", name)
+}
+
+// RunCompanion builds companion binary specified by name and executes it once.
+// The binary is built by the same env as the application and runs with run.env .
+func (r *Reloader) RunCompanion(name string, args []string) error {
+	companion, exists := r.build.Companions[name]
+	if !exists {
+		return xerrors.Errorf("undefined companion %s in build.companions", name)
+	}
+	path := companionPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return xerrors.Errorf("failed to create directory for companion: %w", err)
+	}
+	if companion.Main == "" {
+		return xerrors.Errorf("build.companions.%s.main must be specified", name)
+	}
+	r.logger.Infof("Building %s....", name)
+	if err := r.newBuildCommand().Build("-o", filepath.Join(cwd, path), companion.Main); err != nil {
+		return xerrors.Errorf("failed to build companion %s: %w", name, err)
+	}
+	companionArgs := append(append([]string{}, companion.Args...), args...)
+	r.logger.Infof("Running: %s", name)
+	if r.isDockerMode() {
+		cmd := NewDockerCommand(r.host.Docker, append([]string{path}, companionArgs...)...)
+		cmd.AddEnv(r.runEnv())
+		if err := cmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run companion %s on container: %w", name, err)
+		}
+		return nil
+	}
+	cmd := NewCommand(append([]string{filepath.Join(cwd, path)}, companionArgs...)...)
+	cmd.AddEnv(r.runEnv())
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("failed to run companion %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package rebirth
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const composeRecoverInterval = time.Second
+
+// ResolveComposeService sets the ID of the container running host.compose_service to host.docker .
+func ResolveComposeService(host *Host) error {
+	if host == nil || host.ComposeService == "" {
+		return nil
+	}
+	id, err := composeContainerID(host)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve container of compose service %s: %w", host.ComposeService, err)
+	}
+	host.Docker = id
+	return nil
+}
+
+// composeContainerID returns the ID of the container running host.compose_service by `docker compose ps` .
+// `docker-compose` is used if compose isn't available as the docker plugin ( podman-compose for podman ).
+func composeContainerID(host *Host) (string, error) {
+	args := []string{}
+	for _, file := range host.ComposeFiles {
+		args = append(args, "-f", ExpandPath(file))
+	}
+	args = append(args, "ps", "-q", host.ComposeService)
+	var (
+		out      []byte
+		firstErr error
+	)
+	for _, compose := range containerRuntime().ComposeCommands() {
+		composeArgs := append(append([]string{}, compose[1:]...), args...)
+		result, err := exec.Command(compose[0], composeArgs...).Output()
+		if err == nil {
+			out = result
+			firstErr = nil
+			break
+		}
+		if firstErr == nil {
+			firstErr = xerrors.Errorf("failed to run %s ps: %w", strings.Join(compose, " "), err)
+		}
+	}
+	if firstErr != nil {
+		return "", firstErr
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return "", xerrors.Errorf("compose service %s isn't running", host.ComposeService)
+	}
+	return ids[0], nil
+}
+
+func (r *Reloader) isComposeMode() bool {
+	return r.isDockerMode() && r.host.ComposeService != ""
+}
+
+// watchComposeContainer waits for disconnection of the agent ( e.g. the container is recreated by `docker compose up` ),
+// and restarts the application on the new container of host.compose_service .
+func (r *Reloader) watchComposeContainer(client *AgentClient) {
+	if !r.isComposeMode() {
+		return
+	}
+	go func() {
+		<-client.done
+		if client.isClosed() {
+			return
+		}
+		r.logger.Infof("Lost connection to container of compose service %s. Waiting for the container...", r.host.ComposeService)
+		for {
+			time.Sleep(composeRecoverInterval)
+			if err := r.recoverComposeContainer(client); err != nil {
+				continue
+			}
+			return
+		}
+	}()
+}
+
+func (r *Reloader) recoverComposeContainer(lost *AgentClient) error {
+	r.lockReload()
+	defer r.unlockReload()
+	if r.agent != lost {
+		// already reconnected by reloading
+		return nil
+	}
+	if err := r.reconnectComposeContainer(); err != nil {
+		return err
+	}
+	if err := r.restart(buildPath); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	return nil
+}
+
+// reconnectComposeContainer resolves the container of host.compose_service again,
+// and starts the agent on it if the container is recreated or the agent is disconnected.
+func (r *Reloader) reconnectComposeContainer() error {
+	id, err := composeContainerID(r.host)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve container of compose service %s: %w", r.host.ComposeService, err)
+	}
+	if id == r.host.Docker && r.agent != nil && !r.agent.isDisconnected() {
+		return nil
+	}
+	if id != r.host.Docker {
+		r.logger.Infof("Container of compose service %s is recreated: %s", r.host.ComposeService, shortContainerID(id))
+	}
+	if r.agent != nil {
+		r.agent.Close()
+		r.setAgent(nil)
+	}
+	r.host.Docker = id
+	if err := r.startAgent(); err != nil {
+		return xerrors.Errorf("failed to start agent on container: %w", err)
+	}
+	if r.isSyncEnabled() {
+		if err := r.syncAll(); err != nil {
+			return xerrors.Errorf("failed to sync assets: %w", err)
+		}
+	}
+	return nil
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
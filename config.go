@@ -1,59 +1,594 @@
 package rebirth
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"golang.org/x/xerrors"
 )
 
 type Config struct {
-	Host  *Host  `yaml:"host,omitempty"`
-	Build *Build `yaml:"build,omitempty"`
-	Run   *Run   `yaml:"run,omitempty"`
-	Watch *Watch `yaml:"watch,omitempty"`
-	Task map[string]*Task `yaml:"task,omitempty"`
+	Host  *Host            `yaml:"host,omitempty"`
+	Build *Build           `yaml:"build,omitempty"`
+	Run   *Run             `yaml:"run,omitempty"`
+	Watch *Watch           `yaml:"watch,omitempty"`
+	Task  map[string]*Task `yaml:"task,omitempty"`
+
+	// Tasks are named commands run by `rebirth task <name>` or `task:<name>` in build hooks.
+	Tasks map[string]*Task `yaml:"tasks,omitempty"`
+
+	// Wasm serves the application built for GOOS=js GOARCH=wasm instead of running it.
+	Wasm *Wasm `yaml:"wasm,omitempty"`
+
+	// Proxy forwards requests to the application and holds them while reloading.
+	Proxy *Proxy `yaml:"proxy,omitempty"`
+
+	// Targets are applications hot-reloaded by rebirth instead of the main package of build .
+	Targets map[string]*Target `yaml:"targets,omitempty"`
+
+	// Services are started by `rebirth up` .
+	Services map[string]*Service `yaml:"services,omitempty"`
+	// StartupOrder is the global startup order of services. Each service starts after the previous one.
+	StartupOrder []string `yaml:"startup_order,omitempty"`
+
+	// Log configures messages of rebirth itself.
+	Log *Log `yaml:"log,omitempty"`
+
+	// Test configures `rebirth test` .
+	Test *Test `yaml:"test,omitempty"`
+
+	// Status serves the state and metrics of rebirth on HTTP.
+	Status *StatusServer `yaml:"status,omitempty"`
+
+	// Notify sends notifications of build failures and recoveries.
+	Notify *Notify `yaml:"notify,omitempty"`
+
+	// Profiles are overlays of the config selected by --profile or REBIRTH_PROFILE ( e.g. docker, integration ).
+	// The default profile is applied if no profile is selected.
+	Profiles map[string]*Config `yaml:"profiles,omitempty"`
+}
+
+// Notify specifies destinations of notifications for build results.
+type Notify struct {
+	// Desktop shows desktop notifications by osascript ( macOS ), notify-send ( Linux ) or PowerShell ( Windows ).
+	Desktop bool `yaml:"desktop,omitempty"`
+	// Webhooks are URLs receiving the Slack compatible payload ( {"text": "..."} ) by POST.
+	Webhooks []string `yaml:"webhooks,omitempty"`
+	// On are events notified. failure ( the build fails with a new error ) and recovery ( the build succeeds after failures ) ( default: both ).
+	On []string `yaml:"on,omitempty"`
+}
+
+// StatusServer specifies the local address ( e.g. localhost:9100 ) serving the state as JSON and Prometheus metrics on /metrics .
+type StatusServer struct {
+	Addr string `yaml:"addr,omitempty"`
+}
+
+// Test specifies packages ( default: the current package, or ./... for --watch ), flags ( e.g. -race , -count=1 ) and env of go test .
+// Tests run on the container of host.docker by cross build like the application.
+type Test struct {
+	Packages []string          `yaml:"packages,omitempty"`
+	Flags    []string          `yaml:"flags,omitempty"`
+	Env      map[string]string `yaml:"env,omitempty"`
+}
+
+// Log specifies the level ( debug, info, warn or error. default: info ) and the format ( text or json. default: text ) of messages.
+// Prefix is prepended to each message ( e.g. `[rebirth] ` ).
+type Log struct {
+	Level  string `yaml:"level,omitempty"`
+	Format string `yaml:"format,omitempty"`
+	Prefix string `yaml:"prefix,omitempty"`
+}
+
+// Wasm specifies the dev server for WebAssembly.
+// It serves wasm_exec.js, the compiled module as /main.wasm and static files in Dir on Listen ( default: :8080 ),
+// and reloads browsers on each rebuild.
+type Wasm struct {
+	Listen string `yaml:"listen,omitempty"`
+	Dir    string `yaml:"dir,omitempty"`
+}
+
+// Proxy specifies the reverse proxy listening on Listen ( e.g. :3000 ) and forwarding to Target.
+// Target is a port number, host:port or a name of run.ports . Requests are held during reloading up to Timeout ( default: 30s ).
+type Proxy struct {
+	Listen  string `yaml:"listen"`
+	Target  string `yaml:"target"`
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// DisableOverlay disables the error page served instead of the stale application while the build fails.
+	DisableOverlay bool `yaml:"disable_overlay,omitempty"`
+	// EditorURL is the link of error locations in the error page. {file} and {line} are replaced ( default: vscode://file{file}:{line} ).
+	EditorURL string `yaml:"editor_url,omitempty"`
+
+	// LiveReload injects the script into html responses, which reloads browsers after the application is restarted.
+	LiveReload bool `yaml:"live_reload,omitempty"`
+}
+
+// Target is an application hot-reloaded independently of other targets by a single rebirth process.
+// It's rebuilt only when files of packages built into it change.
+type Target struct {
+	Main string `yaml:"main"`
+	// Output is the path of the built binary ( default: .rebirth/targets/<name> ).
+	Output string `yaml:"output,omitempty"`
+	// Env is added to build.env for building the target.
+	Env map[string]string `yaml:"env,omitempty"`
+	// Run specifies args, env ( added to run.env ), stop_signal and stop_timeout of the target.
+	Run *Run `yaml:"run,omitempty"`
+}
+
+// Service is a binary built from the same module and started by `rebirth up` .
+type Service struct {
+	Main string            `yaml:"main"`
+	Args []string          `yaml:"args,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+	// Port is injected as PORT to env of the service. auto assigns a free port.
+	Port string `yaml:"port,omitempty"`
+	// Pprof is the port of net/http/pprof for `rebirth profile` .
+	Pprof string `yaml:"pprof,omitempty"`
+	// DependsOn are services which must be started before the service.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+	// WaitFor are external dependencies ( e.g. databases ) which must be ready before starting the service.
+	WaitFor []*Healthcheck `yaml:"wait_for,omitempty"`
 }
 
 type Host struct {
 	Docker string `yaml:"docker,omitempty"`
+	Agent  *Agent `yaml:"agent,omitempty"`
+
+	// Runtime is the container runtime for Docker and ComposeService . docker ( default ), podman and nerdctl are available.
+	Runtime string `yaml:"runtime,omitempty"`
+	// DockerContext is the context of docker CLI for the daemon ( e.g. desktop-linux or a remote daemon ).
+	// DOCKER_CONTEXT , DOCKER_HOST or the current context is used by default.
+	DockerContext string `yaml:"docker_context,omitempty"`
+
+	// DockerImage creates Docker container from the image if it doesn't exist, with DockerRunOpts passed to `docker run` .
+	// DockerEphemeral removes the container on closing rebirth.
+	DockerImage     string   `yaml:"docker_image,omitempty"`
+	DockerRunOpts   []string `yaml:"docker_run_opts,omitempty"`
+	DockerEphemeral bool     `yaml:"docker_ephemeral,omitempty"`
+
+	// ComposeService is the service of docker compose running the application instead of Docker .
+	// The container is resolved by `docker compose ps` , and resolved again when compose recreates it.
+	ComposeService string `yaml:"compose_service,omitempty"`
+	// ComposeFiles are passed to docker compose as -f ( default: docker-compose.yml in the current directory ).
+	ComposeFiles []string `yaml:"compose_files,omitempty"`
+
+	// Sync are rules for copying non-binary assets ( e.g. templates ) to the container without mounts.
+	Sync []*SyncRule `yaml:"sync,omitempty"`
+	// SyncBinary is the path on the container where the binary is copied after each build.
+	SyncBinary string `yaml:"sync_binary,omitempty"`
+	// Syncer copies assets and the binary ( default: agent ). docker, rsync and syncers registered by plugins are available.
+	Syncer string `yaml:"syncer,omitempty"`
+	// Rsync is the destination for rsync syncer ( e.g. user@host:/app ).
+	Rsync string `yaml:"rsync,omitempty"`
+
+	// SSH runs the application on the remote machine by SSH instead of the container.
+	SSH *SSHHost `yaml:"ssh,omitempty"`
+
+	// Network injects the address of the host machine reachable from the application.
+	Network *HostNetwork `yaml:"network,omitempty"`
+}
+
+// SSHHost specifies the remote machine ( e.g. user@host ) running the application.
+// The binary is cross compiled on localhost and sent to Dir ( default: /tmp/rebirth/<project> ) by rsync,
+// and the agent started by SSH runs it.
+type SSHHost struct {
+	Host string `yaml:"host"`
+	Key  string `yaml:"key,omitempty"`
+	Port int    `yaml:"port,omitempty"`
+	Dir  string `yaml:"dir,omitempty"`
+}
+
+// HostNetwork injects the address of the host machine reachable from the application to Env ( default: REBIRTH_HOST )
+// and verifies connectivity to Check ( ports on the host or host:port ) at startup.
+// The address is host.docker.internal or the gateway of the container's network on the container, and localhost on the host.
+type HostNetwork struct {
+	Env   string   `yaml:"env,omitempty"`
+	Check []string `yaml:"check,omitempty"`
+}
+
+// SyncRule maps Src on the host to Dst on the container.
+// Changes under Src are copied to Dst and restart the application without building.
+type SyncRule struct {
+	Src string `yaml:"src"`
+	Dst string `yaml:"dst"`
+}
+
+// Agent specifies a prebuilt __rebirth binary used on the container.
+// If neither Path nor URL is specified, rebirth cross compiles itself.
+type Agent struct {
+	Path      string            `yaml:"path,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Checksums map[string]string `yaml:"checksums,omitempty"`
 }
 
 type Build struct {
-	Env    map[string]string `yaml:"env,omitempty"`
-	Init   []string          `yaml:"init,omitempty"`
-	Before []string          `yaml:"before,omitempty"`
-	After  []string          `yaml:"after,omitempty"`
+	// Main is the main package ( e.g. ./cmd/server ) or the go file ( e.g. cmd/server/main.go ) of the application ( default: . ).
+	Main string `yaml:"main,omitempty"`
+	// Output is the path of the built binary ( default: .rebirth/program ). The name is shown in ps as the application.
+	Output string `yaml:"output,omitempty"`
+
+	Env       map[string]string `yaml:"env,omitempty"`
+	Init      []string          `yaml:"init,omitempty"`
+	Before    []*Hook           `yaml:"before,omitempty"`
+	After     []*Hook           `yaml:"after,omitempty"`
+	Microarch *Microarch        `yaml:"microarch,omitempty"`
+
+	// EnvFile is the dotenv file ( relative to rebirth.yml ) loaded as KEY=VALUE pairs. Env takes precedence over it.
+	EnvFile string `yaml:"env_file,omitempty"`
+
+	// Transform are commands transforming the built binary ( e.g. upx -q $REBIRTH_BINARY ).
+	// $REBIRTH_BINARY is the path of the binary to be transformed in place.
+	Transform []string `yaml:"transform,omitempty"`
+
+	// Yield is the max duration waiting for the user's go build / test in the same module before building ( default: 30s ).
+	// 0s disables waiting. The go commands are detected by /proc , so it works on Linux only.
+	Yield string `yaml:"yield,omitempty"`
+
+	// CacheDir is the directory for GOCACHE and GOMODCACHE ( relative to the project directory ).
+	// It's used for builds on localhost if specified, and for build.remote and build.cgo.in_container ( default: .rebirth/cache ),
+	// so builds inside the container don't recompile everything after the container restarts.
+	CacheDir string `yaml:"cache_dir,omitempty"`
+
+	// Parallel is the max number of targets ( and services of `rebirth up` ) built concurrently ( default: number of CPUs ).
+	Parallel int `yaml:"parallel,omitempty"`
+
+	// Generate runs go generate for the package before building when a go file having //go:generate directives changes,
+	// and for all packages ( ./... ) when a file matching GenerateInputs ( e.g. api/*.proto ) changes.
+	Generate       bool     `yaml:"generate,omitempty"`
+	GenerateInputs []string `yaml:"generate_inputs,omitempty"`
+
+	// LockMode fails building in a mode ( local, docker, ssh, ... ) different from the last build
+	// for avoiding mixing binaries of local and container builds.
+	LockMode bool `yaml:"lock_mode,omitempty"`
+
+	// OutputPrefix is prepended to each line of the build output ( e.g. `[build]` ) in OutputPrefixColor ( default: magenta ).
+	// Build errors are colored red on colored output regardless of it.
+	OutputPrefix      string `yaml:"output_prefix,omitempty"`
+	OutputPrefixColor string `yaml:"output_prefix_color,omitempty"`
+
+	// Tags , LDFlags and GCFlags are passed to go build as -tags , -ldflags and -gcflags .
+	Tags    []string `yaml:"tags,omitempty"`
+	LDFlags string   `yaml:"ldflags,omitempty"`
+	GCFlags string   `yaml:"gcflags,omitempty"`
+
+	// Compiler is gc ( default ), gccgo or tinygo .
+	Compiler string `yaml:"compiler,omitempty"`
+	// TinygoTarget is -target for tinygo ( e.g. wasm, arduino ). wasm is used for GOOS=js GOARCH=wasm by default.
+	TinygoTarget string `yaml:"tinygo_target,omitempty"`
+
+	// Remote runs `go build` on the remote machine instead of localhost.
+	Remote *RemoteBuild `yaml:"remote,omitempty"`
+
+	// Tools are commands installing tools used by the application ( e.g. go install ... ).
+	// They run concurrently with the first build of `rebirth up` , so the build must not depend on them.
+	Tools []string `yaml:"tools,omitempty"`
+
+	// SizeAlert warns when the binary size jumps from the previous build.
+	SizeAlert *SizeAlert `yaml:"size_alert,omitempty"`
+
+	// Companions are one-shot binaries built from the same module ( e.g. seeder ).
+	// They are executed on demand by `rebirth run-task <name>` .
+	Companions map[string]*Companion `yaml:"companions,omitempty"`
+
+	// Cgo specifies the toolchain of cgo for cross build ( e.g. for sqlite3 ).
+	Cgo *Cgo `yaml:"cgo,omitempty"`
+}
+
+// Cgo specifies the toolchain of cgo. Enabled ( default: true ) false builds with CGO_ENABLED=0 .
+// CC and CXX are used instead of the musl cross compiler detected for the container ( e.g. zig cc -target aarch64-linux-musl ),
+// and Sysroot is passed to them by --sysroot for headers and libraries of the target .
+// InContainer builds the application inside host.docker container by its own C compiler instead of cross compiling.
+type Cgo struct {
+	Enabled     *bool  `yaml:"enabled,omitempty"`
+	CC          string `yaml:"cc,omitempty"`
+	CXX         string `yaml:"cxx,omitempty"`
+	Sysroot     string `yaml:"sysroot,omitempty"`
+	InContainer bool   `yaml:"in_container,omitempty"`
+}
+
+// RemoteBuild specifies the machine for building by SSH ( e.g. user@host ) or Docker container.
+// Sources are sent to Dir ( default: /tmp/rebirth/<project> ) and the binary is fetched back.
+type RemoteBuild struct {
+	SSH    string `yaml:"ssh,omitempty"`
+	Docker string `yaml:"docker,omitempty"`
+	Dir    string `yaml:"dir,omitempty"`
+}
+
+// SizeAlert specifies the threshold of binary size growth in percent ( default: 10 )
+// and the command for size analysis run when it exceeds the threshold.
+type SizeAlert struct {
+	Threshold float64 `yaml:"threshold,omitempty"`
+	Hook      string  `yaml:"hook,omitempty"`
+}
+
+type Companion struct {
+	Main string   `yaml:"main"`
+	Args []string `yaml:"args,omitempty"`
+}
+
+// Hook is a command of build.before , build.after , run.before or run.after .
+// It's written as the command string, or the mapping with When to run it only when changed files match the globs.
+type Hook struct {
+	Command string `yaml:"command"`
+	// When are glob patterns relative to the project directory ( e.g. queries/*.sql , web/** ).
+	// A string or a list is available. The hook always runs if it's empty or the changed files are unknown ( e.g. the first build ).
+	When []string `yaml:"when,omitempty"`
+}
+
+// Microarch specifies microarchitecture level for cross build.
+// Each value is detected from the target CPU by default ( auto ), and off doesn't set it.
+type Microarch struct {
+	GOARM   string `yaml:"goarm,omitempty"`
+	GOAMD64 string `yaml:"goamd64,omitempty"`
+	GOARM64 string `yaml:"goarm64,omitempty"`
 }
 
 type Run struct {
-	Env map[string]string `yaml:"env,omitempty"`
+	// Command is the command line of the program run instead of the built binary ( e.g. node server.js , ./bin/prebuilt ).
+	// $REBIRTH_BINARY in it is replaced by the built binary ( e.g. ./scripts/start.sh $REBIRTH_BINARY ). Otherwise go build is skipped.
+	Command string `yaml:"command,omitempty"`
+
+	// Args are command line arguments of the application.
+	Args []string          `yaml:"args,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+	// EnvFile is the dotenv file ( relative to rebirth.yml ) loaded as KEY=VALUE pairs. Env takes precedence over it.
+	EnvFile string `yaml:"env_file,omitempty"`
+	// InheritEnv passes env of rebirth to the application on localhost ( default: true ).
+	// If false, the application gets only env by rebirth ( env_file, env, ports, ... ).
+	InheritEnv *bool `yaml:"inherit_env,omitempty"`
+
+	// GoRuntime is env for Go runtime applied to the application.
+	GoRuntime *GoRuntime `yaml:"go_runtime,omitempty"`
+
+	// Ports are injected to env of the application. auto assigns a free port.
+	Ports map[string]string `yaml:"ports,omitempty"`
+	// Sockets are listening addresses ( e.g. :8080, localhost:8080 or a name of ports ) opened by rebirth once for the session.
+	// The application gets them by the listener package instead of listening by itself, so restarts don't drop connections.
+	Sockets map[string]string `yaml:"sockets,omitempty"`
+
+	Output *Output `yaml:"output,omitempty"`
+
+	// Before are commands run before each restart of the application ( e.g. DB migrations ).
+	// They run on the same place as the application with its env. If one of them fails, the application isn't restarted.
+	Before []*Hook `yaml:"before,omitempty"`
+	// After are commands run after each restart of the application ( e.g. warming endpoints ).
+	After []*Hook `yaml:"after,omitempty"`
+	// Shutdown are commands run after the application is stopped on shutdown of rebirth ( e.g. removing temporary data ).
+	Shutdown []*Hook `yaml:"shutdown,omitempty"`
+
+	// Interactive passes the terminal to the application on localhost instead of the keybinding layer ( e.g. for prompts ).
+	// The application stays in the foreground process group of the terminal, so reading it doesn't stop the application by SIGTTIN .
+	Interactive bool `yaml:"interactive,omitempty"`
+
+	// Triggers fire actions when the application's output matches the patterns.
+	Triggers []*Trigger `yaml:"triggers,omitempty"`
+
+	// Pprof is the port ( or host:port, a name of ports ) of net/http/pprof for `rebirth profile` .
+	Pprof string `yaml:"pprof,omitempty"`
+
+	// Strategy is the way to reload the application ( default: stop-start ).
+	// stop-start, blue-green, exec-handoff, signal-only and container-restart are available.
+	Strategy string `yaml:"strategy,omitempty"`
+	// ReloadSignal is the signal sent by exec-handoff ( default: SIGUSR2 ) and signal-only ( default: SIGHUP ).
+	ReloadSignal string `yaml:"reload_signal,omitempty"`
+
+	// Healthcheck checks the restarted application. If it fails, rebirth rolls back to the previous good binary.
+	Healthcheck *Healthcheck `yaml:"healthcheck,omitempty"`
+
+	// Idle stops the application after the timeout with no activity, and restarts it on the next activity.
+	Idle *Idle `yaml:"idle,omitempty"`
+
+	// GracePeriod is the duration for checking the restarted process keeps running ( default: 1s ).
+	GracePeriod string `yaml:"grace_period,omitempty"`
+
+	// StopSignal is sent for stopping the application ( default: SIGTERM ).
+	StopSignal string `yaml:"stop_signal,omitempty"`
+	// StopTimeout is the duration for waiting the application exits by StopSignal before SIGKILL ( default: 5s ).
+	StopTimeout string `yaml:"stop_timeout,omitempty"`
+
+	// ReloadLogLines is the number of lines captured before stopping and after restarting the application
+	// for `rebirth logs --around` ( default: 50, -1 disables capturing ).
+	ReloadLogLines int `yaml:"reload_log_lines,omitempty"`
+
+	// Restart is the policy for restarting the exited application automatically ( default: never ).
+	// never, on-failure and always are available.
+	Restart string `yaml:"restart,omitempty"`
+	// RestartBackoff is the first delay of auto restart ( default: 1s ). It doubles on each retry up to RestartMaxBackoff ( default: 30s ).
+	RestartBackoff    string `yaml:"restart_backoff,omitempty"`
+	RestartMaxBackoff string `yaml:"restart_max_backoff,omitempty"`
+	// RestartMaxRetries is the number of consecutive auto restarts before giving up ( default: unlimited ).
+	RestartMaxRetries int `yaml:"restart_max_retries,omitempty"`
+}
+
+// GoRuntime specifies Go runtime knobs. They take precedence over the inherited env, and run.env takes precedence over them.
+// GOTRACEBACK is all by default. GODEBUG flags are merged into the inherited GODEBUG .
+type GoRuntime struct {
+	GOTRACEBACK string            `yaml:"gotraceback,omitempty"`
+	GODEBUG     map[string]string `yaml:"godebug,omitempty"`
+	GOMEMLIMIT  string            `yaml:"gomemlimit,omitempty"`
+	GOMAXPROCS  string            `yaml:"gomaxprocs,omitempty"`
+	GOGC        string            `yaml:"gogc,omitempty"`
+}
+
+// Output specifies how the application's output is shown.
+type Output struct {
+	// PrettyJSON renders JSON log lines human-readably on the terminal.
+	PrettyJSON bool `yaml:"pretty_json,omitempty"`
+	// Fields are JSON log fields shown after the message ( default: all fields ).
+	Fields []string `yaml:"fields,omitempty"`
+	// File is the path to write the raw output.
+	File string `yaml:"file,omitempty"`
+	// Prefix is prepended to each line on the terminal ( e.g. `[app]` ). Targets are prefixed by their names by default.
+	Prefix string `yaml:"prefix,omitempty"`
+	// PrefixColor is the color of Prefix ( red, green, yellow, blue, magenta, cyan or gray. default: cyan ).
+	PrefixColor string `yaml:"prefix_color,omitempty"`
+	// Color is auto ( default. colored on terminals without NO_COLOR ), always or never .
+	Color string `yaml:"color,omitempty"`
+}
+
+// Trigger fires Action when a line of the application's output matches Pattern ( regexp ).
+// Action is hook ( runs Hook with REBIRTH_TRIGGER_LINE ), restart or notify ( shows Message or the line ).
+// The trigger doesn't fire again during Cooldown ( default: 5s ).
+type Trigger struct {
+	Pattern  string `yaml:"pattern"`
+	Action   string `yaml:"action"`
+	Hook     string `yaml:"hook,omitempty"`
+	Message  string `yaml:"message,omitempty"`
+	Cooldown string `yaml:"cooldown,omitempty"`
+}
+
+// Healthcheck specifies HTTP URL or TCP address for checking health of the application.
+type Healthcheck struct {
+	HTTP     string `yaml:"http,omitempty"`
+	TCP      string `yaml:"tcp,omitempty"`
+	Interval string `yaml:"interval,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+}
+
+// Idle specifies idle shutdown for shared dev servers.
+// Activity is a file change or a request through the proxy.
+type Idle struct {
+	Timeout string `yaml:"timeout,omitempty"`
+	// Container stops the container too ( requires host.docker ).
+	Container bool `yaml:"container,omitempty"`
+}
+
+const defaultGracePeriod = time.Second
+
+func (r *Run) gracePeriod() time.Duration {
+	if r == nil {
+		return defaultGracePeriod
+	}
+	return parseDurationOr(r.GracePeriod, defaultGracePeriod)
+}
+
+const (
+	defaultStopSignal  = "SIGTERM"
+	defaultStopTimeout = 5 * time.Second
+)
+
+// inheritEnv returns true if the application inherits env of rebirth.
+func (r *Run) inheritEnv() bool {
+	return r == nil || r.InheritEnv == nil || *r.InheritEnv
+}
+
+// interactive returns true if run.interactive is specified and stdin is a terminal.
+func (r *Run) interactive() bool {
+	return r != nil && r.Interactive && isTerminal(os.Stdin)
+}
+
+func (r *Run) stopSignal() string {
+	if r == nil || r.StopSignal == "" {
+		return defaultStopSignal
+	}
+	return r.StopSignal
+}
+
+func (r *Run) stopTimeout() time.Duration {
+	if r == nil {
+		return defaultStopTimeout
+	}
+	return parseDurationOr(r.StopTimeout, defaultStopTimeout)
 }
 
 type Watch struct {
 	Root   string   `yaml:"root,omitempty"`
 	Ignore []string `yaml:"ignore,omitempty"`
+
+	// Debounce is the window for coalescing a burst of file events into a single reload ( default: 2s ).
+	Debounce string `yaml:"debounce,omitempty"`
+
+	// Poll detects changes by polling modification times instead of file system events ( e.g. for NFS ).
+	Poll bool `yaml:"poll,omitempty"`
+	// PollInterval is the interval of polling ( default: 1s ).
+	PollInterval string `yaml:"poll_interval,omitempty"`
+
+	// Include are glob patterns ( e.g. templates/**, config/*.yaml ) of files watched in addition to go files.
+	Include []string `yaml:"include,omitempty"`
+	// Exclude are glob patterns ( e.g. vendor/, **/*_gen.go ) of files and directories not watched.
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// Configs are rules for files consumed by the application supporting live config reload.
+	// Changes of them send the signal ( to all targets in targets mode ) or call the endpoint instead of restarting the application.
+	Configs []*ConfigReload `yaml:"configs,omitempty"`
+
+	// ReloadOnly is the rule for templates and static assets of the application reloading them by itself.
+	// It's the same as the last rule of Configs , and browsers are reloaded after notifying.
+	ReloadOnly *ConfigReload `yaml:"reload_only,omitempty"`
+
+	// Migrations runs the hook and restarts the application when files in the directory change.
+	Migrations *Migrations `yaml:"migrations,omitempty"`
+
+	// Freeze is daily time windows ( e.g. 12:00-13:00 ) during which reloading is suppressed.
+	Freeze []string `yaml:"freeze,omitempty"`
+}
+
+// ConfigReload notifies the application of changes of Files ( glob patterns relative to watch.root )
+// by Signal ( e.g. SIGHUP ) or POST request to HTTP ( e.g. http://localhost:1323/-/reload ).
+type ConfigReload struct {
+	Files  []string `yaml:"files"`
+	Signal string   `yaml:"signal,omitempty"`
+	HTTP   string   `yaml:"http,omitempty"`
+}
+
+// Migrations specifies the directory of schema migrations ( e.g. SQL files ) and the hook to apply them.
+// The hook runs with run.env on the same place as the application, after building and before restarting.
+type Migrations struct {
+	Dir  string `yaml:"dir"`
+	Hook string `yaml:"hook"`
 }
 
 type Task struct {
-	Desc string `yaml:"desc,omitempty"`
+	Desc     string   `yaml:"desc,omitempty"`
 	Commands []string `yaml:"commands,omitempty"`
+	// Deps are tasks run before the task. Each task runs once even if multiple tasks depend on it.
+	Deps []string `yaml:"deps,omitempty"`
+	// Container runs the commands on the container of host.docker instead of the go context on localhost.
+	Container bool `yaml:"container,omitempty"`
 }
 
 func LoadConfig(confPath string) (*Config, error) {
+	cfg, err := parseConfig(confPath, ConfigProfile())
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.loadEnvFiles(filepath.Dir(confPath)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseConfig decodes the config file, applies the profile to it and validates it.
+func parseConfig(confPath, profile string) (*Config, error) {
 	file, err := ioutil.ReadFile(confPath)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to read config file from %s: %w", confPath, err)
 	}
 	var cfg Config
-	if err := yaml.Unmarshal(file, &cfg); err != nil {
-		return nil, xerrors.New(yaml.FormatError(err, true, true))
+	// unknown keys ( e.g. typos ) are errors instead of being ignored
+	if err := yaml.NewDecoder(bytes.NewReader(file), yaml.DisallowUnknownField()).Decode(&cfg); err != nil {
+		return nil, xerrors.Errorf("invalid %s:\n%s", confPath, formatYAMLError(file, err))
+	}
+	if err := cfg.applyProfile(profile, file); err != nil {
+		return nil, xerrors.Errorf("failed to apply profile of %s: %w", confPath, err)
+	}
+	cfg.interpolate()
+	if err := cfg.Validate(); err != nil {
+		var errs ConfigErrors
+		if xerrors.As(err, &errs) {
+			return nil, xerrors.Errorf("invalid %s:\n%s", confPath, formatConfigErrors(file, errs))
+		}
+		return nil, err
 	}
 	return &cfg, nil
 }
 
+// ExistsConfig returns true if the config file found by FindConfig exists.
 func ExistsConfig() bool {
-	_, err := os.Stat(configDir)
+	_, err := os.Stat(configPath)
 	return err == nil
 }
@@ -0,0 +1,86 @@
+package rebirth
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultConfigPath = "rebirth.yml"
+	// initConfigPath is the config file created by `rebirth init` if no config file is found.
+	initConfigPath = ".rebirth.yml"
+	configPathEnv  = "REBIRTH_CONFIG"
+)
+
+// configPath is the path of the config file used by rebirth. It's changed by SetConfigPath .
+var configPath = initConfigPath
+
+// ConfigSearchPaths returns paths of the config file searched in order if it isn't specified by --config or REBIRTH_CONFIG .
+// The last one is the global config shared by projects.
+func ConfigSearchPaths() []string {
+	paths := []string{initConfigPath, defaultConfigPath, filepath.Join("configs", defaultConfigPath)}
+	if path := globalConfigPath(); path != "" {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// FindConfig returns the path of the config file. path ( e.g. by --config ) has priority over REBIRTH_CONFIG and ConfigSearchPaths .
+// The specified path is returned even if it doesn't exist, for creating it by `rebirth init` .
+// If no config file is found, it returns .rebirth.yml .
+func FindConfig(path string) string {
+	if path == "" {
+		path = os.Getenv(configPathEnv)
+	}
+	if path != "" {
+		return ExpandPath(path)
+	}
+	for _, path := range ConfigSearchPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return initConfigPath
+}
+
+// globalConfigPath returns $XDG_CONFIG_HOME/rebirth/rebirth.yml , or empty if the home directory is unknown.
+func globalConfigPath() string {
+	dir, err := xdgConfigHome()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rebirth", defaultConfigPath)
+}
+
+// SetConfigPath sets the path of the config file used by rebirth ( e.g. for reloading it, snapshots and `rebirth doctor` ).
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// ConfigPath returns the path of the config file used by rebirth.
+func ConfigPath() string {
+	return configPath
+}
+
+// absConfigPath returns the absolute path of the config file.
+func absConfigPath() string {
+	if filepath.IsAbs(configPath) {
+		return configPath
+	}
+	return filepath.Join(cwd, configPath)
+}
+
+// xdgConfigHome returns $XDG_CONFIG_HOME ( default: ~/.config ).
+func xdgConfigHome() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", xerrors.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config"), nil
+}
@@ -0,0 +1,146 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultProfile = "default"
+	profileEnv     = "REBIRTH_PROFILE"
+)
+
+// configProfile is the name of the profile selected by SetConfigProfile .
+var configProfile string
+
+// SetConfigProfile selects the profile applied on loading the config ( e.g. by --profile ).
+// If it isn't selected, REBIRTH_PROFILE env or the default profile is used.
+func SetConfigProfile(name string) {
+	configProfile = name
+}
+
+// ConfigProfile returns the name of the selected profile. It's empty if no profile is selected.
+func ConfigProfile() string {
+	if configProfile != "" {
+		return configProfile
+	}
+	return os.Getenv(profileEnv)
+}
+
+// applyProfile overrides the config by the profile. The default profile is applied if name is empty, and the missing default profile is ignored.
+// src is the source of the config to know keys specified in the profile, so zero values ( e.g. false ) in the profile override the base config too.
+func (c *Config) applyProfile(name string, src []byte) error {
+	if name == "" {
+		name = defaultProfile
+		if _, exists := c.Profiles[name]; !exists {
+			return nil
+		}
+	}
+	profile, exists := c.Profiles[name]
+	if !exists {
+		names := []string{}
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return xerrors.Errorf("unknown profile %s. profiles aren't defined", name)
+		}
+		return xerrors.Errorf("unknown profile %s. %s are available", name, strings.Join(names, ", "))
+	}
+	if profile == nil {
+		return nil
+	}
+	if len(profile.Profiles) > 0 {
+		return xerrors.Errorf("profiles.%s.profiles: profiles can't be nested", name)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return xerrors.Errorf("failed to decode profile %s: %w", name, err)
+	}
+	profiles, _ := lookupMapping(doc, "profiles")
+	specified, _ := lookupMapping(profiles, name)
+	mergeValue(reflect.ValueOf(c).Elem(), reflect.ValueOf(profile).Elem(), specified)
+	return nil
+}
+
+// lookupMapping returns the value of key in the decoded mapping.
+func lookupMapping(mapping interface{}, key string) (interface{}, bool) {
+	switch mapping := mapping.(type) {
+	case map[string]interface{}:
+		value, exists := mapping[key]
+		return value, exists
+	case map[interface{}]interface{}:
+		for k, value := range mapping {
+			if fmt.Sprint(k) == key {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// mergeValue overrides dst by values of src specified in the profile. specified is the decoded value of src in the profile,
+// and only keys in it are merged even if values are zero ( e.g. false , 0 , null ).
+// Structs and maps are merged recursively, and the others ( e.g. lists ) are replaced.
+func mergeValue(dst, src reflect.Value, specified interface{}) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if specified == nil {
+			// null in the profile removes the setting
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		if src.IsNil() || dst.IsNil() || src.Elem().Kind() != reflect.Struct {
+			dst.Set(src)
+			return
+		}
+		mergeValue(dst.Elem(), src.Elem(), specified)
+	case reflect.Struct:
+		if _, isMapping := specified.(map[string]interface{}); !isMapping {
+			if _, isMapping := specified.(map[interface{}]interface{}); !isMapping {
+				// written in the short form ( e.g. a hook by the command string )
+				dst.Set(src)
+				return
+			}
+		}
+		for i := 0; i < src.NumField(); i++ {
+			field := src.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			value, exists := lookupMapping(specified, name)
+			if !exists {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i), value)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for _, key := range src.MapKeys() {
+			value, current := src.MapIndex(key), dst.MapIndex(key)
+			if current.IsValid() && value.Kind() == reflect.Ptr && !value.IsNil() && !current.IsNil() && value.Elem().Kind() == reflect.Struct {
+				// e.g. settings of the same target
+				sub, _ := lookupMapping(specified, fmt.Sprint(key.Interface()))
+				mergeValue(current, value, sub)
+				continue
+			}
+			dst.SetMapIndex(key, value)
+		}
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,95 @@
+package rebirth
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const configReloadTimeout = 10 * time.Second
+
+// configReload returns the rule of watch.configs or watch.reload_only matched with path relative to watch.root .
+// watch.reload_only works as the last rule of watch.configs which reloads browsers too.
+func (w *Watch) configReload(relPath string) *ConfigReload {
+	if w == nil {
+		return nil
+	}
+	for _, rule := range w.Configs {
+		if matchAnyGlob(rule.Files, relPath) {
+			return rule
+		}
+	}
+	if w.ReloadOnly != nil && matchAnyGlob(w.ReloadOnly.Files, relPath) {
+		return w.ReloadOnly
+	}
+	return nil
+}
+
+// splitConfigFiles splits files into files of watch.configs or watch.reload_only and others.
+func (r *Reloader) splitConfigFiles(files []string) (map[*ConfigReload][]string, []string) {
+	configs := map[*ConfigReload][]string{}
+	others := []string{}
+	for _, file := range files {
+		rule := r.watch.configReload(relPathFrom(watchRoot(r.watch), file))
+		if rule == nil {
+			others = append(others, file)
+			continue
+		}
+		configs[rule] = append(configs[rule], file)
+	}
+	return configs, others
+}
+
+// reloadConfigs notifies the application of changes of config files, templates or static assets instead of restarting it.
+// Files for host.sync are copied to the container before notifying.
+func (r *Reloader) reloadConfigs(configs map[*ConfigReload][]string) error {
+	for rule, files := range configs {
+		if assets, _ := r.splitSyncFiles(files); len(assets) > 0 {
+			if err := r.syncFiles(assets); err != nil {
+				return xerrors.Errorf("failed to sync files: %w", err)
+			}
+		}
+		if rule == r.watch.ReloadOnly {
+			r.logger.Infof("Reloading without restart: %v", files)
+		} else {
+			r.logger.Infof("Reloading config: %v", files)
+		}
+		if err := r.notifyConfigReload(rule); err != nil {
+			return xerrors.Errorf("failed to notify reload: %w", err)
+		}
+		if rule == r.watch.ReloadOnly {
+			// pages are rendered with the changed templates
+			r.reloadBrowsers()
+		}
+	}
+	return nil
+}
+
+func (r *Reloader) notifyConfigReload(rule *ConfigReload) error {
+	if rule.Signal == "" && rule.HTTP == "" {
+		return xerrors.New("signal or http must be specified")
+	}
+	if rule.Signal != "" && r.isTargetsMode() {
+		if err := r.signalTargets(rule.Signal); err != nil {
+			return xerrors.Errorf("failed to send %s: %w", rule.Signal, err)
+		}
+	} else if rule.Signal != "" {
+		if err := r.sendSignal(rule.Signal); err != nil {
+			return xerrors.Errorf("failed to send %s: %w", rule.Signal, err)
+		}
+	}
+	if rule.HTTP != "" {
+		client := &http.Client{Timeout: configReloadTimeout}
+		resp, err := client.Post(rule.HTTP, "text/plain", nil)
+		if err != nil {
+			return xerrors.Errorf("failed to request to %s: %w", rule.HTTP, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return xerrors.Errorf("unexpected status code from %s: %d", rule.HTTP, resp.StatusCode)
+		}
+		r.logger.Infof("Requested %s", rule.HTTP)
+	}
+	return nil
+}
@@ -0,0 +1,596 @@
+package rebirth
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/xerrors"
+)
+
+const defaultContainerRuntime = "docker"
+
+// ContainerRuntime runs commands on containers and manages them for host.docker and host.compose_service .
+// The runtime is selected by host.runtime .
+type ContainerRuntime interface {
+	Name() string
+	// Ping checks the runtime is available.
+	Ping(ctx context.Context) error
+	// Exec runs cmd on the container and returns its exit code.
+	Exec(ctx context.Context, container string, cmd, env []string, stdout, stderr io.Writer) (int, error)
+	// Attach runs cmd on the container with stdin.
+	// It returns writer for stdin and reader for stdout. stderr is written to os.Stderr.
+	Attach(ctx context.Context, container string, cmd, env []string) (io.WriteCloser, io.Reader, error)
+	Inspect(ctx context.Context, container string) (*ContainerInfo, error)
+	// Exists returns false if the container isn't found.
+	Exists(ctx context.Context, container string) (bool, error)
+	// Create creates and starts the container from the image with options of `docker run` , and runs cmd on it.
+	Create(ctx context.Context, container, image string, opts, cmd []string) error
+	// Remove removes the container forcibly.
+	Remove(ctx context.Context, container string) error
+	// ImagePlatform returns GOOS and GOARCH of the image.
+	ImagePlatform(ctx context.Context, image string) (string, string, error)
+	Start(ctx context.Context, container string) error
+	Stop(ctx context.Context, container string) error
+	Restart(ctx context.Context, container string) error
+	// CopyTo extracts the tar archive to dir on the container.
+	CopyTo(ctx context.Context, container, dir string, archive io.Reader) error
+	// CopyFrom copies the file on the container to target on localhost.
+	CopyFrom(ctx context.Context, container, file, target string) error
+	// ComposeCommands returns compose commands in the order of preference ( e.g. docker compose and docker-compose ).
+	ComposeCommands() [][]string
+}
+
+// ContainerInfo is the state of the container used by rebirth.
+type ContainerInfo struct {
+	Running bool
+	Image   string
+	// Gateway is the gateway of the container's network.
+	Gateway string
+}
+
+var (
+	containerRuntimesMu     sync.RWMutex
+	containerRuntimes       = map[string]ContainerRuntime{}
+	currentContainerRuntime ContainerRuntime
+)
+
+func init() {
+	RegisterContainerRuntime(&dockerRuntime{})
+	RegisterContainerRuntime(&cliRuntime{name: "podman", compose: [][]string{{"podman", "compose"}, {"podman-compose"}}})
+	RegisterContainerRuntime(&cliRuntime{name: "nerdctl", compose: [][]string{{"nerdctl", "compose"}}})
+	currentContainerRuntime = containerRuntimes[defaultContainerRuntime]
+}
+
+// RegisterContainerRuntime registers runtime. The runtime which has the same name is overwritten.
+func RegisterContainerRuntime(runtime ContainerRuntime) {
+	containerRuntimesMu.Lock()
+	defer containerRuntimesMu.Unlock()
+	containerRuntimes[runtime.Name()] = runtime
+}
+
+// SetContainerRuntime selects the runtime by name of host.runtime ( default: docker ).
+func SetContainerRuntime(name string) error {
+	if name == "" {
+		name = defaultContainerRuntime
+	}
+	containerRuntimesMu.Lock()
+	defer containerRuntimesMu.Unlock()
+	runtime, exists := containerRuntimes[name]
+	if !exists {
+		return xerrors.Errorf("unknown container runtime %s", name)
+	}
+	currentContainerRuntime = runtime
+	return nil
+}
+
+func containerRuntime() ContainerRuntime {
+	containerRuntimesMu.RLock()
+	defer containerRuntimesMu.RUnlock()
+	return currentContainerRuntime
+}
+
+func (h *Host) runtime() string {
+	if h.Runtime == "" {
+		return defaultContainerRuntime
+	}
+	return h.Runtime
+}
+
+// dockerRuntime uses Docker Engine API by DOCKER_HOST .
+type dockerRuntime struct{}
+
+func (d *dockerRuntime) Name() string { return "docker" }
+
+func (d *dockerRuntime) client() (*client.Client, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create docker client: %w", err)
+	}
+	return cli, nil
+}
+
+func (d *dockerRuntime) Ping(ctx context.Context) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if _, err := cli.Ping(ctx); err != nil {
+		return xerrors.Errorf("failed to ping docker daemon: %w", err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) Exec(ctx context.Context, container string, cmd, env []string, stdout, stderr io.Writer) (int, error) {
+	cli, err := d.client()
+	if err != nil {
+		return 0, err
+	}
+	cfg := types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          env,
+		Cmd:          cmd,
+	}
+	execResp, err := cli.ContainerExecCreate(ctx, container, cfg)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to ContainerExecCreate: %w", err)
+	}
+	attachResp, err := cli.ContainerExecAttach(ctx, execResp.ID, cfg)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to ContainerExecAttach: %w", err)
+	}
+	defer attachResp.Close()
+	if _, err := stdcopy.StdCopy(stdout, stderr, attachResp.Reader); err != nil {
+		return 0, xerrors.Errorf("failed to copy stdout/stderr: %w", err)
+	}
+	resp, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to ContainerExecInspect: %w", err)
+	}
+	return resp.ExitCode, nil
+}
+
+func (d *dockerRuntime) Attach(ctx context.Context, container string, cmd, env []string) (io.WriteCloser, io.Reader, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := types.ExecConfig{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          env,
+		Cmd:          cmd,
+	}
+	execResp, err := cli.ContainerExecCreate(ctx, container, cfg)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to ContainerExecCreate: %w", err)
+	}
+	attachResp, err := cli.ContainerExecAttach(ctx, execResp.ID, cfg)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to ContainerExecAttach: %w", err)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(writer, os.Stderr, attachResp.Reader)
+		writer.CloseWithError(err)
+	}()
+	return attachResp.Conn, reader, nil
+}
+
+func (d *dockerRuntime) Inspect(ctx context.Context, container string) (*ContainerInfo, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+	info, err := cli.ContainerInspect(ctx, container)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to inspect container %s: %w", container, err)
+	}
+	result := &ContainerInfo{
+		Running: info.State != nil && info.State.Running,
+		Image:   info.Image,
+	}
+	if info.NetworkSettings != nil {
+		result.Gateway = info.NetworkSettings.Gateway
+		for _, endpoint := range info.NetworkSettings.Networks {
+			if result.Gateway == "" && endpoint.Gateway != "" {
+				result.Gateway = endpoint.Gateway
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *dockerRuntime) Exists(ctx context.Context, container string) (bool, error) {
+	cli, err := d.client()
+	if err != nil {
+		return false, err
+	}
+	if _, err := cli.ContainerInspect(ctx, container); err != nil {
+		if client.IsErrContainerNotFound(err) {
+			return false, nil
+		}
+		return false, xerrors.Errorf("failed to inspect container %s: %w", container, err)
+	}
+	return true, nil
+}
+
+// Create runs docker CLI because opts are options of `docker run` .
+func (d *dockerRuntime) Create(ctx context.Context, container, image string, opts, cmd []string) error {
+	return runContainerCLI(ctx, d.Name(), container, image, opts, cmd)
+}
+
+func (d *dockerRuntime) Remove(ctx context.Context, container string) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerRemove(ctx, container, types.ContainerRemoveOptions{Force: true}); err != nil {
+		return xerrors.Errorf("failed to remove container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) ImagePlatform(ctx context.Context, image string) (string, string, error) {
+	cli, err := d.client()
+	if err != nil {
+		return "", "", err
+	}
+	info, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", "", xerrors.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return info.Os, info.Architecture, nil
+}
+
+func (d *dockerRuntime) Start(ctx context.Context, container string) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerStart(ctx, container, types.ContainerStartOptions{}); err != nil {
+		return xerrors.Errorf("failed to start container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) Stop(ctx context.Context, container string) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerStop(ctx, container, nil); err != nil {
+		return xerrors.Errorf("failed to stop container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) Restart(ctx context.Context, container string) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if err := cli.ContainerRestart(ctx, container, nil); err != nil {
+		return xerrors.Errorf("failed to restart container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) CopyTo(ctx context.Context, container, dir string, archive io.Reader) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	if err := cli.CopyToContainer(ctx, container, dir, archive, types.CopyToContainerOptions{}); err != nil {
+		return xerrors.Errorf("failed to copy to %s on container %s: %w", dir, container, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) CopyFrom(ctx context.Context, container, file, target string) error {
+	cli, err := d.client()
+	if err != nil {
+		return err
+	}
+	archive, _, err := cli.CopyFromContainer(ctx, container, file)
+	if err != nil {
+		return xerrors.Errorf("failed to copy %s from container %s: %w", file, container, err)
+	}
+	defer archive.Close()
+	if err := extractTarFile(archive, target); err != nil {
+		return xerrors.Errorf("failed to extract %s: %w", file, err)
+	}
+	return nil
+}
+
+func (d *dockerRuntime) ComposeCommands() [][]string {
+	return [][]string{{"docker", "compose"}, {"docker-compose"}}
+}
+
+// cliRuntime runs the CLI compatible with docker ( e.g. podman and nerdctl ).
+// They are used by CLI because rootless podman and containerd don't serve Docker Engine API by default.
+type cliRuntime struct {
+	name    string
+	compose [][]string
+}
+
+// cliErrorStatus is the exit status of the CLI itself failing ( e.g. the container isn't found ).
+// The other status is the exit status of the executed command.
+const cliErrorStatus = 125
+
+func (c *cliRuntime) Name() string { return c.name }
+
+func (c *cliRuntime) command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, c.name, args...)
+}
+
+// output runs the CLI and returns stdout. stderr is included in the error.
+func (c *cliRuntime) output(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := c.command(ctx, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to run %s %s: %s: %w", c.name, strings.Join(args, " "), strings.TrimSpace(stderr.String()), err)
+	}
+	return out, nil
+}
+
+func (c *cliRuntime) Ping(ctx context.Context) error {
+	if _, err := c.output(ctx, "info"); err != nil {
+		return xerrors.Errorf("failed to get info of %s: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) execArgs(container string, cmd, env []string, interactive bool) []string {
+	args := []string{"exec"}
+	if interactive {
+		args = append(args, "-i")
+	}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, container)
+	return append(args, cmd...)
+}
+
+func (c *cliRuntime) Exec(ctx context.Context, container string, cmd, env []string, stdout, stderr io.Writer) (int, error) {
+	execCmd := c.command(ctx, c.execArgs(container, cmd, env, false)...)
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+	err := execCmd.Run()
+	if err == nil {
+		return 0, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() != cliErrorStatus {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, xerrors.Errorf("failed to run %s exec: %w", c.name, err)
+}
+
+func (c *cliRuntime) Attach(ctx context.Context, container string, cmd, env []string) (io.WriteCloser, io.Reader, error) {
+	execCmd := c.command(ctx, c.execArgs(container, cmd, env, true)...)
+	execCmd.Stderr = os.Stderr
+	stdin, err := execCmd.StdinPipe()
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to get stdin: %w", err)
+	}
+	stdout, err := execCmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to get stdout: %w", err)
+	}
+	if err := execCmd.Start(); err != nil {
+		return nil, nil, xerrors.Errorf("failed to start %s exec: %w", c.name, err)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := io.Copy(writer, stdout)
+		if waitErr := execCmd.Wait(); err == nil {
+			err = waitErr
+		}
+		writer.CloseWithError(err)
+	}()
+	return stdin, reader, nil
+}
+
+// cliContainer is the part of the output of inspect. podman and nerdctl output it in the same format as docker.
+type cliContainer struct {
+	Image string `json:"Image"`
+	State *struct {
+		Running bool `json:"Running"`
+	} `json:"State"`
+	NetworkSettings *struct {
+		Gateway  string `json:"Gateway"`
+		Networks map[string]*struct {
+			Gateway string `json:"Gateway"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (c *cliRuntime) Inspect(ctx context.Context, container string) (*ContainerInfo, error) {
+	out, err := c.output(ctx, "container", "inspect", container)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to inspect container %s: %w", container, err)
+	}
+	var containers []*cliContainer
+	if err := json.Unmarshal(out, &containers); err != nil {
+		return nil, xerrors.Errorf("failed to decode output of %s inspect: %w", c.name, err)
+	}
+	if len(containers) == 0 {
+		return nil, xerrors.Errorf("container %s isn't found", container)
+	}
+	info := containers[0]
+	result := &ContainerInfo{
+		Running: info.State != nil && info.State.Running,
+		Image:   info.Image,
+	}
+	if info.NetworkSettings != nil {
+		result.Gateway = info.NetworkSettings.Gateway
+		for _, endpoint := range info.NetworkSettings.Networks {
+			if result.Gateway == "" && endpoint != nil && endpoint.Gateway != "" {
+				result.Gateway = endpoint.Gateway
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c *cliRuntime) Exists(ctx context.Context, container string) (bool, error) {
+	var stderr bytes.Buffer
+	cmd := c.command(ctx, "container", "inspect", container)
+	cmd.Stderr = &stderr
+	if _, err := cmd.Output(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && strings.Contains(strings.ToLower(stderr.String()), "no such") {
+			return false, nil
+		}
+		return false, xerrors.Errorf("failed to inspect container %s: %s: %w", container, strings.TrimSpace(stderr.String()), err)
+	}
+	return true, nil
+}
+
+func (c *cliRuntime) Create(ctx context.Context, container, image string, opts, cmd []string) error {
+	return runContainerCLI(ctx, c.name, container, image, opts, cmd)
+}
+
+func (c *cliRuntime) Remove(ctx context.Context, container string) error {
+	if _, err := c.output(ctx, "rm", "-f", container); err != nil {
+		return xerrors.Errorf("failed to remove container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) ImagePlatform(ctx context.Context, image string) (string, string, error) {
+	out, err := c.output(ctx, "image", "inspect", image)
+	if err != nil {
+		return "", "", xerrors.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	var images []*struct {
+		Os           string `json:"Os"`
+		Architecture string `json:"Architecture"`
+	}
+	if err := json.Unmarshal(out, &images); err != nil {
+		return "", "", xerrors.Errorf("failed to decode output of %s image inspect: %w", c.name, err)
+	}
+	if len(images) == 0 {
+		return "", "", xerrors.Errorf("image %s isn't found", image)
+	}
+	return images[0].Os, images[0].Architecture, nil
+}
+
+func (c *cliRuntime) Start(ctx context.Context, container string) error {
+	if _, err := c.output(ctx, "start", container); err != nil {
+		return xerrors.Errorf("failed to start container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) Stop(ctx context.Context, container string) error {
+	if _, err := c.output(ctx, "stop", container); err != nil {
+		return xerrors.Errorf("failed to stop container %s: %w", container, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) Restart(ctx context.Context, container string) error {
+	if _, err := c.output(ctx, "restart", container); err != nil {
+		return xerrors.Errorf("failed to restart container %s: %w", container, err)
+	}
+	return nil
+}
+
+// CopyTo extracts the archive on localhost and copies the directory by cp ,
+// because reading the archive from stdin isn't supported by all runtimes.
+func (c *cliRuntime) CopyTo(ctx context.Context, container, dir string, archive io.Reader) error {
+	tmp, err := ioutil.TempDir("", "rebirth-cp")
+	if err != nil {
+		return xerrors.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractTar(archive, tmp); err != nil {
+		return xerrors.Errorf("failed to extract archive: %w", err)
+	}
+	// `src/.` copies the contents of the directory like docker cp
+	if _, err := c.output(ctx, "cp", tmp+string(filepath.Separator)+".", fmt.Sprintf("%s:%s", container, dir)); err != nil {
+		return xerrors.Errorf("failed to copy to %s on container %s: %w", dir, container, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) CopyFrom(ctx context.Context, container, file, target string) error {
+	if _, err := c.output(ctx, "cp", fmt.Sprintf("%s:%s", container, file), target); err != nil {
+		return xerrors.Errorf("failed to copy %s from container %s: %w", file, container, err)
+	}
+	return nil
+}
+
+func (c *cliRuntime) ComposeCommands() [][]string {
+	return c.compose
+}
+
+// runContainerCLI runs `<cli> run -d --name container opts image cmd` . The image is pulled if it doesn't exist.
+func runContainerCLI(ctx context.Context, cli, container, image string, opts, cmd []string) error {
+	args := append([]string{"run", "-d", "--name", container}, opts...)
+	args = append(args, image)
+	args = append(args, cmd...)
+	runCmd := exec.CommandContext(ctx, cli, args...)
+	// progress of pulling the image
+	runCmd.Stderr = os.Stderr
+	if _, err := runCmd.Output(); err != nil {
+		return xerrors.Errorf("failed to run %s %s: %w", cli, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// extractTar extracts directories and regular files in the tar archive to dir.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("failed to read archive: %w", err)
+		}
+		name := path.Clean("/" + header.Name)
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return xerrors.Errorf("failed to create %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return xerrors.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+			}
+			file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return xerrors.Errorf("failed to create %s: %w", target, err)
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return xerrors.Errorf("failed to write %s: %w", target, err)
+			}
+			if err := file.Close(); err != nil {
+				return xerrors.Errorf("failed to close %s: %w", target, err)
+			}
+		}
+	}
+}
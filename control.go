@@ -0,0 +1,121 @@
+package rebirth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// serveControl serves control API for the running rebirth on the unix domain socket.
+// Other rebirth commands ( e.g. `rebirth freeze` ) talk to the running rebirth by this API.
+func (r *Reloader) serveControl() error {
+	if _, err := os.Stat(controlSocketPath); err == nil {
+		if NewControlClient().Do(http.MethodGet, "/ping", nil, nil) == nil {
+			return xerrors.Errorf("another rebirth is already running. %s is used", controlSocketPath)
+		}
+		// remove socket of rebirth that exited abnormally
+		os.Remove(controlSocketPath)
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", configDir, err)
+	}
+	listener, err := net.Listen("unix", controlSocketPath)
+	if err != nil {
+		return xerrors.Errorf("failed to listen %s: %w", controlSocketPath, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, req *http.Request) {
+		writeControlResponse(w, map[string]string{"status": "ok"}, nil)
+	})
+	mux.HandleFunc("/freeze", r.handleFreeze)
+	mux.HandleFunc("/status", r.handleStatus)
+	mux.HandleFunc("/focus", r.handleFocus)
+	mux.HandleFunc("/manifest", r.handleManifest)
+	mux.HandleFunc("/snapshot", r.handleSnapshot)
+	r.control = listener
+	addCleanup(func() { os.Remove(controlSocketPath) })
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func (r *Reloader) closeControl() {
+	if r.control == nil {
+		return
+	}
+	r.control.Close()
+	os.Remove(controlSocketPath)
+	r.control = nil
+}
+
+func writeControlResponse(w http.ResponseWriter, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// ControlClient talks to the running rebirth by control API.
+type ControlClient struct {
+	client *http.Client
+}
+
+func NewControlClient() *ControlClient {
+	return &ControlClient{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", controlSocketPath)
+				},
+			},
+		},
+	}
+}
+
+// SetTimeout changes the timeout of requests ( default: 10s ) for requests waiting for restarting.
+func (c *ControlClient) SetTimeout(timeout time.Duration) {
+	c.client.Timeout = timeout
+}
+
+// Do requests to control API and decodes the response to out if out isn't nil.
+func (c *ControlClient) Do(method, path string, query url.Values, out interface{}) error {
+	u := url.URL{Scheme: "http", Host: "rebirth", Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return xerrors.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("failed to request to running rebirth ( is rebirth running ? ): %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var res struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &res)
+		return xerrors.Errorf("failed to %s %s: %s", method, path, res.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return xerrors.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package rebirth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	crashTailLines = 500
+	crashDirFormat = "20060102-150405"
+)
+
+// outputTail keeps the last lines written to it.
+type outputTail struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{max: max}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial = append(t.partial, p...)
+	for {
+		idx := bytes.IndexByte(t.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		t.lines = append(t.lines, string(t.partial[:idx]))
+		t.partial = t.partial[idx+1:]
+	}
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *outputTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := append([]string{}, t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+	}
+	return lines
+}
+
+func (t *outputTail) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = nil
+	t.partial = nil
+}
+
+// panicTrace extracts go's panic or fatal error trace from the output.
+func panicTrace(lines []string) string {
+	for idx, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			return strings.Join(lines[idx:], "\n") + "\n"
+		}
+	}
+	return ""
+}
+
+// crash is the information of the crashed application.
+type crash struct {
+	pid    int
+	status string
+	output []string
+	env    []string
+}
+
+// captureCrash writes crash bundle to .rebirth/crashes/<timestamp>-<pid> and returns the directory.
+// The pid distinguishes crashes within the same second ( e.g. of multiple targets ).
+func captureCrash(c *crash) (string, error) {
+	dir := filepath.Join(crashesDir, fmt.Sprintf("%s-%d", time.Now().Format(crashDirFormat), c.pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	files := map[string]string{
+		"output.log": strings.Join(c.output, "\n") + "\n",
+		"system.txt": crashSystemInfo(c),
+		"env.txt":    crashRuntimeEnv(c.env),
+	}
+	if trace := panicTrace(c.output); trace != "" {
+		files["panic.txt"] = trace
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return "", xerrors.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return dir, nil
+}
+
+func crashSystemInfo(c *crash) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "pid: %d\n", c.pid)
+	fmt.Fprintf(&b, "status: %s\n", c.status)
+	fmt.Fprintf(&b, "rebirth: %s/%s %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+	if out, err := exec.Command("go", "version").Output(); err == nil {
+		fmt.Fprintf(&b, "go: %s", out)
+	}
+	if out, err := exec.Command("uname", "-a").Output(); err == nil {
+		fmt.Fprintf(&b, "uname: %s", out)
+	}
+	return b.String()
+}
+
+// crashRuntimeEnv reports env variables for go runtime ( traceback and core dump settings ).
+func crashRuntimeEnv(env []string) string {
+	values := map[string]string{}
+	for _, kv := range append(os.Environ(), env...) {
+		v := strings.SplitN(kv, "=", 2)
+		if len(v) == 2 && strings.HasPrefix(v[0], "GO") {
+			values[v[0]] = v[1]
+		}
+	}
+	var b strings.Builder
+	for _, name := range []string{"GOTRACEBACK", "GODEBUG", "GOMAXPROCS", "GOGC", "GOMEMLIMIT"} {
+		fmt.Fprintf(&b, "%s=%s\n", name, values[name])
+	}
+	if values["GOTRACEBACK"] != "crash" {
+		b.WriteString("# set GOTRACEBACK=crash to get core dump on crash\n")
+	}
+	return b.String()
+}
+
+// reportCrash captures crash bundle and prints the failure message.
+func (r *Reloader) reportCrash(c *crash) {
+	defer r.recordCrashForPrompt(c)
+	r.setState(StateFailed, crashSummary(c))
+	dir, err := captureCrash(c)
+	if err != nil {
+		r.logger.Errorf("process(%d) crashed ( %s ). failed to capture crash: %+v", c.pid, c.status, err)
+		return
+	}
+	r.logger.Errorf("process(%d) crashed ( %s ). crash report: %s", c.pid, c.status, dir)
+}
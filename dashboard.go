@@ -0,0 +1,420 @@
+package rebirth
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	dashboardRefreshInterval = 100 * time.Millisecond
+	// dashboardMaxLines is the number of lines kept by each pane.
+	dashboardMaxLines = 1000
+)
+
+// ansiEscapePattern matches escape sequences ( e.g. colors ) removed from lines of panes.
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// dashboardPane keeps the last lines written to the pane.
+type dashboardPane struct {
+	title string
+	lines []string
+}
+
+// dashboardTarget is the state of the target shown in the targets pane.
+type dashboardTarget struct {
+	state     string
+	summary   string
+	updatedAt time.Time
+}
+
+// dashboard renders panes of the build output, the application's output and messages of rebirth on the alternate screen.
+// Writes to stdout and stderr not routed to panes ( e.g. hooks and the keybinding help ) are shown in the pane of messages.
+// After stopping, writes to panes go to the terminal as is.
+type dashboard struct {
+	r    *Reloader
+	mu   sync.Mutex
+	term *os.File
+	// stdout and stderr are replaced by pipe while rendering.
+	stdout *os.File
+	stderr *os.File
+	pipe   *os.File
+
+	width  int
+	height int
+
+	build   *dashboardPane
+	app     *dashboardPane
+	events  *dashboardPane
+	targets map[string]*dashboardTarget
+
+	dirty bool
+	// lastHeader is the header and the footer of the last rendering.
+	lastHeader string
+	suspended  bool
+	stopped    bool
+	stopOnce   sync.Once
+	done       chan struct{}
+}
+
+// EnableDashboard renders the terminal dashboard with panes of the build output, the application's output,
+// messages of rebirth and the status of targets instead of writing them interleaved to stdout.
+// It must be called before Run , and it returns error if stdout isn't a terminal.
+func (r *Reloader) EnableDashboard() error {
+	if !isTerminal(os.Stdout) {
+		return xerrors.New("stdout isn't a terminal")
+	}
+	if r.run.interactive() {
+		return xerrors.New("the dashboard can't share the terminal with run.interactive")
+	}
+	d := &dashboard{
+		r:       r,
+		term:    os.Stdout,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		build:   &dashboardPane{title: "build"},
+		app:     &dashboardPane{title: "app"},
+		events:  &dashboardPane{title: "rebirth"},
+		targets: map[string]*dashboardTarget{},
+		done:    make(chan struct{}),
+	}
+	logCfg := &Log{}
+	if r.cfg.Log != nil {
+		// colors and fields of the json format can't be rendered in the pane
+		logCfg.Level = r.cfg.Log.Level
+		logCfg.Prefix = r.cfg.Log.Prefix
+	}
+	logger, err := NewLogger(d.writer(d.events), logCfg)
+	if err != nil {
+		return xerrors.Errorf("failed to create logger: %w", err)
+	}
+	if err := d.start(); err != nil {
+		return xerrors.Errorf("failed to start dashboard: %w", err)
+	}
+	r.logger = logger
+	SetLogger(logger)
+	r.dashboard = d
+	r.OnBuildStart(func() { d.reset(d.build) })
+	addCleanup(d.stop)
+	return nil
+}
+
+// start redirects stdout and stderr to the pane of messages and starts rendering.
+func (d *dashboard) start() error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return xerrors.Errorf("failed to create pipe: %w", err)
+	}
+	d.pipe = writer
+	go io.Copy(d.writer(d.events), reader)
+	d.width, d.height = terminalSize()
+	d.redirect()
+	d.term.WriteString("\x1b[?1049h\x1b[?25l")
+	d.dirty = true
+	go d.render()
+	return nil
+}
+
+func (d *dashboard) redirect() {
+	os.Stdout = d.pipe
+	os.Stderr = d.pipe
+	log.SetOutput(d.pipe)
+}
+
+func (d *dashboard) restoreOutput() {
+	os.Stdout = d.stdout
+	os.Stderr = d.stderr
+	log.SetOutput(d.stderr)
+	d.term.WriteString("\x1b[?25h\x1b[?1049l")
+}
+
+// stop restores the terminal. Messages after stopping ( e.g. on shutdown ) are written to the terminal.
+func (d *dashboard) stop() {
+	if d == nil {
+		return
+	}
+	d.stopOnce.Do(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.stopped = true
+		close(d.done)
+		if !d.suspended {
+			d.restoreOutput()
+		}
+	})
+}
+
+// suspend restores the terminal while fn runs ( e.g. for running the editor ).
+func (d *dashboard) suspend(fn func() error) error {
+	if d == nil {
+		return fn()
+	}
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return fn()
+	}
+	d.suspended = true
+	d.restoreOutput()
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.suspended = false
+		if d.stopped {
+			return
+		}
+		d.redirect()
+		d.term.WriteString("\x1b[?1049h\x1b[?25l")
+		d.dirty = true
+	}()
+	return fn()
+}
+
+// writer returns the writer adding lines to the pane.
+func (d *dashboard) writer(pane *dashboardPane) io.Writer {
+	return &lineWriter{fn: func(line []byte) {
+		d.add(pane, line)
+	}}
+}
+
+func (d *dashboard) add(pane *dashboardPane, line []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		d.term.Write(line)
+		return
+	}
+	text := ansiEscapePattern.ReplaceAllString(strings.TrimRight(string(line), "\r\n"), "")
+	pane.lines = append(pane.lines, strings.Replace(text, "\t", "    ", -1))
+	if len(pane.lines) > dashboardMaxLines {
+		pane.lines = pane.lines[len(pane.lines)-dashboardMaxLines:]
+	}
+	d.dirty = true
+}
+
+// reset clears panes ( e.g. the build output on starting the next build ).
+func (d *dashboard) reset(panes ...*dashboardPane) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, pane := range panes {
+		pane.lines = nil
+	}
+	d.dirty = true
+}
+
+// clear clears all panes.
+func (d *dashboard) clear() {
+	d.reset(d.build, d.app, d.events)
+}
+
+// setTargetState updates the state of the target in the targets pane.
+func (d *dashboard) setTargetState(name, state, summary string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.targets[name] = &dashboardTarget{state: state, summary: summary, updatedAt: time.Now()}
+	d.dirty = true
+}
+
+// render redraws the screen on changes until stopping.
+func (d *dashboard) render() {
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+	ticker := time.NewTicker(dashboardRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-resized:
+			width, height := terminalSize()
+			d.mu.Lock()
+			d.width, d.height = width, height
+			d.dirty = true
+			d.mu.Unlock()
+		case <-ticker.C:
+		}
+		d.draw()
+	}
+}
+
+func (d *dashboard) draw() {
+	header := d.header()
+	footer := d.r.keyboard.summary()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// the header changes without writes to panes ( e.g. the state )
+	if (!d.dirty && header+footer == d.lastHeader) || d.stopped || d.suspended {
+		return
+	}
+	d.dirty = false
+	d.lastHeader = header + footer
+	lines := []string{"\x1b[7m" + fitLine(header, d.width) + colorReset}
+	rows := d.height - 2
+	if len(d.r.targets) > 0 {
+		targetLines := d.targetLines()
+		if len(targetLines) > rows/2 {
+			targetLines = targetLines[:rows/2]
+		}
+		lines = append(lines, targetLines...)
+		rows -= len(targetLines)
+	}
+	buildRows := rows / 4
+	eventRows := rows / 4
+	lines = append(lines, d.paneLines(d.build, buildRows)...)
+	lines = append(lines, d.paneLines(d.app, rows-buildRows-eventRows)...)
+	lines = append(lines, d.paneLines(d.events, eventRows)...)
+	// the last column of the last row isn't written not to scroll the screen
+	lines = append(lines, colorGray+fitLine(footer, d.width-1)+colorReset)
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\x1b[J")
+	d.term.WriteString(b.String())
+}
+
+// header returns the state of the application, the number of watched files and whether watching is paused.
+func (d *dashboard) header() string {
+	r := d.r
+	items := []string{"rebirth"}
+	if len(r.targets) > 0 {
+		items = append(items, fmt.Sprintf("%d targets", len(r.targets)))
+	} else if status := r.Status(); status.State != "" {
+		items = append(items, status.Short())
+	} else {
+		items = append(items, "starting")
+	}
+	if r.watchedFiles != nil {
+		items = append(items, fmt.Sprintf("%d files watched", r.watchedFiles()))
+	}
+	r.failureMu.Lock()
+	paused := r.paused
+	r.failureMu.Unlock()
+	if paused {
+		items = append(items, "paused")
+	}
+	return " " + strings.Join(items, " | ")
+}
+
+// targetLines returns the title and a line per target colored by the state.
+func (d *dashboard) targetLines() []string {
+	names := []string{}
+	width := 0
+	for name := range d.r.targets {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+	lines := []string{d.title("targets")}
+	for _, name := range names {
+		target, exists := d.targets[name]
+		if !exists {
+			lines = append(lines, fitLine(fmt.Sprintf(" %-*s  waiting", width, name), d.width))
+			continue
+		}
+		line := fmt.Sprintf(" %-*s  %-8s  %s  %s", width, name, target.state, target.updatedAt.Format("15:04:05"), target.summary)
+		lines = append(lines, targetStateColor(target.state)+fitLine(line, d.width)+colorReset)
+	}
+	return lines
+}
+
+func targetStateColor(state string) string {
+	switch state {
+	case StateRunning:
+		return colorGreen
+	case StateFailed:
+		return colorRed
+	case StateBuilding:
+		return colorYellow
+	}
+	return colorGray
+}
+
+// paneLines returns the title and the last lines of the pane filling rows.
+func (d *dashboard) paneLines(pane *dashboardPane, rows int) []string {
+	if rows <= 0 {
+		return nil
+	}
+	lines := []string{d.title(pane.title)}
+	body := pane.lines
+	if len(body) > rows-1 {
+		body = body[len(body)-(rows-1):]
+	}
+	for _, line := range body {
+		lines = append(lines, fitLine(line, d.width))
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func (d *dashboard) title(name string) string {
+	title := "── " + name + " "
+	if rest := d.width - len([]rune(title)); rest > 0 {
+		title += strings.Repeat("─", rest)
+	}
+	return "\x1b[1m" + fitLine(title, d.width) + colorReset
+}
+
+// fitLine truncates line to width runes.
+func fitLine(line string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
+
+// terminalSize returns columns and rows of the terminal ( default: 80x24 ).
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if err != nil {
+		return 80, 24
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 80, 24
+	}
+	rows, err := strconv.Atoi(fields[0])
+	if err != nil || rows <= 0 {
+		return 80, 24
+	}
+	cols, err := strconv.Atoi(fields[1])
+	if err != nil || cols <= 0 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+// suspendTerminal restores the terminal from the dashboard and the keybinding layer while fn runs.
+func (r *Reloader) suspendTerminal(fn func() error) error {
+	return r.dashboard.suspend(func() error {
+		return r.keyboard.suspend(fn)
+	})
+}
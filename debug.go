@@ -0,0 +1,90 @@
+package rebirth
+
+import (
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultDebugListen = ":2345"
+	defaultDlvPath     = "dlv"
+	// debugGCFlags disables optimizations and inlining for debugging.
+	debugGCFlags = "all=-N -l"
+)
+
+// debugger runs the application under the headless Delve server, so IDEs can attach to it on Listen .
+type debugger struct {
+	listen string
+	dlv    string
+}
+
+// Debug makes Run build the application without optimizations and run it under dlv exec --headless .
+// The debug session restarts with the application on each reload.
+// listen is the address of the Delve server ( default: :2345 ) and dlv is the path of dlv on the place running the application.
+func (r *Reloader) Debug(listen, dlv string) {
+	if listen == "" {
+		listen = defaultDebugListen
+	}
+	if dlv == "" {
+		dlv = defaultDlvPath
+	}
+	r.debugger = &debugger{listen: listen, dlv: dlv}
+}
+
+func (r *Reloader) isDebugMode() bool {
+	return r.debugger != nil
+}
+
+// command returns the command running binary with args under the Delve server.
+// --continue starts the application without waiting for the client, and --accept-multiclient keeps it running after detaching.
+func (d *debugger) command(binary string, args []string) (string, []string) {
+	dlvArgs := []string{
+		"exec",
+		"--headless",
+		"--listen=" + d.listen,
+		"--api-version=2",
+		"--accept-multiclient",
+		"--continue",
+		binary,
+	}
+	if len(args) > 0 {
+		dlvArgs = append(dlvArgs, "--")
+		dlvArgs = append(dlvArgs, args...)
+	}
+	return d.dlv, dlvArgs
+}
+
+// command returns the command running binary as the application. In debug mode, it runs under dlv .
+func (r *Reloader) command(binary string) (string, []string) {
+	if r.isDebugMode() {
+		return r.debugger.command(binary, r.runArgs())
+	}
+	if words := r.run.commandLine(binary); len(words) > 0 {
+		return words[0], append(words[1:], r.runArgs()...)
+	}
+	return binary, r.runArgs()
+}
+
+// gcflags returns -gcflags for building the application. debug mode disables optimizations instead of build.gcflags .
+func (r *Reloader) gcflags() string {
+	if r.isDebugMode() {
+		return debugGCFlags
+	}
+	return r.build.GCFlags
+}
+
+func (r *Reloader) checkDebugMode() error {
+	if !r.isDebugMode() {
+		return nil
+	}
+	if r.isTargetsMode() || r.isWasmMode() || r.isObserveMode() {
+		return xerrors.New("debug mode doesn't support targets, wasm and observer mode")
+	}
+	if r.run != nil && r.run.Command != "" {
+		return xerrors.New("debug mode doesn't support run.command")
+	}
+	if r.run != nil && r.run.Strategy != "" && r.run.Strategy != defaultReloadStrategy {
+		r.logger.Warnf("run.strategy %s is ignored in debug mode. the debug session restarts by %s", r.run.Strategy, defaultReloadStrategy)
+	}
+	r.logger.Infof("Delve server listens on %s for the application", r.debugger.listen)
+	return nil
+}
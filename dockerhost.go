@@ -0,0 +1,159 @@
+package rebirth
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultDockerContext = "default"
+
+// dockerContextMeta is the metadata of the context created by `docker context create` .
+type dockerContextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]*struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker")
+}
+
+// dockerContextName returns the context in the same order as docker CLI except host.docker_context taking precedence.
+// DOCKER_HOST overrides DOCKER_CONTEXT and the current context of ~/.docker/config.json .
+func dockerContextName(host *Host) string {
+	if host.DockerContext != "" {
+		return host.DockerContext
+	}
+	if os.Getenv("DOCKER_HOST") != "" {
+		return defaultDockerContext
+	}
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if err != nil {
+		return defaultDockerContext
+	}
+	var cfg struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg.CurrentContext == "" {
+		return defaultDockerContext
+	}
+	return cfg.CurrentContext
+}
+
+// SetDockerEndpoint applies the endpoint of the docker context ( e.g. Docker Desktop or a remote daemon ) to
+// DOCKER_HOST , DOCKER_CERT_PATH and DOCKER_TLS_VERIFY read by the Docker Engine API client and docker compose .
+// Nothing is changed for the default context.
+func SetDockerEndpoint(host *Host) error {
+	if host == nil || host.runtime() != defaultContainerRuntime {
+		return nil
+	}
+	name := dockerContextName(host)
+	if name == defaultDockerContext {
+		return nil
+	}
+	// contexts are stored by the digest of the name
+	digest := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(digest[:])
+	data, err := ioutil.ReadFile(filepath.Join(dockerConfigDir(), "contexts", "meta", id, "meta.json"))
+	if err != nil {
+		return xerrors.Errorf("docker context %s isn't found: %w", name, err)
+	}
+	var meta dockerContextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return xerrors.Errorf("failed to decode docker context %s: %w", name, err)
+	}
+	endpoint := meta.Endpoints["docker"]
+	if endpoint == nil || endpoint.Host == "" {
+		return xerrors.Errorf("docker context %s doesn't have docker endpoint", name)
+	}
+	if u, err := url.Parse(endpoint.Host); err == nil && u.Scheme == "ssh" {
+		return xerrors.Errorf(
+			"ssh endpoint of docker context %s isn't supported. forward the socket by `ssh -NL /tmp/docker.sock:/var/run/docker.sock %s` and set DOCKER_HOST=unix:///tmp/docker.sock",
+			name, u.Host,
+		)
+	}
+	os.Setenv("DOCKER_HOST", endpoint.Host)
+	os.Unsetenv("DOCKER_CERT_PATH")
+	os.Unsetenv("DOCKER_TLS_VERIFY")
+	tlsDir := filepath.Join(dockerConfigDir(), "contexts", "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil {
+		os.Setenv("DOCKER_CERT_PATH", tlsDir)
+		if !endpoint.SkipTLSVerify {
+			os.Setenv("DOCKER_TLS_VERIFY", "1")
+		}
+	}
+	logger().Debugf("use docker context %s ( %s )", name, endpoint.Host)
+	return nil
+}
+
+// isRemoteDockerHost returns true if the daemon of DOCKER_HOST runs on another machine,
+// so the project directory isn't shared with containers.
+func isRemoteDockerHost(dockerHost string) bool {
+	u, err := url.Parse(dockerHost)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	hostname := u.Hostname()
+	if hostname == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(hostname)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// isRemoteDocker returns true if the container runs on the remote daemon.
+func (r *Reloader) isRemoteDocker() bool {
+	return r.isDockerMode() && r.host.runtime() == defaultContainerRuntime && isRemoteDockerHost(os.Getenv("DOCKER_HOST"))
+}
+
+// copyFileToContainer copies src on localhost to dst on the container by docker cp .
+func copyFileToContainer(container, src, dst string, mode os.FileMode) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return xerrors.Errorf("failed to read %s: %w", src, err)
+	}
+	dir := path.Dir(dst)
+	if err := runOnContainer(container, "mkdir", "-p", dir); err != nil {
+		return xerrors.Errorf("failed to create %s on container: %w", dir, err)
+	}
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: path.Base(dst),
+		Mode: int64(mode.Perm()),
+		Size: int64(len(data)),
+	}); err != nil {
+		return xerrors.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return xerrors.Errorf("failed to write tar: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return xerrors.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := containerRuntime().CopyTo(context.Background(), container, dir, &archive); err != nil {
+		return xerrors.Errorf("failed to copy %s to %s on container: %w", src, dst, err)
+	}
+	return nil
+}
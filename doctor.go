@@ -0,0 +1,265 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	DiagnosisOK   = "ok"
+	DiagnosisWarn = "warn"
+	DiagnosisFail = "fail"
+
+	doctorTimeout = 10 * time.Second
+)
+
+// Diagnosis is the result of a check by `rebirth doctor` . Fix is the action for resolving the problem.
+type Diagnosis struct {
+	Name    string
+	Level   string
+	Message string
+	Fix     string
+}
+
+func diagnosisOK(name, format string, args ...interface{}) *Diagnosis {
+	return &Diagnosis{Name: name, Level: DiagnosisOK, Message: fmt.Sprintf(format, args...)}
+}
+
+func diagnosisWarn(name, fix, format string, args ...interface{}) *Diagnosis {
+	return &Diagnosis{Name: name, Level: DiagnosisWarn, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+func diagnosisFail(name, fix, format string, args ...interface{}) *Diagnosis {
+	return &Diagnosis{Name: name, Level: DiagnosisFail, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+// Diagnose checks the environment for running rebirth with rebirth.yml before starting it,
+// so problems are reported with fixes instead of failing in the middle of running.
+func Diagnose() []*Diagnosis {
+	diagnoses := []*Diagnosis{checkGoToolchain()}
+	cfg, d := checkConfig()
+	diagnoses = append(diagnoses, d, checkConfigDir())
+	running, stale := checkStaleFiles()
+	diagnoses = append(diagnoses, stale...)
+	if cfg == nil {
+		return diagnoses
+	}
+	r := NewReloader(cfg)
+	// host.compose_service is resolved by checkDocker
+	if r.isDockerMode() {
+		diagnoses = append(diagnoses, r.checkDocker()...)
+	}
+	if r.isSSHMode() {
+		diagnoses = append(diagnoses, r.checkSSH())
+	}
+	return append(diagnoses, r.checkPorts(running)...)
+}
+
+func checkGoToolchain() *Diagnosis {
+	const name = "go toolchain"
+	if _, err := exec.LookPath("go"); err != nil {
+		return diagnosisFail(name, "install Go from https://go.dev/dl/ and add it to PATH", "go isn't found in PATH")
+	}
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return diagnosisFail(name, "check the installation of Go ( e.g. GOROOT )", "failed to run go version: %v", err)
+	}
+	return diagnosisOK(name, "%s", strings.TrimSpace(string(out)))
+}
+
+func checkConfig() (*Config, *Diagnosis) {
+	const name = "config"
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, diagnosisWarn(name, "run `rebirth init` to create it", "%s isn't found", configPath)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, diagnosisFail(name, fmt.Sprintf("fix %s", configPath), "%s is invalid: %s", configPath, errorSummary(err))
+	}
+	return cfg, diagnosisOK(name, "%s is valid", configPath)
+}
+
+func checkConfigDir() *Diagnosis {
+	const name = "workspace"
+	fix := fmt.Sprintf("check the owner and the permission of %s ( e.g. sudo chown -R $USER %s )", configDir, configDir)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return diagnosisFail(name, fix, "failed to create %s: %v", configDir, err)
+	}
+	file, err := ioutil.TempFile(configDir, ".doctor")
+	if err != nil {
+		return diagnosisFail(name, fix, "%s isn't writable: %v", configDir, err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return diagnosisOK(name, "%s is writable", configDir)
+}
+
+// checkStaleFiles reports files left by rebirth that exited abnormally. It returns true if rebirth is running.
+func checkStaleFiles() (bool, []*Diagnosis) {
+	const name = "running rebirth"
+	if _, err := os.Stat(controlSocketPath); err != nil {
+		if _, err := os.Stat(statusPath); err == nil {
+			return false, []*Diagnosis{diagnosisWarn(name, fmt.Sprintf("rm %s", statusPath), "%s is left by rebirth that exited abnormally", statusPath)}
+		}
+		return false, []*Diagnosis{diagnosisOK(name, "rebirth isn't running")}
+	}
+	if err := NewControlClient().Do(http.MethodGet, "/ping", nil, nil); err != nil {
+		return false, []*Diagnosis{diagnosisWarn(name, fmt.Sprintf("rm %s %s", controlSocketPath, statusPath),
+			"%s is left by rebirth that exited abnormally", controlSocketPath)}
+	}
+	status, err := ReadStatusFile()
+	if err != nil || status.Pid == 0 {
+		return true, []*Diagnosis{diagnosisOK(name, "rebirth is running")}
+	}
+	return true, []*Diagnosis{diagnosisOK(name, "rebirth is running ( pid %d, %s )", status.Pid, status.Short())}
+}
+
+func (r *Reloader) checkDocker() []*Diagnosis {
+	name := r.host.runtime()
+	if err := SetContainerRuntime(r.host.Runtime); err != nil {
+		return []*Diagnosis{diagnosisFail(name, "set docker, podman or nerdctl to host.runtime", "%s", errorSummary(err))}
+	}
+	if err := SetDockerEndpoint(r.host); err != nil {
+		return []*Diagnosis{diagnosisFail(name, "fix host.docker_context or DOCKER_CONTEXT ( e.g. docker context ls )", "%s", errorSummary(err))}
+	}
+	rt := containerRuntime()
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	if err := rt.Ping(ctx); err != nil {
+		fix := "start Docker ( e.g. Docker Desktop ) or set DOCKER_HOST"
+		if name != defaultContainerRuntime {
+			fix = fmt.Sprintf("install %s and check `%s info` works", name, name)
+		}
+		return []*Diagnosis{diagnosisFail(name, fix, "%s isn't available: %s", name, errorSummary(err))}
+	}
+	diagnoses := []*Diagnosis{diagnosisOK(name, "%s is available", name)}
+	if err := ResolveComposeService(r.host); err != nil {
+		return append(diagnoses, diagnosisFail("container", fmt.Sprintf("%s up -d %s", strings.Join(rt.ComposeCommands()[0], " "), r.host.ComposeService), "%s", errorSummary(err)))
+	}
+	container := r.host.Docker
+	if exists, err := rt.Exists(ctx, container); err == nil && !exists && r.host.DockerImage != "" {
+		return append(diagnoses, diagnosisOK("container", "container %s will be created from %s", container, r.host.DockerImage))
+	}
+	info, err := rt.Inspect(ctx, container)
+	if err != nil {
+		return append(diagnoses, diagnosisFail("container", "create the container ( e.g. docker compose up -d ), set host.docker_image or fix host.docker", "container %s isn't found: %s", container, errorSummary(err)))
+	}
+	if !info.Running {
+		return append(diagnoses, diagnosisFail("container", fmt.Sprintf("%s start %s", name, container), "container %s isn't running", container))
+	}
+	diagnoses = append(diagnoses, diagnosisOK("container", "container %s is running", container))
+	return append(diagnoses, r.checkContainerPlatform(container)...)
+}
+
+// checkContainerPlatform checks the platform of the container for cross build conflicts with build.env .
+func (r *Reloader) checkContainerPlatform(container string) []*Diagnosis {
+	const name = "platform"
+	goos, goarch, err := containerPlatform(container)
+	if err != nil {
+		return []*Diagnosis{diagnosisFail(name, "set GOOS and GOARCH of the container to build.env", "failed to get GOOS/GOARCH of container %s: %s", container, errorSummary(err))}
+	}
+	for key, value := range map[string]string{"GOOS": goos, "GOARCH": goarch} {
+		if v, exists := r.build.Env[key]; exists && v != value {
+			return []*Diagnosis{diagnosisFail(name, fmt.Sprintf("remove %s from build.env", key),
+				"build.env %s=%s doesn't match %s of container %s", key, v, value, container)}
+		}
+	}
+	diagnoses := []*Diagnosis{diagnosisOK(name, "the application is built for %s/%s of container %s", goos, goarch, container)}
+	if r.build.Env["CGO_ENABLED"] != "0" && r.build.Env["CC"] == "" {
+		if _, err := crossCompilerEnv(goos, goarch); err != nil {
+			diagnoses = append(diagnoses, diagnosisWarn("cross compiler",
+				"brew install FiloSottile/musl-cross/musl-cross --with-aarch64 --with-arm-hf , or set CGO_ENABLED: 0 to build.env",
+				"%s", errorSummary(err)))
+		}
+	}
+	return diagnoses
+}
+
+func (r *Reloader) checkSSH() *Diagnosis {
+	const name = "ssh"
+	ssh := r.host.SSH
+	args := append([]string{"-o", "BatchMode=yes", "-o", fmt.Sprintf("ConnectTimeout=%d", int(doctorTimeout.Seconds()))}, ssh.sshArgs()...)
+	args = append(args, ssh.Host, "true")
+	if out, err := exec.Command("ssh", args...).CombinedOutput(); err != nil {
+		return diagnosisFail(name, fmt.Sprintf("check `ssh %s` works without a password prompt ( e.g. ssh-copy-id %s )", ssh.Host, ssh.Host),
+			"failed to connect to %s: %s", ssh.Host, strings.TrimSpace(string(out)))
+	}
+	return diagnosisOK(name, "%s is reachable", ssh.Host)
+}
+
+// checkPorts checks addresses listened by rebirth and the application aren't used by other processes.
+// They are skipped while rebirth is running because it uses them.
+func (r *Reloader) checkPorts(running bool) []*Diagnosis {
+	const name = "ports"
+	addrs := map[string]string{}
+	if r.run != nil {
+		for portName, value := range r.run.Ports {
+			if value != autoPort {
+				addrs["run.ports."+portName] = ":" + value
+			}
+		}
+		for socketName, value := range r.run.Sockets {
+			if _, isPortName := r.run.Ports[value]; !isPortName {
+				addrs["run.sockets."+socketName] = r.socketAddr(value)
+			}
+		}
+	}
+	if r.proxy != nil && r.proxy.Listen != "" {
+		addrs["proxy.listen"] = r.proxy.Listen
+	}
+	if r.cfg.Status != nil && r.cfg.Status.Addr != "" {
+		addrs["status.addr"] = r.cfg.Status.Addr
+	}
+	if r.isWasmMode() {
+		addrs["wasm.listen"] = r.wasm.listen()
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	if running {
+		return []*Diagnosis{diagnosisOK(name, "skipped because the running rebirth uses them")}
+	}
+	keys := []string{}
+	for key := range addrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	diagnoses := []*Diagnosis{}
+	for _, key := range keys {
+		if err := checkListen(addrs[key]); err != nil {
+			diagnoses = append(diagnoses, diagnosisFail(name,
+				fmt.Sprintf("stop the process using it ( e.g. lsof -i :%s ) or change %s", addrPort(addrs[key]), key),
+				"%s ( %s ) is already in use", addrs[key], key))
+		}
+	}
+	if len(diagnoses) == 0 {
+		return []*Diagnosis{diagnosisOK(name, "%d addresses are free", len(keys))}
+	}
+	return diagnoses
+}
+
+func checkListen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return xerrors.Errorf("failed to listen %s: %w", addr, err)
+	}
+	listener.Close()
+	return nil
+}
+
+func addrPort(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return port
+	}
+	return addr
+}
@@ -0,0 +1,212 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	buildModeLocal     = "local"
+	buildModeDocker    = "docker"
+	buildModeSSH       = "ssh"
+	buildModeRemote    = "remote"
+	buildModeWasm      = "wasm"
+	buildModeContainer = "container"
+)
+
+// driftEnvKeys are env variables making divergent binaries.
+var driftEnvKeys = []string{"GOOS", "GOARCH", "CGO_ENABLED", "CC", "CXX", "GOFLAGS", "GOAMD64", "GOARM64", "GOARM", "GO386"}
+
+// buildProfile is the effective build env of the build mode.
+type buildProfile struct {
+	Env       map[string]string `json:"env"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// buildProfiles are persisted to .rebirth/build_env.json for detecting drift of build env between builds.
+// Mode is the mode of the last build.
+type buildProfiles struct {
+	Mode     string                   `json:"mode"`
+	Profiles map[string]*buildProfile `json:"profiles"`
+}
+
+func buildProfilesPath() string {
+	return filepath.Join(cwd, configDir, "build_env.json")
+}
+
+func loadBuildProfiles() (*buildProfiles, error) {
+	profiles := &buildProfiles{Profiles: map[string]*buildProfile{}}
+	path := buildProfilesPath()
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(file, profiles); err != nil {
+		return nil, xerrors.Errorf("failed to decode %s: %w", path, err)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = map[string]*buildProfile{}
+	}
+	return profiles, nil
+}
+
+func (p *buildProfiles) save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("failed to encode build profiles: %w", err)
+	}
+	path := buildProfilesPath()
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// buildMode returns where and for what the application is built.
+func (r *Reloader) buildMode() string {
+	switch {
+	case r.isWasmMode():
+		return buildModeWasm
+	case r.isRemoteBuild():
+		return buildModeRemote
+	case r.isSSHMode():
+		return buildModeSSH
+	case r.isDockerMode():
+		return buildModeDocker
+	case r.isUsedDocker():
+		// rebirth itself runs on the container
+		return buildModeContainer
+	}
+	return buildModeLocal
+}
+
+// effectiveEnv returns env and flags affecting the built binary. Later env takes precedence like exec.Cmd .
+func (c *GoCommand) effectiveEnv() (map[string]string, error) {
+	env, err := c.buildEnv()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get build env: %w", err)
+	}
+	effective := map[string]string{}
+	for _, key := range driftEnvKeys {
+		if v := os.Getenv(key); v != "" {
+			effective[key] = v
+		}
+	}
+	for _, kv := range env {
+		v := strings.SplitN(kv, "=", 2)
+		if len(v) == 2 && containsString(driftEnvKeys, v[0]) {
+			effective[v[0]] = strings.TrimSpace(v[1])
+		}
+	}
+	flags := map[string]string{
+		"tags":     strings.Join(c.tags, ","),
+		"ldflags":  c.ldflags,
+		"gcflags":  c.gcflags,
+		"compiler": c.compiler,
+	}
+	for k, v := range flags {
+		if v != "" {
+			effective[k] = v
+		}
+	}
+	if out, err := exec.Command("go", "version").Output(); err == nil {
+		effective["go"] = strings.TrimSpace(strings.TrimPrefix(string(out), "go version "))
+	}
+	return effective, nil
+}
+
+// envDiff returns differences of env as `key: from -> to` .
+func envDiff(from, to map[string]string) []string {
+	keys := []string{}
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, exists := from[k]; !exists {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	diff := []string{}
+	for _, k := range keys {
+		if from[k] == to[k] {
+			continue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %s -> %s", k, envValue(from[k]), envValue(to[k])))
+	}
+	return diff
+}
+
+// indentLines returns lines following the message of the log.
+func indentLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString("\n  " + line)
+	}
+	return b.String()
+}
+
+func envValue(v string) string {
+	if v == "" {
+		return "(unset)"
+	}
+	return v
+}
+
+// checkBuildEnvDrift reports differences of the effective build env from the last build,
+// which make binaries different from the ones built by the last mode ( e.g. local -> docker ) or the last env of the mode.
+// If build.lock_mode is true, building in a mode different from the persisted one fails.
+func (r *Reloader) checkBuildEnvDrift(gocmd *GoCommand) error {
+	if r.buildEnvChecked {
+		return nil
+	}
+	mode := r.buildMode()
+	profiles, err := loadBuildProfiles()
+	if err != nil {
+		return xerrors.Errorf("failed to load build profiles: %w", err)
+	}
+	if r.build.LockMode && profiles.Mode != "" && profiles.Mode != mode {
+		return xerrors.Errorf(
+			"build mode is locked to %s by build.lock_mode but %s mode is used. remove %s for switching mode",
+			profiles.Mode, mode, buildProfilesPath(),
+		)
+	}
+	env, err := gocmd.effectiveEnv()
+	if err != nil {
+		return xerrors.Errorf("failed to get effective build env: %w", err)
+	}
+	if mode == buildModeRemote {
+		// the go toolchain of the remote machine isn't the local one
+		delete(env, "go")
+	}
+	if last, exists := profiles.Profiles[profiles.Mode]; exists && profiles.Mode != mode {
+		r.logger.Infof(
+			"Build mode changed: %s -> %s. the binary differs from the last %s build by%s",
+			profiles.Mode, mode, profiles.Mode, indentLines(envDiff(last.Env, env)),
+		)
+	}
+	if last, exists := profiles.Profiles[mode]; exists {
+		if diff := envDiff(last.Env, env); len(diff) > 0 {
+			r.logger.Warnf("build env of %s mode drifted from the last build%s", mode, indentLines(diff))
+		}
+	}
+	profiles.Mode = mode
+	profiles.Profiles[mode] = &buildProfile{Env: env, UpdatedAt: time.Now()}
+	if err := profiles.save(); err != nil {
+		return xerrors.Errorf("failed to save build profiles: %w", err)
+	}
+	r.buildEnvChecked = true
+	return nil
+}
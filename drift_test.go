@@ -0,0 +1,59 @@
+package rebirth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to map[string]string
+		expected []string
+	}{
+		{
+			name:     "same",
+			from:     map[string]string{"GOOS": "linux", "GOARCH": "amd64"},
+			to:       map[string]string{"GOOS": "linux", "GOARCH": "amd64"},
+			expected: []string{},
+		},
+		{
+			name:     "both empty",
+			from:     nil,
+			to:       map[string]string{},
+			expected: []string{},
+		},
+		{
+			name:     "changed",
+			from:     map[string]string{"GOOS": "darwin", "GOARCH": "arm64", "CGO_ENABLED": "1"},
+			to:       map[string]string{"GOOS": "linux", "GOARCH": "arm64", "CGO_ENABLED": "0"},
+			expected: []string{"CGO_ENABLED: 1 -> 0", "GOOS: darwin -> linux"},
+		},
+		{
+			name:     "added and removed",
+			from:     map[string]string{"GOAMD64": "v3", "tags": "dev"},
+			to:       map[string]string{"CC": "x86_64-linux-musl-gcc", "tags": "dev"},
+			expected: []string{"CC: (unset) -> x86_64-linux-musl-gcc", "GOAMD64: v3 -> (unset)"},
+		},
+		{
+			name:     "from nil",
+			from:     nil,
+			to:       map[string]string{"GOOS": "linux", "GOARCH": "amd64"},
+			expected: []string{"GOARCH: (unset) -> amd64", "GOOS: (unset) -> linux"},
+		},
+		{
+			name:     "empty value is unset",
+			from:     map[string]string{"GOFLAGS": ""},
+			to:       map[string]string{},
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if got := envDiff(test.from, test.to); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %q but got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package rebirth
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// parseEnvFile parses KEY=VALUE pairs of the dotenv file.
+// Empty lines , lines starting with # and `export` prefixes are ignored, and values quoted by ' or " are unquoted.
+func parseEnvFile(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+		kv := strings.SplitN(text, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, xerrors.Errorf("invalid line %d of %s: %q", line, path, scanner.Text())
+		}
+		env[name] = unquoteEnvValue(strings.TrimSpace(kv[1]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	return env, nil
+}
+
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 {
+		if quote := value[0]; (quote == '"' || quote == '\'') && value[len(value)-1] == quote {
+			value = value[1 : len(value)-1]
+			if quote == '"' {
+				value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+			}
+			return value
+		}
+	}
+	// inline comment of unquoted value
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return value
+}
+
+// mergeEnvFile returns env of the env file ( relative to dir ) overridden by env .
+func mergeEnvFile(dir, envFile string, env map[string]string) (map[string]string, error) {
+	if envFile == "" {
+		return env, nil
+	}
+	path := envFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	merged, err := parseEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range env {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// loadEnvFiles merges build.env_file and run.env_file into build.env and run.env .
+func (cfg *Config) loadEnvFiles(dir string) error {
+	if cfg.Build != nil {
+		env, err := mergeEnvFile(dir, cfg.Build.EnvFile, cfg.Build.Env)
+		if err != nil {
+			return xerrors.Errorf("failed to load build.env_file: %w", err)
+		}
+		cfg.Build.Env = env
+	}
+	if cfg.Run != nil {
+		env, err := mergeEnvFile(dir, cfg.Run.EnvFile, cfg.Run.Env)
+		if err != nil {
+			return xerrors.Errorf("failed to load run.env_file: %w", err)
+		}
+		cfg.Run.Env = env
+	}
+	return nil
+}
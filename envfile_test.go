@@ -0,0 +1,106 @@
+package rebirth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{
+		{
+			name:     "pairs",
+			content:  "APP_ENV=dev\nPORT=8080\n",
+			expected: map[string]string{"APP_ENV": "dev", "PORT": "8080"},
+		},
+		{
+			name:     "comments and blank lines",
+			content:  "# comment\n\n  \nAPP_ENV=dev # inline comment\n  # indented comment\nURL=http://localhost/#top\n",
+			expected: map[string]string{"APP_ENV": "dev", "URL": "http://localhost/#top"},
+		},
+		{
+			name:     "export",
+			content:  "export APP_ENV=dev\nexport  PORT = 8080\n",
+			expected: map[string]string{"APP_ENV": "dev", "PORT": "8080"},
+		},
+		{
+			name:     "double quotes",
+			content:  `MESSAGE="hello # world"` + "\n" + `ESCAPED="a\nb \"c\" d\\n"` + "\n",
+			expected: map[string]string{"MESSAGE": "hello # world", "ESCAPED": "a\nb \"c\" d\\n"},
+		},
+		{
+			name:     "single quotes",
+			content:  `RAW='a\nb "c" # d'` + "\n",
+			expected: map[string]string{"RAW": `a\nb "c" # d`},
+		},
+		{
+			name:     "empty and unbalanced values",
+			content:  "EMPTY=\nQUOTED=\"\"\nHALF=\"abc\nEQUALS=a=b\n",
+			expected: map[string]string{"EMPTY": "", "QUOTED": "", "HALF": `"abc`, "EQUALS": "a=b"},
+		},
+		{
+			name:     "crlf",
+			content:  "APP_ENV=dev\r\nPORT=8080\r\n",
+			expected: map[string]string{"APP_ENV": "dev", "PORT": "8080"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			path, cleanup := writeEnvFile(t, test.content)
+			defer cleanup()
+			got, err := parseEnvFile(path)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %#v but got %#v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseEnvFileError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "without =", content: "APP_ENV\n"},
+		{name: "empty name", content: "=dev\n"},
+		{name: "space in name", content: "APP ENV=dev\n"},
+		{name: "export only", content: "export\n"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			path, cleanup := writeEnvFile(t, test.content)
+			defer cleanup()
+			if _, err := parseEnvFile(path); err == nil {
+				t.Fatalf("expected error for %q", test.content)
+			}
+		})
+	}
+	if _, err := parseEnvFile(filepath.Join("testdata", "not-found.env")); err == nil {
+		t.Fatal("expected error for the missing file")
+	}
+}
+
+// writeEnvFile writes content to .env in the temporary directory, which is removed by the returned function.
+func writeEnvFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "rebirth-envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ".env")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,153 @@
+package rebirth
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// BuildEvent is passed to callbacks of OnBuildSuccess .
+type BuildEvent struct {
+	Generation int
+	Binary     string
+	Duration   time.Duration
+}
+
+// RestartEvent is passed to callbacks of OnRestart .
+// Generation is the restarted generation. It's older than the latest build on rollback.
+type RestartEvent struct {
+	Generation int
+	Binary     string
+}
+
+// ProcessExitEvent is passed to callbacks of OnProcessExit .
+// Stopped is true if the process is stopped by rebirth ( e.g. for restarting ).
+type ProcessExitEvent struct {
+	Pid     int
+	Status  string
+	Stopped bool
+}
+
+// eventHandlers are callbacks for lifecycle events registered by applications embedding rebirth.
+type eventHandlers struct {
+	buildStart   []func()
+	buildSuccess []func(*BuildEvent)
+	buildError   []func(error)
+	restart      []func(*RestartEvent)
+	processExit  []func(*ProcessExitEvent)
+	configReload []func(*Config)
+}
+
+// OnBuildStart adds callback called when building the application starts.
+func (r *Reloader) OnBuildStart(callback func()) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.buildStart = append(r.events.buildStart, callback)
+}
+
+// OnBuildSuccess adds callback called when the application is built successfully.
+func (r *Reloader) OnBuildSuccess(callback func(*BuildEvent)) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.buildSuccess = append(r.events.buildSuccess, callback)
+}
+
+// OnBuildError adds callback called when building the application fails.
+func (r *Reloader) OnBuildError(callback func(error)) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.buildError = append(r.events.buildError, callback)
+}
+
+// OnRestart adds callback called when the application is restarted by the new binary ( or rolled back ).
+func (r *Reloader) OnRestart(callback func(*RestartEvent)) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.restart = append(r.events.restart, callback)
+}
+
+// OnProcessExit adds callback called when the process of the application exits.
+// On the container, it's called only for exits without stop request by rebirth.
+func (r *Reloader) OnProcessExit(callback func(*ProcessExitEvent)) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.processExit = append(r.events.processExit, callback)
+}
+
+// OnConfigReload adds callback called with the new config when rebirth.yml is reloaded without restarting rebirth.
+func (r *Reloader) OnConfigReload(callback func(*Config)) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events.configReload = append(r.events.configReload, callback)
+}
+
+func (r *Reloader) emitBuildStart() {
+	r.eventsMu.Lock()
+	callbacks := r.events.buildStart
+	r.eventsMu.Unlock()
+	for _, callback := range callbacks {
+		callback()
+	}
+}
+
+func (r *Reloader) emitBuildResult(start time.Time, err error) {
+	r.metrics.recordBuild(start, err)
+	r.notifyBuildResult(err)
+	r.eventsMu.Lock()
+	successCallbacks := r.events.buildSuccess
+	errorCallbacks := r.events.buildError
+	r.eventsMu.Unlock()
+	if err != nil {
+		for _, callback := range errorCallbacks {
+			callback(err)
+		}
+		return
+	}
+	event := &BuildEvent{Generation: r.generation, Binary: buildPath, Duration: time.Since(start)}
+	for _, callback := range successCallbacks {
+		callback(event)
+	}
+}
+
+func (r *Reloader) emitRestart(binary string) {
+	r.metrics.recordRestart()
+	r.eventsMu.Lock()
+	callbacks := r.events.restart
+	r.eventsMu.Unlock()
+	if len(callbacks) == 0 {
+		return
+	}
+	event := &RestartEvent{Generation: r.restartedGeneration(binary), Binary: binary}
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+func (r *Reloader) emitProcessExit(event *ProcessExitEvent) {
+	r.eventsMu.Lock()
+	callbacks := r.events.processExit
+	r.eventsMu.Unlock()
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+func (r *Reloader) emitConfigReload(cfg *Config) {
+	r.eventsMu.Lock()
+	callbacks := r.events.configReload
+	r.eventsMu.Unlock()
+	for _, callback := range callbacks {
+		callback(cfg)
+	}
+}
+
+// restartedGeneration returns the generation of binary. It's older than the latest build on rollback.
+func (r *Reloader) restartedGeneration(binary string) int {
+	if binary != buildPath {
+		// rollback to the artifact of the generation
+		if gen, err := strconv.Atoi(filepath.Base(binary)); err == nil {
+			return gen
+		}
+	}
+	return r.generation
+}
@@ -0,0 +1,221 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// failurePromptBuilds is the number of consecutive build failures for showing the failure prompt.
+	failurePromptBuilds = 2
+	// failurePromptCrashes is the number of crashes within failurePromptCrashWindow regarded as crash-loop.
+	failurePromptCrashes     = 3
+	failurePromptCrashWindow = time.Minute
+)
+
+var (
+	// e.g. ./main.go:10:2: undefined: foo
+	buildErrorLocationPattern = regexp.MustCompile(`^(\S+\.go):(\d+)(?::\d+)?: `)
+	// e.g. \t/path/to/main.go:10 +0x1d
+	traceLocationPattern = regexp.MustCompile(`^\s+(\S+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+// errorLocation is the file and line of the last error.
+type errorLocation struct {
+	file string
+	line int
+}
+
+func (l *errorLocation) String() string {
+	return fmt.Sprintf("%s:%d", l.file, l.line)
+}
+
+// buildErrorLocation finds the first error location of the build output.
+func buildErrorLocation(lines []string) *errorLocation {
+	for _, line := range lines {
+		matches := buildErrorLocationPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		return newErrorLocation(matches[1], matches[2])
+	}
+	return nil
+}
+
+// crashLocation finds the first frame of the project in the panic trace.
+func crashLocation(lines []string) *errorLocation {
+	for _, line := range strings.Split(panicTrace(lines), "\n") {
+		matches := traceLocationPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		file := matches[1]
+		if filepath.IsAbs(file) && !containsPath(cwd, file) {
+			// frame of the standard library or dependencies
+			continue
+		}
+		return newErrorLocation(file, matches[2])
+	}
+	return nil
+}
+
+func newErrorLocation(file, line string) *errorLocation {
+	loc := &errorLocation{file: file}
+	fmt.Sscanf(line, "%d", &loc.line)
+	return loc
+}
+
+// recordBuildResult counts consecutive build failures and shows the failure prompt if the build fails repeatedly.
+func (r *Reloader) recordBuildResult(err error) {
+	r.failureMu.Lock()
+	if err == nil {
+		r.buildFailures = 0
+		r.failureMu.Unlock()
+		r.keyboard.closePrompt()
+		return
+	}
+	r.buildFailures++
+	failures := r.buildFailures
+	r.lastError = buildErrorLocation(r.buildTail.Lines())
+	r.failureMu.Unlock()
+	if failures >= failurePromptBuilds {
+		r.showFailurePrompt(fmt.Sprintf("Build failed %d times in a row", failures))
+	}
+}
+
+// recordCrashForPrompt shows the failure prompt if the application crash-loops.
+func (r *Reloader) recordCrashForPrompt(c *crash) {
+	now := time.Now()
+	r.failureMu.Lock()
+	crashes := []time.Time{}
+	for _, t := range r.crashes {
+		if now.Sub(t) < failurePromptCrashWindow {
+			crashes = append(crashes, t)
+		}
+	}
+	crashes = append(crashes, now)
+	r.crashes = crashes
+	if loc := crashLocation(c.output); loc != nil {
+		r.lastError = loc
+	}
+	r.failureMu.Unlock()
+	if len(crashes) >= failurePromptCrashes {
+		r.showFailurePrompt(fmt.Sprintf("Application crashed %d times within %s", len(crashes), failurePromptCrashWindow))
+	}
+}
+
+// showFailurePrompt shows quick actions for the failure in TTY mode.
+func (r *Reloader) showFailurePrompt(title string) {
+	if !r.keyboard.isStarted() {
+		return
+	}
+	r.failureMu.Lock()
+	loc := r.lastError
+	goodGeneration := r.goodGeneration
+	r.failureMu.Unlock()
+	actions := []*keyBinding{
+		{key: 'r', desc: "retry", fn: r.retry},
+	}
+	if loc != nil {
+		actions = append(actions, &keyBinding{
+			key:  'e',
+			desc: fmt.Sprintf("open %s in $EDITOR", loc),
+			fn:   func() { r.openInEditor(loc) },
+		})
+	}
+	actions = append(actions, &keyBinding{key: 'p', desc: "pause watching", fn: r.togglePause})
+	if goodGeneration != 0 && r.artifacts.exists(goodGeneration) {
+		actions = append(actions, &keyBinding{
+			key:  'b',
+			desc: fmt.Sprintf("rollback to generation %d", goodGeneration),
+			fn:   r.rollback,
+		})
+	}
+	r.keyboard.showPrompt(title, actions)
+}
+
+func (r *Reloader) retry() {
+	r.logger.Infof("Retrying...")
+	if err := r.Reload(); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
+// openInEditor opens loc in $EDITOR . The editors accepting file:line are detected by the name.
+func (r *Reloader) openInEditor(loc *errorLocation) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		r.logger.Warnf("$EDITOR is not set")
+		return
+	}
+	args := strings.Fields(editor)
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders":
+		args = append(args, "-g", loc.String())
+	case "subl", "zed":
+		args = append(args, loc.String())
+	default:
+		// vi, emacs, nano and others
+		args = append(args, fmt.Sprintf("+%d", loc.line), loc.file)
+	}
+	if err := r.suspendTerminal(func() error {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run %s: %w", editor, err)
+		}
+		return nil
+	}); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
+// togglePause pauses or resumes watching. Changes during pausing are applied once on resuming.
+func (r *Reloader) togglePause() {
+	r.failureMu.Lock()
+	r.paused = !r.paused
+	paused := r.paused
+	changed := r.pausedChanges
+	r.pausedChanges = false
+	r.failureMu.Unlock()
+	if paused {
+		r.logger.Infof("Watching is paused. press p to resume")
+		return
+	}
+	r.logger.Infof("Watching is resumed")
+	if changed {
+		r.retry()
+	}
+}
+
+// skipIfPaused records changes while watching is paused and returns true.
+func (r *Reloader) skipIfPaused() bool {
+	r.failureMu.Lock()
+	defer r.failureMu.Unlock()
+	if r.paused {
+		r.pausedChanges = true
+	}
+	return r.paused
+}
+
+// rollback restarts the previous good generation.
+func (r *Reloader) rollback() {
+	r.lockReload()
+	defer r.unlockReload()
+	r.logger.Infof("Rolling back to generation %d...", r.goodGeneration)
+	if err := r.restart(r.artifacts.path(r.goodGeneration)); err != nil {
+		r.logger.Errorf("%v", err)
+		return
+	}
+	r.rolledBack()
+	r.logger.Infof("Rolled back to generation %d", r.goodGeneration)
+}
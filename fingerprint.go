@@ -0,0 +1,192 @@
+package rebirth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fingerprintAssets records content hashes of restart-only assets ( host.sync and watch.migrations.dir ).
+func (r *Reloader) fingerprintAssets() {
+	dirs := []string{}
+	if r.isSyncEnabled() {
+		for _, rule := range syncRules(r.host) {
+			dirs = append(dirs, rule.Src)
+		}
+	}
+	if migrations := r.migrations(); migrations != nil {
+		dirs = append(dirs, migrations.Dir)
+	}
+	r.fingerprintMu.Lock()
+	defer r.fingerprintMu.Unlock()
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				r.fingerprints[filepath.Clean(path)] = string(fileHash(path))
+			}
+			return nil
+		})
+	}
+}
+
+// changedAssets returns files whose content differs from the recorded hash, and their new hashes for recordAssets.
+// Touched files without modification ( e.g. timestamps updated by git checkout ) are removed.
+// Removed files have the empty hash.
+func (r *Reloader) changedAssets(files []string) ([]string, map[string]string) {
+	r.fingerprintMu.Lock()
+	defer r.fingerprintMu.Unlock()
+	changed := []string{}
+	hashes := map[string]string{}
+	for _, file := range files {
+		path := filepath.Clean(file)
+		if _, err := os.Stat(path); err != nil {
+			// removed
+			changed = append(changed, file)
+			hashes[path] = ""
+			continue
+		}
+		hash := string(fileHash(path))
+		if prev, exists := r.fingerprints[path]; exists && prev == hash {
+			continue
+		}
+		changed = append(changed, file)
+		hashes[path] = hash
+	}
+	return changed, hashes
+}
+
+// recordAssets records hashes by changedAssets after the assets are synced or migrated.
+// Hashes aren't recorded on failure, so the next change of the files isn't skipped.
+func (r *Reloader) recordAssets(hashes map[string]string) {
+	r.fingerprintMu.Lock()
+	defer r.fingerprintMu.Unlock()
+	for path, hash := range hashes {
+		if hash == "" {
+			delete(r.fingerprints, path)
+		} else {
+			r.fingerprints[path] = hash
+		}
+	}
+}
+
+// sourceFiles returns watched go files, go.mod and go.sum .
+func (r *Reloader) sourceFiles() []string {
+	files := []string{"go.mod", "go.sum"}
+	watcher := &Watcher{cfg: r.watch}
+	for _, dir := range watcher.watchPaths() {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		for _, match := range matches {
+			if !strings.HasSuffix(match, "_test.go") {
+				files = append(files, match)
+			}
+		}
+	}
+	return files
+}
+
+// hashSources returns content hashes of files before building. All source files are hashed if files are unknown.
+// Removed files have the empty hash.
+func (r *Reloader) hashSources(files []string) map[string]string {
+	if len(files) == 0 {
+		files = r.sourceFiles()
+	}
+	hashes := map[string]string{}
+	for _, file := range files {
+		hashes[filepath.Clean(file)] = string(fileHash(file))
+	}
+	return hashes
+}
+
+// recordBuiltSources records hashes by hashSources as the last successful build.
+// Files changed while building are compared with the content before building, so the next reload isn't skipped.
+func (r *Reloader) recordBuiltSources(hashes map[string]string) {
+	r.fingerprintMu.Lock()
+	defer r.fingerprintMu.Unlock()
+	for path, hash := range hashes {
+		if hash == "" {
+			delete(r.builtSources, path)
+		} else {
+			r.builtSources[path] = hash
+		}
+	}
+}
+
+// changedSources returns files whose content differs from the last successful build.
+// Files saved without modification ( e.g. temporary saves of editors and touch ) are removed.
+// Changes reverted to the built content are removed too because the running binary is built from it.
+func (r *Reloader) changedSources(files []string) []string {
+	r.fingerprintMu.Lock()
+	defer r.fingerprintMu.Unlock()
+	changed := []string{}
+	for _, file := range files {
+		path := filepath.Clean(file)
+		built, exists := r.builtSources[path]
+		if _, err := os.Stat(path); err != nil {
+			if exists {
+				changed = append(changed, file)
+			}
+			continue
+		}
+		if !exists || built != string(fileHash(path)) {
+			changed = append(changed, file)
+		}
+	}
+	return changed
+}
+
+// restartFingerprint returns the hash of binary, args and env for running it.
+// The application isn't restarted if it's the same as the running process.
+func (r *Reloader) restartFingerprint(binary string) string {
+	hash := binaryHash(binary)
+	if hash == nil {
+		return ""
+	}
+	env := r.runEnv()
+	sort.Strings(env)
+	h := sha256.New()
+	h.Write(hash)
+	for _, arg := range append(r.runArgs(), env...) {
+		io.WriteString(h, arg)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// binaryHash returns the content ID of the build ID of binary. The build ID differs for each change of the main package
+// ( e.g. comments ) even if the code is identical, and the content ID is the hash of the binary without it.
+// The hash of the whole file is used if binary has no build ID ( e.g. built by tinygo or compressed by build.transform ).
+func binaryHash(binary string) []byte {
+	out, err := exec.Command("go", "tool", "buildid", binary).Output()
+	if err != nil {
+		return fileHash(binary)
+	}
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return fileHash(binary)
+	}
+	return []byte(id[strings.LastIndex(id, "/")+1:])
+}
+
+// isSameAsRunning returns true if the built binary is identical to the binary of the running process,
+// and it runs with the same args and env.
+func (r *Reloader) isSameAsRunning(binary string) bool {
+	if r.runningFingerprint == "" || !r.isRunning() {
+		return false
+	}
+	return r.restartFingerprint(binary) == r.runningFingerprint
+}
+
+// canSkipRestart returns true if restarting is needed only for the binary changed by files.
+// Manual reloads ( unknown files ), migrations and synced assets always restart the application.
+func (r *Reloader) canSkipRestart(files []string, migrate bool) bool {
+	if len(files) == 0 || migrate {
+		return false
+	}
+	assets, _ := r.splitSyncFiles(files)
+	return len(assets) == 0
+}
@@ -0,0 +1,141 @@
+package rebirth
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Focus starts building immediately for the file saved by the editor
+// without waiting for the file system event and debounce.
+// The following event for the file is skipped if the file isn't modified after Focus.
+func (r *Reloader) Focus(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return xerrors.Errorf("failed to get absolute path from %s: %w", path, err)
+	}
+	r.focusMu.Lock()
+	r.focused[absPath] = time.Now()
+	r.focusMu.Unlock()
+	r.logger.Infof("Focus: %s", path)
+	if _, err := r.wakeUp(); err != nil {
+		return xerrors.Errorf("failed to wake up: %w", err)
+	}
+	if err := r.Reload(); err != nil {
+		return xerrors.Errorf("failed to reload: %w", err)
+	}
+	return nil
+}
+
+// ReloadFiles reloads for the changed files. It skips reloading if all files are already built by Focus.
+func (r *Reloader) ReloadFiles(files []string) error {
+	r.logger.Debugf("Changed: %v", files)
+	if len(files) > 0 && r.isBuiltByFocus(files) {
+		return nil
+	}
+	if len(files) > 0 && r.isGenerated(files) {
+		r.logger.Debugf("Skipped files generated by go generate: %v", files)
+		return nil
+	}
+	files, configChanged := splitSelfConfig(files)
+	if configChanged {
+		if err := r.reloadSelfConfig(); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+		if len(files) == 0 {
+			return nil
+		}
+	}
+	if r.skipIfPaused() {
+		return nil
+	}
+	if _, err := r.wakeUp(); err != nil {
+		return xerrors.Errorf("failed to wake up: %w", err)
+	}
+	if r.isObserveMode() {
+		return r.checkBuild()
+	}
+	configs, files := r.splitConfigFiles(files)
+	if len(configs) > 0 {
+		if err := r.reloadConfigs(configs); err != nil {
+			return xerrors.Errorf("failed to reload configs: %w", err)
+		}
+		if len(files) == 0 {
+			// the application reloads configs and templates by itself
+			return nil
+		}
+	}
+	if r.isTargetsMode() {
+		r.reloadTargetFiles(files)
+		return nil
+	}
+	assets, others := r.splitSyncFiles(files)
+	migrations, others := r.splitMigrationFiles(others)
+	assets, assetHashes := r.changedAssets(assets)
+	migrations, migrationHashes := r.changedAssets(migrations)
+	others = r.changedSources(others)
+	if len(files) > 0 && len(assets) == 0 && len(migrations) == 0 && len(others) == 0 {
+		r.logger.Infof("Skipped restarting: content of the changed files is unchanged from the last build")
+		return nil
+	}
+	if len(assets) > 0 {
+		if err := r.syncFiles(assets); err != nil {
+			return xerrors.Errorf("failed to sync assets: %w", err)
+		}
+		r.recordAssets(assetHashes)
+	}
+	// assets and migrations only need restarting
+	build := len(files) == 0 || len(others) > 0
+	if build {
+		// the build must not use stale generated code
+		if err := r.generate(r.generatePackages(others)); err != nil {
+			return xerrors.Errorf("failed to generate: %w", err)
+		}
+	}
+	if err := r.reloadForFiles(files, build, len(migrations) > 0); err != nil {
+		return xerrors.Errorf("failed to reload: %w", err)
+	}
+	r.recordAssets(migrationHashes)
+	return nil
+}
+
+func (r *Reloader) isBuiltByFocus(files []string) bool {
+	r.focusMu.Lock()
+	defer r.focusMu.Unlock()
+	for _, file := range files {
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			return false
+		}
+		focusedAt, exists := r.focused[absPath]
+		if !exists {
+			return false
+		}
+		info, err := os.Stat(absPath)
+		if err != nil || info.ModTime().After(focusedAt) {
+			return false
+		}
+	}
+	for _, file := range files {
+		absPath, _ := filepath.Abs(file)
+		delete(r.focused, absPath)
+	}
+	return true
+}
+
+func (r *Reloader) handleFocus(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Query().Get("path")
+	if path == "" {
+		writeControlResponse(w, nil, xerrors.New("path must be specified"))
+		return
+	}
+	go func() {
+		if err := r.Focus(path); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+	}()
+	writeControlResponse(w, map[string]string{"path": path}, nil)
+}
@@ -0,0 +1,159 @@
+package rebirth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const freezeTimeFormat = "15:04"
+
+// freezeWindow is a daily time window like 12:00-13:00 .
+// If end is earlier than start, the window continues to the next day.
+type freezeWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+func parseFreezeWindow(src string) (*freezeWindow, error) {
+	times := strings.Split(src, "-")
+	if len(times) != 2 {
+		return nil, xerrors.Errorf("invalid freeze window %q. window must be HH:MM-HH:MM", src)
+	}
+	parsed := make([]time.Duration, 2)
+	for i, v := range times {
+		t, err := time.Parse(freezeTimeFormat, strings.TrimSpace(v))
+		if err != nil {
+			return nil, xerrors.Errorf("invalid freeze window %q: %w", src, err)
+		}
+		parsed[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return &freezeWindow{start: parsed[0], end: parsed[1]}, nil
+}
+
+// until returns the end of the window if now is in the window, otherwise returns zero time.
+func (w *freezeWindow) until(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+	if w.start <= w.end {
+		if w.start <= offset && offset < w.end {
+			return midnight.Add(w.end)
+		}
+		return time.Time{}
+	}
+	if offset >= w.start {
+		return midnight.Add(24 * time.Hour).Add(w.end)
+	}
+	if offset < w.end {
+		return midnight.Add(w.end)
+	}
+	return time.Time{}
+}
+
+// Freeze suppresses reloading for d. Changes during freezing are applied once at the end.
+// If d is zero, freezing by Freeze is canceled.
+func (r *Reloader) Freeze(d time.Duration) {
+	r.freezeMu.Lock()
+	if d == 0 {
+		r.freezeUntil = time.Time{}
+	} else {
+		r.freezeUntil = time.Now().Add(d)
+	}
+	r.freezeMu.Unlock()
+	if d == 0 {
+		r.logger.Infof("Unfrozen reloading")
+		r.applyFrozenReload()
+		return
+	}
+	r.logger.Infof("Reloading is frozen until %s", r.freezeUntil.Format(time.RFC3339))
+}
+
+// frozenUntil returns the end of freezing. If reloading isn't frozen, returns zero time.
+func (r *Reloader) frozenUntil(now time.Time) time.Time {
+	until := time.Time{}
+	if now.Before(r.freezeUntil) {
+		until = r.freezeUntil
+	}
+	if r.watch == nil {
+		return until
+	}
+	for _, src := range r.watch.Freeze {
+		window, err := parseFreezeWindow(src)
+		if err != nil {
+			continue
+		}
+		if end := window.until(now); end.After(until) {
+			until = end
+		}
+	}
+	return until
+}
+
+// deferReloadIfFrozen records reloading request if reloading is frozen and returns true.
+// If migrate is true, watch.migrations.hook is run at the end too.
+func (r *Reloader) deferReloadIfFrozen(migrate bool) bool {
+	r.freezeMu.Lock()
+	defer r.freezeMu.Unlock()
+	now := time.Now()
+	until := r.frozenUntil(now)
+	if until.IsZero() {
+		return false
+	}
+	if !r.pendingReload {
+		r.logger.Infof("Reloading is frozen until %s. changes are applied at the end", until.Format(freezeTimeFormat))
+	}
+	r.pendingReload = true
+	if migrate {
+		r.pendingMigrate = true
+	}
+	if r.freezeTimer != nil {
+		r.freezeTimer.Stop()
+	}
+	r.freezeTimer = time.AfterFunc(until.Sub(now), r.applyFrozenReload)
+	return true
+}
+
+func (r *Reloader) applyFrozenReload() {
+	r.freezeMu.Lock()
+	pending := r.pendingReload
+	migrate := r.pendingMigrate
+	r.pendingReload = false
+	r.pendingMigrate = false
+	r.freezeMu.Unlock()
+	if !pending {
+		return
+	}
+	r.logger.Infof("Applying changes recorded while reloading was frozen")
+	if err := r.reloadFor(true, migrate); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
+func (r *Reloader) handleFreeze(w http.ResponseWriter, req *http.Request) {
+	d, err := parseFreezeDuration(req.URL.Query().Get("duration"))
+	if err != nil {
+		writeControlResponse(w, nil, err)
+		return
+	}
+	r.Freeze(d)
+	r.freezeMu.Lock()
+	until := r.freezeUntil
+	r.freezeMu.Unlock()
+	writeControlResponse(w, map[string]time.Time{"until": until}, nil)
+}
+
+func parseFreezeDuration(src string) (time.Duration, error) {
+	if src == "off" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(src)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid duration %q: %w", src, err)
+	}
+	if d <= 0 {
+		return 0, xerrors.Errorf("duration must be positive: %s", src)
+	}
+	return d, nil
+}
@@ -0,0 +1,325 @@
+package rebirth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// fuzzCorpusHeader is the first line of corpus files encoded by go test -fuzz .
+	fuzzCorpusHeader = "go test fuzz v1"
+	fuzzStopTimeout  = 10 * time.Second
+	// defaultFuzzMinimizeTime is the time the fuzzer spends minimizing each new input before adding it to the corpus.
+	defaultFuzzMinimizeTime = "60s"
+)
+
+// FuzzRunner runs the fuzz test by go test -fuzz and restarts it when the code under test changes.
+// The corpus generated by the fuzzer is preserved in .rebirth/fuzz across runs ( even after go clean -fuzzcache ).
+// Each new entry is minimized by the fuzzer by -fuzzminimizetime , and entries with the same content
+// and entries which aren't corpus files are removed on saving.
+type FuzzRunner struct {
+	gocmd *GoCommand
+	pkg   string
+	fuzz  string
+	args  []string
+
+	mu         sync.Mutex
+	cmd        *Command
+	importPath string
+	dirs       map[string]bool
+}
+
+// NewFuzzRunner creates FuzzRunner running the fuzz test fuzz ( e.g. FuzzParse ) in pkg ( e.g. ./parser ) by gocmd .
+// args are passed to go test ( e.g. -fuzztime , -parallel ).
+func NewFuzzRunner(gocmd *GoCommand, pkg, fuzz string, args []string) *FuzzRunner {
+	return &FuzzRunner{gocmd: gocmd, pkg: pkg, fuzz: fuzz, args: args}
+}
+
+func (f *FuzzRunner) corpusDir() string {
+	return filepath.Join(cwd, configDir, "fuzz", filepath.FromSlash(f.importPath), f.fuzz)
+}
+
+// cacheDir returns the directory where go test -fuzz writes the generated corpus.
+func (f *FuzzRunner) cacheDir() (string, error) {
+	out, err := exec.Command("go", "env", "GOCACHE").Output()
+	if err != nil {
+		return "", xerrors.Errorf("failed to get GOCACHE: %w", err)
+	}
+	cache := strings.TrimSpace(string(out))
+	if cache == "" || cache == "off" {
+		return "", xerrors.New("GOCACHE is disabled. go test -fuzz requires the build cache")
+	}
+	return filepath.Join(cache, "fuzz", filepath.FromSlash(f.importPath), f.fuzz), nil
+}
+
+// resolve gets the import path of the package and directories of packages used by its tests in the module.
+func (f *FuzzRunner) resolve() error {
+	out, err := exec.Command("go", "list", f.pkg).Output()
+	if err != nil {
+		return xerrors.Errorf("failed to get import path of %s: %w", f.pkg, err)
+	}
+	args := []string{"list", "-deps", "-test", "-f", "{{if not .Standard}}{{.Dir}}{{end}}"}
+	if len(f.gocmd.tags) > 0 {
+		args = append(args, "-tags", strings.Join(f.gocmd.tags, ","))
+	}
+	deps, err := exec.Command("go", append(args, f.pkg)...).Output()
+	if err != nil {
+		return xerrors.Errorf("failed to list dependencies of %s: %w", f.pkg, err)
+	}
+	dirs := map[string]bool{}
+	for _, dir := range strings.Split(string(deps), "\n") {
+		if dir != "" && containsPath(cwd, dir) {
+			dirs[dir] = true
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.importPath = strings.TrimSpace(string(out))
+	f.dirs = dirs
+	return nil
+}
+
+// Start restores the preserved corpus and starts fuzzing.
+func (f *FuzzRunner) Start() error {
+	if err := f.resolve(); err != nil {
+		return xerrors.Errorf("failed to resolve package: %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.start()
+}
+
+func (f *FuzzRunner) start() error {
+	cacheDir, err := f.cacheDir()
+	if err != nil {
+		return xerrors.Errorf("failed to get fuzz cache directory: %w", err)
+	}
+	restored, err := mergeFuzzCorpus(cacheDir, f.corpusDir())
+	if err != nil {
+		return xerrors.Errorf("failed to restore corpus: %w", err)
+	}
+	if restored > 0 {
+		logger().Infof("Restored %d corpus entries of %s from %s", restored, f.fuzz, f.corpusDir())
+	}
+	args, err := f.gocmd.toolCommand("test")
+	if err != nil {
+		return xerrors.Errorf("failed to get command: %w", err)
+	}
+	args = append(args, f.gocmd.buildFlags()...)
+	args = append(args, "-run", "^$", "-fuzz", fmt.Sprintf("^%s$", f.fuzz))
+	if !hasTestFlag(f.args, "timeout") {
+		// fuzzing runs until it finds a failure or -fuzztime elapses
+		args = append(args, "-timeout", "0")
+	}
+	if !hasTestFlag(f.args, "fuzzminimizetime") {
+		args = append(args, "-fuzzminimizetime", defaultFuzzMinimizeTime)
+	}
+	args = append(args, f.args...)
+	args = append(args, f.pkg)
+	cmd, err := f.gocmd.command(args...)
+	if err != nil {
+		return xerrors.Errorf("failed to create command: %w", err)
+	}
+	cmd.OnExit(func(err error) {
+		if cmd.IsStopped() {
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err := f.saveCorpus(); err != nil {
+			logger().Errorf("%v", err)
+		}
+		if err != nil {
+			logger().Errorf(
+				"Fuzzing %s failed ( %v ). failing inputs are written to testdata/fuzz/%s . waiting for changes...",
+				f.fuzz, err, f.fuzz,
+			)
+			return
+		}
+		logger().Infof("Fuzzing %s finished. waiting for changes...", f.fuzz)
+	})
+	logger().Infof("Fuzzing %s in %s", f.fuzz, f.pkg)
+	cmd.RunAsync()
+	f.cmd = cmd
+	return nil
+}
+
+// hasTestFlag returns true if args have the flag of go test ( e.g. -timeout 1m , --timeout=1m ).
+func hasTestFlag(args []string, name string) bool {
+	for _, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// saveCorpus merges the corpus generated by the fuzzer into .rebirth/fuzz and removes duplicated entries.
+func (f *FuzzRunner) saveCorpus() error {
+	cacheDir, err := f.cacheDir()
+	if err != nil {
+		return xerrors.Errorf("failed to get fuzz cache directory: %w", err)
+	}
+	if _, err := mergeFuzzCorpus(f.corpusDir(), cacheDir); err != nil {
+		return xerrors.Errorf("failed to save corpus: %w", err)
+	}
+	entries, removed, err := dedupFuzzCorpus(f.corpusDir())
+	if err != nil {
+		return xerrors.Errorf("failed to remove duplicated corpus entries: %w", err)
+	}
+	if entries > 0 {
+		logger().Infof("Saved %d corpus entries of %s to %s ( %d duplicated or invalid entries removed )", entries, f.fuzz, f.corpusDir(), removed)
+	}
+	return nil
+}
+
+// affects returns true if the files contain the code under test or aren't go files ( e.g. go.mod ).
+func (f *FuzzRunner) affects(files []string) bool {
+	if len(files) == 0 {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		if f.dirs[filepath.Dir(path)] || filepath.Ext(path) != ".go" {
+			return true
+		}
+	}
+	return false
+}
+
+// RunFiles restarts the fuzzer if the files affect the fuzz test.
+func (f *FuzzRunner) RunFiles(files []string) error {
+	if !f.affects(files) {
+		return nil
+	}
+	if err := f.Stop(); err != nil {
+		return xerrors.Errorf("failed to stop fuzzer: %w", err)
+	}
+	// imports may be changed
+	if err := f.resolve(); err != nil {
+		return xerrors.Errorf("failed to resolve package: %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	logger().Infof("Restarting fuzzer...")
+	return f.start()
+}
+
+// Stop stops the running fuzzer by SIGINT for go test to write the generated corpus, and preserves it.
+func (f *FuzzRunner) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cmd == nil {
+		return nil
+	}
+	cmd := f.cmd
+	f.cmd = nil
+	if err := cmd.StopGroupGracefully(syscall.SIGINT, fuzzStopTimeout); err != nil {
+		return xerrors.Errorf("failed to stop fuzzer: %w", err)
+	}
+	if err := f.saveCorpus(); err != nil {
+		return xerrors.Errorf("failed to save corpus: %w", err)
+	}
+	return nil
+}
+
+// mergeFuzzCorpus copies corpus entries in src which don't exist in dst by content, and returns the number of copied entries.
+func mergeFuzzCorpus(dst, src string) (int, error) {
+	srcFiles, err := ioutil.ReadDir(src)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, xerrors.Errorf("failed to create %s: %w", dst, err)
+	}
+	hashes, err := fuzzCorpusHashes(dst)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to get corpus of %s: %w", dst, err)
+	}
+	copied := 0
+	for _, file := range srcFiles {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(src, file.Name())
+		hash := fmt.Sprintf("%x", fileHash(path))
+		if hashes[hash] {
+			continue
+		}
+		if err := copyFile(filepath.Join(dst, file.Name()), path, 0644); err != nil {
+			return copied, xerrors.Errorf("failed to copy corpus: %w", err)
+		}
+		hashes[hash] = true
+		copied++
+	}
+	return copied, nil
+}
+
+func fuzzCorpusHashes(dir string) (map[string]bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", dir, err)
+	}
+	hashes := map[string]bool{}
+	for _, file := range files {
+		if !file.IsDir() {
+			hashes[fmt.Sprintf("%x", fileHash(filepath.Join(dir, file.Name())))] = true
+		}
+	}
+	return hashes, nil
+}
+
+// dedupFuzzCorpus removes entries which have the same content as others or aren't corpus files,
+// and returns the number of the remaining and removed entries. Inputs are already minimized by the fuzzer.
+func dedupFuzzCorpus(dir string) (int, int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, xerrors.Errorf("failed to read %s: %w", dir, err)
+	}
+	seen := map[[sha256.Size]byte]bool{}
+	entries, removed := 0, 0
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return entries, removed, xerrors.Errorf("failed to read %s: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+		if seen[sum] || !bytes.HasPrefix(content, []byte(fuzzCorpusHeader)) {
+			if err := os.Remove(path); err != nil {
+				return entries, removed, xerrors.Errorf("failed to remove %s: %w", path, err)
+			}
+			removed++
+			continue
+		}
+		seen[sum] = true
+		entries++
+	}
+	return entries, removed, nil
+}
@@ -0,0 +1,95 @@
+package rebirth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+var goGenerateDirective = []byte("//go:generate")
+
+// generatePackages returns packages ( e.g. ./api ) of changed go files having //go:generate directives.
+// If a file matches build.generate_inputs , all packages ( ./... ) are returned because the inputs aren't tied to a package.
+func (r *Reloader) generatePackages(files []string) []string {
+	if !r.build.Generate {
+		return nil
+	}
+	dirs := map[string]bool{}
+	for _, file := range files {
+		rel := file
+		if path, err := filepath.Abs(file); err == nil {
+			if relPath, err := filepath.Rel(cwd, path); err == nil {
+				rel = relPath
+			}
+		}
+		if matchAnyGlob(r.build.GenerateInputs, filepath.ToSlash(rel)) {
+			return []string{"./..."}
+		}
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil || !bytes.Contains(content, goGenerateDirective) {
+			continue
+		}
+		dirs["./"+filepath.ToSlash(filepath.Dir(rel))] = true
+	}
+	pkgs := []string{}
+	for dir := range dirs {
+		pkgs = append(pkgs, strings.TrimSuffix(dir, "/."))
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// generate runs go generate for pkgs with build.env and build.tags on localhost.
+// The generators run on localhost even for host.docker because they produce sources, not the binary.
+// Files written by it are recorded for skipping the following events of them.
+func (r *Reloader) generate(pkgs []string) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	r.logger.Infof("Generating %s...", strings.Join(pkgs, " "))
+	gocmd := NewGoCommand()
+	env := []string{}
+	for k, v := range r.build.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
+	}
+	gocmd.AddEnv(env)
+	gocmd.SetBuildFlags(r.build.Tags, "", "")
+	stdout, stderr := r.buildOutput()
+	gocmd.SetStdout(stdout)
+	gocmd.SetStderr(stderr)
+	start := time.Now()
+	if err := gocmd.Generate(pkgs...); err != nil {
+		return xerrors.Errorf("failed to go generate: %w", err)
+	}
+	r.focusMu.Lock()
+	r.generatedFrom = start
+	r.generatedAt = time.Now()
+	r.focusMu.Unlock()
+	return nil
+}
+
+// isGenerated returns true if all files are written by the last go generate, which was followed by the build.
+func (r *Reloader) isGenerated(files []string) bool {
+	r.focusMu.Lock()
+	defer r.focusMu.Unlock()
+	if r.generatedAt.IsZero() {
+		return false
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || info.ModTime().Before(r.generatedFrom) || info.ModTime().After(r.generatedAt) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package rebirth
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob returns true if name matches pattern.
+// In addition to path.Match syntax, ** matches any number of directories
+// and the pattern ending with / matches everything under the directory.
+func matchGlob(pattern, name string) bool {
+	pattern = filepath.ToSlash(pattern)
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	name = filepath.ToSlash(filepath.Clean(name))
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			rest := patterns[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(rest, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		matched, err := path.Match(patterns[0], names[0])
+		if err != nil || !matched {
+			return false
+		}
+		patterns = patterns[1:]
+		names = names[1:]
+	}
+	return len(names) == 0
+}
+
+// matchAnyGlob returns true if name matches one of patterns.
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package rebirth
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		// path.Match syntax matches a single directory level
+		{pattern: "*.go", name: "main.go", expected: true},
+		{pattern: "*.go", name: "cmd/main.go", expected: false},
+		{pattern: "config/*.yaml", name: "config/app.yaml", expected: true},
+		{pattern: "config/*.yaml", name: "config/dev/app.yaml", expected: false},
+		// **/ matches any number of directories including none
+		{pattern: "**/*.go", name: "main.go", expected: true},
+		{pattern: "**/*.go", name: "cmd/api/main.go", expected: true},
+		{pattern: "**/*_test.go", name: "pkg/a_test.go", expected: true},
+		{pattern: "**/*_test.go", name: "pkg/a.go", expected: false},
+		{pattern: "cmd/**/main.go", name: "cmd/main.go", expected: true},
+		{pattern: "cmd/**/main.go", name: "cmd/api/v1/main.go", expected: true},
+		{pattern: "cmd/**/main.go", name: "internal/api/main.go", expected: false},
+		// trailing ** and / match everything under the directory
+		{pattern: "templates/**", name: "templates/index.html", expected: true},
+		{pattern: "templates/**", name: "templates/layouts/base.html", expected: true},
+		{pattern: "templates/**", name: "static/index.html", expected: false},
+		{pattern: "vendor/", name: "vendor/github.com/pkg/errors/errors.go", expected: true},
+		{pattern: "vendor/", name: "vendorx/a.go", expected: false},
+		// patterns are anchored to the root
+		{pattern: "vendor/", name: "internal/vendor/a.go", expected: false},
+		{pattern: "templates/**", name: "web/templates/index.html", expected: false},
+		{pattern: "static/*.css", name: "web/static/a.css", expected: false},
+		// negated character classes
+		{pattern: "**/[^_]*.go", name: "pkg/a.go", expected: true},
+		{pattern: "**/[^_]*.go", name: "pkg/_a.go", expected: false},
+		{pattern: "*.[^g]*", name: "a.txt", expected: true},
+		{pattern: "*.[^g]*", name: "a.go", expected: false},
+		// names are cleaned
+		{pattern: "cmd/*.go", name: "./cmd/main.go", expected: true},
+		{pattern: "cmd/*.go", name: "cmd//main.go", expected: true},
+		// invalid patterns match nothing
+		{pattern: "[", name: "[", expected: false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.pattern+" "+test.name, func(t *testing.T) {
+			if got := matchGlob(test.pattern, test.name); got != test.expected {
+				t.Fatalf("expected %v for %s matched with %s but got %v", test.expected, test.name, test.pattern, got)
+			}
+		})
+	}
+}
+
+func TestMatchAnyGlob(t *testing.T) {
+	patterns := []string{"**/*.tmpl", "config/*.yaml"}
+	if !matchAnyGlob(patterns, "web/index.tmpl") {
+		t.Fatal("expected web/index.tmpl to match")
+	}
+	if !matchAnyGlob(patterns, "config/app.yaml") {
+		t.Fatal("expected config/app.yaml to match")
+	}
+	if matchAnyGlob(patterns, "main.go") {
+		t.Fatal("expected main.go not to match")
+	}
+	if matchAnyGlob(nil, "main.go") {
+		t.Fatal("expected no patterns not to match")
+	}
+}
@@ -0,0 +1,84 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultGoRuntimeEnv is applied to the application if it isn't specified by run.go_runtime or the inherited env.
+var defaultGoRuntimeEnv = map[string]string{
+	"GOTRACEBACK": "all",
+}
+
+// goRuntimeEnv returns env for Go runtime knobs.
+// The precedence is run.env > run.go_runtime > the inherited env > defaultGoRuntimeEnv .
+func (r *Reloader) goRuntimeEnv() []string {
+	values := map[string]string{}
+	for name, value := range defaultGoRuntimeEnv {
+		if _, exists := r.inheritedEnv(name); !exists {
+			values[name] = value
+		}
+	}
+	if r.run != nil && r.run.GoRuntime != nil {
+		rt := r.run.GoRuntime
+		for name, value := range map[string]string{
+			"GOTRACEBACK": rt.GOTRACEBACK,
+			"GOMEMLIMIT":  rt.GOMEMLIMIT,
+			"GOMAXPROCS":  rt.GOMAXPROCS,
+			"GOGC":        rt.GOGC,
+		} {
+			if value != "" {
+				values[name] = value
+			}
+		}
+		if len(rt.GODEBUG) > 0 {
+			inherited, _ := r.inheritedEnv("GODEBUG")
+			values["GODEBUG"] = mergeGODEBUG(inherited, rt.GODEBUG)
+		}
+	}
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := []string{}
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%s=%s", name, values[name]))
+	}
+	return env
+}
+
+// inheritedEnv returns env inherited by the application.
+// On the container and host.ssh it is unknown from the host, so it is regarded as empty. So is it with run.inherit_env: false .
+func (r *Reloader) inheritedEnv(name string) (string, bool) {
+	if r.isDockerMode() || r.isSSHMode() || !r.run.inheritEnv() {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+// mergeGODEBUG overrides flags of inherited GODEBUG by flags.
+func mergeGODEBUG(inherited string, flags map[string]string) string {
+	values := map[string]string{}
+	for _, flag := range strings.Split(inherited, ",") {
+		kv := strings.SplitN(flag, "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			values[kv[0]] = kv[1]
+		}
+	}
+	for name, value := range flags {
+		values[name] = value
+	}
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	merged := []string{}
+	for _, name := range names {
+		merged = append(merged, fmt.Sprintf("%s=%s", name, values[name]))
+	}
+	return strings.Join(merged, ",")
+}
@@ -0,0 +1,82 @@
+package rebirth
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultHealthcheckInterval = 500 * time.Millisecond
+	defaultHealthcheckTimeout  = 10 * time.Second
+)
+
+func parseDurationOr(src string, defaultValue time.Duration) time.Duration {
+	if src == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(src)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+func (h *Healthcheck) interval() time.Duration {
+	return parseDurationOr(h.Interval, defaultHealthcheckInterval)
+}
+
+func (h *Healthcheck) timeout() time.Duration {
+	return parseDurationOr(h.Timeout, defaultHealthcheckTimeout)
+}
+
+func (h *Healthcheck) check() error {
+	if h.HTTP != "" {
+		client := &http.Client{Timeout: h.interval()}
+		resp, err := client.Get(h.HTTP)
+		if err != nil {
+			return xerrors.Errorf("failed to request to %s: %w", h.HTTP, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return xerrors.Errorf("unhealthy status code from %s: %d", h.HTTP, resp.StatusCode)
+		}
+		return nil
+	}
+	if h.TCP != "" {
+		conn, err := net.DialTimeout("tcp", h.TCP, h.interval())
+		if err != nil {
+			return xerrors.Errorf("failed to connect to %s: %w", h.TCP, err)
+		}
+		conn.Close()
+		return nil
+	}
+	return nil
+}
+
+// wait checks health of the application every interval until it becomes healthy or timeout.
+func (h *Healthcheck) wait() error {
+	return h.waitProcess(nil)
+}
+
+// waitProcess is wait for the process started alongside the current process ( e.g. by blue-green ).
+// The current process serving the same address may pass the check, so it fails as soon as alive returns false
+// ( e.g. the new process exited by EADDRINUSE ) and the process must be alive after passing it.
+func (h *Healthcheck) waitProcess(alive func() bool) error {
+	deadline := time.Now().Add(h.timeout())
+	for {
+		err := h.check()
+		if alive != nil && !alive() {
+			return xerrors.New("process exited before becoming healthy")
+		}
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return xerrors.Errorf("application isn't healthy within %s: %w", h.timeout(), err)
+		}
+		time.Sleep(h.interval())
+	}
+}
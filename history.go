@@ -0,0 +1,141 @@
+package rebirth
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultSizeAlertThreshold = 10.0
+
+// buildRecord is a build result recorded to the history store.
+type buildRecord struct {
+	Time       time.Time `json:"time"`
+	Generation int       `json:"generation"`
+	DurationMs int64     `json:"duration_ms"`
+	Size       int64     `json:"size,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// historyStore records build results to .rebirth/history.jsonl .
+type historyStore struct {
+	path string
+}
+
+func newHistoryStore() *historyStore {
+	return &historyStore{path: filepath.Join(cwd, configDir, "history.jsonl")}
+}
+
+func (s *historyStore) append(record *buildRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return xerrors.Errorf("failed to encode build record: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", s.path, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return xerrors.Errorf("failed to write build record: %w", err)
+	}
+	return nil
+}
+
+// records returns all build records in recorded order.
+func (s *historyStore) records() ([]*buildRecord, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return []*buildRecord{}, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open %s: %w", s.path, err)
+	}
+	defer file.Close()
+	records := []*buildRecord{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record buildRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", s.path, err)
+	}
+	return records, nil
+}
+
+// lastSucceeded returns the latest record of the succeeded build. It returns nil if there is no such record.
+func (s *historyStore) lastSucceeded() (*buildRecord, error) {
+	records, err := s.records()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get records: %w", err)
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Error == "" {
+			return records[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *SizeAlert) threshold() float64 {
+	if a == nil || a.Threshold <= 0 {
+		return defaultSizeAlertThreshold
+	}
+	return a.Threshold
+}
+
+// recordBuild records the build result and warns if the binary size jumps over build.size_alert.threshold .
+func (r *Reloader) recordBuild(start time.Time, buildErr error) error {
+	record := &buildRecord{
+		Time:       start,
+		Generation: r.generation,
+		DurationMs: int64(time.Since(start) / time.Millisecond),
+	}
+	if buildErr != nil {
+		record.Error = buildErr.Error()
+		if err := r.history.append(record); err != nil {
+			return xerrors.Errorf("failed to append build record: %w", err)
+		}
+		return nil
+	}
+	info, err := os.Stat(buildPath)
+	if err != nil {
+		return xerrors.Errorf("failed to get size of %s: %w", buildPath, err)
+	}
+	record.Size = info.Size()
+	prev, err := r.history.lastSucceeded()
+	if err != nil {
+		return xerrors.Errorf("failed to get previous build record: %w", err)
+	}
+	if err := r.history.append(record); err != nil {
+		return xerrors.Errorf("failed to append build record: %w", err)
+	}
+	if prev == nil || prev.Size == 0 {
+		return nil
+	}
+	growth := float64(record.Size-prev.Size) / float64(prev.Size) * 100
+	alert := r.build.SizeAlert
+	if growth <= alert.threshold() {
+		return nil
+	}
+	r.logger.Warnf(
+		"binary size jumped %.1f%% ( %d bytes -> %d bytes )",
+		growth, prev.Size, record.Size,
+	)
+	if alert == nil || alert.Hook == "" {
+		return nil
+	}
+	r.logger.Infof("Running: %s", alert.Hook)
+	if err := r.runBuildHookCommandInGoContext(alert.Hook); err != nil {
+		return xerrors.Errorf("failed to run build.size_alert.hook: %w", err)
+	}
+	return nil
+}
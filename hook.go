@@ -0,0 +1,255 @@
+package rebirth
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Hook commands are verified and sandboxed by the following env of the developer.
+// They aren't specified in rebirth.yml because rebirth.yml of untrusted branches can't be trusted.
+const (
+	// hookPolicyEnv is confirm ( ask for changed hooks ) or allowlist ( run only allowed hooks ).
+	hookPolicyEnv = "REBIRTH_HOOKS"
+	// hookAllowEnv is comma separated prefixes of commands allowed without confirmation ( e.g. go generate,make ).
+	hookAllowEnv = "REBIRTH_HOOKS_ALLOW"
+	// hookSandboxEnv is restricted for running hooks without env of the developer ( e.g. tokens ).
+	hookSandboxEnv = "REBIRTH_HOOKS_ENV"
+)
+
+const (
+	hookPolicyConfirm   = "confirm"
+	hookPolicyAllowlist = "allowlist"
+	hookEnvRestricted   = "restricted"
+)
+
+// restrictedEnvNames are env names inherited by hooks in restricted env. Env beginning with GO is inherited too.
+var restrictedEnvNames = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TMPDIR", "LANG", "LC_ALL"}
+
+// hookCommands returns commands run as hooks by cfg.
+func hookCommands(cfg *Config) []string {
+	commands := []string{}
+	if build := cfg.Build; build != nil {
+		commands = append(commands, build.Init...)
+		commands = append(commands, hookCommandsOf(build.Before)...)
+		commands = append(commands, hookCommandsOf(build.After)...)
+		commands = append(commands, build.Transform...)
+		commands = append(commands, build.Tools...)
+		if build.SizeAlert != nil && build.SizeAlert.Hook != "" {
+			commands = append(commands, build.SizeAlert.Hook)
+		}
+	}
+	commands = append(commands, runHookCommands(cfg.Run)...)
+	for _, target := range cfg.Targets {
+		commands = append(commands, runHookCommands(target.Run)...)
+	}
+	if cfg.Watch != nil && cfg.Watch.Migrations != nil && cfg.Watch.Migrations.Hook != "" {
+		commands = append(commands, cfg.Watch.Migrations.Hook)
+	}
+	for _, task := range cfg.AllTasks() {
+		commands = append(commands, task.Commands...)
+	}
+	sort.Strings(commands)
+	unique := []string{}
+	for i, command := range commands {
+		if i > 0 && commands[i-1] == command {
+			continue
+		}
+		if strings.HasPrefix(command, taskHookPrefix) {
+			// commands of the task are verified as commands of tasks
+			continue
+		}
+		unique = append(unique, command)
+	}
+	return unique
+}
+
+// runHookCommands returns run.command and hooks of run ( e.g. of targets ).
+// run.command runs any program instead of the built binary, so it's verified like hooks.
+func runHookCommands(run *Run) []string {
+	commands := []string{}
+	if run == nil {
+		return commands
+	}
+	if run.Command != "" {
+		commands = append(commands, run.Command)
+	}
+	commands = append(commands, hookCommandsOf(run.Before)...)
+	commands = append(commands, hookCommandsOf(run.After)...)
+	commands = append(commands, hookCommandsOf(run.Shutdown)...)
+	for _, trigger := range run.Triggers {
+		if trigger.Hook != "" {
+			commands = append(commands, trigger.Hook)
+		}
+	}
+	return commands
+}
+
+// hookShellMetaChars are characters making sh -c run other commands or redirect the output.
+const hookShellMetaChars = ";&|$`><\n"
+
+// isAllowedHook returns true if command is an entry of REBIRTH_HOOKS_ALLOW , or its words start with words of an entry
+// ( e.g. go generate ./... is allowed by go generate ). Commands with shell metacharacters are allowed only by the same entry.
+func isAllowedHook(command string) bool {
+	command = strings.TrimSpace(command)
+	words, err := splitShellWords(command)
+	exactOnly := err != nil || strings.ContainsAny(command, hookShellMetaChars)
+	for _, entry := range strings.Split(os.Getenv(hookAllowEnv), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if command == entry {
+			return true
+		}
+		if exactOnly {
+			continue
+		}
+		prefix, err := splitShellWords(entry)
+		if err != nil || len(prefix) == 0 || len(prefix) > len(words) {
+			continue
+		}
+		if reflect.DeepEqual(words[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyHooks verifies hook commands of cfg by REBIRTH_HOOKS .
+// With confirm, hooks which aren't trusted yet ( e.g. changed by pulling a branch ) must be confirmed on the terminal,
+// and the confirmed hooks are saved as trusted for the project.
+// With allowlist, only hooks allowed by REBIRTH_HOOKS_ALLOW can be run.
+func VerifyHooks(cfg *Config) error {
+	policy := os.Getenv(hookPolicyEnv)
+	if policy == "" {
+		return nil
+	}
+	if policy != hookPolicyConfirm && policy != hookPolicyAllowlist {
+		return xerrors.Errorf("unknown %s=%s. %s or %s is available", hookPolicyEnv, policy, hookPolicyConfirm, hookPolicyAllowlist)
+	}
+	commands := hookCommands(cfg)
+	trusted, err := loadTrustedHooks()
+	if err != nil {
+		return xerrors.Errorf("failed to load trusted hooks: %w", err)
+	}
+	untrusted := []string{}
+	for _, command := range commands {
+		if isAllowedHook(command) {
+			continue
+		}
+		if policy == hookPolicyConfirm && containsString(trusted[cwd], command) {
+			continue
+		}
+		untrusted = append(untrusted, command)
+	}
+	if len(untrusted) == 0 {
+		return nil
+	}
+	if policy == hookPolicyAllowlist {
+		return xerrors.Errorf("hooks aren't allowed by %s: %s", hookAllowEnv, strings.Join(untrusted, ", "))
+	}
+	if !isTerminal(os.Stdin) {
+		return xerrors.Errorf("hooks must be confirmed on the terminal: %s", strings.Join(untrusted, ", "))
+	}
+	fmt.Printf("%s has hook commands which aren't trusted yet:\n", configPath)
+	for _, command := range untrusted {
+		fmt.Printf("  %s\n", command)
+	}
+	fmt.Print("Trust and run them? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return xerrors.New("hook commands aren't trusted")
+	}
+	trusted[cwd] = commands
+	if err := saveTrustedHooks(trusted); err != nil {
+		return xerrors.Errorf("failed to save trusted hooks: %w", err)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedHooksPath returns the path of trusted hooks per project. It is outside of the project for protecting it from branches.
+func trustedHooksPath() (string, error) {
+	dir, err := xdgConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rebirth", "trusted_hooks.json"), nil
+}
+
+func loadTrustedHooks() (map[string][]string, error) {
+	trusted := map[string][]string{}
+	path, err := trustedHooksPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return trusted, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(file, &trusted); err != nil {
+		return nil, xerrors.Errorf("failed to decode %s: %w", path, err)
+	}
+	return trusted, nil
+}
+
+func saveTrustedHooks(trusted map[string][]string) error {
+	path, err := trustedHooksPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return xerrors.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	data, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("failed to encode trusted hooks: %w", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// hookEnviron returns env inherited by hooks. With REBIRTH_HOOKS_ENV=restricted ,
+// only basic env and env for go are inherited, and env of the developer ( e.g. tokens ) isn't passed to hooks.
+func hookEnviron() []string {
+	if os.Getenv(hookSandboxEnv) != hookEnvRestricted {
+		return os.Environ()
+	}
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(name, "GO") || containsString(restrictedEnvNames, name) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// newHookCommand creates Command for the hook with env by hookEnviron.
+func newHookCommand(args ...string) *Command {
+	cmd := NewCommand(args...)
+	cmd.cmd.Env = hookEnviron()
+	return cmd
+}
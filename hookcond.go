@@ -0,0 +1,88 @@
+package rebirth
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+func (h *Hook) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var command string
+	if err := unmarshal(&command); err == nil {
+		h.Command = command
+		return nil
+	}
+	var hook struct {
+		Command string      `yaml:"command"`
+		When    interface{} `yaml:"when"`
+	}
+	if err := unmarshal(&hook); err != nil {
+		// the error of go-yaml is returned as it is for showing the position ( e.g. unknown key )
+		return err
+	}
+	if hook.Command == "" {
+		return xerrors.New("command of hook must be specified")
+	}
+	h.Command = hook.Command
+	h.When = nil
+	switch when := hook.When.(type) {
+	case nil:
+	case string:
+		h.When = []string{when}
+	case []interface{}:
+		for _, pattern := range when {
+			h.When = append(h.When, fmt.Sprint(pattern))
+		}
+	default:
+		return xerrors.Errorf("when of hook must be a glob or a list of globs: %v", hook.When)
+	}
+	return nil
+}
+
+// matches returns true if the hook runs for the changed files.
+// files are empty if they are unknown ( e.g. the first build, manual reload or rollback ).
+func (h *Hook) matches(files []string) bool {
+	if len(h.When) == 0 || len(files) == 0 {
+		return true
+	}
+	for _, file := range files {
+		if matchAnyGlob(h.When, projectRelPath(file)) {
+			return true
+		}
+	}
+	return false
+}
+
+// projectRelPath returns slash separated path of file relative to the project directory.
+func projectRelPath(file string) string {
+	rel := file
+	if path, err := filepath.Abs(file); err == nil {
+		if relPath, err := filepath.Rel(cwd, path); err == nil {
+			rel = relPath
+		}
+	}
+	return filepath.ToSlash(rel)
+}
+
+// hookCommandsOf returns commands of hooks.
+func hookCommandsOf(hooks []*Hook) []string {
+	commands := []string{}
+	for _, hook := range hooks {
+		commands = append(commands, hook.Command)
+	}
+	return commands
+}
+
+// matchedHooks returns hooks which run for the changed files of the current reload.
+func (r *Reloader) matchedHooks(hooks []*Hook) []*Hook {
+	matched := []*Hook{}
+	for _, hook := range hooks {
+		if hook.matches(r.changedFiles) {
+			matched = append(matched, hook)
+			continue
+		}
+		r.logger.Debugf("Skipped %s: changed files don't match %v", hook.Command, hook.When)
+	}
+	return matched
+}
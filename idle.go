@@ -0,0 +1,136 @@
+package rebirth
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func (i *Idle) timeout() time.Duration {
+	if i == nil {
+		return 0
+	}
+	return parseDurationOr(i.Timeout, 0)
+}
+
+func (r *Reloader) idleConfig() *Idle {
+	if r.run == nil {
+		return nil
+	}
+	return r.run.Idle
+}
+
+// startIdleTimer starts the timer for idle shutdown if run.idle.timeout is specified.
+func (r *Reloader) startIdleTimer() {
+	timeout := r.idleConfig().timeout()
+	if timeout <= 0 {
+		return
+	}
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	r.idleTimer = time.AfterFunc(timeout, r.shutdownIdle)
+}
+
+// MarkActivity records activity for the application ( e.g. proxy traffic ).
+// If the application was stopped by idle shutdown, it is restarted.
+func (r *Reloader) MarkActivity() error {
+	if !r.touchIdle() {
+		return nil
+	}
+	// restarting must not race with reloading and idle shutdown like restartBySignal
+	r.lockReload()
+	defer r.unlockReload()
+	woken, err := r.wakeUpLocked()
+	if err != nil {
+		return xerrors.Errorf("failed to wake up: %w", err)
+	}
+	if !woken {
+		return nil
+	}
+	if err := r.sendReloadingSignal(); err != nil {
+		return xerrors.Errorf("failed to restart application: %w", err)
+	}
+	return nil
+}
+
+// touchIdle resets the idle timer and returns true if the application was stopped by idle shutdown.
+// It doesn't take reloadMu , so activities while building aren't blocked.
+func (r *Reloader) touchIdle() bool {
+	timeout := r.idleConfig().timeout()
+	if timeout <= 0 {
+		return false
+	}
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	if r.idleTimer != nil {
+		r.idleTimer.Reset(timeout)
+	}
+	return r.idle
+}
+
+// wakeUp resets the idle timer and starts the container again if it was stopped by idle shutdown.
+// It returns true if the application was stopped by idle shutdown.
+func (r *Reloader) wakeUp() (bool, error) {
+	if !r.touchIdle() {
+		return false, nil
+	}
+	r.lockReload()
+	defer r.unlockReload()
+	return r.wakeUpLocked()
+}
+
+// wakeUpLocked is wakeUp with reloadMu held. It does nothing if another activity already woke up the application.
+func (r *Reloader) wakeUpLocked() (bool, error) {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	if !r.idle {
+		return false, nil
+	}
+	r.idle = false
+	r.logger.Infof("Waking up from idle...")
+	if !r.idleContainer {
+		return true, nil
+	}
+	if err := containerRuntime().Start(context.Background(), r.host.Docker); err != nil {
+		return true, xerrors.Errorf("failed to start container %s: %w", r.host.Docker, err)
+	}
+	r.idleContainer = false
+	if err := r.startAgent(); err != nil {
+		return true, xerrors.Errorf("failed to start agent: %w", err)
+	}
+	return true, nil
+}
+
+// shutdownIdle stops the application, and the container if run.idle.container is true.
+func (r *Reloader) shutdownIdle() {
+	r.lockReload()
+	defer r.unlockReload()
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	if r.idle {
+		return
+	}
+	r.logger.Infof("No activity for %s. stop application until the next change", r.idleConfig().timeout())
+	r.idle = true
+	if r.agent == nil {
+		if err := r.stopCurrentProcess(); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+		return
+	}
+	if _, err := r.agent.Stop(); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	if !r.idleConfig().Container {
+		return
+	}
+	r.logger.Infof("Stopping container %s...", r.host.Docker)
+	r.agent.Close()
+	r.setAgent(nil)
+	if err := containerRuntime().Stop(context.Background(), r.host.Docker); err != nil {
+		r.logger.Errorf("%v", err)
+		return
+	}
+	r.idleContainer = true
+}
@@ -0,0 +1,353 @@
+package rebirth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// importAir translates .air.toml of air ( https://github.com/cosmtrek/air ).
+func importAir(ic *importedConfig, src []byte) error {
+	tables, err := parseTOML(src)
+	if err != nil {
+		return err
+	}
+	root, build, proxy := tables[""], tables["build"], tables["proxy"]
+	if dir := root.str("root"); dir != "" && dir != "." {
+		ic.cfg.Watch.Root = dir
+	}
+	// pre_cmd runs before cmd
+	for _, cmd := range build.strs("pre_cmd") {
+		ic.cfg.Build.Before = append(ic.cfg.Build.Before, &Hook{Command: cmd})
+	}
+	if cmd := build.str("cmd"); cmd != "" {
+		if err := ic.importBuildCommand(cmd); err != nil {
+			return err
+		}
+	}
+	run := build.str("full_bin")
+	if run == "" {
+		run = build.str("bin")
+	}
+	if err := ic.importRunCommand(run); err != nil {
+		return err
+	}
+	ic.cfg.Run.Args = append(ic.cfg.Run.Args, build.strs("args_bin")...)
+	if build.bool("send_interrupt") {
+		ic.cfg.Run.StopSignal = "SIGINT"
+		if delay := build.duration("kill_delay"); delay > 0 {
+			ic.cfg.Run.StopTimeout = delay.String()
+		}
+	}
+	ic.includeExtensions(build.strs("include_ext"))
+	ic.cfg.Watch.Include = append(ic.cfg.Watch.Include, build.strs("include_file")...)
+	ic.ignore(build.strs("exclude_dir"))
+	ic.cfg.Watch.Exclude = append(ic.cfg.Watch.Exclude, build.strs("exclude_file")...)
+	for _, re := range build.strs("exclude_regex") {
+		glob, ok := regexpToGlob(re)
+		if !ok {
+			ic.note("build.exclude_regex %s can't be translated to watch.exclude", re)
+			continue
+		}
+		ic.cfg.Watch.Exclude = appendUnique(ic.cfg.Watch.Exclude, glob)
+	}
+	if delay := build.int("delay"); delay > 0 {
+		ic.cfg.Watch.Debounce = millisecondsDuration(delay)
+	}
+	if build.bool("poll") {
+		ic.cfg.Watch.Poll = true
+		if interval := build.int("poll_interval"); interval > 0 {
+			ic.cfg.Watch.PollInterval = millisecondsDuration(interval)
+		}
+	}
+	if dirs := build.strs("include_dir"); len(dirs) > 0 {
+		ic.note("build.include_dir ( %s ) isn't translated. rebirth watches all directories under watch.root except watch.ignore", strings.Join(dirs, ", "))
+	}
+	if cmds := build.strs("post_cmd"); len(cmds) > 0 {
+		ic.note("build.post_cmd ( %s ) isn't translated. It runs on exiting air", strings.Join(cmds, ", "))
+	}
+	for _, key := range []string{"exclude_unchanged", "follow_symlink", "rerun"} {
+		if build.bool(key) {
+			ic.note("build.%s isn't translated", key)
+		}
+	}
+	if proxy.bool("enabled") {
+		ic.cfg.Proxy = &Proxy{
+			Listen: ":" + strconv.FormatInt(proxy.int("proxy_port"), 10),
+			Target: strconv.FormatInt(proxy.int("app_port"), 10),
+		}
+	}
+	return nil
+}
+
+// tomlTable is the table of TOML. Values are string, int64, float64, bool or []interface{} of them.
+type tomlTable map[string]interface{}
+
+func (t tomlTable) str(key string) string {
+	s, _ := t[key].(string)
+	return s
+}
+
+func (t tomlTable) strs(key string) []string {
+	values := []string{}
+	list, _ := t[key].([]interface{})
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func (t tomlTable) int(key string) int64 {
+	i, _ := t[key].(int64)
+	return i
+}
+
+func (t tomlTable) bool(key string) bool {
+	b, _ := t[key].(bool)
+	return b
+}
+
+// duration returns the duration written as the string ( e.g. 500ms ) or nanoseconds.
+func (t tomlTable) duration(key string) time.Duration {
+	if s := t.str(key); s != "" {
+		d, _ := time.ParseDuration(s)
+		return d
+	}
+	return time.Duration(t.int(key))
+}
+
+// parseTOML parses tables and key/value pairs of TOML. Values are strings, integers, floats, booleans and arrays of them,
+// which are enough for config files of air. The root table is named "" .
+func parseTOML(src []byte) (map[string]tomlTable, error) {
+	p := &tomlParser{src: string(src), line: 1}
+	tables := map[string]tomlTable{"": {}}
+	table := tables[""]
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return tables, nil
+		}
+		if p.peek() == '[' {
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				return nil, p.errorf("table name isn't closed")
+			}
+			name := strings.Trim(strings.TrimSpace(p.src[p.pos+1:p.pos+end]), "[]")
+			p.pos += end + 1
+			if p.peek() == ']' {
+				// array of tables
+				p.pos++
+			}
+			if _, exists := tables[name]; !exists {
+				tables[name] = tomlTable{}
+			}
+			table = tables[name]
+		} else {
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipBlank(false)
+			if p.peek() != '=' {
+				return nil, p.errorf("= is expected after %s", key)
+			}
+			p.pos++
+			p.skipBlank(false)
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			table[key] = value
+		}
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return xerrors.Errorf("line %d: %s", p.line, xerrors.Errorf(format, args...))
+}
+
+// skipBlank skips spaces and comments, and newlines too if newline is true.
+func (p *tomlParser) skipBlank(newline bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newline:
+			p.pos++
+			p.line++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) key() (string, error) {
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.str()
+	}
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == '_' || c == '-' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if start == p.pos {
+		return "", p.errorf("key is expected")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for {
+			p.skipBlank(true)
+			if p.peek() == ']' {
+				p.pos++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			p.skipBlank(true)
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+			default:
+				return nil, p.errorf("array isn't closed")
+			}
+		}
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += len("true")
+		return true, nil
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += len("false")
+		return false, nil
+	}
+	start := p.pos
+	for !p.eof() && strings.IndexByte("0123456789+-._eE", p.peek()) >= 0 {
+		p.pos++
+	}
+	text := strings.Replace(p.src[start:p.pos], "_", "", -1)
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+	return nil, p.errorf("unsupported value %s", strings.SplitN(p.src[start:], "\n", 2)[0])
+}
+
+// str parses the basic string ( "..." ) or the literal string ( '...' ) including multi-line ones.
+func (p *tomlParser) str() (string, error) {
+	quote := p.src[p.pos : p.pos+1]
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	p.pos += len(quote)
+	if len(quote) == 3 && p.peek() == '\n' {
+		// the newline after the opening quotes is trimmed
+		p.pos++
+		p.line++
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("string isn't closed")
+		}
+		if strings.HasPrefix(p.src[p.pos:], quote) {
+			p.pos += len(quote)
+			return b.String(), nil
+		}
+		c := p.peek()
+		switch {
+		case c == '\n' && len(quote) == 1:
+			return "", p.errorf("string isn't closed")
+		case c == '\\' && quote[0] == '"' && p.pos+1 < len(p.src):
+			p.pos++
+			if len(quote) == 3 && strings.TrimLeft(strings.SplitN(p.src[p.pos:], "\n", 2)[0], " \t\r") == "" {
+				// the line ending backslash trims the newline and whitespaces up to the next non-whitespace
+				for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+					if p.peek() == '\n' {
+						p.line++
+					}
+					p.pos++
+				}
+				continue
+			}
+			switch e := p.peek(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size >= len(p.src) {
+					return "", p.errorf("invalid escape")
+				}
+				r, err := strconv.ParseInt(p.src[p.pos+1:p.pos+1+size], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape: %w", err)
+				}
+				b.WriteRune(rune(r))
+				p.pos += size
+			default:
+				b.WriteByte(e)
+			}
+			p.pos++
+			continue
+		case c == '\n':
+			p.line++
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
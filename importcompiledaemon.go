@@ -0,0 +1,129 @@
+package rebirth
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const compileDaemonCommand = "CompileDaemon"
+
+// compileDaemonBoolFlags are flags of CompileDaemon without values.
+var compileDaemonBoolFlags = map[string]bool{
+	"recursive": true, "graceful-kill": true, "polling": true, "color": true, "log-prefix": true, "verbose": true, "command-stop": true,
+}
+
+// importCompileDaemon translates flags of CompileDaemon ( https://github.com/githubnemo/CompileDaemon ) written in Makefile, Procfile, Dockerfile or the compose file.
+func importCompileDaemon(ic *importedConfig, src []byte) error {
+	line := ""
+	for _, l := range readLines(src) {
+		if idx := strings.Index(l, compileDaemonCommand); idx >= 0 {
+			line = l[idx+len(compileDaemonCommand):]
+			break
+		}
+	}
+	if line == "" {
+		return xerrors.Errorf("%s isn't found", compileDaemonCommand)
+	}
+	// the rest of the command name ( e.g. CompileDaemon@latest ) and the commands after CompileDaemon are ignored
+	if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		line = line[idx:]
+	} else {
+		line = ""
+	}
+	if commands := splitShellCommands(line); len(commands) > 0 {
+		line = commands[0]
+	}
+	args, err := splitShellWords(line)
+	if err != nil {
+		return xerrors.Errorf("failed to parse %s: %w", line, err)
+	}
+	flags := map[string][]string{}
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		name := strings.TrimLeft(args[i], "-")
+		value := "true"
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value = name[:idx], name[idx+1:]
+		} else if !compileDaemonBoolFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		flags[name] = append(flags[name], value)
+	}
+	last := func(name, defaultValue string) string {
+		values := flags[name]
+		if len(values) == 0 {
+			return defaultValue
+		}
+		return values[len(values)-1]
+	}
+	dir := path.Clean(last("directory", "."))
+	if dir != "." {
+		ic.cfg.Watch.Root = dir
+	}
+	ic.dir = path.Clean(last("build-dir", dir))
+	if err := ic.importBuildCommand(last("build", "go build")); err != nil {
+		return err
+	}
+	if err := ic.importRunCommand(last("command", "")); err != nil {
+		return err
+	}
+	pattern := last("pattern", `(.+\.go|.+\.c)$`)
+	if exts, ok := patternExtensions(pattern); ok {
+		ic.includeExtensions(exts)
+	} else {
+		ic.note("-pattern %s can't be translated to watch.include", pattern)
+	}
+	for _, glob := range flags["include"] {
+		ic.cfg.Watch.Include = appendUnique(ic.cfg.Watch.Include, "**/"+glob)
+	}
+	for _, glob := range flags["exclude"] {
+		ic.cfg.Watch.Exclude = appendUnique(ic.cfg.Watch.Exclude, "**/"+glob)
+	}
+	ic.ignore(flags["exclude-dir"])
+	if last("graceful-kill", "false") == "true" {
+		ic.cfg.Run.StopSignal = "SIGTERM"
+		if timeout, err := strconv.ParseInt(last("graceful-timeout", "3"), 10, 64); err == nil {
+			ic.cfg.Run.StopTimeout = millisecondsDuration(timeout * 1000)
+		}
+	}
+	if last("polling", "false") == "true" {
+		ic.cfg.Watch.Poll = true
+		if interval, err := strconv.ParseInt(last("polling-interval", "100"), 10, 64); err == nil {
+			ic.cfg.Watch.PollInterval = millisecondsDuration(interval)
+		}
+	}
+	if last("recursive", "true") == "false" {
+		ic.note("-recursive=false isn't translated. rebirth watches all directories under watch.root")
+	}
+	if dir := last("run-dir", ""); dir != "" {
+		ic.note("-run-dir %s isn't translated. The application runs in the current directory", dir)
+	}
+	return nil
+}
+
+// patternExtensions returns extensions of the regular expression matching files by extensions ( e.g. (.+\.go|.+\.c)$ ).
+func patternExtensions(re string) ([]string, bool) {
+	re = strings.TrimSuffix(re, "$")
+	if strings.HasPrefix(re, "(") && strings.HasSuffix(re, ")") {
+		re = re[1 : len(re)-1]
+	}
+	exts := []string{}
+	for _, alt := range strings.Split(re, "|") {
+		alt = strings.TrimPrefix(strings.TrimPrefix(alt, ".+"), ".*")
+		if !strings.HasPrefix(alt, `\.`) {
+			return nil, false
+		}
+		ext := strings.TrimPrefix(alt, `\.`)
+		if ext == "" || strings.ContainsAny(ext, `\^$.|?*+()[]{}`) {
+			return nil, false
+		}
+		exts = append(exts, ext)
+	}
+	return exts, true
+}
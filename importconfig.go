@@ -0,0 +1,536 @@
+package rebirth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// configImporter translates the config file of another hot reload tool to rebirth.yml .
+type configImporter struct {
+	// sources are default config files of the tool searched in order.
+	sources []string
+	// marker is the text which the source must contain ( e.g. the command line in Makefile ).
+	marker string
+	load   func(ic *importedConfig, src []byte) error
+}
+
+var configImporters = map[string]*configImporter{
+	"air":           {sources: []string{".air.toml", ".air.conf"}, load: importAir},
+	"realize":       {sources: []string{".realize.yaml", ".realize.yml"}, load: importRealize},
+	"fresh":         {sources: []string{"runner.conf"}, load: importFresh},
+	"compiledaemon": {sources: append([]string{"Makefile", "Procfile", "Dockerfile"}, composeFileNames...), marker: compileDaemonCommand, load: importCompileDaemon},
+}
+
+// ImportTools returns names of tools supported by ImportConfig .
+func ImportTools() []string {
+	tools := []string{}
+	for tool := range configImporters {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	return tools
+}
+
+// importedConfig is the config translated from another tool and settings which can't be translated.
+type importedConfig struct {
+	cfg   *Config
+	notes []string
+	// module is the module path for resolving the main package specified by the import path.
+	module string
+	// dir is the directory in which the build command runs ( e.g. cd cmd/api && go build ).
+	dir string
+	// main is the main package built by the build command.
+	main string
+	// built is true if go build is found in the build command.
+	built bool
+}
+
+// ImportConfig creates the config file ( default: .rebirth.yml ) translated from the config file of the tool ( e.g. .air.toml of air ) and .rebirth directory.
+// If src is empty, the default config file of the tool is used.
+// Build commands, watch patterns and env are translated, and the other settings are written as comments.
+func ImportConfig(tool, src string) error {
+	importer, exists := configImporters[strings.ToLower(tool)]
+	if !exists {
+		return xerrors.Errorf("unsupported tool %s. %s are available", tool, strings.Join(ImportTools(), ", "))
+	}
+	if err := checkInitConfigPath(); err != nil {
+		return err
+	}
+	if src == "" {
+		src = importer.findSource()
+		if src == "" {
+			return xerrors.Errorf("config of %s isn't found. %s are searched", tool, strings.Join(importer.sources, ", "))
+		}
+	}
+	file, err := ioutil.ReadFile(src)
+	if err != nil {
+		return xerrors.Errorf("failed to read %s: %w", src, err)
+	}
+	ic := &importedConfig{
+		cfg: &Config{Build: &Build{}, Run: &Run{}, Watch: &Watch{}},
+		dir: ".",
+	}
+	if mod, err := ioutil.ReadFile(goModPath); err == nil {
+		ic.module = parseModulePath(mod)
+	}
+	if err := importer.load(ic, file); err != nil {
+		return xerrors.Errorf("failed to translate %s: %w", src, err)
+	}
+	ic.setMain()
+	if err := ic.cfg.Validate(); err != nil {
+		return xerrors.Errorf("failed to translate %s: %w", src, err)
+	}
+	if err := createConfig(ic.render(tool, src)); err != nil {
+		return err
+	}
+	if len(ic.notes) > 0 {
+		logger().Warnf("%d settings of %s aren't translated. See comments of %s", len(ic.notes), src, configPath)
+	}
+	return nil
+}
+
+func (i *configImporter) findSource() string {
+	for _, src := range i.sources {
+		file, err := ioutil.ReadFile(src)
+		if err != nil {
+			continue
+		}
+		if i.marker == "" || strings.Contains(string(file), i.marker) {
+			return src
+		}
+	}
+	return ""
+}
+
+func (ic *importedConfig) note(format string, args ...interface{}) {
+	ic.notes = append(ic.notes, fmt.Sprintf(format, args...))
+}
+
+// setMain builds the main package outside of the current directory by build.main .
+func (ic *importedConfig) setMain() {
+	if ic.main == "" || ic.main == "." || len(ic.cfg.Targets) > 0 {
+		return
+	}
+	ic.cfg.Build.Main = ic.main
+}
+
+// importBuildCommand translates the shell command building the application ( e.g. go generate ./... && go build -o tmp/main . ).
+// go build is translated to build settings, and the other commands become build.before or build.after .
+func (ic *importedConfig) importBuildCommand(command string) error {
+	for _, cmd := range splitShellCommands(command) {
+		words, err := splitShellWords(cmd)
+		if err != nil {
+			return xerrors.Errorf("failed to parse build command %s: %w", command, err)
+		}
+		env, args := splitEnvAssignments(words)
+		switch {
+		case len(args) == 2 && args[0] == "cd":
+			ic.dir = path.Join(ic.dir, args[1])
+		case isGoBuild(args):
+			if ic.built {
+				ic.note("%s is built by rebirth only once. %s isn't translated", ic.main, cmd)
+				continue
+			}
+			ic.addEnv(&ic.cfg.Build.Env, env)
+			ic.importGoBuildArgs(args[2:])
+		case ic.built:
+			ic.cfg.Build.After = append(ic.cfg.Build.After, &Hook{Command: cmd})
+		default:
+			ic.cfg.Build.Before = append(ic.cfg.Build.Before, &Hook{Command: cmd})
+		}
+	}
+	if !ic.built {
+		ic.note("build command %s doesn't run go build. It's kept as build.before , and rebirth builds the main package after it", command)
+	}
+	return nil
+}
+
+func isGoBuild(args []string) bool {
+	return len(args) >= 2 && path.Base(args[0]) == "go" && (args[1] == "build" || args[1] == "install")
+}
+
+// goBuildValueFlags are flags of go build taking the value as the next argument.
+var goBuildValueFlags = map[string]bool{
+	"o": true, "tags": true, "ldflags": true, "gcflags": true, "asmflags": true, "gccgoflags": true,
+	"mod": true, "modfile": true, "pkgdir": true, "buildmode": true, "compiler": true, "installsuffix": true,
+	"overlay": true, "p": true, "pgo": true, "toolexec": true, "exec": true, "C": true,
+}
+
+// importGoBuildArgs translates flags and the package of go build .
+func (ic *importedConfig) importGoBuildArgs(args []string) {
+	ic.built = true
+	pkgs := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value = name[:idx], name[idx+1:]
+		} else if goBuildValueFlags[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+			arg += " " + value
+		}
+		switch name {
+		case "o", "v":
+			// the binary is built by rebirth
+		case "tags":
+			ic.cfg.Build.Tags = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+		case "ldflags":
+			ic.cfg.Build.LDFlags = value
+		case "gcflags":
+			ic.cfg.Build.GCFlags = value
+		default:
+			ic.note("go build flag %s isn't translated", arg)
+		}
+	}
+	if len(pkgs) == 0 {
+		pkgs = []string{"."}
+	}
+	ic.main = ic.localPackage(pkgs[0])
+	if len(pkgs) > 1 {
+		ic.note("only %s of go build packages ( %s ) is translated", pkgs[0], strings.Join(pkgs, ", "))
+	}
+}
+
+// localPackage returns the package relative to the project directory ( e.g. ./cmd/api ) .
+func (ic *importedConfig) localPackage(pkg string) string {
+	switch {
+	case strings.HasSuffix(pkg, ".go"):
+		pkg = path.Dir(pkg)
+	case ic.module != "" && (pkg == ic.module || strings.HasPrefix(pkg, ic.module+"/")):
+		pkg = "." + strings.TrimPrefix(pkg, ic.module)
+	case !strings.HasPrefix(pkg, "."):
+		// the package outside of the module
+		return pkg
+	}
+	pkg = path.Join(ic.dir, pkg)
+	if pkg == "." || strings.HasPrefix(pkg, "../") {
+		return pkg
+	}
+	return "./" + pkg
+}
+
+// importRunCommand translates the shell command running the built binary ( e.g. APP_ENV=dev ./tmp/main serve ) to env and args of run .
+func (ic *importedConfig) importRunCommand(command string) error {
+	commands := splitShellCommands(command)
+	if len(commands) == 0 {
+		return nil
+	}
+	if len(commands) > 1 {
+		ic.note("only the first command of %s is translated to run", command)
+	}
+	words, err := splitShellWords(commands[0])
+	if err != nil {
+		return xerrors.Errorf("failed to parse run command %s: %w", command, err)
+	}
+	env, args := splitEnvAssignments(words)
+	ic.addEnv(&ic.cfg.Run.Env, env)
+	if len(args) > 1 {
+		// the binary is built by rebirth
+		ic.cfg.Run.Args = append(ic.cfg.Run.Args, args[1:]...)
+	}
+	return nil
+}
+
+func (ic *importedConfig) addEnv(dst *map[string]string, env map[string]string) {
+	if len(env) == 0 {
+		return
+	}
+	if *dst == nil {
+		*dst = map[string]string{}
+	}
+	for k, v := range env {
+		(*dst)[k] = v
+	}
+}
+
+// includeExtensions watches files of extensions ( e.g. tmpl or .tmpl ) in addition to go files.
+func (ic *importedConfig) includeExtensions(exts []string) {
+	for _, ext := range exts {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext == "" || ext == "go" {
+			continue
+		}
+		ic.cfg.Watch.Include = appendUnique(ic.cfg.Watch.Include, "**/*."+ext)
+	}
+}
+
+func (ic *importedConfig) ignore(dirs []string) {
+	for _, dir := range dirs {
+		dir = strings.TrimSuffix(path.Clean(strings.TrimSpace(dir)), "/")
+		if dir == "" || dir == "." {
+			continue
+		}
+		ic.cfg.Watch.Ignore = appendUnique(ic.cfg.Watch.Ignore, dir)
+	}
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// millisecondsDuration returns the duration of rebirth.yml ( e.g. 1s ) for milliseconds.
+func millisecondsDuration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+// regexpToGlob translates the regular expression matching the suffix of paths ( e.g. _test.go , \.pb\.go$ ) to the glob.
+func regexpToGlob(re string) (string, bool) {
+	re = strings.TrimSuffix(re, "$")
+	var b strings.Builder
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch {
+		case c == '\\' && i+1 < len(re) && strings.IndexByte("./-_", re[i+1]) >= 0:
+			i++
+			b.WriteByte(re[i])
+		case c == '.' && i+1 < len(re) && (re[i+1] == '*' || re[i+1] == '+'):
+			i++
+			b.WriteByte('*')
+		case strings.IndexByte(`\^$|?*+()[]{}`, c) >= 0:
+			return "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	glob := strings.TrimPrefix(b.String(), "*")
+	if glob == "" {
+		return "", false
+	}
+	return "**/*" + glob, true
+}
+
+// splitShellCommands splits the shell command by && , ; and newlines outside of quotes.
+func splitShellCommands(command string) []string {
+	commands := []string{}
+	var quote byte
+	start := 0
+	add := func(end int) {
+		if cmd := strings.TrimSpace(command[start:end]); cmd != "" {
+			commands = append(commands, cmd)
+		}
+	}
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';' || c == '\n':
+			add(i)
+			start = i + 1
+		case c == '&' && i+1 < len(command) && command[i+1] == '&':
+			add(i)
+			i++
+			start = i + 1
+		}
+	}
+	add(len(command))
+	return commands
+}
+
+// splitEnvAssignments splits leading assignments ( e.g. CGO_ENABLED=0 ) of the command.
+func splitEnvAssignments(words []string) (map[string]string, []string) {
+	env := map[string]string{}
+	for len(words) > 0 {
+		idx := strings.Index(words[0], "=")
+		if idx <= 0 || !isEnvName(words[0][:idx]) {
+			break
+		}
+		env[words[0][:idx]] = words[0][idx+1:]
+		words = words[1:]
+	}
+	return env, words
+}
+
+func isEnvName(name string) bool {
+	for i, c := range name {
+		if c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (i > 0 && '0' <= c && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// render writes the translated config as rebirth.yml . Settings which can't be translated are written as comments.
+func (ic *importedConfig) render(tool, src string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# rebirth.yml translated from %s of %s\n", src, tool)
+	if len(ic.notes) > 0 {
+		b.WriteString("# The following settings aren't translated:\n")
+		for _, note := range ic.notes {
+			fmt.Fprintf(&b, "#   - %s\n", strings.Replace(note, "\n", " ", -1))
+		}
+	}
+	cfg := ic.cfg
+	if build := cfg.Build; build != nil && !emptySection(build) {
+		b.WriteString("build:\n")
+		writeYAMLScalar(&b, 1, "main", build.Main)
+		writeYAMLMap(&b, 1, "env", build.Env)
+		writeYAMLHooks(&b, 1, "before", build.Before)
+		writeYAMLHooks(&b, 1, "after", build.After)
+		writeYAMLList(&b, 1, "tags", build.Tags)
+		writeYAMLScalar(&b, 1, "ldflags", build.LDFlags)
+		writeYAMLScalar(&b, 1, "gcflags", build.GCFlags)
+		if build.Generate {
+			writeYAMLScalar(&b, 1, "generate", "true")
+		}
+	}
+	if cfg.Run != nil && !emptySection(cfg.Run) {
+		b.WriteString("run:\n")
+		writeYAMLRun(&b, 1, cfg.Run)
+	}
+	if watch := cfg.Watch; watch != nil && !emptySection(watch) {
+		b.WriteString("watch:\n")
+		writeYAMLScalar(&b, 1, "root", watch.Root)
+		writeYAMLList(&b, 1, "ignore", watch.Ignore)
+		writeYAMLScalar(&b, 1, "debounce", watch.Debounce)
+		if watch.Poll {
+			writeYAMLScalar(&b, 1, "poll", "true")
+		}
+		writeYAMLScalar(&b, 1, "poll_interval", watch.PollInterval)
+		writeYAMLList(&b, 1, "include", watch.Include)
+		writeYAMLList(&b, 1, "exclude", watch.Exclude)
+	}
+	if cfg.Proxy != nil {
+		b.WriteString("proxy:\n")
+		writeYAMLScalar(&b, 1, "listen", cfg.Proxy.Listen)
+		writeYAMLScalar(&b, 1, "target", cfg.Proxy.Target)
+	}
+	if len(cfg.Targets) > 0 {
+		b.WriteString("targets:\n")
+		names := []string{}
+		for name := range cfg.Targets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			target := cfg.Targets[name]
+			fmt.Fprintf(&b, "  %s:\n", yamlString(name))
+			writeYAMLScalar(&b, 2, "main", target.Main)
+			if target.Run != nil && !emptySection(target.Run) {
+				b.WriteString("    run:\n")
+				writeYAMLRun(&b, 3, target.Run)
+			}
+		}
+	}
+	return b.String()
+}
+
+func emptySection(v interface{}) bool {
+	switch v := v.(type) {
+	case *Build:
+		return v.Main == "" && len(v.Env) == 0 && len(v.Before) == 0 && len(v.After) == 0 && len(v.Tags) == 0 && v.LDFlags == "" && v.GCFlags == "" && !v.Generate
+	case *Run:
+		return len(v.Args) == 0 && len(v.Env) == 0 && v.StopSignal == "" && v.StopTimeout == ""
+	case *Watch:
+		return v.Root == "" && len(v.Ignore) == 0 && v.Debounce == "" && !v.Poll && v.PollInterval == "" && len(v.Include) == 0 && len(v.Exclude) == 0
+	}
+	return false
+}
+
+func writeYAMLRun(b *strings.Builder, indent int, run *Run) {
+	writeYAMLList(b, indent, "args", run.Args)
+	writeYAMLMap(b, indent, "env", run.Env)
+	writeYAMLScalar(b, indent, "stop_signal", run.StopSignal)
+	writeYAMLScalar(b, indent, "stop_timeout", run.StopTimeout)
+}
+
+func writeYAMLScalar(b *strings.Builder, indent int, key, value string) {
+	if value == "" {
+		return
+	}
+	if value == "true" {
+		fmt.Fprintf(b, "%s%s: true\n", strings.Repeat("  ", indent), key)
+		return
+	}
+	fmt.Fprintf(b, "%s%s: %s\n", strings.Repeat("  ", indent), key, yamlString(value))
+}
+
+func writeYAMLList(b *strings.Builder, indent int, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", strings.Repeat("  ", indent), key)
+	for _, value := range values {
+		fmt.Fprintf(b, "%s  - %s\n", strings.Repeat("  ", indent), yamlString(value))
+	}
+}
+
+func writeYAMLHooks(b *strings.Builder, indent int, key string, hooks []*Hook) {
+	commands := []string{}
+	for _, hook := range hooks {
+		commands = append(commands, hook.Command)
+	}
+	writeYAMLList(b, indent, key, commands)
+}
+
+func writeYAMLMap(b *strings.Builder, indent int, key string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", strings.Repeat("  ", indent), key)
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s  %s: %s\n", strings.Repeat("  ", indent), yamlString(k), yamlString(values[k]))
+	}
+}
+
+// yamlString quotes the value unless it's a plain string of yaml ( e.g. ./cmd/api ).
+func yamlString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.HasPrefix(s, "-") || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	for _, c := range s {
+		if c == ' ' || c == '_' || c == '.' || c == '/' || c == '-' || c == '+' || c == '=' || c == ',' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			continue
+		}
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// readLines returns lines of the file joining lines continued by the trailing backslash.
+func readLines(src []byte) []string {
+	return strings.Split(strings.Replace(strings.Replace(string(src), "\r\n", "\n", -1), "\\\n", " ", -1), "\n")
+}
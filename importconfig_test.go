@@ -0,0 +1,154 @@
+package rebirth
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of testdata")
+
+func TestImportConfig(t *testing.T) {
+	tests := []struct {
+		tool string
+		src  string
+	}{
+		{tool: "air", src: ".air.toml"},
+		{tool: "realize", src: ".realize.yaml"},
+		{tool: "fresh", src: "runner.conf"},
+		{tool: "compiledaemon", src: "Procfile"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.tool, func(t *testing.T) {
+			dir := filepath.Join("testdata", "import", test.tool)
+			src, err := ioutil.ReadFile(filepath.Join(dir, test.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ic := &importedConfig{
+				cfg:    &Config{Build: &Build{}, Run: &Run{}, Watch: &Watch{}},
+				dir:    ".",
+				module: "example.com/app",
+			}
+			if err := configImporters[test.tool].load(ic, src); err != nil {
+				t.Fatalf("failed to translate %s: %+v", test.src, err)
+			}
+			ic.setMain()
+			if err := ic.cfg.Validate(); err != nil {
+				t.Fatalf("translated config is invalid: %+v", err)
+			}
+			got := ic.render(test.tool, test.src)
+			golden := filepath.Join(dir, "rebirth.yml")
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(expected) {
+				t.Fatalf("unexpected config of %s.\nexpected:\n%s\ngot:\n%s", test.src, expected, got)
+			}
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected map[string]tomlTable
+	}{
+		{
+			name: "root table",
+			src:  "root = \".\"\ntmp_dir = 'tmp' # comment\n",
+			expected: map[string]tomlTable{
+				"": {"root": ".", "tmp_dir": "tmp"},
+			},
+		},
+		{
+			name: "tables",
+			src:  "[build]\ncmd = \"go build\"\n\n[proxy]\nenabled = true\nproxy_port = 8_090\n",
+			expected: map[string]tomlTable{
+				"":      {},
+				"build": {"cmd": "go build"},
+				"proxy": {"enabled": true, "proxy_port": int64(8090)},
+			},
+		},
+		{
+			name: "multi-line array",
+			src:  "exclude_dir = [\n  \"assets\", # comment\n  'tmp',\n]\n",
+			expected: map[string]tomlTable{
+				"": {"exclude_dir": []interface{}{"assets", "tmp"}},
+			},
+		},
+		{
+			name: "escapes",
+			src:  `exclude_regex = ["_test\\.go", "\u0041\t"]` + "\nliteral = 'C:\\tmp'\n",
+			expected: map[string]tomlTable{
+				"": {"exclude_regex": []interface{}{`_test\.go`, "A\t"}, "literal": `C:\tmp`},
+			},
+		},
+		{
+			name: "multi-line strings",
+			src:  "cmd = \"\"\"\ngo build \\\n  .\"\"\"\nbin = '''\ntmp/main'''\n",
+			expected: map[string]tomlTable{
+				"": {"cmd": "go build .", "bin": "tmp/main"},
+			},
+		},
+		{
+			name: "floats and quoted keys",
+			src:  "\"poll interval\" = 0.5\nrate = 1e3\n",
+			expected: map[string]tomlTable{
+				"": {"poll interval": 0.5, "rate": float64(1000)},
+			},
+		},
+		{
+			name: "array of tables",
+			src:  "[[hooks]]\nname = \"a\"\n",
+			expected: map[string]tomlTable{
+				"":      {},
+				"hooks": {"name": "a"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(test.src))
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %#v but got %#v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseTOMLError(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "unclosed table", src: "[build\ncmd = \"go build\"\n"},
+		{name: "missing =", src: "cmd \"go build\"\n"},
+		{name: "unclosed string", src: "cmd = \"go build\n"},
+		{name: "unclosed array", src: "exclude_dir = [\"assets\" \"tmp\"]\n"},
+		{name: "trailing value", src: "delay = 1000 ms\n"},
+		{name: "unsupported value", src: "date = 1979-05-27T07:32:00Z\n"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseTOML([]byte(test.src)); err == nil {
+				t.Fatalf("expected error for %q", test.src)
+			}
+		})
+	}
+}
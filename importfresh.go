@@ -0,0 +1,51 @@
+package rebirth
+
+import (
+	"strconv"
+	"strings"
+)
+
+// importFresh translates runner.conf of fresh ( https://github.com/gravityblast/fresh ).
+func importFresh(ic *importedConfig, src []byte) error {
+	settings := map[string]string{}
+	for _, line := range readLines(src) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		settings[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if root := settings["root"]; root != "" && root != "." {
+		ic.cfg.Watch.Root = root
+	}
+	noRebuild := map[string]bool{}
+	for _, ext := range splitFreshList(settings["no_rebuild_ext"]) {
+		noRebuild[strings.TrimPrefix(ext, ".")] = true
+	}
+	exts := []string{}
+	for _, ext := range splitFreshList(settings["valid_ext"]) {
+		if !noRebuild[strings.TrimPrefix(ext, ".")] {
+			exts = append(exts, ext)
+		}
+	}
+	ic.includeExtensions(exts)
+	ic.ignore(splitFreshList(settings["ignored"]))
+	if delay, err := strconv.ParseInt(settings["build_delay"], 10, 64); err == nil && delay > 0 {
+		ic.cfg.Watch.Debounce = millisecondsDuration(delay)
+	}
+	return nil
+}
+
+func splitFreshList(value string) []string {
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,146 @@
+package rebirth
+
+import (
+	"path"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"golang.org/x/xerrors"
+)
+
+// realizeConfig is .realize.yaml of realize ( https://github.com/oxequa/realize ).
+type realizeConfig struct {
+	Settings struct {
+		Legacy struct {
+			Force    bool   `yaml:"force"`
+			Interval string `yaml:"interval"`
+		} `yaml:"legacy"`
+	} `yaml:"settings"`
+	Schema []*realizeProject `yaml:"schema"`
+}
+
+type realizeProject struct {
+	Name     string            `yaml:"name"`
+	Path     string            `yaml:"path"`
+	Env      map[string]string `yaml:"env"`
+	Args     []string          `yaml:"args"`
+	Commands struct {
+		Vet      realizeTool `yaml:"vet"`
+		Fmt      realizeTool `yaml:"fmt"`
+		Test     realizeTool `yaml:"test"`
+		Generate realizeTool `yaml:"generate"`
+		Install  realizeTool `yaml:"install"`
+		Build    realizeTool `yaml:"build"`
+		Run      realizeTool `yaml:"run"`
+	} `yaml:"commands"`
+	Watcher struct {
+		Paths      []string `yaml:"paths"`
+		Extensions []string `yaml:"extensions"`
+		Ignored    []string `yaml:"ignored_paths"`
+		Scripts    []struct {
+			Type    string `yaml:"type"`
+			Command string `yaml:"command"`
+			Path    string `yaml:"path"`
+		} `yaml:"scripts"`
+	} `yaml:"watcher"`
+}
+
+type realizeTool struct {
+	Status bool     `yaml:"status"`
+	Method string   `yaml:"method"`
+	Args   []string `yaml:"args"`
+}
+
+// importRealize translates schema of .realize.yaml . Multiple projects become targets.
+func importRealize(ic *importedConfig, src []byte) error {
+	var cfg realizeConfig
+	if err := yaml.Unmarshal(src, &cfg); err != nil {
+		return xerrors.Errorf("failed to decode: %w", err)
+	}
+	if len(cfg.Schema) == 0 {
+		return xerrors.New("schema has no projects")
+	}
+	if cfg.Settings.Legacy.Force {
+		ic.cfg.Watch.Poll = true
+		ic.cfg.Watch.PollInterval = cfg.Settings.Legacy.Interval
+	}
+	for _, project := range cfg.Schema {
+		run := ic.cfg.Run
+		if len(cfg.Schema) > 1 {
+			run = &Run{}
+		}
+		if err := ic.importRealizeProject(project, run); err != nil {
+			return xerrors.Errorf("failed to translate project %s: %w", project.Name, err)
+		}
+		if len(cfg.Schema) > 1 {
+			if ic.cfg.Targets == nil {
+				ic.cfg.Targets = map[string]*Target{}
+			}
+			name := project.Name
+			if name == "" {
+				name = path.Base(ic.main)
+			}
+			ic.cfg.Targets[name] = &Target{Main: ic.main, Run: run}
+		}
+	}
+	return nil
+}
+
+func (ic *importedConfig) importRealizeProject(project *realizeProject, run *Run) error {
+	ic.dir = "."
+	if project.Path != "" {
+		ic.dir = path.Clean(project.Path)
+	}
+	ic.main = ic.localPackage(".")
+	ic.built = false
+	for _, tool := range []realizeTool{project.Commands.Install, project.Commands.Build} {
+		if !tool.Status {
+			continue
+		}
+		if tool.Method == "" {
+			ic.importGoBuildArgs(tool.Args)
+			continue
+		}
+		if err := ic.importBuildCommand(strings.Join(append([]string{tool.Method}, tool.Args...), " ")); err != nil {
+			return err
+		}
+	}
+	if project.Commands.Generate.Status {
+		ic.cfg.Build.Generate = true
+	}
+	for _, tool := range []struct {
+		name string
+		realizeTool
+	}{{"vet", project.Commands.Vet}, {"fmt", project.Commands.Fmt}, {"test", project.Commands.Test}} {
+		if tool.Status {
+			ic.note("commands.%s of %s isn't translated. Add it to build.before if it's needed", tool.name, project.Name)
+		}
+	}
+	if project.Commands.Run.Method != "" {
+		ic.note("commands.run.method %s of %s isn't translated. rebirth runs the built binary", project.Commands.Run.Method, project.Name)
+	}
+	ic.addEnv(&run.Env, project.Env)
+	run.Args = append(run.Args, project.Args...)
+	ic.includeExtensions(project.Watcher.Extensions)
+	ic.ignore(project.Watcher.Ignored)
+	for _, dir := range project.Watcher.Paths {
+		if dir = path.Clean(strings.TrimPrefix(dir, "/")); dir != "." {
+			ic.note("watcher.paths %s of %s isn't translated. rebirth watches all directories under watch.root", dir, project.Name)
+		}
+	}
+	for _, script := range project.Watcher.Scripts {
+		hook := &Hook{Command: script.Command}
+		if script.Path != "" {
+			hook.Command = "cd " + script.Path + " && " + script.Command
+		}
+		switch script.Type {
+		case "before":
+			ic.cfg.Build.Before = append(ic.cfg.Build.Before, hook)
+		case "after":
+			ic.cfg.Build.After = append(ic.cfg.Build.After, hook)
+		default:
+			ic.note("watcher.scripts %s of %s isn't translated", script.Command, project.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package agent
+
+// Version is the protocol version spoken between rebirth and the agent.
+// The agent rejects nothing by version, but rebirth refuses to talk to an agent
+// whose version is different from its own.
+const Version = 7
+
+// Request types sent from rebirth to the agent.
+const (
+	RequestHello  = "hello"
+	RequestStart  = "start"
+	RequestStop   = "stop"
+	RequestStatus = "status"
+	RequestCopy   = "copy"
+	RequestSignal = "signal"
+	RequestDial   = "dial"
+	RequestLookup = "lookup"
+	RequestSwitch = "switch"
+)
+
+// Response types sent from the agent to rebirth.
+// ResponseResult is the reply for a request and it has the same ID as the request.
+// ResponseOutput and ResponseExit are events and their ID is always zero.
+const (
+	ResponseResult = "result"
+	ResponseOutput = "output"
+	ResponseExit   = "exit"
+)
+
+// Request is a message from rebirth to the agent.
+// Each message is encoded as JSON and written to stdin of the agent.
+type Request struct {
+	ID      int      `json:"id"`
+	Type    string   `json:"type"`
+	Version int      `json:"version,omitempty"`
+	Path    string   `json:"path,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Data    []byte   `json:"data,omitempty"`
+	Mode    uint32   `json:"mode,omitempty"`
+
+	// Signal is the name of the signal sent by signal request ( e.g. SIGHUP ).
+	Signal string `json:"signal,omitempty"`
+
+	// Addr is host:port connected by dial request, or host resolved by lookup request.
+	Addr string `json:"addr,omitempty"`
+
+	// Wait is the duration in milliseconds for checking readiness after start.
+	// The process is ready if it is still running after Wait.
+	Wait int `json:"wait,omitempty"`
+
+	// StopSignal and StopTimeout ( milliseconds ) of start request are used for stopping the started process.
+	// StopSignal is sent first, and the process is killed if it doesn't exit within StopTimeout.
+	// If StopSignal is empty, the process is killed immediately.
+	StopSignal  string `json:"stop_signal,omitempty"`
+	StopTimeout int    `json:"stop_timeout,omitempty"`
+
+	// Next makes start request start the process alongside the current process instead of replacing it,
+	// and makes stop and status requests stop and report that process. switch request stops the current process and replaces it by that process.
+	Next bool `json:"next,omitempty"`
+}
+
+// Response is a message from the agent to rebirth.
+// Each message is encoded as JSON and written to stdout of the agent.
+type Response struct {
+	ID         int    `json:"id"`
+	Type       string `json:"type"`
+	Version    int    `json:"version,omitempty"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	Pid        int    `json:"pid,omitempty"`
+	Running    bool   `json:"running,omitempty"`
+	ExitStatus int    `json:"exit_status,omitempty"`
+	Stream     string `json:"stream,omitempty"`
+	Line       string `json:"line,omitempty"`
+
+	// Ready reports the readiness result of the started process.
+	Ready bool `json:"ready,omitempty"`
+	// PrevPid and PrevExitStatus report the process stopped by start request.
+	PrevPid        int `json:"prev_pid,omitempty"`
+	PrevExitStatus int `json:"prev_exit_status,omitempty"`
+	// Stopped is true if the exited process was stopped by request.
+	Stopped bool `json:"stopped,omitempty"`
+	// Addrs are addresses resolved by lookup request.
+	Addrs []string `json:"addrs,omitempty"`
+	// Next is true if the exited process was started by start request with Next and wasn't switched to.
+	Next bool `json:"next,omitempty"`
+}
@@ -0,0 +1,371 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	stopTimeout = 10 * time.Second
+	dialTimeout = 3 * time.Second
+)
+
+type process struct {
+	cmd         *exec.Cmd
+	done        chan struct{}
+	exitStatus  int
+	stopped     int32
+	next        int32
+	stopSignal  os.Signal
+	stopTimeout time.Duration
+}
+
+// Server handles requests from rebirth and manages the application process.
+type Server struct {
+	dec  *json.Decoder
+	enc  *json.Encoder
+	mu   sync.Mutex
+	proc *process
+
+	// handleMu serializes requests and Close .
+	handleMu sync.Mutex
+	// next is the process started alongside proc for switching to it after it becomes healthy.
+	next *process
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		dec: json.NewDecoder(r),
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Serve handles requests until the input is closed.
+// The application process is stopped before returning.
+func (s *Server) Serve() error {
+	defer s.Close()
+	for {
+		var req Request
+		if err := s.dec.Decode(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to decode request: %w", err)
+		}
+		s.handleMu.Lock()
+		res := s.handle(&req)
+		s.handleMu.Unlock()
+		s.send(res)
+	}
+}
+
+// Close stops the application processes and their process trees.
+func (s *Server) Close() {
+	s.handleMu.Lock()
+	defer s.handleMu.Unlock()
+	s.stopNext()
+	s.stop()
+}
+
+func (s *Server) handle(req *Request) *Response {
+	res := &Response{ID: req.ID, Type: ResponseResult}
+	var err error
+	switch req.Type {
+	case RequestHello:
+		res.Version = Version
+	case RequestStart:
+		err = s.start(req, res)
+	case RequestStop:
+		if req.Next {
+			res.Pid, res.ExitStatus = s.stopNext()
+		} else {
+			res.Pid, res.ExitStatus = s.stop()
+		}
+	case RequestSwitch:
+		err = s.switchNext(res)
+	case RequestStatus:
+		if req.Next {
+			processStatus(s.next, res)
+		} else {
+			s.status(res)
+		}
+	case RequestCopy:
+		err = s.copy(req)
+	case RequestSignal:
+		err = s.signal(req, res)
+	case RequestDial:
+		err = s.dial(req)
+	case RequestLookup:
+		err = s.lookup(req, res)
+	default:
+		err = fmt.Errorf("unsupported request type %q", req.Type)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.OK = true
+	return res
+}
+
+func (s *Server) send(res *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(res)
+}
+
+func (s *Server) start(req *Request, res *Response) error {
+	if req.Next {
+		s.stopNext()
+	} else {
+		res.PrevPid, res.PrevExitStatus = s.stop()
+	}
+	var stopSignal os.Signal
+	if req.StopSignal != "" {
+		sig, err := ParseSignal(req.StopSignal)
+		if err != nil {
+			return fmt.Errorf("invalid stop signal: %w", err)
+		}
+		stopSignal = sig
+	}
+	cmd := exec.Command(req.Path, req.Args...)
+	cmd.Env = append(os.Environ(), req.Env...)
+	// the process group is killed with the process for children of the application not to remain
+	setProcessGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to pipe stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to pipe stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", req.Path, err)
+	}
+	proc := &process{
+		cmd:         cmd,
+		done:        make(chan struct{}),
+		stopSignal:  stopSignal,
+		stopTimeout: time.Duration(req.StopTimeout) * time.Millisecond,
+	}
+	if req.Next {
+		proc.next = 1
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go s.forward(&wg, "stdout", stdout)
+	go s.forward(&wg, "stderr", stderr)
+	go func() {
+		wg.Wait()
+		proc.exitStatus = exitStatus(cmd.Wait())
+		close(proc.done)
+		s.send(&Response{
+			Type:       ResponseExit,
+			Pid:        cmd.Process.Pid,
+			ExitStatus: proc.exitStatus,
+			Stopped:    atomic.LoadInt32(&proc.stopped) == 1,
+			Next:       atomic.LoadInt32(&proc.next) == 1,
+		})
+	}()
+	if req.Next {
+		s.next = proc
+	} else {
+		s.proc = proc
+	}
+	res.Pid = cmd.Process.Pid
+	select {
+	case <-proc.done:
+		res.ExitStatus = proc.exitStatus
+		if req.Next {
+			// the current process keeps running
+			s.next = nil
+		}
+	case <-time.After(time.Duration(req.Wait) * time.Millisecond):
+		res.Running = true
+		res.Ready = true
+	}
+	return nil
+}
+
+func (s *Server) forward(wg *sync.WaitGroup, stream string, r io.Reader) {
+	defer wg.Done()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			s.send(&Response{Type: ResponseOutput, Stream: stream, Line: line})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stop stops the current process and returns its pid and exit status.
+func (s *Server) stop() (int, int) {
+	proc := s.proc
+	s.proc = nil
+	return stopProcess(proc)
+}
+
+// stopNext stops the process started alongside the current process.
+func (s *Server) stopNext() (int, int) {
+	proc := s.next
+	s.next = nil
+	return stopProcess(proc)
+}
+
+// switchNext stops the current process and makes the process started alongside it current.
+func (s *Server) switchNext(res *Response) error {
+	next := s.next
+	if next == nil {
+		return fmt.Errorf("next process isn't started")
+	}
+	select {
+	case <-next.done:
+		s.next = nil
+		return fmt.Errorf("next process(%d) exited with status %d", next.cmd.Process.Pid, next.exitStatus)
+	default:
+	}
+	res.PrevPid, res.PrevExitStatus = s.stop()
+	s.next = nil
+	atomic.StoreInt32(&next.next, 0)
+	s.proc = next
+	s.status(res)
+	return nil
+}
+
+// stopProcess stops proc and returns its pid and exit status.
+// The stop signal of the process is sent first, and it is killed if it doesn't exit within the stop timeout.
+func stopProcess(proc *process) (int, int) {
+	if proc == nil {
+		return 0, 0
+	}
+	pid := proc.cmd.Process.Pid
+	select {
+	case <-proc.done:
+		return pid, proc.exitStatus
+	default:
+	}
+	atomic.StoreInt32(&proc.stopped, 1)
+	if proc.stopSignal != nil && proc.cmd.Process.Signal(proc.stopSignal) == nil {
+		select {
+		case <-proc.done:
+			// children left by the process must not remain as orphans
+			killProcessGroup(pid)
+			return pid, proc.exitStatus
+		case <-time.After(proc.stopTimeout):
+		}
+	}
+	killProcessGroup(pid)
+	select {
+	case <-proc.done:
+	case <-time.After(stopTimeout):
+	}
+	return pid, proc.exitStatus
+}
+
+func (s *Server) status(res *Response) {
+	processStatus(s.proc, res)
+}
+
+// processStatus writes the pid and the state of proc to res.
+func processStatus(proc *process, res *Response) {
+	if proc == nil {
+		return
+	}
+	res.Pid = proc.cmd.Process.Pid
+	select {
+	case <-proc.done:
+		res.ExitStatus = proc.exitStatus
+	default:
+		res.Running = true
+	}
+}
+
+func (s *Server) signal(req *Request, res *Response) error {
+	sig, err := ParseSignal(req.Signal)
+	if err != nil {
+		return err
+	}
+	proc := s.proc
+	if proc == nil {
+		return fmt.Errorf("process isn't running")
+	}
+	res.Pid = proc.cmd.Process.Pid
+	if err := proc.cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to send %s to process(%d): %w", req.Signal, res.Pid, err)
+	}
+	res.Running = true
+	return nil
+}
+
+// dial checks connectivity from the container to req.Addr .
+func (s *Server) dial(req *Request) error {
+	conn, err := net.DialTimeout("tcp", req.Addr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", req.Addr, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// lookup resolves req.Addr by DNS of the container.
+func (s *Server) lookup(req *Request, res *Response) error {
+	addrs, err := net.LookupHost(req.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to lookup %s: %w", req.Addr, err)
+	}
+	res.Addrs = addrs
+	return nil
+}
+
+func (s *Server) copy(req *Request) error {
+	mode := os.FileMode(req.Mode)
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", req.Path, err)
+	}
+	tmpfile, err := ioutil.TempFile(filepath.Dir(req.Path), ".copy")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := tmpfile.Write(req.Data); err != nil {
+		tmpfile.Close()
+		return fmt.Errorf("failed to write %s: %w", tmpfile.Name(), err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmpfile.Name(), err)
+	}
+	if err := os.Chmod(tmpfile.Name(), mode); err != nil {
+		return fmt.Errorf("failed to change mode of %s: %w", tmpfile.Name(), err)
+	}
+	if err := os.Rename(tmpfile.Name(), req.Path); err != nil {
+		return fmt.Errorf("failed to rename to %s: %w", req.Path, err)
+	}
+	return nil
+}
+
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
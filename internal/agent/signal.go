@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// signals are sent by name because the number of a signal depends on OS.
+// Signals which don't exist on every OS are added in signal_unix.go .
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses signal name like SIGHUP or HUP.
+func ParseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, exists := signals[name]
+	if !exists {
+		return 0, fmt.Errorf("unsupported signal %s", name)
+	}
+	return sig, nil
+}
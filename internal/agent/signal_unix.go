@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package agent
+
+import "syscall"
+
+func init() {
+	signals["SIGUSR1"] = syscall.SIGUSR1
+	signals["SIGUSR2"] = syscall.SIGUSR2
+}
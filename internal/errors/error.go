@@ -8,6 +8,20 @@ Please install cross compiler by the following command
 
 $ brew install FiloSottile/musl-cross/musl-cross
 
+For arm64 or arm containers, add compilers for them
+
+$ brew install FiloSottile/musl-cross/musl-cross --with-aarch64 --with-arm-hf
+
 ( Sorry, wait about 30 minutes... )
+`)
+	ErrAgentSource = xerrors.New(`
+rebirth source tree is not found, so cannot cross compile rebirth agent for the container.
+Please specify a prebuilt agent in rebirth.yml like the following
+
+host:
+  agent:
+    url: https://example.com/__rebirth_{os}_{arch}
+    checksums:
+      linux-amd64: <sha256 checksum>
 `)
 )
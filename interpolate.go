@@ -0,0 +1,91 @@
+package rebirth
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// interpolationPattern matches $${VAR} ( escaped ), ${VAR} and ${VAR:-default} .
+var interpolationPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// runtimeEnvPrefix is the prefix of env given by rebirth at runtime ( e.g. REBIRTH_BINARY ).
+// They are kept for expanding by the commands.
+const runtimeEnvPrefix = "REBIRTH_"
+
+// interpolate expands ${VAR} and ${VAR:-default} in value by the current env.
+// ${VAR:-default} uses default if VAR is unset or empty, and $${ is replaced by ${ .
+func interpolate(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return interpolationPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		sub := interpolationPattern.FindStringSubmatch(match)
+		name, hasDefault, def := sub[1], sub[2] != "", sub[3]
+		if strings.HasPrefix(name, runtimeEnvPrefix) {
+			if v, exists := os.LookupEnv(name); exists {
+				return v
+			}
+			return match
+		}
+		if v := os.Getenv(name); v != "" || !hasDefault {
+			return v
+		}
+		return def
+	})
+}
+
+// interpolate expands env in all string values of the config.
+func (cfg *Config) interpolate() {
+	interpolateValue(reflect.ValueOf(cfg))
+}
+
+// interpolateValue expands env in string values reachable from v.
+// Values which aren't addressable ( e.g. values of maps and interfaces ) are copied, expanded and set back.
+func interpolateValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			interpolateValue(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if elem := v.Elem(); elem.Kind() == reflect.Ptr {
+			interpolateValue(elem)
+		} else if v.CanSet() {
+			v.Set(interpolatedCopy(elem))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				interpolateValue(field)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			interpolateValue(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			v.SetMapIndex(key, interpolatedCopy(v.MapIndex(key)))
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(interpolate(v.String()))
+		}
+	}
+}
+
+// interpolatedCopy returns the addressable copy of v whose env is expanded.
+func interpolatedCopy(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+	interpolateValue(copied)
+	return copied
+}
@@ -0,0 +1,103 @@
+package rebirth
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{
+		"REBIRTH_TEST_HOST":  "localhost",
+		"REBIRTH_TEST_EMPTY": "",
+		"TEST_PORT":          "8080",
+		"TEST_EMPTY":         "",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	os.Unsetenv("TEST_UNSET")
+	os.Unsetenv("REBIRTH_TEST_UNSET")
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "no variables", expected: "no variables"},
+		{value: "${TEST_PORT}", expected: "8080"},
+		{value: ":${TEST_PORT}/path", expected: ":8080/path"},
+		{value: "${TEST_UNSET}", expected: ""},
+		{value: "${TEST_UNSET:-3000}", expected: "3000"},
+		{value: "${TEST_EMPTY:-3000}", expected: "3000"},
+		{value: "${TEST_PORT:-3000}", expected: "8080"},
+		{value: "${TEST_UNSET:-}", expected: ""},
+		{value: "${TEST_UNSET:-a b:c}", expected: "a b:c"},
+		{value: "$${TEST_PORT}", expected: "${TEST_PORT}"},
+		// the escape is the last $ before {
+		{value: "$$${TEST_PORT}", expected: "$${TEST_PORT}"},
+		{value: "$TEST_PORT", expected: "$TEST_PORT"},
+		{value: "$$", expected: "$$"},
+		{value: "${TEST_PORT", expected: "${TEST_PORT"},
+		{value: "${", expected: "${"},
+		{value: "${1INVALID}", expected: "${1INVALID}"},
+		{value: "${TEST_PORT}${TEST_UNSET:-x}", expected: "8080x"},
+		// runtime env of rebirth is kept for expanding by the commands if it's unset
+		{value: "${REBIRTH_TEST_HOST}", expected: "localhost"},
+		{value: "${REBIRTH_TEST_EMPTY}", expected: ""},
+		{value: "${REBIRTH_TEST_UNSET}", expected: "${REBIRTH_TEST_UNSET}"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			if got := interpolate(test.value); got != test.expected {
+				t.Fatalf("expected %q but got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInterpolateValue(t *testing.T) {
+	os.Setenv("TEST_PORT", "8080")
+	defer os.Unsetenv("TEST_PORT")
+	type endpoint struct {
+		URL  string
+		Tags []string
+	}
+	type config struct {
+		Addr      string
+		Ptr       *endpoint
+		List      []string
+		Env       map[string]string
+		Endpoints map[string]endpoint
+		Pointers  map[string]*endpoint
+		Any       interface{}
+		AnyMap    map[string]interface{}
+		unexposed string
+	}
+	cfg := &config{
+		Addr:      ":${TEST_PORT}",
+		Ptr:       &endpoint{URL: "http://localhost:${TEST_PORT}"},
+		List:      []string{"--port=${TEST_PORT}"},
+		Env:       map[string]string{"PORT": "${TEST_PORT}"},
+		Endpoints: map[string]endpoint{"api": {URL: "http://localhost:${TEST_PORT}", Tags: []string{"${TEST_PORT}"}}},
+		Pointers:  map[string]*endpoint{"api": {URL: "http://localhost:${TEST_PORT}"}},
+		Any:       "${TEST_PORT}",
+		AnyMap:    map[string]interface{}{"port": "${TEST_PORT}", "list": []interface{}{"${TEST_PORT}"}},
+		unexposed: "${TEST_PORT}",
+	}
+	interpolateValue(reflect.ValueOf(cfg))
+	expected := &config{
+		Addr:      ":8080",
+		Ptr:       &endpoint{URL: "http://localhost:8080"},
+		List:      []string{"--port=8080"},
+		Env:       map[string]string{"PORT": "8080"},
+		Endpoints: map[string]endpoint{"api": {URL: "http://localhost:8080", Tags: []string{"8080"}}},
+		Pointers:  map[string]*endpoint{"api": {URL: "http://localhost:8080"}},
+		Any:       "8080",
+		AnyMap:    map[string]interface{}{"port": "8080", "list": []interface{}{"8080"}},
+		unexposed: "${TEST_PORT}",
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, cfg)
+	}
+}
@@ -0,0 +1,276 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// keyBinding is the action for the keystroke.
+type keyBinding struct {
+	key  byte
+	desc string
+	fn   func()
+}
+
+// keyboard reads keystrokes from the terminal and dispatches them to the bound actions.
+// The terminal is switched to non-canonical mode without echo, and Ctrl-C still sends SIGINT.
+// Bindings of the showing prompt take precedence over the default bindings.
+type keyboard struct {
+	mu       sync.Mutex
+	bindings map[byte]*keyBinding
+	// keys are bound keys in the order shown in the help.
+	keys    []byte
+	prompt  map[byte]*keyBinding
+	state   string
+	started bool
+}
+
+func newKeyboard() *keyboard {
+	return &keyboard{bindings: map[byte]*keyBinding{}}
+}
+
+// bind binds fn to key. It is shown as desc in the help.
+func (k *keyboard) bind(key byte, desc string, fn func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, exists := k.bindings[key]; !exists {
+		k.keys = append(k.keys, key)
+	}
+	k.bindings[key] = &keyBinding{key: key, desc: desc, fn: fn}
+}
+
+// help shows the default bindings.
+func (k *keyboard) help() {
+	k.mu.Lock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Keybindings:\n")
+	for _, key := range k.keys {
+		fmt.Fprintf(&b, "  [%c] %s\n", key, k.bindings[key].desc)
+	}
+	k.mu.Unlock()
+	fmt.Print(b.String())
+}
+
+// summary returns the default bindings in a line ( e.g. for the footer of the dashboard ).
+func (k *keyboard) summary() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	items := []string{}
+	for _, key := range k.keys {
+		items = append(items, fmt.Sprintf("[%c] %s", key, k.bindings[key].desc))
+	}
+	return strings.Join(items, "  ")
+}
+
+// start starts reading keystrokes if stdin is a terminal. Otherwise, it does nothing.
+func (k *keyboard) start() error {
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+	state, err := stty("-g")
+	if err != nil {
+		// stdin is a character device but not a terminal ( e.g. /dev/null )
+		return nil
+	}
+	if err := setRawMode(); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.state = strings.TrimSpace(state)
+	k.started = true
+	k.mu.Unlock()
+	// registered right after changing the terminal mode, so it's restored on exiting by errors too
+	addCleanup(func() {
+		if err := k.restore(); err != nil {
+			logger().Errorf("%v", err)
+		}
+	})
+	go k.read()
+	return nil
+}
+
+func (k *keyboard) isStarted() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.started
+}
+
+func (k *keyboard) read() {
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		k.dispatch(buf[0])
+	}
+}
+
+func (k *keyboard) dispatch(key byte) {
+	k.mu.Lock()
+	binding, exists := k.prompt[key]
+	if exists {
+		k.prompt = nil
+	} else {
+		binding, exists = k.bindings[key]
+	}
+	k.mu.Unlock()
+	if !exists {
+		return
+	}
+	binding.fn()
+}
+
+// showPrompt shows title and the actions. The next keystroke of them closes the prompt.
+func (k *keyboard) showPrompt(title string, bindings []*keyBinding) {
+	prompt := map[byte]*keyBinding{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", title)
+	for _, binding := range bindings {
+		prompt[binding.key] = binding
+		fmt.Fprintf(&b, "  [%c] %s\n", binding.key, binding.desc)
+	}
+	k.mu.Lock()
+	k.prompt = prompt
+	k.mu.Unlock()
+	fmt.Print(b.String())
+}
+
+// closePrompt closes the showing prompt without any action.
+func (k *keyboard) closePrompt() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.prompt = nil
+}
+
+// suspend restores the terminal while fn runs ( e.g. for running the editor ).
+func (k *keyboard) suspend(fn func() error) (e error) {
+	if err := k.restore(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := setRawMode(); err != nil && e == nil {
+			e = err
+		}
+	}()
+	return fn()
+}
+
+// setRawMode switches the terminal to non-canonical mode without echo.
+func setRawMode() error {
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return xerrors.Errorf("failed to set terminal mode: %w", err)
+	}
+	return nil
+}
+
+// restore restores the terminal state saved by start.
+func (k *keyboard) restore() error {
+	k.mu.Lock()
+	state := k.state
+	k.mu.Unlock()
+	if state == "" {
+		return nil
+	}
+	if _, err := stty(state); err != nil {
+		return xerrors.Errorf("failed to restore terminal state: %w", err)
+	}
+	return nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", xerrors.Errorf("failed to run stty %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// startKeyboard starts the keybinding layer in TTY mode. The terminal is passed to the application by run.interactive instead.
+func (r *Reloader) startKeyboard() error {
+	if r.run.interactive() {
+		return nil
+	}
+	r.keyboard.bind('r', "rebuild and restart", r.forceReload)
+	r.keyboard.bind('s', "stop or start the application without building", r.toggleRunning)
+	r.keyboard.bind('p', "pause or resume watching", r.togglePause)
+	r.keyboard.bind('c', "clear the screen", clearScreen)
+	r.keyboard.bind('q', "quit", quit)
+	r.keyboard.bind('h', "show keybindings", r.keyboard.help)
+	if r.dashboard != nil {
+		r.keyboard.bind('c', "clear the panes", r.dashboard.clear)
+	}
+	if err := r.keyboard.start(); err != nil {
+		return xerrors.Errorf("failed to start reading keystrokes: %w", err)
+	}
+	if r.keyboard.isStarted() {
+		r.logger.Infof("Press h to show keybindings")
+	}
+	return nil
+}
+
+func (r *Reloader) forceReload() {
+	r.logger.Infof("Rebuilding...")
+	if err := r.Reload(); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
+// toggleRunning stops the running application, or starts the last built binary again without building.
+// The next change builds and starts the stopped application too.
+func (r *Reloader) toggleRunning() {
+	if r.isTargetsMode() || r.isObserveMode() || r.isWasmMode() {
+		r.logger.Warnf("stopping the application isn't supported for targets, observer and wasm mode")
+		return
+	}
+	r.lockReload()
+	defer r.unlockReload()
+	if r.isRunning() {
+		r.logger.Infof("Stopping application... press s to start")
+		if err := r.stopApplication(); err != nil {
+			r.logger.Errorf("%v", err)
+			return
+		}
+		r.setState(StateStopped, "")
+		return
+	}
+	// nothing runs for the other strategies to hand off
+	if err := (&stopStartStrategy{}).Reload(r, buildPath); err != nil {
+		r.setState(StateFailed, errorSummary(err))
+		r.logger.Errorf("failed to start application: %v", err)
+		return
+	}
+	r.setState(StateRunning, "")
+}
+
+// stopApplication stops the application on localhost or the container without auto restart.
+func (r *Reloader) stopApplication() error {
+	if r.agent == nil {
+		return r.stopCurrentProcess()
+	}
+	if _, err := r.agent.Stop(); err != nil {
+		return xerrors.Errorf("failed to stop application on %s: %w", r.agentTarget(), err)
+	}
+	return nil
+}
+
+func clearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
+// quit starts graceful shutdown like Ctrl-C .
+func quit() {
+	if err := interruptSelf(); err != nil {
+		logger().Errorf("failed to quit: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package rebirth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"golang.org/x/xerrors"
+)
+
+// launchAgentTmpl escapes values by xml because paths may contain & or < .
+var launchAgentTmpl = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": escapeXML}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{ xml .Label }}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{ xml .Program }}</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{ xml .Dir }}</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>{{ xml .Path }}</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{ xml .Log }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ xml .Log }}</string>
+</dict>
+</plist>
+`))
+
+func escapeXML(s string) (string, error) {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+// launchAgentLabel returns the label unique to the project directory.
+func launchAgentLabel() string {
+	sum := sha256.Sum256([]byte(cwd))
+	name := invalidLabelChars.ReplaceAllString(filepath.Base(cwd), "-")
+	return fmt.Sprintf("com.github.goccy.rebirth.%s.%s", name, hex.EncodeToString(sum[:])[:8])
+}
+
+func launchAgentPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", xerrors.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchAgentLabel()+".plist"), nil
+}
+
+// InstallLaunchAgent registers the rebirth session of the project as a per-user launchd agent ( macOS only ).
+// The session is kept alive across logins, and the output is written to .rebirth/rebirth.log .
+func InstallLaunchAgent() error {
+	if runtime.GOOS != "darwin" {
+		return xerrors.New("launchd agent is supported on macOS only")
+	}
+	program, err := os.Executable()
+	if err != nil {
+		return xerrors.Errorf("failed to get path of rebirth: %w", err)
+	}
+	plistPath, err := launchAgentPath()
+	if err != nil {
+		return xerrors.Errorf("failed to get path of launchd agent: %w", err)
+	}
+	var plist bytes.Buffer
+	if err := launchAgentTmpl.Execute(&plist, map[string]string{
+		"Label":   launchAgentLabel(),
+		"Program": program,
+		"Dir":     cwd,
+		"Path":    os.Getenv("PATH"),
+		"Log":     filepath.Join(cwd, configDir, "rebirth.log"),
+	}); err != nil {
+		return xerrors.Errorf("failed to render plist: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", filepath.Dir(plistPath), err)
+	}
+	if err := ioutil.WriteFile(plistPath, plist.Bytes(), 0644); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", plistPath, err)
+	}
+	// reload if already installed
+	NewCommand("launchctl", "unload", plistPath).Run()
+	if err := NewCommand("launchctl", "load", "-w", plistPath).Run(); err != nil {
+		return xerrors.Errorf("failed to load %s: %w", plistPath, err)
+	}
+	logger().Infof("Installed %s", plistPath)
+	return nil
+}
+
+// UninstallLaunchAgent unregisters the launchd agent installed by InstallLaunchAgent.
+func UninstallLaunchAgent() error {
+	if runtime.GOOS != "darwin" {
+		return xerrors.New("launchd agent is supported on macOS only")
+	}
+	plistPath, err := launchAgentPath()
+	if err != nil {
+		return xerrors.Errorf("failed to get path of launchd agent: %w", err)
+	}
+	if _, err := os.Stat(plistPath); err != nil {
+		return xerrors.Errorf("launchd agent isn't installed for %s", cwd)
+	}
+	if err := NewCommand("launchctl", "unload", "-w", plistPath).Run(); err != nil {
+		return xerrors.Errorf("failed to unload %s: %w", plistPath, err)
+	}
+	if err := os.Remove(plistPath); err != nil {
+		return xerrors.Errorf("failed to remove %s: %w", plistPath, err)
+	}
+	logger().Infof("Uninstalled %s", plistPath)
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package listener gets listening sockets opened by rebirth ( run.sockets ) in the application.
+// The socket is kept open by rebirth across restarts, so the restarted process doesn't fail
+// by "address already in use" and connections arriving while restarting wait in the backlog instead of being refused.
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvName is the env passing inherited sockets to the application as name=fd list ( e.g. http=3,admin=4 ).
+const EnvName = "REBIRTH_LISTEN_FDS"
+
+// Inherited returns the listener of the socket named name in run.sockets .
+// It returns nil without error if the application isn't started by rebirth with the socket.
+func Inherited(name string) (net.Listener, error) {
+	for _, kv := range strings.Split(os.Getenv(EnvName), ",") {
+		v := strings.SplitN(kv, "=", 2)
+		if len(v) != 2 || v[0] != name {
+			continue
+		}
+		fd, err := strconv.Atoi(v[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid fd %q of socket %s: %w", v[1], name, err)
+		}
+		file := os.NewFile(uintptr(fd), name)
+		defer file.Close()
+		l, err := net.FileListener(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get listener of socket %s: %w", name, err)
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// Listen returns the listener inherited from rebirth by name, or listens addr by itself if it isn't inherited
+// ( e.g. the application is started without rebirth ).
+func Listen(name, network, addr string) (net.Listener, error) {
+	l, err := Inherited(name)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		return l, nil
+	}
+	return net.Listen(network, addr)
+}
@@ -0,0 +1,115 @@
+package rebirth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	liveReloadPath       = "/__rebirth/livereload"
+	liveReloadScriptPath = "/__rebirth/livereload.js"
+)
+
+// liveReloadScript reloads the page when rebirth pushes reload event.
+const liveReloadScript = `(function() {
+  var source = new EventSource("` + liveReloadPath + `");
+  source.addEventListener("reload", function() { location.reload(); });
+})();
+`
+
+// liveReload pushes reload event to browsers by Server-Sent Events.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: map[chan struct{}]struct{}{}}
+}
+
+func (l *liveReload) register(mux *http.ServeMux) {
+	mux.Handle(liveReloadPath, l)
+	mux.HandleFunc(liveReloadScriptPath, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(w, liveReloadScript)
+	})
+}
+
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.clients[ch] = struct{}{}
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, ch)
+		l.mu.Unlock()
+	}()
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// broadcast pushes reload event to all connected browsers.
+func (l *liveReload) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// injectLiveReloadResponse inserts the live reload script to the html response of the application.
+// Compressed responses are passed through, so Accept-Encoding of the request should be removed beforehand.
+func injectLiveReloadResponse(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return xerrors.Errorf("failed to read response: %w", err)
+	}
+	body = injectLiveReload(body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// reloadBrowsers reloads browsers connected through the proxy with proxy.live_reload .
+func (r *Reloader) reloadBrowsers() {
+	if r.proxy == nil || !r.proxy.LiveReload {
+		return
+	}
+	r.logger.Infof("Reloading browsers...")
+	r.liveReload.broadcast()
+}
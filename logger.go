@@ -0,0 +1,210 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLogLevel parses debug, info, warn ( or warning ) and error . Empty level is info .
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "", "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, xerrors.Errorf("unknown log level %s. debug, info, warn and error are available", level)
+}
+
+// Logger receives messages of rebirth itself. The output of the application and go commands isn't logged.
+// Library users can replace it by WithLogger or SetLogger .
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// stdLogger writes messages of Level or higher to w.
+// The text format writes messages as is ( warnings are prefixed by WARNING: ), and the json format writes a JSON object per line.
+type stdLogger struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  LogLevel
+	format string
+	prefix string
+}
+
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Prefix  string    `json:"prefix,omitempty"`
+	Message string    `json:"msg"`
+}
+
+// NewLogger creates the logger writing to w by log config. If cfg is nil, it writes messages of info or higher in the text format.
+func NewLogger(w io.Writer, cfg *Log) (Logger, error) {
+	if cfg == nil {
+		cfg = &Log{}
+	}
+	level, err := ParseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid log.level: %w", err)
+	}
+	format := cfg.Format
+	switch format {
+	case "":
+		format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		return nil, xerrors.Errorf("unknown log.format %s. text and json are available", format)
+	}
+	return &stdLogger{mu: &sync.Mutex{}, w: w, level: level, format: format, prefix: cfg.Prefix}, nil
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) { l.log(LogLevelDebug, format, args) }
+func (l *stdLogger) Infof(format string, args ...interface{})  { l.log(LogLevelInfo, format, args) }
+func (l *stdLogger) Warnf(format string, args ...interface{})  { l.log(LogLevelWarn, format, args) }
+func (l *stdLogger) Errorf(format string, args ...interface{}) { l.log(LogLevelError, format, args) }
+
+func (l *stdLogger) log(level LogLevel, format string, args []interface{}) {
+	if level < l.level {
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	var line string
+	if l.format == LogFormatJSON {
+		data, err := json.Marshal(&logEntry{
+			Time:    time.Now(),
+			Level:   level.String(),
+			Prefix:  strings.TrimSpace(l.prefix),
+			Message: msg,
+		})
+		if err != nil {
+			return
+		}
+		line = string(data) + "\n"
+	} else {
+		if level == LogLevelWarn {
+			msg = "WARNING: " + msg
+		}
+		line = l.prefix + msg + "\n"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, line)
+}
+
+// prefixLogger prefixes messages of the wrapped logger.
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+func (l *prefixLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf("%s%s", l.prefix, fmt.Sprintf(format, args...))
+}
+
+func (l *prefixLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof("%s%s", l.prefix, fmt.Sprintf(format, args...))
+}
+
+func (l *prefixLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf("%s%s", l.prefix, fmt.Sprintf(format, args...))
+}
+
+func (l *prefixLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf("%s%s", l.prefix, fmt.Sprintf(format, args...))
+}
+
+// WithPrefix returns the logger prefixing messages by prefix ( e.g. `[api] ` ).
+// Prefixes of the logger created by NewLogger are nested and written to the prefix field in the json format.
+func WithPrefix(logger Logger, prefix string) Logger {
+	if l, ok := logger.(*stdLogger); ok {
+		copied := *l
+		copied.prefix = l.prefix + prefix
+		return &copied
+	}
+	return &prefixLogger{logger: logger, prefix: prefix}
+}
+
+var (
+	loggerMu      sync.RWMutex
+	defaultLogger Logger = &stdLogger{mu: &sync.Mutex{}, w: os.Stdout, level: LogLevelInfo, format: LogFormatText}
+)
+
+// SetLogger replaces the logger used by package level functions ( e.g. TaskRunner and `rebirth up` )
+// and Reloader created without WithLogger .
+func SetLogger(logger Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	defaultLogger = logger
+}
+
+func logger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return defaultLogger
+}
+
+// ReloaderOption customizes Reloader created by NewReloader .
+type ReloaderOption func(*Reloader)
+
+// WithLogger makes Reloader log messages by logger instead of the logger configured by log in rebirth.yml .
+func WithLogger(logger Logger) ReloaderOption {
+	return func(r *Reloader) {
+		r.logger = logger
+	}
+}
+
+// newReloaderLogger returns the logger configured by log config.
+// Invalid config falls back to the package level logger because it's reported by NewLogger on loading config.
+func newReloaderLogger(cfg *Log) Logger {
+	if cfg == nil {
+		return logger()
+	}
+	l, err := NewLogger(os.Stdout, cfg)
+	if err != nil {
+		return logger()
+	}
+	return l
+}
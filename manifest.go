@@ -0,0 +1,131 @@
+package rebirth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Manifest describes the latest successful build. It is written to .rebirth/manifest.json
+// and served by the control API, so other tools can verify exactly what's running.
+type Manifest struct {
+	Generation     int       `json:"generation"`
+	BuiltAt        time.Time `json:"built_at"`
+	Binary         string    `json:"binary"`
+	SHA256         string    `json:"sha256"`
+	Size           int64     `json:"size"`
+	GoVersion      string    `json:"go_version"`
+	Compiler       string    `json:"compiler"`
+	Command        []string  `json:"command"`
+	EnvFingerprint string    `json:"env_fingerprint"`
+	GitCommit      string    `json:"git_commit,omitempty"`
+	GitDirty       bool      `json:"git_dirty,omitempty"`
+	RemoteBuild    bool      `json:"remote_build,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+func manifestPath() string {
+	return filepath.Join(cwd, configDir, "manifest.json")
+}
+
+// envFingerprint returns hash of the sorted env. Values aren't exposed because env may have secrets.
+func envFingerprint(env []string) string {
+	sorted := append([]string{}, env...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func gitCommit() (string, bool) {
+	commit, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	status, err := exec.Command("git", "status", "--porcelain").Output()
+	dirty := err == nil && len(strings.TrimSpace(string(status))) > 0
+	return strings.TrimSpace(string(commit)), dirty
+}
+
+// writeManifest writes the manifest of the built binary.
+func (r *Reloader) writeManifest(start time.Time, binary, source string) error {
+	checksum, err := fileChecksum(binary)
+	if err != nil {
+		return xerrors.Errorf("failed to get checksum of %s: %w", binary, err)
+	}
+	info, err := os.Stat(binary)
+	if err != nil {
+		return xerrors.Errorf("failed to get size of %s: %w", binary, err)
+	}
+	gocmd := r.newBuildCommand()
+	if r.isRemoteBuild() {
+		gocmd = r.newRemoteBuildCommand()
+	}
+	env, err := gocmd.buildEnv()
+	if err != nil {
+		return xerrors.Errorf("failed to get build env: %w", err)
+	}
+	command, err := gocmd.toolCommand("build")
+	if err != nil {
+		return xerrors.Errorf("failed to get build command: %w", err)
+	}
+	command = append(command, gocmd.buildFlags()...)
+	command = append(command, "-o", binary, source)
+	goVersion, _ := exec.Command("go", "version").Output()
+	compiler := r.build.Compiler
+	if compiler == "" {
+		compiler = compilerGC
+	}
+	commit, dirty := gitCommit()
+	manifest := &Manifest{
+		Generation:     r.generation,
+		BuiltAt:        start,
+		Binary:         binary,
+		SHA256:         checksum,
+		Size:           info.Size(),
+		GoVersion:      strings.TrimSpace(string(goVersion)),
+		Compiler:       compiler,
+		Command:        command,
+		EnvFingerprint: envFingerprint(env),
+		GitCommit:      commit,
+		GitDirty:       dirty,
+		RemoteBuild:    r.isRemoteBuild(),
+		DurationMs:     int64(time.Since(start) / time.Millisecond),
+	}
+	return r.saveManifest(manifest)
+}
+
+// saveManifest writes manifest to .rebirth/manifest.json and serves it by the control API.
+func (r *Reloader) saveManifest(manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := ioutil.WriteFile(manifestPath(), data, 0644); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", manifestPath(), err)
+	}
+	r.manifestMu.Lock()
+	r.manifest = manifest
+	r.manifestMu.Unlock()
+	return nil
+}
+
+func (r *Reloader) handleManifest(w http.ResponseWriter, req *http.Request) {
+	r.manifestMu.Lock()
+	manifest := r.manifest
+	r.manifestMu.Unlock()
+	if manifest == nil {
+		writeControlResponse(w, nil, xerrors.New("no successful build yet"))
+		return
+	}
+	writeControlResponse(w, manifest, nil)
+}
@@ -0,0 +1,198 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// buildMetrics are counters of builds and restarts since rebirth started.
+type buildMetrics struct {
+	mu                 sync.Mutex
+	startedAt          time.Time
+	builds             int
+	buildFailures      int
+	restarts           int
+	lastBuildAt        time.Time
+	lastBuildDuration  time.Duration
+	lastBuildSucceeded bool
+	lastBuildError     string
+}
+
+func newBuildMetrics() *buildMetrics {
+	return &buildMetrics{startedAt: time.Now()}
+}
+
+func (m *buildMetrics) recordBuild(start time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.builds++
+	m.lastBuildAt = time.Now()
+	m.lastBuildDuration = m.lastBuildAt.Sub(start)
+	m.lastBuildSucceeded = err == nil
+	m.lastBuildError = ""
+	if err != nil {
+		m.buildFailures++
+		m.lastBuildError = errorSummary(err)
+	}
+}
+
+func (m *buildMetrics) recordRestart() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.restarts++
+}
+
+// Metrics is the state of the running rebirth served on status.addr .
+type Metrics struct {
+	State      string `json:"state"`
+	Generation int    `json:"generation"`
+	// Pid is the pid of the application ( on the container or the remote machine for host.docker and host.ssh ). 0 if it isn't running.
+	Pid           int        `json:"pid"`
+	LastBuild     *LastBuild `json:"last_build,omitempty"`
+	Builds        int        `json:"builds"`
+	BuildFailures int        `json:"build_failures"`
+	Restarts      int        `json:"restarts"`
+	WatchedFiles  int        `json:"watched_files"`
+	UptimeSeconds float64    `json:"uptime_seconds"`
+}
+
+// LastBuild is the result of the last build.
+type LastBuild struct {
+	At              time.Time `json:"at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Succeeded       bool      `json:"succeeded"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// SetWatchedFilesCounter sets the function returning the number of watched files ( e.g. Watcher.WatchedFiles ) reported by Metrics .
+func (r *Reloader) SetWatchedFilesCounter(counter func() int) {
+	r.watchedFiles = counter
+}
+
+// Metrics returns the current state, build results and counters of the running rebirth.
+func (r *Reloader) Metrics() *Metrics {
+	status := r.Status()
+	m := r.metrics
+	m.mu.Lock()
+	metrics := &Metrics{
+		State:         status.State,
+		Generation:    status.Generation,
+		Builds:        m.builds,
+		BuildFailures: m.buildFailures,
+		Restarts:      m.restarts,
+		UptimeSeconds: time.Since(m.startedAt).Seconds(),
+	}
+	if !m.lastBuildAt.IsZero() {
+		metrics.LastBuild = &LastBuild{
+			At:              m.lastBuildAt,
+			DurationSeconds: m.lastBuildDuration.Seconds(),
+			Succeeded:       m.lastBuildSucceeded,
+			Error:           m.lastBuildError,
+		}
+	}
+	m.mu.Unlock()
+	metrics.Pid = r.applicationPid()
+	if r.watchedFiles != nil {
+		metrics.WatchedFiles = r.watchedFiles()
+	}
+	return metrics
+}
+
+// applicationPid returns the pid of the running application, or 0 if it isn't running.
+func (r *Reloader) applicationPid() int {
+	r.statusMu.Lock()
+	cmd, client := r.cmd, r.agent
+	r.statusMu.Unlock()
+	if client != nil {
+		res, err := client.Status()
+		if err != nil || !res.Running {
+			return 0
+		}
+		return res.Pid
+	}
+	if cmd == nil || !cmd.IsRunning() {
+		return 0
+	}
+	return cmd.Pid()
+}
+
+// serveStatus serves Metrics as JSON on / and in the Prometheus text format on /metrics at status.addr .
+func (r *Reloader) serveStatus() error {
+	if r.cfg.Status == nil || r.cfg.Status.Addr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", r.cfg.Status.Addr)
+	if err != nil {
+		return xerrors.Errorf("failed to listen %s: %w", r.cfg.Status.Addr, err)
+	}
+	r.statusListener = listener
+	r.logger.Infof("Serving status on http://%s ( /metrics for Prometheus )", listener.Addr())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" && req.URL.Path != "/status" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Metrics())
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePrometheusMetrics(w, r.Metrics())
+	})
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func (r *Reloader) closeStatus() {
+	if r.statusListener == nil {
+		return
+	}
+	r.statusListener.Close()
+	r.statusListener = nil
+}
+
+// prometheusStates are values of the rebirth_state gauge.
+var prometheusStates = []string{StateBuilding, StateRunning, StateFailed, StateObserving}
+
+func writePrometheusMetrics(w io.Writer, m *Metrics) {
+	gauge := func(name, help string, value interface{}) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	counter := func(name, help string, value int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	fmt.Fprintf(w, "# HELP rebirth_state Current state of rebirth.\n# TYPE rebirth_state gauge\n")
+	for _, state := range prometheusStates {
+		value := 0
+		if m.State == state {
+			value = 1
+		}
+		fmt.Fprintf(w, "rebirth_state{state=%q} %d\n", state, value)
+	}
+	gauge("rebirth_generation", "Generation of the last successful build.", m.Generation)
+	gauge("rebirth_application_pid", "Pid of the running application. 0 if it isn't running.", m.Pid)
+	counter("rebirth_builds_total", "Number of builds.", m.Builds)
+	counter("rebirth_build_failures_total", "Number of failed builds.", m.BuildFailures)
+	counter("rebirth_restarts_total", "Number of restarts of the application.", m.Restarts)
+	gauge("rebirth_watched_files", "Number of watched files.", m.WatchedFiles)
+	gauge("rebirth_uptime_seconds", "Seconds since rebirth started.", m.UptimeSeconds)
+	if m.LastBuild != nil {
+		success := 0
+		if m.LastBuild.Succeeded {
+			success = 1
+		}
+		gauge("rebirth_last_build_timestamp_seconds", "Unix time of the last build.", m.LastBuild.At.Unix())
+		gauge("rebirth_last_build_duration_seconds", "Duration of the last build.", m.LastBuild.DurationSeconds)
+		gauge("rebirth_last_build_success", "1 if the last build succeeded.", success)
+	}
+	fmt.Fprintf(w, "# HELP rebirth_info Process of rebirth.\n# TYPE rebirth_info gauge\nrebirth_info{pid=\"%d\"} 1\n", os.Getpid())
+}
@@ -0,0 +1,223 @@
+package rebirth
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	microarchAuto = "auto"
+	microarchOff  = "off"
+)
+
+var (
+	amd64Levels = []struct {
+		level string
+		flags []string
+	}{
+		{level: "v4", flags: []string{"avx512f", "avx512bw", "avx512cd", "avx512dq", "avx512vl"}},
+		{level: "v3", flags: []string{"avx", "avx2", "bmi1", "bmi2", "f16c", "fma", "abm", "movbe", "xsave"}},
+		{level: "v2", flags: []string{"cx16", "lahf_lm", "popcnt", "sse4_1", "sse4_2", "ssse3"}},
+	}
+	microarchCache   = map[string]*targetCPU{}
+	microarchCacheMu sync.Mutex
+
+	// microarchGoVersions are minor versions of Go supporting the variables. Older toolchains reject unknown GOARM64 .
+	microarchGoVersions = map[string]int{
+		"GOAMD64": 18,
+		"GOARM64": 23,
+	}
+	goMinorVersionOnce sync.Once
+	goMinorVersion     int
+)
+
+type targetCPU struct {
+	machine  string
+	features map[string]struct{}
+}
+
+func (cpu *targetCPU) has(features ...string) bool {
+	for _, feature := range features {
+		if _, exists := cpu.features[feature]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// goamd64 returns the highest GOAMD64 level supported by the target.
+// Every level requires all features of lower levels.
+func (cpu *targetCPU) goamd64() string {
+	level := "v1"
+	for i := len(amd64Levels) - 1; i >= 0; i-- {
+		if !cpu.has(amd64Levels[i].flags...) {
+			break
+		}
+		level = amd64Levels[i].level
+	}
+	return level
+}
+
+func (cpu *targetCPU) goarm() string {
+	switch {
+	case strings.HasPrefix(cpu.machine, "armv5"):
+		return "5"
+	case strings.HasPrefix(cpu.machine, "armv6"):
+		return "6"
+	case strings.HasPrefix(cpu.machine, "armv7"), strings.HasPrefix(cpu.machine, "armv8"):
+		if cpu.has("vfpv3") {
+			return "7"
+		}
+		return "6"
+	}
+	return ""
+}
+
+func (cpu *targetCPU) goarm64() string {
+	v := "v8.0"
+	if cpu.has("atomics") {
+		v += ",lse"
+	}
+	if cpu.has("aes", "pmull", "sha1", "sha2") {
+		v += ",crypto"
+	}
+	return v
+}
+
+// env returns GO{ARM,AMD64,ARM64} environment variables for the target.
+func (cpu *targetCPU) env() []string {
+	switch cpu.machine {
+	case "x86_64", "amd64":
+		return []string{fmt.Sprintf("GOAMD64=%s", cpu.goamd64())}
+	case "aarch64", "arm64":
+		return []string{fmt.Sprintf("GOARM64=%s", cpu.goarm64())}
+	}
+	if goarm := cpu.goarm(); goarm != "" {
+		return []string{fmt.Sprintf("GOARM=%s", goarm)}
+	}
+	return []string{}
+}
+
+func parseCPUInfo(machine, cpuinfo string) *targetCPU {
+	cpu := &targetCPU{
+		machine:  strings.TrimSpace(machine),
+		features: map[string]struct{}{},
+	}
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		if key != "flags" && key != "Features" {
+			continue
+		}
+		for _, feature := range strings.Fields(kv[1]) {
+			cpu.features[feature] = struct{}{}
+		}
+	}
+	return cpu
+}
+
+// detectTargetCPU detects CPU of the target by output of given command runner.
+// The result is cached by name of the target.
+func detectTargetCPU(name string, output func(args ...string) ([]byte, error)) (*targetCPU, error) {
+	microarchCacheMu.Lock()
+	defer microarchCacheMu.Unlock()
+	if cpu, exists := microarchCache[name]; exists {
+		return cpu, nil
+	}
+	machine, err := output("uname", "-m")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get machine name: %w", err)
+	}
+	cpuinfo, err := output("cat", "/proc/cpuinfo")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get cpuinfo: %w", err)
+	}
+	cpu := parseCPUInfo(string(machine), string(cpuinfo))
+	microarchCache[name] = cpu
+	return cpu, nil
+}
+
+func containerCPU(container string) (*targetCPU, error) {
+	cpu, err := detectTargetCPU(container, func(args ...string) ([]byte, error) {
+		return NewDockerCommand(container, args...).Output()
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to detect cpu of container %s: %w", container, err)
+	}
+	return cpu, nil
+}
+
+// microarchEnv returns environment variables for microarchitecture of the target.
+// The configured value is used if it isn't auto, and no variable is set for off.
+// Detected values are dropped if go on localhost doesn't support the variable.
+func microarchEnv(cfg *Microarch, cpu *targetCPU) []string {
+	if cfg == nil {
+		cfg = &Microarch{}
+	}
+	detected := map[string]string{}
+	for _, kv := range cpu.env() {
+		v := strings.SplitN(kv, "=", 2)
+		detected[v[0]] = v[1]
+	}
+	env := []string{}
+	for _, setting := range []struct {
+		name  string
+		value string
+	}{
+		{name: "GOARM", value: cfg.GOARM},
+		{name: "GOAMD64", value: cfg.GOAMD64},
+		{name: "GOARM64", value: cfg.GOARM64},
+	} {
+		switch setting.value {
+		case microarchOff:
+		case "", microarchAuto:
+			if !supportsMicroarchEnv(setting.name) {
+				continue
+			}
+			if v, exists := detected[setting.name]; exists {
+				env = append(env, fmt.Sprintf("%s=%s", setting.name, v))
+			}
+		default:
+			env = append(env, fmt.Sprintf("%s=%s", setting.name, setting.value))
+		}
+	}
+	return env
+}
+
+// supportsMicroarchEnv returns true if go on localhost supports the variable. It's true if the version is unknown ( e.g. devel ).
+func supportsMicroarchEnv(name string) bool {
+	goMinorVersionOnce.Do(func() {
+		out, err := exec.Command("go", "version").Output()
+		if err != nil {
+			return
+		}
+		goMinorVersion = parseGoMinorVersion(string(out))
+	})
+	return goMinorVersion == 0 || goMinorVersion >= microarchGoVersions[name]
+}
+
+// parseGoMinorVersion returns the minor version of output of go version ( e.g. 22 of go version go1.22.3 linux/amd64 ), or 0 if unknown.
+func parseGoMinorVersion(out string) int {
+	fields := strings.Fields(out)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "go1.") {
+		return 0
+	}
+	minor := strings.TrimPrefix(fields[2], "go1.")
+	end := 0
+	for end < len(minor) && minor[end] >= '0' && minor[end] <= '9' {
+		end++
+	}
+	v, err := strconv.Atoi(minor[:end])
+	if err != nil {
+		return 0
+	}
+	return v
+}
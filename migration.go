@@ -0,0 +1,66 @@
+package rebirth
+
+import (
+	"golang.org/x/xerrors"
+)
+
+func (r *Reloader) migrations() *Migrations {
+	if r.watch == nil || r.watch.Migrations == nil || r.watch.Migrations.Dir == "" {
+		return nil
+	}
+	return r.watch.Migrations
+}
+
+// splitMigrationFiles splits files into files under watch.migrations.dir and the others.
+func (r *Reloader) splitMigrationFiles(files []string) ([]string, []string) {
+	migrations := r.migrations()
+	if migrations == nil {
+		return []string{}, files
+	}
+	matched := []string{}
+	others := []string{}
+	for _, file := range files {
+		if containsPath(migrations.Dir, file) {
+			matched = append(matched, file)
+		} else {
+			others = append(others, file)
+		}
+	}
+	return matched, others
+}
+
+// runMigrationHook runs watch.migrations.hook on the same place as the application ( localhost, container or host.ssh ).
+func (r *Reloader) runMigrationHook() error {
+	migrations := r.migrations()
+	if migrations == nil || migrations.Hook == "" {
+		return nil
+	}
+	r.logger.Infof("Running: %s", migrations.Hook)
+	if r.isDockerMode() {
+		cmd := NewDockerCommand(r.host.Docker, "sh", "-c", migrations.Hook)
+		cmd.AddEnv(r.runEnv())
+		if err := cmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run watch.migrations.hook on container: %w", err)
+		}
+		code, err := cmd.ExitCode()
+		if err != nil {
+			return xerrors.Errorf("failed to get exit code of watch.migrations.hook: %w", err)
+		}
+		if code != 0 {
+			return xerrors.Errorf("watch.migrations.hook exited with status %d", code)
+		}
+		return nil
+	}
+	if r.isSSHMode() {
+		if err := r.newSSHHookCommand(migrations.Hook, r.runEnv()).Run(); err != nil {
+			return xerrors.Errorf("failed to run watch.migrations.hook on %s: %w", r.host.SSH.Host, err)
+		}
+		return nil
+	}
+	cmd := newHookCommand("sh", "-c", migrations.Hook)
+	cmd.AddEnv(r.runEnv())
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("failed to run watch.migrations.hook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package rebirth
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// isCopyMode returns true if the project directory isn't shared with host.docker container ( e.g. the remote daemon ).
+// In this case, the agent and the binary are copied by docker cp to copyModeDir before reloading.
+func (r *Reloader) isCopyMode() bool {
+	return r.isDockerMode() && !r.hasSharedMount(r.host.Docker)
+}
+
+// hasSharedMount returns true if the project directory is mounted on the working directory of the container.
+// It's checked once per container ( e.g. compose recreates the container ).
+func (r *Reloader) hasSharedMount(container string) bool {
+	if r.isRemoteDocker() {
+		return false
+	}
+	r.agentMu.Lock()
+	shared, checked := r.sharedMounts[container]
+	r.agentMu.Unlock()
+	if checked {
+		return shared
+	}
+	shared = checkSharedMount(container)
+	if !shared {
+		r.logger.Infof("Project directory isn't mounted on container %s. The agent and the binary are copied to %s", container, r.copyModeDir())
+	}
+	r.agentMu.Lock()
+	r.sharedMounts[container] = shared
+	r.agentMu.Unlock()
+	return shared
+}
+
+// checkSharedMount reads the file ( mountCheckPath ) written with a random token from the working directory of the container.
+// The container without cat is regarded as not mounted because copying works regardless of mounts.
+func checkSharedMount(container string) bool {
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	file := filepath.Join(cwd, mountCheckPath)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false
+	}
+	if err := ioutil.WriteFile(file, []byte(token), 0644); err != nil {
+		return false
+	}
+	defer os.Remove(file)
+	out, err := NewDockerCommand(container, "cat", filepath.ToSlash(mountCheckPath)).Output()
+	return err == nil && string(out) == token
+}
+
+func (r *Reloader) copyModeDir() string {
+	return path.Join(defaultRemoteDir, filepath.Base(cwd))
+}
+
+// copyModePath returns the path on the container for the file in the project directory.
+func (r *Reloader) copyModePath(file string) (string, error) {
+	rel, err := filepath.Rel(cwd, file)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get relative path of %s: %w", file, err)
+	}
+	return path.Join(r.copyModeDir(), filepath.ToSlash(rel)), nil
+}
+
+// copyToContainer copies the file in the project directory to copyModeDir on the container.
+func (r *Reloader) copyToContainer(file string) error {
+	dst, err := r.copyModePath(file)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return xerrors.Errorf("failed to get mode of %s: %w", file, err)
+	}
+	if err := copyFileToContainer(r.host.Docker, file, dst, info.Mode()); err != nil {
+		return xerrors.Errorf("failed to copy %s to container: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package rebirth
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultHostNetworkEnv = "REBIRTH_HOST"
+	dockerInternalHost    = "host.docker.internal"
+	hostDialTimeout       = 3 * time.Second
+)
+
+func (n *HostNetwork) env() string {
+	if n.Env == "" {
+		return defaultHostNetworkEnv
+	}
+	return n.Env
+}
+
+func (r *Reloader) hostNetwork() *HostNetwork {
+	if r.host == nil {
+		return nil
+	}
+	return r.host.Network
+}
+
+// setupHostNetwork resolves the address of the host machine reachable from the application
+// and verifies connectivity to host.network.check .
+// Unreachable addresses are reported as warnings because the dependencies may start later.
+func (r *Reloader) setupHostNetwork() error {
+	network := r.hostNetwork()
+	if network == nil {
+		return nil
+	}
+	addr, err := r.resolveHostAddress()
+	if err != nil {
+		return xerrors.Errorf("failed to resolve address of host: %w", err)
+	}
+	r.hostAddr = addr
+	r.logger.Infof("Host address for the application: %s=%s", network.env(), addr)
+	for _, target := range network.Check {
+		if !strings.Contains(target, ":") {
+			target = net.JoinHostPort(addr, target)
+		}
+		if err := r.dialFromApp(target); err != nil {
+			if r.isSSHMode() {
+				r.logger.Warnf(
+					"%s isn't reachable from the application: %v\n  the service on the host must listen on the address reachable from %s",
+					target, err, r.host.SSH.Host,
+				)
+			} else if r.isDockerMode() || r.isOnDockerContainer() {
+				r.logger.Warnf(
+					"%s isn't reachable from the application: %v\n  the service on the host must listen on 0.0.0.0 instead of 127.0.0.1 for connections from containers",
+					target, err,
+				)
+			} else {
+				r.logger.Warnf("%s isn't reachable from the application: %v", target, err)
+			}
+			continue
+		}
+		r.logger.Infof("%s is reachable from the application", target)
+	}
+	return nil
+}
+
+// resolveHostAddress returns host.docker.internal if it is resolved on the container ( Docker Desktop ),
+// or the gateway of the container's network ( Linux ). On host.ssh , it's the address of the ssh client.
+func (r *Reloader) resolveHostAddress() (string, error) {
+	switch {
+	case r.isSSHMode():
+		return r.sshClientAddress()
+	case r.isDockerMode():
+		if _, err := r.agent.Lookup(dockerInternalHost); err == nil {
+			return dockerInternalHost, nil
+		}
+		return containerGateway(r.host.Docker)
+	case r.isOnDockerContainer():
+		if _, err := net.LookupHost(dockerInternalHost); err == nil {
+			return dockerInternalHost, nil
+		}
+		return defaultGateway()
+	}
+	return "localhost", nil
+}
+
+// dialFromApp checks connectivity to addr from the place where the application runs.
+func (r *Reloader) dialFromApp(addr string) error {
+	if r.isDockerMode() || r.isSSHMode() {
+		if _, err := r.agent.Dial(addr); err != nil {
+			return xerrors.Errorf("failed to dial on %s: %w", r.agentTarget(), err)
+		}
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", addr, hostDialTimeout)
+	if err != nil {
+		return xerrors.Errorf("failed to dial: %w", err)
+	}
+	conn.Close()
+	return nil
+}
+
+func (r *Reloader) hostNetworkEnv() []string {
+	network := r.hostNetwork()
+	if network == nil || r.hostAddr == "" {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("%s=%s", network.env(), r.hostAddr)}
+}
+
+// containerGateway returns the gateway of the container's network.
+func containerGateway(container string) (string, error) {
+	info, err := containerRuntime().Inspect(context.Background(), container)
+	if err != nil {
+		return "", xerrors.Errorf("failed to inspect container %s: %w", container, err)
+	}
+	if info.Gateway == "" {
+		return "", xerrors.Errorf("gateway of container %s isn't found", container)
+	}
+	return info.Gateway, nil
+}
+
+// defaultGateway returns the default gateway from /proc/net/route on the container.
+func defaultGateway() (string, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", xerrors.Errorf("failed to open /proc/net/route: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Iface Destination Gateway ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.LittleEndian.PutUint32(ip, binary.BigEndian.Uint32(b))
+		return ip.String(), nil
+	}
+	return "", xerrors.New("default gateway isn't found")
+}
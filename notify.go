@@ -0,0 +1,143 @@
+package rebirth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	notifyOnFailure  = "failure"
+	notifyOnRecovery = "recovery"
+
+	notifyTitle          = "rebirth"
+	notifyWebhookTimeout = 10 * time.Second
+)
+
+// buildNotifier remembers the last notified error for notifying only changes of build results.
+type buildNotifier struct {
+	mu        sync.Mutex
+	lastError string
+}
+
+// notifies returns true if notify.on contains event ( default: failure and recovery ).
+func (n *Notify) notifies(event string) bool {
+	if len(n.On) == 0 {
+		return true
+	}
+	return containsString(n.On, event)
+}
+
+func (n *Notify) validate() error {
+	for _, event := range n.On {
+		if event != notifyOnFailure && event != notifyOnRecovery {
+			return xerrors.Errorf("unknown notify.on %q. failure or recovery is available", event)
+		}
+	}
+	return nil
+}
+
+// notifyBuildResult sends notifications of notify when the build fails with a new error,
+// and when it succeeds after failures. Repeated failures by the same error are notified once.
+func (r *Reloader) notifyBuildResult(err error) {
+	notify := r.cfg.Notify
+	if notify == nil {
+		return
+	}
+	summary := ""
+	if err != nil {
+		summary = buildErrorSummary(r.buildTail.Lines(), err)
+	}
+	n := &r.notifier
+	n.mu.Lock()
+	last := n.lastError
+	n.lastError = summary
+	n.mu.Unlock()
+	var message string
+	switch {
+	case err != nil && summary != last && notify.notifies(notifyOnFailure):
+		message = fmt.Sprintf("%s: build failed. %s", filepath.Base(cwd), summary)
+	case err == nil && last != "" && notify.notifies(notifyOnRecovery):
+		message = fmt.Sprintf("%s: build recovered ( #%d )", filepath.Base(cwd), r.generation)
+	default:
+		return
+	}
+	// notifications must not delay reloading
+	go r.sendNotification(notify, message)
+}
+
+func (r *Reloader) sendNotification(notify *Notify, message string) {
+	if notify.Desktop {
+		if err := notifyDesktop(notifyTitle, message); err != nil {
+			r.logger.Warnf("failed to show desktop notification: %v", err)
+		}
+	}
+	for _, url := range notify.Webhooks {
+		if err := notifyWebhook(url, message); err != nil {
+			r.logger.Warnf("failed to notify webhook: %v", err)
+		}
+	}
+}
+
+// notifyDesktop shows the notification by osascript on macOS, notify-send on Linux and PowerShell on Windows.
+func notifyDesktop(title, message string) error {
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+		args = []string{"osascript", "-e", script}
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms;`+
+			`$n = New-Object System.Windows.Forms.NotifyIcon;`+
+			`$n.Icon = [System.Drawing.SystemIcons]::Information;`+
+			`$n.Visible = $true;`+
+			`$n.ShowBalloonTip(5000, %s, %s, 'None');`+
+			`Start-Sleep -Seconds 5; $n.Dispose()`, powerShellQuote(title), powerShellQuote(message))
+		args = []string{"powershell", "-NoProfile", "-Command", script}
+	default:
+		args = []string{"notify-send", title, message}
+	}
+	if err := NewCommand(args...).Run(); err != nil {
+		return xerrors.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// notifyWebhook posts the Slack compatible payload ( {"text": message} ) to url.
+func notifyWebhook(url, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return xerrors.Errorf("failed to encode payload: %w", err)
+	}
+	client := &http.Client{Timeout: notifyWebhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// the url may contain the secret of the webhook
+		if urlErr, ok := err.(*neturl.Error); ok {
+			err = urlErr.Err
+		}
+		return xerrors.Errorf("failed to request to webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return xerrors.Errorf("unexpected status code from webhook: %d", resp.StatusCode)
+	}
+	return nil
+}
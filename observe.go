@@ -0,0 +1,123 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const observeInterval = time.Second
+
+// observer is the process ( or the container ) whose lifecycle is owned by another tool.
+// rebirth only watches files, checks builds and reports them, and never restarts it.
+type observer struct {
+	pid       int
+	container string
+}
+
+func (o *observer) String() string {
+	if o.container != "" {
+		return "container " + o.container
+	}
+	return fmt.Sprintf("process(%d)", o.pid)
+}
+
+// alive returns true if the observed process exists or the observed container is running.
+func (o *observer) alive() (bool, error) {
+	if o.container == "" {
+		return processExists(o.pid), nil
+	}
+	info, err := containerRuntime().Inspect(context.Background(), o.container)
+	if err != nil {
+		return false, xerrors.Errorf("failed to inspect container %s: %w", o.container, err)
+	}
+	return info.Running, nil
+}
+
+// Observe makes Run attach to the running process by pid or the container instead of starting the application.
+// Changes are only checked by building, and the process is never restarted.
+func (r *Reloader) Observe(pid int, container string) {
+	r.observer = &observer{pid: pid, container: container}
+}
+
+func (r *Reloader) isObserveMode() bool {
+	return r.observer != nil
+}
+
+// runObserver checks builds until ctx is canceled.
+func (r *Reloader) runObserver(ctx context.Context) error {
+	if r.isTargetsMode() || r.isWasmMode() {
+		return xerrors.New("observer mode doesn't support targets and wasm")
+	}
+	if (r.observer.pid == 0) == (r.observer.container == "") {
+		return xerrors.New("either pid or container must be specified for observer mode")
+	}
+	alive, err := r.observer.alive()
+	if err != nil {
+		return xerrors.Errorf("failed to check %s: %w", r.observer, err)
+	}
+	if !alive {
+		return xerrors.Errorf("%s isn't running", r.observer)
+	}
+	r.logger.Infof("Observing %s. changes are checked by building without restarting it", r.observer)
+	if err := r.runBuildInitCommands(); err != nil {
+		return xerrors.Errorf("failed to build.init commands: %w", err)
+	}
+	if err := r.checkBuild(); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+	go r.watchObserved(ctx)
+	<-ctx.Done()
+	return nil
+}
+
+// checkBuild builds the application for reporting build errors of the change without restarting the observed process.
+func (r *Reloader) checkBuild() error {
+	r.lockReload()
+	defer r.unlockReload()
+	start := time.Now()
+	r.setState(StateBuilding, "")
+	r.emitBuildStart()
+	err := r.xbuild(observeBuildPath, r.build.main())
+	if recordErr := r.recordBuild(start, err); recordErr != nil {
+		r.logger.Errorf("%v", recordErr)
+	}
+	r.recordBuildResult(err)
+	r.emitBuildResult(start, err)
+	if err != nil {
+		r.setState(StateFailed, buildErrorSummary(r.buildTail.Lines(), err))
+		return xerrors.Errorf("failed to check build: %w", err)
+	}
+	r.setState(StateObserving, "")
+	r.logger.Infof("Build succeeded in %s. %s isn't restarted by observer mode", time.Since(start).Round(time.Millisecond), r.observer)
+	return nil
+}
+
+// watchObserved reports exits ( and restarts of the container ) of the observed process.
+func (r *Reloader) watchObserved(ctx context.Context) {
+	running := true
+	ticker := time.NewTicker(observeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		alive, err := r.observer.alive()
+		if err != nil {
+			r.logger.Errorf("%v", err)
+			continue
+		}
+		switch {
+		case running && !alive:
+			r.logger.Warnf("%s exited. rebirth keeps checking builds", r.observer)
+			r.emitProcessExit(&ProcessExitEvent{Pid: r.observer.pid, Status: "exited"})
+		case !running && alive:
+			r.logger.Infof("%s is running again", r.observer)
+		}
+		running = alive
+	}
+}
@@ -0,0 +1,355 @@
+package rebirth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+const (
+	colorModeAuto   = "auto"
+	colorModeAlways = "always"
+	colorModeNever  = "never"
+)
+
+var (
+	colorNames = map[string]string{
+		"red":     colorRed,
+		"green":   colorGreen,
+		"yellow":  colorYellow,
+		"blue":    colorBlue,
+		"magenta": colorMagenta,
+		"cyan":    colorCyan,
+		"gray":    colorGray,
+	}
+	// prefixColors are assigned to prefixes of targets in order.
+	prefixColors = []string{colorCyan, colorMagenta, colorGreen, colorBlue, colorYellow}
+)
+
+var (
+	jsonLevelKeys   = []string{"level", "lvl", "severity"}
+	jsonMessageKeys = []string{"msg", "message"}
+	jsonTimeKeys    = []string{"time", "ts", "timestamp"}
+)
+
+// lineWriter calls fn for each line written to it.
+type lineWriter struct {
+	mu      sync.Mutex
+	partial []byte
+	fn      func(line []byte)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		line := make([]byte, idx+1)
+		copy(line, w.partial[:idx+1])
+		w.partial = w.partial[idx+1:]
+		w.fn(line)
+	}
+	return len(p), nil
+}
+
+// flush calls fn for the last line without newline.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) == 0 {
+		return
+	}
+	w.fn(w.partial)
+	w.partial = nil
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// prettyJSON renders JSON log line human-readably.
+type prettyJSON struct {
+	cfg     *Output
+	colored bool
+}
+
+func (p *prettyJSON) render(line []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(trimmed, &entry); err != nil {
+		return nil, false
+	}
+	ts := p.take(entry, jsonTimeKeys)
+	level := p.take(entry, jsonLevelKeys)
+	msg := p.take(entry, jsonMessageKeys)
+	var b strings.Builder
+	if ts != "" {
+		b.WriteString(p.color(colorGray, ts))
+		b.WriteString(" ")
+	}
+	if level != "" {
+		b.WriteString(p.color(p.levelColor(level), fmt.Sprintf("%-5s", strings.ToUpper(level))))
+		b.WriteString(" ")
+	}
+	b.WriteString(msg)
+	for _, key := range p.fieldKeys(entry) {
+		value, exists := entry[key]
+		if !exists {
+			continue
+		}
+		b.WriteString(" ")
+		b.WriteString(p.color(colorBlue, key+"="))
+		b.WriteString(p.format(value))
+	}
+	b.WriteString("\n")
+	return []byte(b.String()), true
+}
+
+func (p *prettyJSON) take(entry map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if value, exists := entry[key]; exists {
+			delete(entry, key)
+			return p.format(value)
+		}
+	}
+	return ""
+}
+
+func (p *prettyJSON) fieldKeys(entry map[string]interface{}) []string {
+	if len(p.cfg.Fields) > 0 {
+		return p.cfg.Fields
+	}
+	keys := []string{}
+	for key := range entry {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (p *prettyJSON) format(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func (p *prettyJSON) levelColor(level string) string {
+	switch strings.ToLower(level) {
+	case "error", "err", "fatal", "panic", "critical":
+		return colorRed
+	case "warn", "warning":
+		return colorYellow
+	case "info":
+		return colorGreen
+	}
+	return colorGray
+}
+
+func (p *prettyJSON) color(color, s string) string {
+	if !p.colored {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// parseColor returns the escape sequence of the color name ( e.g. cyan ). Empty name is def .
+func parseColor(name, def string) (string, error) {
+	if name == "" {
+		return def, nil
+	}
+	color, exists := colorNames[strings.ToLower(name)]
+	if !exists {
+		return "", xerrors.Errorf("unknown color %s. red, green, yellow, blue, magenta, cyan and gray are available", name)
+	}
+	return color, nil
+}
+
+// assignedPrefixColor returns the color assigned to name in sorted names ( e.g. targets and services ).
+func assignedPrefixColor(names []string, name string) string {
+	for i, n := range names {
+		if n == name {
+			return prefixColors[i%len(prefixColors)]
+		}
+	}
+	return colorCyan
+}
+
+// newPrefixWriter writes each line to dst with the prefix ( e.g. `[app]` ).
+// If highlightErrors is true, lines of build errors ( file.go:line:col: ) are colored red.
+func newPrefixWriter(dst io.Writer, prefix, color string, colored, highlightErrors bool) io.Writer {
+	head := ""
+	switch {
+	case prefix != "" && colored:
+		head = color + prefix + colorReset + " "
+	case prefix != "":
+		head = prefix + " "
+	}
+	return &lineWriter{fn: func(line []byte) {
+		if colored && highlightErrors && buildErrorLocationPattern.Match(line) {
+			line = []byte(colorRed + strings.TrimRight(string(line), "\n") + colorReset + "\n")
+		}
+		dst.Write(append([]byte(head), line...))
+	}}
+}
+
+// appOutput is the destination of the application's output.
+// The raw output is written to run.output.file, and the terminal output is filtered by run.output .
+type appOutput struct {
+	cfg    *Output
+	raw    *os.File
+	stdout io.Writer
+	stderr io.Writer
+	tees   []func() io.Writer
+
+	// dashboard receives the terminal output instead of stdout and stderr if it's enabled.
+	dashboard *dashboard
+}
+
+func newAppOutput(cfg *Output) (*appOutput, error) {
+	if cfg == nil {
+		cfg = &Output{}
+	}
+	switch cfg.Color {
+	case "", colorModeAuto, colorModeAlways, colorModeNever:
+	default:
+		return nil, xerrors.Errorf("unknown run.output.color %s. auto, always and never are available", cfg.Color)
+	}
+	color, err := parseColor(cfg.PrefixColor, colorCyan)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid run.output.prefix_color: %w", err)
+	}
+	out := &appOutput{cfg: cfg}
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to open %s: %w", cfg.File, err)
+		}
+		out.raw = file
+	}
+	out.stdout, out.stderr = out.prefixed(cfg.Prefix, color)
+	return out, nil
+}
+
+// colored returns true if the terminal output is colored by run.output.color .
+// In auto mode, output is colored if it's a terminal and NO_COLOR isn't set.
+func (o *appOutput) colored(terminal *os.File) bool {
+	if o.dashboard != nil {
+		// panes are rendered without colors
+		return false
+	}
+	switch o.cfg.Color {
+	case colorModeAlways:
+		return true
+	case colorModeNever:
+		return false
+	}
+	return isTerminal(terminal) && os.Getenv("NO_COLOR") == ""
+}
+
+func (o *appOutput) newWriter(terminal *os.File, prefix, color string) io.Writer {
+	var dst io.Writer = terminal
+	if o.dashboard != nil {
+		dst = o.dashboard.writer(o.dashboard.app)
+	}
+	if prefix != "" {
+		dst = newPrefixWriter(dst, prefix, color, o.colored(terminal), false)
+	}
+	if o.cfg.PrettyJSON {
+		pretty := &prettyJSON{cfg: o.cfg, colored: o.colored(terminal)}
+		prefixed := dst
+		dst = &lineWriter{fn: func(line []byte) {
+			if rendered, ok := pretty.render(line); ok {
+				prefixed.Write(rendered)
+				return
+			}
+			prefixed.Write(line)
+		}}
+	}
+	writers := []io.Writer{dst}
+	if o.raw != nil {
+		writers = append(writers, o.raw)
+	}
+	for _, newWriter := range o.tees {
+		writers = append(writers, newWriter())
+	}
+	if len(writers) == 1 {
+		return dst
+	}
+	return io.MultiWriter(writers...)
+}
+
+// prefixed returns stdout and stderr prefixing lines on the terminal by prefix ( e.g. `[api]` for targets ).
+// The raw output and writers of tee don't have the prefix.
+func (o *appOutput) prefixed(prefix, color string) (io.Writer, io.Writer) {
+	return o.newWriter(os.Stdout, prefix, color), o.newWriter(os.Stderr, prefix, color)
+}
+
+// buildOutput returns stdout and stderr for the go command prefixed by build.output_prefix .
+// Build errors are colored red if the output is colored.
+func (o *appOutput) buildOutput(prefix, color string) (io.Writer, io.Writer) {
+	if o.dashboard != nil {
+		pane := o.dashboard.build
+		return newPrefixWriter(o.dashboard.writer(pane), prefix, color, false, false),
+			newPrefixWriter(o.dashboard.writer(pane), prefix, color, false, false)
+	}
+	if prefix == "" && !o.colored(os.Stderr) {
+		return os.Stdout, os.Stderr
+	}
+	return newPrefixWriter(os.Stdout, prefix, color, o.colored(os.Stdout), true),
+		newPrefixWriter(os.Stderr, prefix, color, o.colored(os.Stderr), true)
+}
+
+// useDashboard writes the terminal output to panes of the dashboard.
+func (o *appOutput) useDashboard(d *dashboard) {
+	o.dashboard = d
+	color, _ := parseColor(o.cfg.PrefixColor, colorCyan)
+	o.stdout, o.stderr = o.prefixed(o.cfg.Prefix, color)
+}
+
+// tee writes stdout and stderr to writers created by newWriter too.
+func (o *appOutput) tee(newWriter func() io.Writer) {
+	o.tees = append(o.tees, newWriter)
+	o.stdout = io.MultiWriter(o.stdout, newWriter())
+	o.stderr = io.MultiWriter(o.stderr, newWriter())
+}
+
+func (o *appOutput) Close() error {
+	if o.raw == nil {
+		return nil
+	}
+	if err := o.raw.Close(); err != nil {
+		return xerrors.Errorf("failed to close %s: %w", o.cfg.File, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package rebirth
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultEditorURL = "vscode://file{file}:{line}"
+
+// buildFailure is the failed build shown by the error overlay of the proxy.
+type buildFailure struct {
+	Time  time.Time
+	Error string
+	Lines []*overlayLine
+}
+
+// overlayLine is a line of the build output. Href is the editor link if the line has the location of the error.
+type overlayLine struct {
+	Text string
+	Href template.URL
+}
+
+func (p *Proxy) editorURL() string {
+	if p.EditorURL == "" {
+		return defaultEditorURL
+	}
+	return p.EditorURL
+}
+
+// editorLink returns the link to the location ( e.g. ./main.go:10:2: ) in the line by editor_url .
+func (p *Proxy) editorLink(line string) string {
+	matches := buildErrorLocationPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return ""
+	}
+	file := matches[1]
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(cwd, file)
+	}
+	return strings.NewReplacer("{file}", filepath.ToSlash(file), "{line}", matches[2]).Replace(p.editorURL())
+}
+
+// showBuildFailure makes the proxy serve the error overlay instead of the stale application until the next successful build.
+// Browsers showing the overlay are reloaded to show the new error.
+func (r *Reloader) showBuildFailure(lines []string, err error) {
+	if r.proxy == nil || r.proxy.DisableOverlay {
+		return
+	}
+	failure := &buildFailure{Time: time.Now(), Error: buildErrorSummary(lines, err)}
+	for _, line := range lines {
+		// the link is built from the local build output with the configured scheme ( e.g. vscode:// )
+		failure.Lines = append(failure.Lines, &overlayLine{Text: line, Href: template.URL(r.proxy.editorLink(line))})
+	}
+	r.overlayMu.Lock()
+	r.buildFailure = failure
+	r.overlayMu.Unlock()
+	r.liveReload.broadcast()
+}
+
+// clearBuildFailure stops serving the error overlay, and reloads browsers showing it for the restarted application.
+func (r *Reloader) clearBuildFailure() {
+	r.overlayMu.Lock()
+	shown := r.buildFailure != nil
+	r.buildFailure = nil
+	r.overlayMu.Unlock()
+	if shown {
+		r.liveReload.broadcast()
+	}
+}
+
+// serveOverlay serves the error overlay if the last build failed. It returns false if the application should serve req.
+// Pages get the styled HTML, and other requests ( e.g. fetch from the frontend ) get the plain text.
+func (r *Reloader) serveOverlay(w http.ResponseWriter, req *http.Request) bool {
+	r.overlayMu.Lock()
+	failure := r.buildFailure
+	r.overlayMu.Unlock()
+	if failure == nil {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if !strings.Contains(req.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "rebirth: build failed\n%s\n", failure.Error)
+		for _, line := range failure.Lines {
+			fmt.Fprintln(w, line.Text)
+		}
+		return true
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := overlayTemplate.Execute(w, map[string]interface{}{
+		"Failure":    failure,
+		"Time":       failure.Time.Format("15:04:05"),
+		"ScriptPath": liveReloadScriptPath,
+	}); err != nil {
+		r.logger.Errorf("failed to render error overlay: %v", err)
+	}
+	return true
+}
+
+var overlayTemplate = template.Must(template.New("overlay").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Build failed - rebirth</title>
+<style>
+  body { margin: 0; background: #1e1e1e; color: #e8e8e8; font-family: Menlo, Consolas, monospace; }
+  header { padding: 16px 24px; background: #b71c1c; color: #fff; }
+  header h1 { margin: 0; font-size: 18px; }
+  header p { margin: 4px 0 0; font-size: 13px; opacity: 0.8; }
+  pre { margin: 0; padding: 16px 24px; font-size: 13px; line-height: 1.5; white-space: pre-wrap; }
+  a { color: #ff8a80; }
+  .error { color: #ff8a80; }
+  footer { padding: 0 24px 16px; font-size: 12px; color: #9e9e9e; }
+</style>
+</head>
+<body>
+<header>
+  <h1>Build failed</h1>
+  <p>{{ .Time }} {{ .Failure.Error }}</p>
+</header>
+<pre>{{ range .Failure.Lines }}{{ if .Href }}<a class="error" href="{{ .Href }}">{{ .Text }}</a>{{ else }}{{ .Text }}{{ end }}
+{{ end }}</pre>
+<footer>This page is reloaded when the build succeeds.</footer>
+<script src="{{ .ScriptPath }}"></script>
+</body>
+</html>
+`))
@@ -0,0 +1,93 @@
+package rebirth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// errFileLocked is returned by lockFile if another process holds the lock.
+var errFileLocked = xerrors.New("file is locked")
+
+// lockPidFile writes the pid of rebirth to .rebirth/rebirth.pid and locks it until rebirth exits,
+// so two rebirth can't run for the same project. The lock is released by OS even if rebirth is killed,
+// so the pid in the file which isn't locked is stale.
+func (r *Reloader) lockPidFile() error {
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", configDir, err)
+	}
+	file, err := os.OpenFile(pidPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", pidPath, err)
+	}
+	if err := lockFile(file, true); err != nil {
+		defer file.Close()
+		if err == errFileLocked {
+			if pid := readPid(file); pid != 0 {
+				return xerrors.Errorf("another rebirth(%d) is already running for this project. %s is locked", pid, pidPath)
+			}
+			return xerrors.Errorf("another rebirth is already running for this project. %s is locked", pidPath)
+		}
+		return xerrors.Errorf("failed to lock %s: %w", pidPath, err)
+	}
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return xerrors.Errorf("failed to truncate %s: %w", pidPath, err)
+	}
+	if _, err := file.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		file.Close()
+		return xerrors.Errorf("failed to write %s: %w", pidPath, err)
+	}
+	r.pidFile = file
+	return nil
+}
+
+// unlockPidFile clears the pid and releases the lock.
+// The file isn't removed because another rebirth may be opening it for locking.
+func (r *Reloader) unlockPidFile() {
+	if r.pidFile == nil {
+		return
+	}
+	r.pidFile.Truncate(0)
+	r.pidFile.Close()
+	r.pidFile = nil
+}
+
+// runningPid returns the pid of rebirth running for the project, or 0 if it isn't running.
+// The pid is trusted only while the pid file is locked, so a process which reused the pid of exited rebirth isn't returned.
+func runningPid() (int, error) {
+	file, err := os.Open(pidPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("failed to open %s: %w", pidPath, err)
+	}
+	defer file.Close()
+	err = lockFile(file, false)
+	if err == nil {
+		// stale pid file of rebirth that exited abnormally
+		unlockFile(file)
+		return 0, nil
+	}
+	if err != errFileLocked {
+		return 0, xerrors.Errorf("failed to check lock of %s: %w", pidPath, err)
+	}
+	return readPid(file), nil
+}
+
+func readPid(file *os.File) int {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package rebirth
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile locks file by flock without blocking. It returns errFileLocked if another process holds the lock.
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return errFileLocked
+		}
+		return err
+	}
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
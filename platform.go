@@ -0,0 +1,113 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/goccy/rebirth/internal/errors"
+	"golang.org/x/xerrors"
+)
+
+// crossCompilers are prefixes of musl-cross compilers for cgo by GOARCH of the linux container.
+var crossCompilers = map[string]string{
+	"amd64": "x86_64-linux-musl",
+	"arm64": "aarch64-linux-musl",
+	"arm":   "arm-linux-musleabihf",
+	"386":   "i486-linux-musl",
+}
+
+var (
+	platformCache   = map[string]*targetPlatform{}
+	platformCacheMu sync.Mutex
+)
+
+type targetPlatform struct {
+	goos   string
+	goarch string
+}
+
+// detectContainerPlatform detects GOOS and GOARCH of the container without go on it.
+// `uname -s -m` is used first because it's the machine actually running the application ( e.g. under emulation ),
+// and the platform of the image is used for images without uname ( e.g. distroless ). The result is cached by the container.
+func detectContainerPlatform(container string) (*targetPlatform, error) {
+	platformCacheMu.Lock()
+	defer platformCacheMu.Unlock()
+	if platform, exists := platformCache[container]; exists {
+		return platform, nil
+	}
+	platform, unameErr := containerUnamePlatform(container)
+	if unameErr != nil {
+		var err error
+		platform, err = containerImagePlatform(container)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to detect platform of container %s by uname ( %v ) and the image: %w", container, unameErr, err)
+		}
+	}
+	platformCache[container] = platform
+	return platform, nil
+}
+
+func containerUnamePlatform(container string) (*targetPlatform, error) {
+	out, err := NewDockerCommand(container, "uname", "-s", "-m").Output()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to run uname: %w", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return nil, xerrors.Errorf("unexpected output of uname: %s", string(out))
+	}
+	goos, exists := unameGOOS[fields[0]]
+	if !exists {
+		return nil, xerrors.Errorf("unsupported os %s", fields[0])
+	}
+	goarch, exists := unameGOARCH[fields[1]]
+	if !exists {
+		return nil, xerrors.Errorf("unsupported architecture %s", fields[1])
+	}
+	return &targetPlatform{goos: goos, goarch: goarch}, nil
+}
+
+func containerImagePlatform(container string) (*targetPlatform, error) {
+	ctx := context.Background()
+	rt := containerRuntime()
+	info, err := rt.Inspect(ctx, container)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to inspect container: %w", err)
+	}
+	goos, goarch, err := rt.ImagePlatform(ctx, info.Image)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to inspect image %s: %w", info.Image, err)
+	}
+	if goos == "" || goarch == "" {
+		return nil, xerrors.Errorf("platform of image %s is unknown", info.Image)
+	}
+	// some images have the name by uname ( e.g. aarch64 )
+	if arch, exists := unameGOARCH[goarch]; exists {
+		goarch = arch
+	}
+	return &targetPlatform{goos: goos, goarch: goarch}, nil
+}
+
+// crossCompilerEnv returns CC and CXX for building with cgo for the platform of the container.
+// Nothing is required if it's the same as the host.
+func crossCompilerEnv(goos, goarch string) ([]string, error) {
+	if goos == runtime.GOOS && goarch == runtime.GOARCH {
+		return []string{}, nil
+	}
+	prefix, exists := crossCompilers[goarch]
+	if goos != "linux" || !exists {
+		return nil, xerrors.Errorf("cross compiler for %s/%s with cgo isn't supported. set CGO_ENABLED: 0 to build.env", goos, goarch)
+	}
+	cc := fmt.Sprintf("%s-cc", prefix)
+	if _, err := exec.LookPath(cc); err != nil {
+		return nil, xerrors.Errorf("%s for %s/%s isn't found: %w", cc, goos, goarch, errors.ErrCrossCompiler)
+	}
+	return []string{
+		fmt.Sprintf("CC=%s", cc),
+		fmt.Sprintf("CXX=%s-c++", prefix),
+	}, nil
+}
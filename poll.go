@@ -0,0 +1,104 @@
+package rebirth
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/fsnotify.v1"
+)
+
+const defaultPollInterval = time.Second
+
+// fileSnapshot is the state of the watched file for polling.
+type fileSnapshot struct {
+	modTime time.Time
+	size    int64
+	hash    []byte
+}
+
+// isPolling returns true if watch.poll is specified.
+func (w *Watcher) isPolling() bool {
+	return w.cfg != nil && w.cfg.Poll
+}
+
+func (w *Watcher) pollInterval() time.Duration {
+	if w.cfg == nil {
+		return defaultPollInterval
+	}
+	return parseDurationOr(w.cfg.PollInterval, defaultPollInterval)
+}
+
+// runPolling detects changes by comparing modification times and sizes of the watched files at the interval.
+// The content hash is compared too for ignoring files touched without modification.
+// Detected changes are passed to the same pipeline as file system events.
+func (w *Watcher) runPolling() {
+	logger().Infof("Polling %s every %s", w.root(), w.pollInterval())
+	snapshots := w.snapshot(nil)
+	go func() {
+		defer w.recoverRuntimeError()
+		for {
+			select {
+			case <-time.After(w.pollInterval()):
+			case <-w.done:
+				return
+			}
+			current := w.snapshot(snapshots)
+			for path, s := range current {
+				prev, exists := snapshots[path]
+				switch {
+				case !exists:
+					w.addEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+				case string(prev.hash) != string(s.hash):
+					w.addEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+				}
+			}
+			for path := range snapshots {
+				if _, exists := current[path]; !exists {
+					w.addEvent(fsnotify.Event{Name: path, Op: fsnotify.Remove})
+				}
+			}
+			snapshots = current
+		}
+	}()
+}
+
+// snapshot takes snapshots of the target files. The hash of prev is reused if the file isn't modified.
+func (w *Watcher) snapshot(prev map[string]*fileSnapshot) map[string]*fileSnapshot {
+	snapshots := map[string]*fileSnapshot{}
+	for _, dir := range w.watchPaths() {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*"))
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if !w.isTargetEvent(fsnotify.Event{Name: path}) {
+				continue
+			}
+			s := &fileSnapshot{modTime: info.ModTime(), size: info.Size()}
+			if p, exists := prev[path]; exists && p.modTime.Equal(s.modTime) && p.size == s.size {
+				s.hash = p.hash
+			} else {
+				s.hash = fileHash(path)
+			}
+			snapshots[path] = s
+		}
+	}
+	return snapshots
+}
+
+func fileHash(path string) []byte {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,98 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+
+	"golang.org/x/xerrors"
+)
+
+const autoPort = "auto"
+
+// assignPorts resolves run.ports . auto is replaced by a free port.
+// Assigned ports are saved to .rebirth/ports.json and reused while they are free,
+// so the application gets the same ports across reloads and rebirth sessions.
+func (r *Reloader) assignPorts() error {
+	if r.run == nil || len(r.run.Ports) == 0 {
+		return nil
+	}
+	saved := map[string]int{}
+	if file, err := ioutil.ReadFile(portsPath); err == nil {
+		json.Unmarshal(file, &saved)
+	}
+	ports := map[string]int{}
+	for name, value := range r.run.Ports {
+		if value != autoPort {
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return xerrors.Errorf("invalid port %q for run.ports.%s: %w", value, name, err)
+			}
+			ports[name] = port
+			continue
+		}
+		if port, exists := saved[name]; exists && isFreePort(port) {
+			ports[name] = port
+			continue
+		}
+		port, err := freePort()
+		if err != nil {
+			return xerrors.Errorf("failed to get free port for %s: %w", name, err)
+		}
+		ports[name] = port
+	}
+	data, err := json.Marshal(ports)
+	if err != nil {
+		return xerrors.Errorf("failed to encode ports: %w", err)
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", configDir, err)
+	}
+	if err := ioutil.WriteFile(portsPath, data, 0644); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", portsPath, err)
+	}
+	r.ports = ports
+	for _, name := range r.portNames() {
+		r.logger.Infof("Assigned port %s=%d", name, r.ports[name])
+	}
+	return nil
+}
+
+func (r *Reloader) portNames() []string {
+	names := []string{}
+	for name := range r.ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Reloader) portEnv() []string {
+	env := []string{}
+	for _, name := range r.portNames() {
+		env = append(env, fmt.Sprintf("%s=%d", name, r.ports[name]))
+	}
+	return env
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, xerrors.Errorf("failed to listen: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func isFreePort(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
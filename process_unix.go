@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package rebirth
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd start in a new process group whose id is the pid of cmd.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process and its descendants in the process group.
+func killProcessGroup(pid int) error {
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// signalProcessGroup sends sig to all processes in the process group.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// processExists returns true if the process of pid exists.
+func processExists(pid int) bool {
+	return syscall.Kill(pid, 0) != syscall.ESRCH
+}
+
+// interruptSelf starts graceful shutdown like Ctrl-C .
+func interruptSelf() error {
+	return syscall.Kill(os.Getpid(), syscall.SIGINT)
+}
+
+// execSelf replaces the process of rebirth by path with args.
+func execSelf(path string, args []string) error {
+	return syscall.Exec(path, args, os.Environ())
+}
@@ -0,0 +1,67 @@
+package rebirth
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup makes cmd start in a new process group not to receive Ctrl-C on the console.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessGroup kills the process and its descendants by taskkill because Windows has no process group to signal.
+func killProcessGroup(pid int) error {
+	if !processExists(pid) {
+		return nil
+	}
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// signalProcessGroup interrupts the process. Signals except SIGKILL can't be sent on Windows,
+// so the process tree is killed if the interrupt isn't supported.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if sig != syscall.SIGKILL {
+		if process, err := os.FindProcess(pid); err == nil && process.Signal(os.Interrupt) == nil {
+			return nil
+		}
+	}
+	return killProcessGroup(pid)
+}
+
+// processExists returns true if the process of pid exists.
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// interruptSelf starts graceful shutdown like Ctrl-C . os.Interrupt can't be sent to a process on Windows,
+// so it's passed to the handler of HandleShutdown directly.
+func interruptSelf() error {
+	if process, err := os.FindProcess(os.Getpid()); err == nil && process.Signal(os.Interrupt) == nil {
+		return nil
+	}
+	return requestShutdown()
+}
+
+// execSelf runs path with args as the new rebirth and exits with its status because Windows has no exec.
+func execSelf(path string, args []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,129 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	ProfileCPU  = "cpu"
+	ProfileHeap = "heap"
+)
+
+// pprofAddr resolves pprof of the application or the service to the address.
+// The value is a port number, host:port or a name of run.ports .
+func pprofAddr(value string) (string, error) {
+	if value == "" {
+		return "", xerrors.New("pprof isn't specified")
+	}
+	if strings.Contains(value, ":") {
+		return value, nil
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		return fmt.Sprintf("localhost:%s", value), nil
+	}
+	ports := map[string]int{}
+	file, err := ioutil.ReadFile(portsPath)
+	if err != nil {
+		return "", xerrors.Errorf("failed to read %s for port %s: %w", portsPath, value, err)
+	}
+	if err := json.Unmarshal(file, &ports); err != nil {
+		return "", xerrors.Errorf("failed to decode %s: %w", portsPath, err)
+	}
+	port, exists := ports[value]
+	if !exists {
+		return "", xerrors.Errorf("port %s isn't assigned", value)
+	}
+	return fmt.Sprintf("localhost:%d", port), nil
+}
+
+func profileTarget(cfg *Config, name string) (string, error) {
+	if name == "" {
+		if cfg.Run == nil || cfg.Run.Pprof == "" {
+			return "", xerrors.New("run.pprof must be specified")
+		}
+		return cfg.Run.Pprof, nil
+	}
+	service, exists := cfg.Services[name]
+	if !exists {
+		return "", xerrors.Errorf("undefined service %s", name)
+	}
+	if service.Pprof == "" {
+		return "", xerrors.Errorf("services.%s.pprof must be specified", name)
+	}
+	return service.Pprof, nil
+}
+
+// CaptureProfile fetches the profile from net/http/pprof of the application ( or the service specified by name )
+// and saves it under .rebirth/profiles . It returns the path of the saved profile.
+func CaptureProfile(cfg *Config, name, kind string, seconds int) (string, error) {
+	target, err := profileTarget(cfg, name)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get pprof: %w", err)
+	}
+	addr, err := pprofAddr(target)
+	if err != nil {
+		return "", xerrors.Errorf("failed to resolve pprof address: %w", err)
+	}
+	var url string
+	switch kind {
+	case ProfileCPU:
+		url = fmt.Sprintf("http://%s/debug/pprof/profile?seconds=%d", addr, seconds)
+	case ProfileHeap:
+		url = fmt.Sprintf("http://%s/debug/pprof/heap", addr)
+	default:
+		return "", xerrors.Errorf("unknown profile kind %s", kind)
+	}
+	if kind == ProfileCPU {
+		logger().Infof("Capturing cpu profile for %d seconds from %s", seconds, addr)
+	}
+	client := &http.Client{Timeout: time.Duration(seconds)*time.Second + 30*time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", xerrors.Errorf("failed to request to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("unexpected status code from %s: %d", url, resp.StatusCode)
+	}
+	if name == "" {
+		name = "app"
+	}
+	dir := filepath.Join(configDir, "profiles")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s-%s.pprof", name, kind, time.Now().Format("20060102-150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", xerrors.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return "", xerrors.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// OpenProfile opens the profile by `go tool pprof` interactively.
+func OpenProfile(path string) error {
+	cmd := exec.Command("go", "tool", "pprof", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("failed to run go tool pprof: %w", err)
+	}
+	return nil
+}
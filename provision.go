@@ -0,0 +1,75 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// provisionContainer creates and starts host.docker container from host.docker_image if it doesn't exist,
+// and starts it if it's stopped. The project directory is mounted on the same path as the working directory,
+// and run.ports are published on the same ports.
+func (r *Reloader) provisionContainer() error {
+	if !r.isDockerMode() || r.host.DockerImage == "" || r.host.ComposeService != "" {
+		return nil
+	}
+	ctx := context.Background()
+	rt := containerRuntime()
+	container := r.host.Docker
+	exists, err := rt.Exists(ctx, container)
+	if err != nil {
+		return xerrors.Errorf("failed to check container %s: %w", container, err)
+	}
+	if exists {
+		info, err := rt.Inspect(ctx, container)
+		if err != nil {
+			return xerrors.Errorf("failed to inspect container %s: %w", container, err)
+		}
+		if !info.Running {
+			r.logger.Infof("Starting container %s...", container)
+			if err := rt.Start(ctx, container); err != nil {
+				return xerrors.Errorf("failed to start container %s: %w", container, err)
+			}
+		}
+		return nil
+	}
+	r.logger.Infof("Creating container %s from %s...", container, r.host.DockerImage)
+	// the container is kept running for the agent executing the application
+	if err := rt.Create(ctx, container, r.host.DockerImage, r.provisionOptions(), []string{"-f", "/dev/null"}); err != nil {
+		return xerrors.Errorf("failed to create container %s: %w", container, err)
+	}
+	r.provisioned = r.host.DockerEphemeral
+	return nil
+}
+
+func (r *Reloader) provisionOptions() []string {
+	opts := []string{"--entrypoint", "tail"}
+	if !r.isRemoteDocker() {
+		opts = append(opts, "-v", fmt.Sprintf("%s:%s", cwd, cwd), "-w", cwd)
+	}
+	names := []string{}
+	for name := range r.ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts = append(opts, "-p", fmt.Sprintf("%d:%d", r.ports[name], r.ports[name]))
+	}
+	return append(opts, r.host.DockerRunOpts...)
+}
+
+// removeProvisionedContainer removes the container created by provisionContainer for host.docker_ephemeral .
+// The container which existed before starting rebirth is kept.
+func (r *Reloader) removeProvisionedContainer() error {
+	if !r.provisioned {
+		return nil
+	}
+	r.logger.Infof("Removing container %s...", r.host.Docker)
+	if err := containerRuntime().Remove(context.Background(), r.host.Docker); err != nil {
+		return xerrors.Errorf("failed to remove container %s: %w", r.host.Docker, err)
+	}
+	r.provisioned = false
+	return nil
+}
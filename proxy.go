@@ -0,0 +1,158 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultProxyTimeout = 30 * time.Second
+	proxyDialInterval   = 100 * time.Millisecond
+)
+
+func (p *Proxy) timeout() time.Duration {
+	return parseDurationOr(p.Timeout, defaultProxyTimeout)
+}
+
+// proxyTargetAddr resolves proxy.target to the address.
+func (r *Reloader) proxyTargetAddr() (string, error) {
+	target := r.proxy.Target
+	if target == "" {
+		return "", xerrors.New("proxy.target must be specified")
+	}
+	if strings.Contains(target, ":") {
+		return target, nil
+	}
+	if _, err := strconv.Atoi(target); err == nil {
+		return fmt.Sprintf("localhost:%s", target), nil
+	}
+	port, exists := r.ports[target]
+	if !exists {
+		return "", xerrors.Errorf("port %s for proxy.target isn't defined in run.ports", target)
+	}
+	return fmt.Sprintf("localhost:%d", port), nil
+}
+
+// serveProxy starts the reverse proxy to the application.
+// Requests are held while the application is being rebuilt and restarted,
+// and connections are retried until the restarted application starts listening.
+func (r *Reloader) serveProxy() error {
+	if r.proxy.Listen == "" {
+		return xerrors.New("proxy.listen must be specified")
+	}
+	addr, err := r.proxyTargetAddr()
+	if err != nil {
+		return xerrors.Errorf("failed to resolve proxy.target: %w", err)
+	}
+	timeout := r.proxy.timeout()
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr})
+	proxy.Transport = &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: retryDial(timeout),
+	}
+	if r.proxy.LiveReload {
+		director := proxy.Director
+		proxy.Director = func(req *http.Request) {
+			director(req)
+			// the script can't be injected into compressed html
+			req.Header.Del("Accept-Encoding")
+		}
+		proxy.ModifyResponse = injectLiveReloadResponse
+	}
+	listener, err := net.Listen("tcp", r.proxy.Listen)
+	if err != nil {
+		return xerrors.Errorf("failed to listen %s: %w", r.proxy.Listen, err)
+	}
+	r.proxyListener = listener
+	r.logger.Infof("Proxy %s to %s", r.proxy.Listen, addr)
+	mux := http.NewServeMux()
+	// for reloading the error overlay and pages with proxy.live_reload
+	r.liveReload.register(mux)
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if err := r.MarkActivity(); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+		if !r.waitReloading(timeout) {
+			http.Error(w, fmt.Sprintf("rebirth: reloading doesn't finish within %s", timeout), http.StatusServiceUnavailable)
+			return
+		}
+		if r.serveOverlay(w, req) {
+			return
+		}
+		proxy.ServeHTTP(w, req)
+	})
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func (r *Reloader) closeProxy() {
+	if r.proxyListener == nil {
+		return
+	}
+	r.proxyListener.Close()
+	r.proxyListener = nil
+}
+
+// lockReload takes reloadMu for a reload. Requests through the proxy wait for it by waitReloading .
+func (r *Reloader) lockReload() {
+	r.reloadMu.Lock()
+	r.reloadingMu.Lock()
+	r.reloading = make(chan struct{})
+	r.reloadingMu.Unlock()
+}
+
+// unlockReload releases reloadMu taken by lockReload and wakes up requests waiting for the reload.
+func (r *Reloader) unlockReload() {
+	r.reloadingMu.Lock()
+	close(r.reloading)
+	r.reloading = nil
+	r.reloadingMu.Unlock()
+	r.reloadMu.Unlock()
+}
+
+// waitReloading waits for the running reload up to timeout. It returns false if it doesn't finish.
+// Reloads started while waiting ( e.g. queued changes ) are waited for too.
+func (r *Reloader) waitReloading(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		r.reloadingMu.Lock()
+		reloading := r.reloading
+		r.reloadingMu.Unlock()
+		if reloading == nil {
+			return true
+		}
+		select {
+		case <-reloading:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// retryDial returns dial function retrying until timeout ( e.g. while the restarted application isn't listening yet ).
+func retryDial(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		deadline := time.Now().Add(timeout)
+		for {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil || time.Now().After(deadline) {
+				return nil, err
+			}
+			time.Sleep(proxyDialInterval)
+		}
+	}
+}
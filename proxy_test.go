@@ -0,0 +1,24 @@
+package rebirth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitReloading(t *testing.T) {
+	r := &Reloader{}
+	if !r.waitReloading(time.Millisecond) {
+		t.Fatal("expected no wait without reloads")
+	}
+	r.lockReload()
+	if r.waitReloading(10 * time.Millisecond) {
+		t.Fatal("expected timeout while reloading")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.unlockReload()
+	}()
+	if !r.waitReloading(time.Second) {
+		t.Fatal("expected the reload to finish")
+	}
+}
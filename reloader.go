@@ -1,17 +1,21 @@
 package rebirth
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/goccy/rebirth/internal/agent"
+	"github.com/goccy/rebirth/internal/errors"
 	"golang.org/x/xerrors"
 )
 
@@ -19,76 +23,441 @@ var (
 	cwd               string
 	configDir         string
 	buildPath         string
-	pidPath           string
-	dockerRebirthPath string
+	agentsDir         string
 	binPath           string
 	pkgPath           string
+	controlSocketPath string
+	portsPath         string
+	statusPath        string
+	crashesDir        string
+	pidPath           string
+	mountCheckPath    string
+	observeBuildPath  string
 )
 
 func init() {
 	cwd, _ = os.Getwd()
 	configDir = ".rebirth"
-	buildPath = filepath.Join(cwd, configDir, "program")
-	pidPath = filepath.Join(configDir, "server.pid")
-	dockerRebirthPath = filepath.Join(configDir, "__rebirth")
+	buildPath = (&Build{}).output()
+	agentsDir = filepath.Join(configDir, "agents")
 	binPath = filepath.Join(configDir, "bin")
 	pkgPath = filepath.Join(configDir, "pkg")
+	controlSocketPath = filepath.Join(configDir, "control.sock")
+	portsPath = filepath.Join(configDir, "ports.json")
+	statusPath = filepath.Join(configDir, "status.json")
+	crashesDir = filepath.Join(configDir, "crashes")
+	pidPath = filepath.Join(configDir, "rebirth.pid")
+	mountCheckPath = filepath.Join(configDir, "mount-check")
+	observeBuildPath = filepath.Join(configDir, "observe")
 }
 
 type Reloader struct {
-	host  *Host
-	cmd   *Command
-	build *Build
-	run   *Run
+	cfg  *Config
+	host *Host
+	// cmd and agent are written with both reloadMu and statusMu held by setCmd and setAgent ,
+	// so applicationPid reads them by statusMu without waiting for building.
+	cmd     *Command
+	agent   *AgentClient
+	build   *Build
+	run     *Run
+	watch   *Watch
+	control net.Listener
+	ports   map[string]int
+	output  *appOutput
+
+	// pidFile is locked while rebirth is running for the project.
+	pidFile *os.File
+
+	artifacts  *artifactStore
+	history    *historyStore
+	reloadLogs *reloadLogCapture
+	manifestMu sync.Mutex
+	manifest   *Manifest
+	generation int
+	// goodGeneration is written under reloadMu and failureMu , and read under either of them.
+	goodGeneration int
+	// runningGeneration differs from generation after rolling back to goodGeneration .
+	runningGeneration int
+	goodManifest      *Manifest
+
+	observer      *observer
+	debugger      *debugger
+	sockets       []*inheritedSocket
+	keyboard      *keyboard
+	dashboard     *dashboard
+	buildTail     *outputTail
+	failureMu     sync.Mutex
+	buildFailures int
+	crashes       []time.Time
+	lastError     *errorLocation
+	paused        bool
+	pausedChanges bool
+
+	reloadMu sync.Mutex
+	// reloading is closed when the reload holding reloadMu finishes ( nil if no reload is running ).
+	// It's guarded by reloadingMu .
+	reloading   chan struct{}
+	reloadingMu sync.Mutex
+	focusMu     sync.Mutex
+	focused     map[string]time.Time
+	// generatedFrom and generatedAt are the duration of the last go generate by build.generate .
+	generatedFrom time.Time
+	generatedAt   time.Time
+
+	// changedFiles are files changed for the current reload ( nil if unknown ). It's guarded by reloadMu .
+	changedFiles []string
+
+	syncerOnce sync.Once
+	syncerImpl Syncer
+	syncerErr  error
+
+	fingerprintMu sync.Mutex
+	fingerprints  map[string]string
+	// builtSources are content hashes of source files at the last successful build.
+	builtSources map[string]string
+	// runningFingerprint is restartFingerprint of the running process.
+	runningFingerprint string
+
+	wasm       *Wasm
+	liveReload *liveReload
+
+	proxy         *Proxy
+	proxyListener net.Listener
+
+	metrics        *buildMetrics
+	notifier       buildNotifier
+	statusListener net.Listener
+	watchedFiles   func() int
+
+	overlayMu    sync.Mutex
+	buildFailure *buildFailure
+
+	hostAddr  string
+	extraArgs []string
+
+	sshGOOS   string
+	sshGOARCH string
+
+	buildEnvChecked bool
+
+	eventsMu sync.Mutex
+	events   eventHandlers
+
+	statusMu       sync.Mutex
+	state          string
+	stateError     string
+	stateUpdatedAt time.Time
+
+	restartMu      sync.Mutex
+	restartRetries int
+	restartTimer   *time.Timer
+	restartClosed  bool
+	// agentBinary and agentStarted are the binary of the last started process on the container and its start time.
+	// They're recorded before waiting for the readiness for auto restart of the process crashing on startup.
+	agentBinary  string
+	agentStarted time.Time
+
+	agentMu        sync.Mutex
+	agentPlatforms map[string]string
+	agentInstalled map[string]bool
+	// sharedMounts are results of hasSharedMount by the container.
+	sharedMounts map[string]bool
+
+	tasks map[string]*Task
+
+	targets      map[string]*Target
+	targetStates map[string]*targetState
+	// buildSlots limits builds of targets and services running concurrently by build.parallel .
+	buildSlotsOnce sync.Once
+	buildSlots     chan struct{}
+
+	services     map[string]*Service
+	startupOrder []string
+	servicesMu   sync.Mutex
+	serviceCmds  map[string]*Command
+
+	idleMu        sync.Mutex
+	idleTimer     *time.Timer
+	idle          bool
+	idleContainer bool
+
+	// provisioned is true if host.docker container is created by host.docker_image and removed on closing.
+	provisioned bool
+
+	freezeMu       sync.Mutex
+	freezeUntil    time.Time
+	freezeTimer    *time.Timer
+	pendingReload  bool
+	pendingMigrate bool
+
+	logger Logger
 }
 
-func NewReloader(cfg *Config) *Reloader {
-	return &Reloader{
-		host:  cfg.Host,
-		build: cfg.Build,
-		run:   cfg.Run,
+func NewReloader(cfg *Config, opts ...ReloaderOption) *Reloader {
+	build := cfg.Build
+	if build == nil {
+		build = &Build{}
+	}
+	targetStates := map[string]*targetState{}
+	for name := range cfg.Targets {
+		targetStates[name] = &targetState{buildTail: newOutputTail(crashTailLines)}
+	}
+	r := &Reloader{
+		cfg:            cfg,
+		host:           cfg.Host,
+		build:          build,
+		run:            cfg.Run,
+		watch:          cfg.Watch,
+		wasm:           cfg.Wasm,
+		proxy:          cfg.Proxy,
+		liveReload:     newLiveReload(),
+		tasks:          cfg.AllTasks(),
+		targets:        cfg.Targets,
+		targetStates:   targetStates,
+		services:       cfg.Services,
+		startupOrder:   cfg.StartupOrder,
+		serviceCmds:    map[string]*Command{},
+		focused:        map[string]time.Time{},
+		fingerprints:   map[string]string{},
+		builtSources:   map[string]string{},
+		agentPlatforms: map[string]string{},
+		agentInstalled: map[string]bool{},
+		sharedMounts:   map[string]bool{},
+		artifacts:      newArtifactStore(),
+		history:        newHistoryStore(),
+		keyboard:       newKeyboard(),
+		buildTail:      newOutputTail(crashTailLines),
+		metrics:        newBuildMetrics(),
+		logger:         newReloaderLogger(cfg.Log),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	buildPath = build.output()
+	return r
+}
+
+// Run builds and starts the application, and keeps reloading it until ctx is canceled.
+// On cancellation, it stops the application, services and the agent by Close and returns the result.
+// If starting fails, what is started so far ( e.g. the pid lock, the control socket and the provisioned container ) is closed too.
+func (r *Reloader) Run(ctx context.Context) error {
+	defer r.dashboard.stop()
+	if r.host != nil {
+		if err := SetContainerRuntime(r.host.Runtime); err != nil {
+			return xerrors.Errorf("invalid host.runtime: %w", err)
+		}
+		if err := SetDockerEndpoint(r.host); err != nil {
+			return xerrors.Errorf("failed to set docker endpoint: %w", err)
+		}
+	}
+	if err := ResolveComposeService(r.host); err != nil {
+		return xerrors.Errorf("failed to resolve compose service: %w", err)
+	}
+	if err := r.lockPidFile(); err != nil {
+		return xerrors.Errorf("failed to lock pid file: %w", err)
+	}
+	if err := r.start(ctx); err != nil {
+		if closeErr := r.Close(); closeErr != nil {
+			r.logger.Errorf("%v", closeErr)
+		}
+		return err
 	}
+	<-ctx.Done()
+	return r.Close()
 }
 
-func (r *Reloader) Run() error {
-	if !r.IsEnabledReload() {
-		if err := r.writePID(); err != nil {
-			return xerrors.Errorf("failed to write pid: %w", err)
+// start starts rebirth and the application for Run . It returns without error if ctx is canceled between phases,
+// and observer mode returns after ctx is canceled.
+func (r *Reloader) start(ctx context.Context) error {
+	if err := r.serveControl(); err != nil {
+		return xerrors.Errorf("failed to serve control api: %w", err)
+	}
+	addCleanup(func() { os.Remove(statusPath) })
+	if err := r.serveStatus(); err != nil {
+		return xerrors.Errorf("failed to serve status: %w", err)
+	}
+	if err := r.assignPorts(); err != nil {
+		return xerrors.Errorf("failed to assign ports: %w", err)
+	}
+	if err := r.provisionContainer(); err != nil {
+		return xerrors.Errorf("failed to provision container: %w", err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := r.openSockets(); err != nil {
+		return xerrors.Errorf("failed to open run.sockets: %w", err)
+	}
+	if r.proxy != nil {
+		if err := r.serveProxy(); err != nil {
+			return xerrors.Errorf("failed to serve proxy: %w", err)
 		}
-		if err := r.reload(); err != nil {
-			return xerrors.Errorf("failed to reload: %w", err)
+	}
+	var outputCfg *Output
+	if r.run != nil {
+		outputCfg = r.run.Output
+	}
+	output, err := newAppOutput(outputCfg)
+	if err != nil {
+		return xerrors.Errorf("failed to create output: %w", err)
+	}
+	r.output = output
+	if r.dashboard != nil {
+		output.useDashboard(r.dashboard)
+	}
+	if _, err := parseColor(r.build.OutputPrefixColor, colorMagenta); err != nil {
+		return xerrors.Errorf("invalid build.output_prefix_color: %w", err)
+	}
+	if err := r.validateCgo(); err != nil {
+		return xerrors.Errorf("invalid build.cgo: %w", err)
+	}
+	if r.cfg.Notify != nil {
+		if err := r.cfg.Notify.validate(); err != nil {
+			return xerrors.Errorf("invalid notify: %w", err)
 		}
-	} else if r.isUsedDocker() && !r.isOnDockerContainer() {
-		if err := r.xbuildRebirth(); err != nil {
-			return xerrors.Errorf("failed to cross compile for rebirth: %w", err)
+	}
+	if err := r.watchOutputTriggers(); err != nil {
+		return xerrors.Errorf("failed to watch output for triggers: %w", err)
+	}
+	r.captureReloadLogs()
+	if err := r.startKeyboard(); err != nil {
+		return xerrors.Errorf("failed to start keyboard: %w", err)
+	}
+	if err := r.checkDebugMode(); err != nil {
+		return xerrors.Errorf("failed to start debug mode: %w", err)
+	}
+	if r.isObserveMode() {
+		if err := r.runObserver(ctx); err != nil {
+			return xerrors.Errorf("failed to observe: %w", err)
 		}
-		if err := r.runBuildInitCommands(); err != nil {
-			return xerrors.Errorf("failed to build.init commands: %w", err)
+		return nil
+	}
+	if err := r.showRestartPolicy(); err != nil {
+		return xerrors.Errorf("failed to get run.restart: %w", err)
+	}
+	if r.isTargetsMode() {
+		if err := r.runTargets(); err != nil {
+			return xerrors.Errorf("failed to run targets: %w", err)
 		}
-		if err := r.xbuild(buildPath, "."); err != nil {
-			return xerrors.Errorf("failed to build on host: %w", err)
+		return nil
+	}
+	if r.isWasmMode() {
+		if err := r.serveWasm(); err != nil {
+			return xerrors.Errorf("failed to serve wasm: %w", err)
 		}
-		go NewDockerCommand(r.host.Docker, dockerRebirthPath).Run()
-	} else {
-		// running reloader on localhost
-		if err := r.runBuildInitCommands(); err != nil {
-			return xerrors.Errorf("failed to build.init commands: %w", err)
+	}
+	if r.isDockerMode() {
+		if err := r.installAgent(); err != nil {
+			return xerrors.Errorf("failed to install rebirth agent: %w", err)
 		}
-		if err := r.xbuild(buildPath, "."); err != nil {
-			return xerrors.Errorf("failed to build on host: %w", err)
+	}
+	if r.isSSHMode() {
+		if err := r.setupSSH(); err != nil {
+			return xerrors.Errorf("failed to setup host.ssh: %w", err)
 		}
-		if err := r.reload(); err != nil {
-			return xerrors.Errorf("failed to reload: %w", err)
+	}
+	if err := r.runBuildInitCommands(); err != nil {
+		return xerrors.Errorf("failed to build.init commands: %w", err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	sources := r.hashSources(nil)
+	if err := r.buildApp(); err != nil {
+		return xerrors.Errorf("failed to build on host: %w", err)
+	}
+	r.recordBuiltSources(sources)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if r.isDockerMode() || r.isSSHMode() {
+		if err := r.startAgent(); err != nil {
+			return xerrors.Errorf("failed to start agent on %s: %w", r.agentTarget(), err)
+		}
+	}
+	if err := r.setupHostNetwork(); err != nil {
+		return xerrors.Errorf("failed to setup host network: %w", err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if r.isSyncEnabled() {
+		if err := r.syncAll(); err != nil {
+			return xerrors.Errorf("failed to sync assets: %w", err)
 		}
 	}
-	r.watchReloadSignal()
-	for {
-		time.Sleep(1 * time.Second)
+	r.fingerprintAssets()
+	if err := r.runMigrationHook(); err != nil {
+		return xerrors.Errorf("failed to migrate: %w", err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := r.sendReloadingSignal(); err != nil {
+		return xerrors.Errorf("failed to reload: %w", err)
+	}
+	r.watchReloadSignal(ctx)
+	r.startIdleTimer()
+	return nil
+}
+
+// connectAgent starts the agent on the container or the remote machine of host.ssh and connects to it.
+func (r *Reloader) connectAgent() (*AgentClient, error) {
+	if r.isSSHMode() {
+		return r.startSSHAgent()
+	}
+	path, err := r.installAgentFor(r.host.Docker)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to install agent: %w", err)
+	}
+	if r.isCopyMode() {
+		agent := filepath.Join(cwd, path)
+		if err := r.copyToContainer(agent); err != nil {
+			return nil, xerrors.Errorf("failed to copy agent to container: %w", err)
+		}
+		if path, err = r.copyModePath(agent); err != nil {
+			return nil, err
+		}
 	}
+	return StartAgent(r.host.Docker, path)
+}
+
+func (r *Reloader) startAgent() error {
+	client, err := r.connectAgent()
+	if err != nil {
+		return xerrors.Errorf("failed to start agent: %w", err)
+	}
+	r.setAgent(client)
+	r.watchComposeContainer(client)
+	client.SetOutput(r.output.stdout, r.output.stderr)
+	client.SetStopSignal(r.run.stopSignal(), r.run.stopTimeout())
+	client.OnExit(func(res *agent.Response) {
+		status := fmt.Sprintf("exit status %d", res.ExitStatus)
+		r.emitProcessExit(&ProcessExitEvent{Pid: res.Pid, Status: status})
+		if res.ExitStatus != 0 {
+			r.reportCrash(&crash{
+				pid:    res.Pid,
+				status: status,
+				output: client.tail.Lines(),
+				env:    r.runEnv(),
+			})
+		}
+		binary, started := r.agentProcess()
+		r.scheduleAutoRestart(res.Pid, status, res.ExitStatus != 0, time.Since(started), func() error {
+			return r.reloadOnContainer(binary)
+		})
+	})
 	return nil
 }
 
 func (r *Reloader) runBuildHookCommandInGoContext(cmd string) error {
+	if strings.HasPrefix(cmd, taskHookPrefix) {
+		name := strings.TrimPrefix(cmd, taskHookPrefix)
+		if err := newTaskRunner(r.tasks, r.host, r.build, r.run, r.logger).Run(name); err != nil {
+			return xerrors.Errorf("failed to run task %s: %w", name, err)
+		}
+		return nil
+	}
 	gocmd := NewGoCommand()
 	env := []string{}
 	for k, v := range r.build.Env {
@@ -103,7 +472,7 @@ func (r *Reloader) runBuildHookCommandInGoContext(cmd string) error {
 
 func (r *Reloader) runBuildInitCommands() error {
 	for _, cmd := range r.build.Init {
-		fmt.Printf("Running: %s\n", cmd)
+		r.logger.Infof("Running: %s", cmd)
 		if err := r.runBuildHookCommandInGoContext(cmd); err != nil {
 			return xerrors.Errorf("failed to run command in build.init: %w", err)
 		}
@@ -112,9 +481,9 @@ func (r *Reloader) runBuildInitCommands() error {
 }
 
 func (r *Reloader) runBuildBeforeCommands() error {
-	for _, cmd := range r.build.Before {
-		fmt.Printf("Running: %s\n", cmd)
-		if err := r.runBuildHookCommandInGoContext(cmd); err != nil {
+	for _, hook := range r.matchedHooks(r.build.Before) {
+		r.logger.Infof("Running: %s", hook.Command)
+		if err := r.runBuildHookCommandInGoContext(hook.Command); err != nil {
 			return xerrors.Errorf("failed to run command in build.before: %w", err)
 		}
 	}
@@ -122,28 +491,60 @@ func (r *Reloader) runBuildBeforeCommands() error {
 }
 
 func (r *Reloader) runBuildAfterCommands() error {
-	for _, cmd := range r.build.After {
-		fmt.Printf("Running: %s\n", cmd)
-		if err := r.runBuildHookCommandInGoContext(cmd); err != nil {
+	for _, hook := range r.matchedHooks(r.build.After) {
+		r.logger.Infof("Running: %s", hook.Command)
+		if err := r.runBuildHookCommandInGoContext(hook.Command); err != nil {
 			return xerrors.Errorf("failed to run command in build.after: %w", err)
 		}
 	}
 	return nil
 }
 
-func (r *Reloader) IsEnabledReload() bool {
-	if !r.isUsedDocker() {
-		return true
+func (r *Reloader) Reload() error {
+	if r.isTargetsMode() {
+		r.reloadTargets(r.targetNames(), nil)
+		return nil
 	}
-	if !r.isOnDockerContainer() {
-		return true
+	if r.isObserveMode() {
+		return r.checkBuild()
 	}
-	return false
+	if err := r.reloadFor(true, false); err != nil {
+		return xerrors.Errorf("failed to reload: %w", err)
+	}
+	return nil
 }
 
-func (r *Reloader) Reload() error {
-	if err := r.xbuild(buildPath, "."); err != nil {
-		return xerrors.Errorf("failed to build on host: %w", err)
+// reloadFor restarts the application after building it if build is true
+// and running watch.migrations.hook if migrate is true.
+func (r *Reloader) reloadFor(build, migrate bool) error {
+	return r.reloadForFiles(nil, build, migrate)
+}
+
+// reloadForFiles is reloadFor for the changed files. Hooks with when run only if files match them.
+func (r *Reloader) reloadForFiles(files []string, build, migrate bool) error {
+	if r.deferReloadIfFrozen(migrate) {
+		return nil
+	}
+	r.lockReload()
+	defer r.unlockReload()
+	r.changedFiles = files
+	defer func() { r.changedFiles = nil }()
+	if build {
+		sources := r.hashSources(files)
+		if err := r.buildApp(); err != nil {
+			return xerrors.Errorf("failed to build on host: %w", err)
+		}
+		r.recordBuiltSources(sources)
+		if r.run.usesBinary() && r.canSkipRestart(files, migrate) && r.isSameAsRunning(buildPath) {
+			r.logger.Infof("Skipped restarting: the built binary is identical to the running one")
+			r.setState(StateRunning, "")
+			return nil
+		}
+	}
+	if migrate {
+		if err := r.runMigrationHook(); err != nil {
+			return xerrors.Errorf("failed to migrate: %w", err)
+		}
 	}
 	if err := r.sendReloadingSignal(); err != nil {
 		return xerrors.Errorf("failed to send reloading signal: %w", err)
@@ -152,140 +553,386 @@ func (r *Reloader) Reload() error {
 }
 
 func (r *Reloader) Close() error {
-	if !r.isUsedDocker() {
-		return nil
-	}
-	if r.isOnDockerContainer() {
-		fmt.Println("stop current process...")
+	r.closeAutoRestart()
+	r.reloadLogs.close()
+	r.closeControl()
+	os.Remove(statusPath)
+	r.closeProxy()
+	r.closeStatus()
+	if err := r.stopServices(); err != nil {
+		return xerrors.Errorf("failed to stop services: %w", err)
+	}
+	if err := r.stopTargets(); err != nil {
+		return xerrors.Errorf("failed to stop targets: %w", err)
+	}
+	switch {
+	case r.isObserveMode():
+		// the observed process is owned by another tool
+	case r.agent == nil:
+		if r.cmd != nil {
+			r.logger.Infof("stop current process...")
+		}
 		if err := r.stopCurrentProcess(); err != nil {
 			return xerrors.Errorf("failed to stop current process: %w", err)
 		}
-		return nil
+	default:
+		r.logger.Infof("stop agent on %s...", r.agentTarget())
+		if err := r.agent.Close(); err != nil {
+			return xerrors.Errorf("failed to close agent: %w", err)
+		}
 	}
-
-	pid, err := r.readPID()
-	if err != nil {
-		return xerrors.Errorf("failed to read pid: %w", err)
+	r.runShutdownCommands()
+	if err := r.removeProvisionedContainer(); err != nil {
+		return xerrors.Errorf("failed to remove container: %w", err)
 	}
-	containerName := r.host.Docker
-	fmt.Println("stop hot reloader on container...")
-	if err := NewDockerCommand(containerName, "kill", "-QUIT", fmt.Sprint(pid)).Run(); err != nil {
-		return xerrors.Errorf("failed to exec command on docker container: %w", err)
+	r.closeSockets()
+	r.unlockPidFile()
+	if err := r.keyboard.restore(); err != nil {
+		return xerrors.Errorf("failed to restore terminal: %w", err)
+	}
+	r.dashboard.stop()
+	if r.output != nil {
+		if err := r.output.Close(); err != nil {
+			return xerrors.Errorf("failed to close output: %w", err)
+		}
 	}
 	return nil
 }
 
 func (r *Reloader) isUsedDocker() bool {
-	return r.host != nil && r.host.Docker != ""
+	return r.host != nil && (r.host.Docker != "" || r.host.ComposeService != "")
 }
 
+// isOnDockerContainer returns true if rebirth runs on the container. podman creates /run/.containerenv instead of /.dockerenv .
 func (r *Reloader) isOnDockerContainer() bool {
-	_, err := os.Stat(filepath.Join("/", ".dockerenv"))
-	return err == nil
-}
-
-func (r *Reloader) readPID() (int, error) {
-	file, err := ioutil.ReadFile(pidPath)
-	if err != nil {
-		return -1, xerrors.Errorf("failed to read pid file: %w", err)
-	}
-	pid, err := strconv.ParseInt(string(file), 10, 64)
-	if err != nil {
-		return -1, xerrors.Errorf("failed to parse pid number: %w", err)
+	for _, file := range []string{filepath.Join("/", ".dockerenv"), filepath.Join("/", "run", ".containerenv")} {
+		if _, err := os.Stat(file); err == nil {
+			return true
+		}
 	}
-	return int(pid), nil
+	return false
 }
 
-func (r *Reloader) writePID() error {
-	pid := os.Getpid()
-	if err := ioutil.WriteFile(pidPath, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
-		return xerrors.Errorf("failed to write pid file: %w", err)
-	}
-	return nil
+// isDockerMode returns true if the application runs on the container and rebirth runs on the host.
+// In wasm mode, the application runs on the browser.
+func (r *Reloader) isDockerMode() bool {
+	return r.isUsedDocker() && !r.isOnDockerContainer() && !r.isWasmMode()
 }
 
 func (r *Reloader) stopCurrentProcess() error {
 	if r.cmd == nil {
 		return nil
 	}
-	if err := r.cmd.Stop(); err != nil {
+	sig, err := agent.ParseSignal(r.run.stopSignal())
+	if err != nil {
+		return xerrors.Errorf("failed to parse run.stop_signal: %w", err)
+	}
+	if err := r.cmd.StopGracefully(sig, r.run.stopTimeout()); err != nil {
 		return xerrors.Errorf("failed to stop process: %w", err)
 	}
-	r.cmd = nil
+	r.setCmd(nil)
 	return nil
 }
 
-func (r *Reloader) reload() (e error) {
-	fmt.Println("Restarting...")
+func (r *Reloader) reload(binary string) (e error) {
+	r.logger.Infof("Restarting...")
 	if err := r.stopCurrentProcess(); err != nil {
 		return xerrors.Errorf("failed to stop current process: %w", err)
 	}
-	execCmd := NewCommand(buildPath)
-	if r.run != nil {
-		env := []string{}
-		for k, v := range r.run.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
+	r.setCmd(r.startProcess(binary))
+	return nil
+}
+
+// startProcess starts binary as the application on localhost.
+func (r *Reloader) startProcess(binary string) *Command {
+	env := r.runEnv()
+	path, args := r.command(binary)
+	execCmd := NewCommand(append([]string{path}, args...)...)
+	if !r.run.inheritEnv() {
+		execCmd.ClearEnv()
+	}
+	execCmd.AddEnv(env)
+	files, socketEnv := r.socketFiles()
+	execCmd.SetExtraFiles(files)
+	execCmd.AddEnv(socketEnv)
+	execCmd.SetOutput(r.output.stdout, r.output.stderr)
+	if r.run.interactive() {
+		execCmd.SetForeground()
+	}
+	tail := newOutputTail(crashTailLines)
+	execCmd.SetOutputTail(tail)
+	started := time.Now()
+	execCmd.OnExit(func(err error) {
+		status := "exit status 0"
+		if err != nil {
+			status = err.Error()
 		}
-		execCmd.AddEnv(env)
-	}
-	r.cmd = execCmd
+		r.emitProcessExit(&ProcessExitEvent{Pid: execCmd.Pid(), Status: status, Stopped: execCmd.IsStopped()})
+		if execCmd.IsStopped() {
+			return
+		}
+		if err != nil {
+			r.reportCrash(&crash{
+				pid:    execCmd.Pid(),
+				status: err.Error(),
+				output: tail.Lines(),
+				env:    env,
+			})
+		}
+		r.scheduleAutoRestart(execCmd.Pid(), status, err != nil, time.Since(started), func() error {
+			if r.cmd != execCmd {
+				// replaced by another process ( e.g. the new process of blue-green failed health check )
+				return nil
+			}
+			r.logger.Infof("Restarting...")
+			r.setCmd(r.startProcess(binary))
+			return nil
+		})
+	})
 	execCmd.RunAsync()
+	return execCmd
+}
+
+// SetArgs specifies extra arguments for the application passed after run.args ( e.g. arguments after -- of rebirth ).
+func (r *Reloader) SetArgs(args []string) {
+	r.extraArgs = args
+}
+
+func (r *Reloader) runArgs() []string {
+	args := []string{}
+	if r.run != nil {
+		args = append(args, r.run.Args...)
+	}
+	return append(args, r.extraArgs...)
+}
+
+func (r *Reloader) runEnv() []string {
+	env := append(r.goRuntimeEnv(), r.portEnv()...)
+	env = append(env, r.hostNetworkEnv()...)
+	if r.run == nil {
+		return env
+	}
+	for k, v := range r.run.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
+// agentPath returns the path of binary on the container.
+func (r *Reloader) agentPath(binary string) (string, error) {
+	if r.host.SyncBinary != "" {
+		return r.host.SyncBinary, nil
+	}
+	if r.isCopyMode() {
+		return r.copyModePath(binary)
+	}
+	// the project directory is the working directory of the agent
+	path, err := filepath.Rel(cwd, binary)
+	if err != nil {
+		return "", xerrors.Errorf("failed to get relative path of %s: %w", binary, err)
+	}
+	return path, nil
+}
+
+// reloadOnContainer restarts the application on the container by the agent
+// and reports the result acknowledged by the agent.
+func (r *Reloader) reloadOnContainer(binary string) error {
+	r.logger.Infof("Restarting...")
+	path, err := r.agentPath(binary)
+	if err != nil {
+		return xerrors.Errorf("failed to get path on container: %w", err)
+	}
+	grace := r.run.gracePeriod()
+	r.agent.tail.Reset()
+	name, args := r.command(path)
+	r.setAgentProcess(binary, time.Now())
+	res, err := r.agent.Start(name, args, r.runEnv(), grace)
+	if err != nil {
+		return xerrors.Errorf("failed to start application on container: %w", err)
+	}
+	if res.PrevPid != 0 {
+		r.logger.Infof("stopped process(%d) on %s ( exit status %d )", res.PrevPid, r.agentTarget(), res.PrevExitStatus)
+	}
+	if !res.Ready {
+		return xerrors.Errorf(
+			"process(%d) on %s exited with status %d within %s",
+			res.Pid,
+			r.agentTarget(),
+			res.ExitStatus,
+			grace,
+		)
+	}
+	r.logger.Infof("Reloaded successfully. process(%d) is running on %s", res.Pid, r.agentTarget())
 	return nil
 }
 
-func (r *Reloader) watchReloadSignal() {
+func (r *Reloader) setCmd(cmd *Command) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.cmd = cmd
+}
+
+func (r *Reloader) setAgent(client *AgentClient) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.agent = client
+}
+
+// setAgentProcess records the binary and the start time of the process started on the container.
+func (r *Reloader) setAgentProcess(binary string, started time.Time) {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	r.agentBinary = binary
+	r.agentStarted = started
+}
+
+// agentProcess returns the binary and the start time of the last started process on the container.
+func (r *Reloader) agentProcess() (string, time.Time) {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	return r.agentBinary, r.agentStarted
+}
+
+// watchReloadSignal restarts the application on SIGHUP until ctx is canceled.
+func (r *Reloader) watchReloadSignal(ctx context.Context) {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGHUP)
 
 	go func() {
+		defer signal.Stop(sig)
 		for {
-			<-sig
-			go r.reload()
+			select {
+			case <-sig:
+				r.restartBySignal()
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 }
 
+func (r *Reloader) restartBySignal() {
+	r.lockReload()
+	defer r.unlockReload()
+	if err := r.sendReloadingSignal(); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
 func (r *Reloader) rebirthDir() string {
 	_, file, _, _ := runtime.Caller(0)
 	return filepath.Dir(file)
 }
 
-func (r *Reloader) xbuildRebirth() error {
-	cmdFile := filepath.Join(r.rebirthDir(), "cmd", "rebirth", "main.go")
+func (r *Reloader) xbuildAgent(platform, target string) error {
+	cmdFile := filepath.Join(r.rebirthDir(), "cmd", "rebirth-agent", "main.go")
+	if _, err := os.Stat(cmdFile); err != nil {
+		return errors.ErrAgentSource
+	}
 	gocmd := NewGoCommand()
-	gocmd.EnableCrossBuild(r.host.Docker)
+	gocmd.SetTarget(splitPlatform(platform))
+	gocmd.DisableCgo()
 	gocmd.SetDir(r.rebirthDir())
-	if r.build != nil {
-		env := []string{}
-		for k, v := range r.build.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
-		}
-		gocmd.AddEnv(env)
+	env := []string{}
+	for k, v := range r.build.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
 	}
-	if err := gocmd.Build("-o", filepath.Join(cwd, dockerRebirthPath), cmdFile); err != nil {
-		return xerrors.Errorf("failed to cross build rebirth: %w", err)
+	gocmd.AddEnv(env)
+	if err := gocmd.Build("-o", target, cmdFile); err != nil {
+		return xerrors.Errorf("failed to cross build rebirth agent: %w", err)
 	}
 	return nil
 }
 
-func (r *Reloader) xbuild(target, source string) error {
-	fmt.Println("Building....")
-	if err := r.runBuildBeforeCommands(); err != nil {
-		return xerrors.Errorf("failed to run build.before commands: %w", err)
+// main returns the main package or the go file built as the application.
+func (b *Build) main() string {
+	if b.Main != "" {
+		return b.Main
+	}
+	return "."
+}
+
+// output returns the absolute path of the built binary.
+func (b *Build) output() string {
+	if b.Output == "" {
+		return filepath.Join(cwd, configDir, "program")
+	}
+	output := ExpandPath(b.Output)
+	if filepath.IsAbs(output) {
+		return output
 	}
+	return filepath.Join(cwd, output)
+}
+
+// newBuildCommand creates GoCommand with build env for the application.
+func (r *Reloader) newBuildCommand() *GoCommand {
 	gocmd := NewGoCommand()
-	if r.build != nil {
-		env := []string{}
-		for k, v := range r.build.Env {
-			env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
-		}
-		gocmd.AddEnv(env)
+	env := []string{}
+	for k, v := range r.build.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
 	}
-	if r.isUsedDocker() && !r.isOnDockerContainer() {
+	gocmd.AddEnv(env)
+	gocmd.SetCompiler(r.build.Compiler, r.build.TinygoTarget)
+	gocmd.SetBuildFlags(r.build.Tags, r.build.LDFlags, r.gcflags())
+	gocmd.SetCgo(r.build.Cgo)
+	gocmd.SetCacheDir(r.localCacheDir())
+	if r.isSSHMode() {
+		// cross compiler for C isn't available for the remote machine
+		gocmd.SetTarget(r.sshGOOS, r.sshGOARCH)
+		gocmd.DisableCgo()
+	}
+	if r.isWasmMode() {
+		gocmd.DisableCgo()
+		// the target is used for -target of tinygo too
+		gocmd.SetTarget("js", "wasm")
+	}
+	if r.isDockerMode() {
 		gocmd.EnableCrossBuild(r.host.Docker)
+		gocmd.SetMicroarch(r.build.Microarch)
 	}
-	if err := gocmd.Build("-o", target, source); err != nil {
-		return xerrors.Errorf("failed to build: %w", err)
+	return gocmd
+}
+
+// buildPrefixColor returns the color of build.output_prefix . It's validated by Run .
+func (r *Reloader) buildPrefixColor() string {
+	color, _ := parseColor(r.build.OutputPrefixColor, colorMagenta)
+	return color
+}
+
+// buildOutput returns destinations of the output of go build prefixed by build.output_prefix .
+func (r *Reloader) buildOutput() (io.Writer, io.Writer) {
+	if r.output == nil {
+		return os.Stdout, os.Stderr
+	}
+	return r.output.buildOutput(r.build.OutputPrefix, r.buildPrefixColor())
+}
+
+func (r *Reloader) xbuild(target, source string) error {
+	r.logger.Infof("Building....")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return xerrors.Errorf("failed to create directory of %s: %w", target, err)
+	}
+	if err := r.runBuildBeforeCommands(); err != nil {
+		return xerrors.Errorf("failed to run build.before commands: %w", err)
+	}
+	if r.isRemoteBuild() {
+		if err := r.remoteBuild(target, source); err != nil {
+			return xerrors.Errorf("failed to build on remote: %w", err)
+		}
+	} else {
+		r.yieldToGoCommands()
+		gocmd := r.newBuildCommand()
+		if err := r.checkBuildEnvDrift(gocmd); err != nil {
+			return xerrors.Errorf("failed to check build env: %w", err)
+		}
+		r.buildTail.Reset()
+		gocmd.SetOutputTail(r.buildTail)
+		stdout, stderr := r.buildOutput()
+		gocmd.SetStdout(stdout)
+		gocmd.SetStderr(stderr)
+		if err := gocmd.Build("-o", target, source); err != nil {
+			return xerrors.Errorf("failed to build: %w", err)
+		}
 	}
 	if err := r.runBuildAfterCommands(); err != nil {
 		return xerrors.Errorf("failed to run build.after commands: %w", err)
@@ -294,19 +941,70 @@ func (r *Reloader) xbuild(target, source string) error {
 }
 
 func (r *Reloader) sendReloadingSignal() error {
-	if r.host != nil && r.host.Docker != "" {
-		pid, err := r.readPID()
-		if err != nil {
-			return xerrors.Errorf("failed to read pid: %w", err)
+	if err := r.restart(buildPath); err != nil {
+		r.setState(StateFailed, errorSummary(err))
+		return xerrors.Errorf("failed to restart: %w", err)
+	}
+	if err := r.checkHealth(); err != nil {
+		r.setState(StateFailed, errorSummary(err))
+		return xerrors.Errorf("failed to check health: %w", err)
+	}
+	r.setState(StateRunning, "")
+	return nil
+}
+
+// putBinary sends binary to host.ssh or the container. It does nothing if run.command doesn't use the built binary.
+func (r *Reloader) putBinary(binary string) error {
+	if !r.run.usesBinary() {
+		return nil
+	}
+	if r.isSSHMode() {
+		if err := r.uploadSSH(binary); err != nil {
+			return xerrors.Errorf("failed to upload binary to %s: %w", r.host.SSH.Host, err)
 		}
-		containerName := r.host.Docker
-		if err := NewDockerCommand(containerName, "kill", "-HUP", fmt.Sprint(pid)).Run(); err != nil {
-			return xerrors.Errorf("failed to exec command on docker container: %w", err)
+	}
+	if r.isCopyMode() && r.host.SyncBinary == "" {
+		if err := r.copyToContainer(binary); err != nil {
+			return xerrors.Errorf("failed to copy binary to container: %w", err)
 		}
+	}
+	if err := r.syncBinary(binary); err != nil {
+		return xerrors.Errorf("failed to sync binary: %w", err)
+	}
+	return nil
+}
+
+// restart replaces the current process by binary with the reload strategy.
+func (r *Reloader) restart(binary string) error {
+	if r.isWasmMode() {
+		r.logger.Infof("Reloading browsers...")
+		r.liveReload.broadcast()
 		return nil
 	}
-	if err := r.reload(); err != nil {
-		return xerrors.Errorf("failed to reload: %w", err)
+	if r.isComposeMode() {
+		if err := r.reconnectComposeContainer(); err != nil {
+			return xerrors.Errorf("failed to reconnect to compose service: %w", err)
+		}
+	}
+	if err := r.putBinary(binary); err != nil {
+		return err
+	}
+	strategy, err := r.strategy()
+	if err != nil {
+		return xerrors.Errorf("failed to get reload strategy: %w", err)
+	}
+	if err := r.runBeforeCommands(); err != nil {
+		return xerrors.Errorf("failed to run run.before: %w", err)
+	}
+	before := r.reloadLogs.before()
+	if err := strategy.Reload(r, binary); err != nil {
+		return xerrors.Errorf("failed to reload by %s strategy: %w", strategy.Name(), err)
 	}
+	r.reloadLogs.start(r.restartedGeneration(binary), before)
+	r.runningFingerprint = r.restartFingerprint(binary)
+	r.resetAutoRestart()
+	r.emitRestart(binary)
+	r.runAfterCommands()
+	r.reloadBrowsers()
 	return nil
 }
@@ -0,0 +1,281 @@
+package rebirth
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultReloadLogLines = 50
+	// reloadLogWindow is the maximum duration for capturing output after the restart.
+	reloadLogWindow = 10 * time.Second
+)
+
+// ReloadLog is the application's output captured around the restart of the generation.
+// Before is the last lines of the stopped process, and After is the first lines of the restarted one.
+type ReloadLog struct {
+	Time       time.Time `json:"time"`
+	Generation int       `json:"generation"`
+	Before     []string  `json:"before,omitempty"`
+	After      []string  `json:"after,omitempty"`
+}
+
+func (r *Run) reloadLogLines() int {
+	if r == nil || r.ReloadLogLines == 0 {
+		return defaultReloadLogLines
+	}
+	return r.ReloadLogLines
+}
+
+func reloadLogsPath() string {
+	return filepath.Join(cwd, configDir, "reloads.jsonl")
+}
+
+func (s *historyStore) appendReloadLog(log *ReloadLog) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return xerrors.Errorf("failed to encode reload log: %w", err)
+	}
+	path := reloadLogsPath()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return xerrors.Errorf("failed to write reload log: %w", err)
+	}
+	return nil
+}
+
+// reloadLogs returns all captured reload logs in recorded order.
+func (s *historyStore) reloadLogs() ([]*ReloadLog, error) {
+	path := reloadLogsPath()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []*ReloadLog{}, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	logs := []*ReloadLog{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var log ReloadLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			continue
+		}
+		logs = append(logs, &log)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	return logs, nil
+}
+
+// reloadLogCapture keeps the last lines of the application's output,
+// and captures them and the following lines around each restart.
+type reloadLogCapture struct {
+	mu      sync.Mutex
+	max     int
+	tail    *outputTail
+	current *ReloadLog
+	timer   *time.Timer
+	history *historyStore
+}
+
+// captureReloadLogs starts keeping output of the application for reload logs.
+func (r *Reloader) captureReloadLogs() {
+	max := r.run.reloadLogLines()
+	if max < 0 {
+		return
+	}
+	capture := &reloadLogCapture{max: max, tail: newOutputTail(max), history: r.history}
+	r.output.tee(func() io.Writer {
+		return &lineWriter{fn: capture.addLine}
+	})
+	r.reloadLogs = capture
+}
+
+func (c *reloadLogCapture) addLine(line []byte) {
+	text := strings.TrimRight(string(line), "\r\n")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tail.Write([]byte(text + "\n"))
+	if c.current == nil {
+		return
+	}
+	c.current.After = append(c.current.After, text)
+	if len(c.current.After) >= c.max {
+		c.flush()
+	}
+}
+
+// before returns the last lines of the current process. It's called before stopping the process.
+func (c *reloadLogCapture) before() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tail.Lines()
+}
+
+// start captures the first lines of the restarted generation up to reloadLogWindow .
+func (c *reloadLogCapture) start(generation int, before []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+	c.tail.Reset()
+	log := &ReloadLog{Time: time.Now(), Generation: generation, Before: before}
+	c.current = log
+	c.timer = time.AfterFunc(reloadLogWindow, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.current == log {
+			c.flush()
+		}
+	})
+}
+
+// close records the capturing log on shutdown.
+func (c *reloadLogCapture) close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+}
+
+func (c *reloadLogCapture) flush() {
+	if c.current == nil {
+		return
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if err := c.history.appendReloadLog(c.current); err != nil {
+		logger().Errorf("%v", err)
+	}
+	c.current = nil
+}
+
+// ParseGeneration parses generation like gen17 or 17 .
+func ParseGeneration(src string) (int, error) {
+	gen, err := strconv.Atoi(strings.TrimPrefix(src, "gen"))
+	if err != nil || gen <= 0 {
+		return 0, xerrors.Errorf("invalid generation %s. e.g. gen17", src)
+	}
+	return gen, nil
+}
+
+// FindReloadLog returns the latest reload log of the generation. If generation is 0, the latest reload log is returned.
+func FindReloadLog(generation int) (*ReloadLog, error) {
+	logs, err := newHistoryStore().reloadLogs()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get reload logs: %w", err)
+	}
+	for i := len(logs) - 1; i >= 0; i-- {
+		if generation == 0 || logs[i].Generation == generation {
+			return logs[i], nil
+		}
+	}
+	if generation == 0 {
+		return nil, xerrors.New("no reload logs are captured")
+	}
+	return nil, xerrors.Errorf("no reload logs are captured for generation %d", generation)
+}
+
+// PrintReloadLog prints output of the stopped process and the restarted generation.
+func PrintReloadLog(w io.Writer, log *ReloadLog) {
+	fmt.Fprintf(w, "--- before restarting generation %d ( %s ) ---\n", log.Generation, log.Time.Format(time.RFC3339))
+	for _, line := range log.Before {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "--- after restarting generation %d ---\n", log.Generation)
+	for _, line := range log.After {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// PrintStats prints build results of generations and lines of reload logs captured for them.
+func PrintStats(w io.Writer) error {
+	history := newHistoryStore()
+	records, err := history.records()
+	if err != nil {
+		return xerrors.Errorf("failed to get build records: %w", err)
+	}
+	logs, err := history.reloadLogs()
+	if err != nil {
+		return xerrors.Errorf("failed to get reload logs: %w", err)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GENERATION\tTIME\tBUILD\tSIZE\tSTATUS\tRELOAD LOGS")
+	for i, record := range records {
+		status := "ok"
+		gen := strconv.Itoa(record.Generation)
+		if record.Error != "" {
+			status = "failed"
+			// failed builds don't increment generation
+			gen = "-"
+		}
+		size := "-"
+		if record.Size > 0 {
+			size = fmt.Sprintf("%.1fMB", float64(record.Size)/1024/1024)
+		}
+		reloadLogs := "-"
+		if record.Error == "" {
+			var next *buildRecord
+			if i+1 < len(records) {
+				next = records[i+1]
+			}
+			if log := buildReloadLog(record, next, logs); log != nil {
+				reloadLogs = fmt.Sprintf("%d before, %d after", len(log.Before), len(log.After))
+			}
+		}
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			gen,
+			record.Time.Format("2006-01-02 15:04:05"),
+			(time.Duration(record.DurationMs) * time.Millisecond).String(),
+			size,
+			status,
+			reloadLogs,
+		)
+	}
+	return tw.Flush()
+}
+
+// buildReloadLog returns the reload log of the generation built by record.
+// Generations start from 1 for each rebirth process, so the log is searched until the next build.
+func buildReloadLog(record, next *buildRecord, logs []*ReloadLog) *ReloadLog {
+	var found *ReloadLog
+	for _, log := range logs {
+		if log.Generation != record.Generation || log.Time.Before(record.Time) {
+			continue
+		}
+		if next != nil && !log.Time.Before(next.Time) {
+			continue
+		}
+		found = log
+	}
+	return found
+}
@@ -0,0 +1,250 @@
+package rebirth
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultRemoteDir = "/tmp/rebirth"
+
+func (b *RemoteBuild) dir() string {
+	if b.Dir != "" {
+		return b.Dir
+	}
+	return path.Join(defaultRemoteDir, filepath.Base(cwd))
+}
+
+func (r *Reloader) isRemoteBuild() bool {
+	remote := r.remoteBuildConfig()
+	return remote != nil && (remote.SSH != "" || remote.Docker != "")
+}
+
+// remoteBuildConfig returns build.remote , or host.docker container for build.cgo.in_container .
+func (r *Reloader) remoteBuildConfig() *RemoteBuild {
+	if r.isCgoInContainer() {
+		return &RemoteBuild{Docker: r.host.Docker}
+	}
+	return r.build.Remote
+}
+
+// newRemoteBuildCommand creates GoCommand for building on the remote machine.
+func (r *Reloader) newRemoteBuildCommand() *GoCommand {
+	gocmd := r.newBuildCommand()
+	if r.isCgoInContainer() {
+		// C compiler on the container builds for itself
+		gocmd.BuildOnTarget()
+	} else {
+		// cgo isn't available by cross compiler on the remote machine
+		gocmd.DisableCgo()
+	}
+	gocmd.SetCacheDir(r.remoteCacheDir(r.remoteBuildConfig()))
+	return gocmd
+}
+
+// remoteBuildCommand returns the shell command for building on the remote machine.
+// The binary is written to .rebirth/program under build.remote.dir .
+func (r *Reloader) remoteBuildCommand(source string) (string, error) {
+	gocmd := r.newRemoteBuildCommand()
+	env, err := gocmd.buildEnv()
+	if err != nil {
+		return "", xerrors.Errorf("failed to get build env: %w", err)
+	}
+	args := []string{"cd", shellQuote(r.remoteBuildConfig().dir()), "&&", "mkdir", "-p", configDir, "&&", "env"}
+	for _, e := range env {
+		args = append(args, shellQuote(e))
+	}
+	args = append(args, "go", "build", "-o", path.Join(configDir, "program"))
+	for _, flag := range gocmd.buildFlags() {
+		args = append(args, shellQuote(flag))
+	}
+	args = append(args, shellQuote(source))
+	return strings.Join(args, " "), nil
+}
+
+// remoteBuild sends sources to build.remote , builds there and fetches the binary to target.
+func (r *Reloader) remoteBuild(target, source string) error {
+	if err := r.checkBuildEnvDrift(r.newRemoteBuildCommand()); err != nil {
+		return xerrors.Errorf("failed to check build env: %w", err)
+	}
+	command, err := r.remoteBuildCommand(source)
+	if err != nil {
+		return xerrors.Errorf("failed to get command for remote build: %w", err)
+	}
+	remote := r.remoteBuildConfig()
+	if remote.SSH != "" {
+		if err := r.sshBuild(remote, command, target); err != nil {
+			return xerrors.Errorf("failed to build on %s: %w", remote.SSH, err)
+		}
+		return nil
+	}
+	if err := r.dockerBuild(remote, command, target); err != nil {
+		return xerrors.Errorf("failed to build on container %s: %w", remote.Docker, err)
+	}
+	return nil
+}
+
+func (r *Reloader) sshBuild(remote *RemoteBuild, command, target string) error {
+	dir := remote.dir()
+	if err := NewCommand("ssh", remote.SSH, "mkdir", "-p", shellQuote(dir)).Run(); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	rsync := []string{"rsync", "-az", "--delete"}
+	// excluded files aren't removed by --delete , so build.cache_dir is kept
+	for _, exclude := range r.remoteExcludes() {
+		rsync = append(rsync, "--exclude", exclude)
+	}
+	rsync = append(rsync, cwd+"/", fmt.Sprintf("%s:%s/", remote.SSH, dir))
+	if err := NewCommand(rsync...).Run(); err != nil {
+		return xerrors.Errorf("failed to send sources: %w", err)
+	}
+	if err := NewCommand("ssh", remote.SSH, command).Run(); err != nil {
+		return xerrors.Errorf("failed to build: %w", err)
+	}
+	binary := fmt.Sprintf("%s:%s", remote.SSH, path.Join(dir, configDir, "program"))
+	if err := NewCommand("rsync", "-az", binary, target).Run(); err != nil {
+		return xerrors.Errorf("failed to fetch binary: %w", err)
+	}
+	return nil
+}
+
+func (r *Reloader) dockerBuild(remote *RemoteBuild, command, target string) error {
+	dir := remote.dir()
+	excludes := r.remoteExcludes()
+	if err := runOnContainer(remote.Docker, "mkdir", "-p", dir); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := runOnContainer(remote.Docker, cleanCommand(dir, excludes)...); err != nil {
+		return xerrors.Errorf("failed to clean %s: %w", dir, err)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeSourceTar(writer, excludes))
+	}()
+	ctx := context.Background()
+	rt := containerRuntime()
+	if err := rt.CopyTo(ctx, remote.Docker, dir, reader); err != nil {
+		reader.Close()
+		return xerrors.Errorf("failed to send sources: %w", err)
+	}
+	if err := runOnContainer(remote.Docker, "sh", "-c", command); err != nil {
+		return xerrors.Errorf("failed to build: %w", err)
+	}
+	if err := rt.CopyFrom(ctx, remote.Docker, path.Join(dir, configDir, "program"), target); err != nil {
+		return xerrors.Errorf("failed to fetch binary: %w", err)
+	}
+	return nil
+}
+
+// cleanCommand returns the command removing files in dir except top level directories of excludes ( e.g. build.cache_dir ).
+func cleanCommand(dir string, excludes []string) []string {
+	cmd := []string{"find", dir, "-mindepth", "1", "-maxdepth", "1"}
+	kept := map[string]bool{}
+	for _, exclude := range excludes {
+		name := strings.SplitN(exclude, "/", 2)[0]
+		if !kept[name] {
+			kept[name] = true
+			cmd = append(cmd, "!", "-name", name)
+		}
+	}
+	return append(cmd, "-exec", "rm", "-rf", "{}", "+")
+}
+
+func runOnContainer(container string, cmd ...string) error {
+	dockerCmd := NewDockerCommand(container, cmd...)
+	if err := dockerCmd.Run(); err != nil {
+		return xerrors.Errorf("failed to run: %w", err)
+	}
+	code, err := dockerCmd.ExitCode()
+	if err != nil {
+		return xerrors.Errorf("failed to get exit code: %w", err)
+	}
+	if code != 0 {
+		return xerrors.Errorf("%s exited with status %d", strings.Join(cmd, " "), code)
+	}
+	return nil
+}
+
+// writeSourceTar writes files of the project except excludes to w as tar archive.
+func writeSourceTar(w io.Writer, excludes []string) error {
+	tw := tar.NewWriter(w)
+	if err := filepath.Walk(cwd, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cwd, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		for _, exclude := range excludes {
+			if filepath.ToSlash(rel) == exclude {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	}); err != nil {
+		return xerrors.Errorf("failed to archive sources: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return xerrors.Errorf("failed to close tar writer: %w", err)
+	}
+	return nil
+}
+
+// extractTarFile writes the first regular file in the tar archive to target.
+func extractTarFile(r io.Reader, target string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return xerrors.New("no file in archive")
+		}
+		if err != nil {
+			return xerrors.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeAgent(target, tr); err != nil {
+			return xerrors.Errorf("failed to write %s: %w", target, err)
+		}
+		return nil
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
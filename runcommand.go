@@ -0,0 +1,94 @@
+package rebirth
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// usesBinary returns false if run.command runs the program without the built binary ( e.g. node server.js ).
+func (r *Run) usesBinary() bool {
+	return r == nil || r.Command == "" || strings.Contains(r.Command, "REBIRTH_BINARY")
+}
+
+// commandLine returns words of run.command whose $REBIRTH_BINARY is replaced by binary.
+// It's empty if run.command isn't specified.
+func (r *Run) commandLine(binary string) []string {
+	if r == nil || r.Command == "" {
+		return nil
+	}
+	words, err := splitShellWords(r.Command)
+	if err != nil {
+		// it's validated on loading the config
+		return nil
+	}
+	replacer := strings.NewReplacer("${REBIRTH_BINARY}", binary, "$REBIRTH_BINARY", binary)
+	for i, word := range words {
+		words[i] = replacer.Replace(word)
+	}
+	return words
+}
+
+// runCommandHooks runs build.before and build.after instead of building the application for run.command without the built binary.
+func (r *Reloader) runCommandHooks() error {
+	if err := r.runBuildBeforeCommands(); err != nil {
+		return xerrors.Errorf("failed to run build.before commands: %w", err)
+	}
+	if err := r.runBuildAfterCommands(); err != nil {
+		return xerrors.Errorf("failed to run build.after commands: %w", err)
+	}
+	return nil
+}
+
+// splitShellWords splits the shell command to words by spaces outside of quotes.
+func splitShellWords(command string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(command) && strings.IndexByte("\"\\$`", command[i+1]) >= 0 {
+				i++
+				word.WriteByte(command[i])
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(command):
+			i++
+			if command[i] != '\n' {
+				word.WriteByte(command[i])
+				inWord = true
+			}
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, xerrors.Errorf("unterminated quote %c", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,98 @@
+package rebirth
+
+import (
+	"bytes"
+
+	"golang.org/x/xerrors"
+)
+
+// runBeforeCommands runs run.before before each restart of the application.
+// If one of them fails, the application isn't restarted.
+func (r *Reloader) runBeforeCommands() error {
+	if r.run == nil {
+		return nil
+	}
+	for _, hook := range r.matchedHooks(r.run.Before) {
+		if err := r.runAppHookCommand("run.before", hook.Command); err != nil {
+			return xerrors.Errorf("failed to run command in run.before: %w", err)
+		}
+	}
+	return nil
+}
+
+// runAfterCommands runs run.after after each restart of the application.
+// The application is already running, so failures are only logged.
+func (r *Reloader) runAfterCommands() {
+	if r.run == nil {
+		return
+	}
+	for _, hook := range r.matchedHooks(r.run.After) {
+		if err := r.runAppHookCommand("run.after", hook.Command); err != nil {
+			r.logger.Errorf("failed to run command in run.after: %v", err)
+			return
+		}
+	}
+}
+
+// runShutdownCommands runs run.shutdown after stopping the application on shutdown of rebirth.
+// rebirth is exiting, so failures are only logged.
+func (r *Reloader) runShutdownCommands() {
+	if r.run == nil {
+		return
+	}
+	for _, hook := range r.matchedHooks(r.run.Shutdown) {
+		if err := r.runAppHookCommand("run.shutdown", hook.Command); err != nil {
+			r.logger.Errorf("failed to run command in run.shutdown: %v", err)
+		}
+	}
+}
+
+// runAppHookCommand runs cmd on the same place as the application ( localhost, container or host.ssh ) with env of the application.
+// Output of cmd is written to the log of rebirth line by line.
+func (r *Reloader) runAppHookCommand(name, cmd string) error {
+	r.logger.Infof("Running: %s", cmd)
+	stdout, stderr := r.appHookOutput(name)
+	defer stdout.flush()
+	defer stderr.flush()
+	if r.isDockerMode() {
+		dockerCmd := NewDockerCommand(r.host.Docker, "sh", "-c", cmd)
+		dockerCmd.AddEnv(r.runEnv())
+		dockerCmd.SetOutput(stdout, stderr)
+		if err := dockerCmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run %s on container: %w", cmd, err)
+		}
+		code, err := dockerCmd.ExitCode()
+		if err != nil {
+			return xerrors.Errorf("failed to get exit code of %s: %w", cmd, err)
+		}
+		if code != 0 {
+			return xerrors.Errorf("%s exited with status %d", cmd, code)
+		}
+		return nil
+	}
+	if r.isSSHMode() {
+		sshCmd := r.newSSHHookCommand(cmd, r.runEnv())
+		sshCmd.SetOutput(stdout, stderr)
+		if err := sshCmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run %s on %s: %w", cmd, r.host.SSH.Host, err)
+		}
+		return nil
+	}
+	hookCmd := newHookCommand("sh", "-c", cmd)
+	hookCmd.AddEnv(r.runEnv())
+	hookCmd.SetOutput(stdout, stderr)
+	if err := hookCmd.Run(); err != nil {
+		return xerrors.Errorf("failed to run %s: %w", cmd, err)
+	}
+	return nil
+}
+
+func (r *Reloader) appHookOutput(name string) (*lineWriter, *lineWriter) {
+	stdout := &lineWriter{fn: func(line []byte) {
+		r.logger.Infof("[%s] %s", name, bytes.TrimRight(line, "\r\n"))
+	}}
+	stderr := &lineWriter{fn: func(line []byte) {
+		r.logger.Warnf("[%s] %s", name, bytes.TrimRight(line, "\r\n"))
+	}}
+	return stdout, stderr
+}
@@ -0,0 +1,152 @@
+package rebirth
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	dockerfileNames  = []string{"Dockerfile"}
+	composeFileNames = []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}
+)
+
+// project is the layout of the project detected for scaffolding rebirth.yml .
+type project struct {
+	module      string
+	rootMain    bool
+	mains       []string
+	dockerfile  string
+	composeFile string
+}
+
+// isMainPackage returns true if dir has go files of main package.
+func isMainPackage(dir string) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if f.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+func firstExistingFile(names []string) string {
+	for _, name := range names {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func detectProject() (*project, error) {
+	mod, err := ioutil.ReadFile(goModPath)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s. rebirth supports go modules only: %w", goModPath, err)
+	}
+	p := &project{
+		module:      parseModulePath(mod),
+		rootMain:    isMainPackage("."),
+		dockerfile:  firstExistingFile(dockerfileNames),
+		composeFile: firstExistingFile(composeFileNames),
+	}
+	dirs, err := filepath.Glob(filepath.Join("cmd", "*"))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to find main packages under cmd: %w", err)
+	}
+	for _, dir := range dirs {
+		if isMainPackage(dir) {
+			p.mains = append(p.mains, "./"+filepath.ToSlash(dir))
+		}
+	}
+	sort.Strings(p.mains)
+	return p, nil
+}
+
+// scaffoldConfig renders rebirth.yml for the project. Settings which can't be detected are commented out.
+func scaffoldConfig(p *project) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# rebirth.yml for %s\n", p.module)
+	name := path.Base(p.module)
+	switch {
+	case p.composeFile != "":
+		fmt.Fprintf(&b, "# %s is found. specify the service running the application\n", p.composeFile)
+		fmt.Fprintf(&b, "# host:\n#   compose_service: %s\n", name)
+	case p.dockerfile != "":
+		fmt.Fprintf(&b, "# %s is found. specify the container running the application\n", p.dockerfile)
+		fmt.Fprintf(&b, "# host:\n#   docker: %s\n", name)
+	}
+	if !p.rootMain && len(p.mains) == 1 {
+		fmt.Fprintf(&b, "build:\n  main: %s\n", p.mains[0])
+	}
+	if !p.rootMain && len(p.mains) > 1 {
+		b.WriteString("targets:\n")
+		for _, main := range p.mains {
+			fmt.Fprintf(&b, "  %s:\n    main: %s\n", path.Base(main), main)
+		}
+	}
+	b.WriteString("run:\n")
+	b.WriteString("  args: []\n")
+	b.WriteString("watch:\n")
+	b.WriteString("  ignore:\n    - vendor\n")
+	return b.String()
+}
+
+// InitConfig creates the config file ( default: .rebirth.yml ) with settings detected from the project ( module, main packages and Dockerfile )
+// and .rebirth directory.
+func InitConfig() error {
+	if err := checkInitConfigPath(); err != nil {
+		return err
+	}
+	p, err := detectProject()
+	if err != nil {
+		return xerrors.Errorf("failed to detect project: %w", err)
+	}
+	if !p.rootMain && len(p.mains) == 0 {
+		return xerrors.New("main package isn't found in the current directory and cmd/*")
+	}
+	return createConfig(scaffoldConfig(p))
+}
+
+// checkInitConfigPath fails if the config file created by `rebirth init` already exists.
+func checkInitConfigPath() error {
+	if configPath == globalConfigPath() {
+		// the global config is found, but the project has no config yet
+		configPath = initConfigPath
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		return xerrors.Errorf("already exists %s", configPath)
+	}
+	return nil
+}
+
+// createConfig writes content to the config file and creates .rebirth directory.
+func createConfig(content string) error {
+	if err := ioutil.WriteFile(configPath, []byte(content), 0644); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", configPath, err)
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", configDir, err)
+	}
+	logger().Infof("Created %s and %s", configPath, configDir)
+	return nil
+}
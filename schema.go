@@ -0,0 +1,286 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"golang.org/x/xerrors"
+)
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var (
+	hookType   = reflect.TypeOf(Hook{})
+	configType = reflect.TypeOf(Config{})
+)
+
+// ConfigSchema returns JSON Schema of rebirth.yml generated from Config .
+// It's used by editors ( e.g. yaml-language-server ) for completion and validation.
+func ConfigSchema() ([]byte, error) {
+	schema := structSchema(configType)
+	schema["$schema"] = schemaDraft
+	schema["title"] = "rebirth.yml"
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode schema: %w", err)
+	}
+	return data, nil
+}
+
+func typeSchema(typ reflect.Type) map[string]interface{} {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == configType {
+		// profiles have the same schema as the root
+		return map[string]interface{}{"$ref": "#"}
+	}
+	if typ == hookType {
+		// a command string or a mapping with command and when
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{
+					"type":                 "object",
+					"required":             []string{"command"},
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"command": map[string]interface{}{"type": "string"},
+						"when": map[string]interface{}{
+							"oneOf": []interface{}{
+								map[string]interface{}{"type": "string"},
+								map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		// scalars ( e.g. PORT: 8080 ) are decoded as string
+		return map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(typ.Elem())}
+	case reflect.Struct:
+		return structSchema(typ)
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(typ reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// LintConfig checks the config file against ConfigSchema ( types and unknown keys ) and by Config.Validate .
+// Each of profiles is checked after applying it. Unlike LoadConfig , it doesn't read env files, so it can run on CI without them.
+func LintConfig(confPath string) error {
+	if err := checkConfigSchema(confPath); err != nil {
+		return err
+	}
+	cfg, err := parseConfig(confPath, "")
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == defaultProfile {
+			continue
+		}
+		if _, err := parseConfig(confPath, name); err != nil {
+			return xerrors.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkConfigSchema validates the config file by JSON Schema of ConfigSchema , so the schema used by editors can't drift from the linter.
+func checkConfigSchema(confPath string) error {
+	file, err := ioutil.ReadFile(confPath)
+	if err != nil {
+		return xerrors.Errorf("failed to read config file from %s: %w", confPath, err)
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(file, &doc); err != nil {
+		return xerrors.Errorf("invalid %s:\n%s", confPath, formatYAMLError(file, err))
+	}
+	data, err := ConfigSchema()
+	if err != nil {
+		return err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return xerrors.Errorf("failed to decode schema: %w", err)
+	}
+	v := &configValidator{}
+	v.checkSchema(schema, schema, doc, nil)
+	if len(v.errs) > 0 {
+		return xerrors.Errorf("invalid %s:\n%s", confPath, formatConfigErrors(file, v.errs))
+	}
+	return nil
+}
+
+// checkSchema checks value by the subset of JSON Schema generated by ConfigSchema .
+// root is the schema referred by $ref: "#" . null values are regarded as unspecified.
+func (v *configValidator) checkSchema(root, schema map[string]interface{}, value interface{}, keys []interface{}) {
+	if value == nil {
+		return
+	}
+	if schema["$ref"] == "#" {
+		schema = root
+	}
+	if oneOf, exists := schema["oneOf"].([]interface{}); exists {
+		for _, sub := range oneOf {
+			alt := &configValidator{}
+			alt.checkSchema(root, sub.(map[string]interface{}), value, keys)
+			if len(alt.errs) == 0 {
+				return
+			}
+		}
+		v.add("doesn't match any of the allowed forms", keys...)
+		return
+	}
+	if typ, exists := schema["type"]; exists && !matchSchemaType(typ, value) {
+		v.addf(keys, "must be %s", schemaTypeNames(typ))
+		return
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		items, exists := schema["items"].(map[string]interface{})
+		if !exists {
+			return
+		}
+		for i, item := range value {
+			v.checkSchema(root, items, item, append(keys[:len(keys):len(keys)], i))
+		}
+	case map[string]interface{}:
+		v.checkSchemaObject(root, schema, value, keys)
+	case map[interface{}]interface{}:
+		obj := map[string]interface{}{}
+		for k, item := range value {
+			obj[fmt.Sprint(k)] = item
+		}
+		v.checkSchemaObject(root, schema, obj, keys)
+	}
+}
+
+func (v *configValidator) checkSchemaObject(root, schema, value map[string]interface{}, keys []interface{}) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := []string{}
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		itemKeys := append(keys[:len(keys):len(keys)], name)
+		if property, exists := properties[name].(map[string]interface{}); exists {
+			v.checkSchema(root, property, value[name], itemKeys)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.add("unknown key", itemKeys...)
+			}
+		case map[string]interface{}:
+			v.checkSchema(root, additional, value[name], itemKeys)
+		}
+	}
+	if required, exists := schema["required"].([]interface{}); exists {
+		for _, name := range required {
+			if _, exists := value[name.(string)]; !exists {
+				v.add("must be specified", append(keys[:len(keys):len(keys)], name)...)
+			}
+		}
+	}
+}
+
+func matchSchemaType(typ interface{}, value interface{}) bool {
+	if types, ok := typ.([]interface{}); ok {
+		for _, t := range types {
+			if matchSchemaType(t, value) {
+				return true
+			}
+		}
+		return false
+	}
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch value := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return value == float64(int64(value))
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func schemaTypeNames(typ interface{}) string {
+	types, ok := typ.([]interface{})
+	if !ok {
+		return fmt.Sprint(typ)
+	}
+	names := []string{}
+	for _, t := range types {
+		names = append(names, fmt.Sprint(t))
+	}
+	return strings.Join(names, " or ")
+}
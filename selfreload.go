@@ -0,0 +1,142 @@
+package rebirth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// selfArgs are the original arguments of rebirth for restarting itself.
+// They are copied before main rewrites os.Args .
+var selfArgs = append([]string{}, os.Args...)
+
+// isSelfConfig returns true if file is the config file used by rebirth.
+func isSelfConfig(file string) bool {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	return path == absConfigPath()
+}
+
+// splitSelfConfig removes rebirth.yml from files and reports whether it's changed.
+func splitSelfConfig(files []string) ([]string, bool) {
+	others := []string{}
+	changed := false
+	for _, file := range files {
+		if isSelfConfig(file) {
+			changed = true
+			continue
+		}
+		others = append(others, file)
+	}
+	return others, changed
+}
+
+// restartRequiredChanges returns names of changed settings which are applied only on starting rebirth.
+func restartRequiredChanges(old, cfg *Config) []string {
+	oldRun, run := old.Run, cfg.Run
+	if oldRun == nil {
+		oldRun = &Run{}
+	}
+	if run == nil {
+		run = &Run{}
+	}
+	oldBuild, build := old.Build, cfg.Build
+	if oldBuild == nil {
+		oldBuild = &Build{}
+	}
+	if build == nil {
+		build = &Build{}
+	}
+	oldWatch, watch := old.Watch, cfg.Watch
+	if oldWatch == nil {
+		oldWatch = &Watch{}
+	}
+	if watch == nil {
+		watch = &Watch{}
+	}
+	values := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"host", old.Host, cfg.Host},
+		{"wasm", old.Wasm, cfg.Wasm},
+		{"proxy", old.Proxy, cfg.Proxy},
+		{"targets", old.Targets, cfg.Targets},
+		{"services", old.Services, cfg.Services},
+		{"startup_order", old.StartupOrder, cfg.StartupOrder},
+		{"log", old.Log, cfg.Log},
+		{"status", old.Status, cfg.Status},
+		{"build.output", oldBuild.Output, build.Output},
+		{"run.ports", oldRun.Ports, run.Ports},
+		{"run.sockets", oldRun.Sockets, run.Sockets},
+		{"run.output", oldRun.Output, run.Output},
+		{"run.triggers", oldRun.Triggers, run.Triggers},
+		{"run.idle", oldRun.Idle, run.Idle},
+		{"watch.root", oldWatch.Root, watch.Root},
+		{"watch.ignore", oldWatch.Ignore, watch.Ignore},
+		{"watch.poll", oldWatch.Poll, watch.Poll},
+		{"watch.poll_interval", oldWatch.PollInterval, watch.PollInterval},
+	}
+	changes := []string{}
+	for _, v := range values {
+		if !reflect.DeepEqual(v.old, v.new) {
+			changes = append(changes, v.name)
+		}
+	}
+	if os.Getenv(hookPolicyEnv) != "" && !reflect.DeepEqual(hookCommands(old), hookCommands(cfg)) {
+		// new hooks must be verified again
+		changes = append(changes, "hooks")
+	}
+	return changes
+}
+
+// reloadSelfConfig loads the changed rebirth.yml and applies it without restarting rebirth.
+// build, run and watch patterns take effect on the next reload.
+// If settings applied only on starting are changed, rebirth restarts itself.
+// An invalid config is reported and the current config is kept.
+func (r *Reloader) reloadSelfConfig() error {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return xerrors.Errorf("failed to load %s. keep the current config: %w", configPath, err)
+	}
+	if changes := restartRequiredChanges(r.cfg, cfg); len(changes) > 0 {
+		r.logger.Infof("Restarting rebirth for changes of %s in %s", strings.Join(changes, ", "), configPath)
+		return r.restartSelf()
+	}
+	build := cfg.Build
+	if build == nil {
+		build = &Build{}
+	}
+	r.lockReload()
+	r.cfg = cfg
+	r.build = build
+	r.run = cfg.Run
+	r.watch = cfg.Watch
+	r.tasks = cfg.AllTasks()
+	r.unlockReload()
+	r.logger.Infof("Reloaded %s. changes take effect on the next reload", configPath)
+	r.emitConfigReload(cfg)
+	return nil
+}
+
+// restartSelf stops the application gracefully and replaces the process of rebirth by itself with the original arguments.
+func (r *Reloader) restartSelf() error {
+	path, err := os.Executable()
+	if err != nil {
+		return xerrors.Errorf("failed to get path of rebirth: %w", err)
+	}
+	if err := r.Close(); err != nil {
+		return xerrors.Errorf("failed to close: %w", err)
+	}
+	killProcessGroups()
+	runCleanups()
+	if err := execSelf(path, selfArgs); err != nil {
+		return xerrors.Errorf("failed to restart rebirth: %w", err)
+	}
+	return nil
+}
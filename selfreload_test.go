@@ -0,0 +1,100 @@
+package rebirth
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestRestartRequiredChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		hooks    string
+		expected []string
+	}{
+		{
+			name:     "same",
+			old:      "host:\n  docker: app\nrun:\n  args: [serve]\n",
+			new:      "host:\n  docker: app\nrun:\n  args: [serve]\n",
+			expected: []string{},
+		},
+		{
+			name:     "settings applied on reloading",
+			old:      "build:\n  tags: [dev]\nrun:\n  args: [serve]\n  env:\n    PORT: \"8080\"\nwatch:\n  include: [templates/**]\n",
+			new:      "build:\n  tags: [prod]\nrun:\n  args: [worker]\n  env:\n    PORT: \"9090\"\nwatch:\n  include: [static/**]\n",
+			expected: []string{},
+		},
+		{
+			name:     "host",
+			old:      "host:\n  docker: app\n",
+			new:      "host:\n  docker: api\n",
+			expected: []string{"host"},
+		},
+		{
+			name:     "added sections",
+			old:      "build:\n  tags: [dev]\n",
+			new:      "proxy:\n  listen: :8080\n  target: \"8081\"\nrun:\n  ports:\n    \"8081\": \"8081\"\nwatch:\n  root: cmd\n",
+			expected: []string{"proxy", "run.ports", "watch.root"},
+		},
+		{
+			name:     "removed sections",
+			old:      "log:\n  level: debug\nbuild:\n  output: bin/app\nwatch:\n  poll: true\n  poll_interval: 1s\n",
+			new:      "",
+			expected: []string{"log", "build.output", "watch.poll", "watch.poll_interval"},
+		},
+		{
+			name:     "watch.ignore",
+			old:      "watch:\n  ignore: [vendor]\n",
+			new:      "watch:\n  ignore: [vendor, node_modules]\n",
+			expected: []string{"watch.ignore"},
+		},
+		{
+			name:     "hooks without policy",
+			old:      "build:\n  before: [go generate ./...]\n",
+			new:      "build:\n  before: [make assets]\n",
+			expected: []string{},
+		},
+		{
+			name:     "hooks with policy",
+			old:      "build:\n  before: [go generate ./...]\n",
+			new:      "build:\n  before: [make assets]\n",
+			hooks:    hookPolicyConfirm,
+			expected: []string{"hooks"},
+		},
+		{
+			name:     "same hooks with policy",
+			old:      "build:\n  before: [go generate ./...]\n",
+			new:      "build:\n  before: [go generate ./...]\n  tags: [dev]\n",
+			hooks:    hookPolicyAllowlist,
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			orig, exists := os.LookupEnv(hookPolicyEnv)
+			os.Setenv(hookPolicyEnv, test.hooks)
+			defer func() {
+				if exists {
+					os.Setenv(hookPolicyEnv, orig)
+				} else {
+					os.Unsetenv(hookPolicyEnv)
+				}
+			}()
+			var old, cfg Config
+			if err := yaml.Unmarshal([]byte(test.old), &old); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(test.new), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if got := restartRequiredChanges(&old, &cfg); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected %q but got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package rebirth
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/xerrors"
+)
+
+// Commands run in their own process groups, so Ctrl-C on the terminal is delivered to rebirth only
+// and rebirth stops them in order. The process groups are tracked for killing whole process trees on forced exit.
+// The application by run.interactive runs in the foreground process group of rebirth, and only the process is tracked.
+var (
+	shutdownMu sync.Mutex
+	shutdownFn func() error
+	// processGroups has true for the process group, or false for the process in the group of rebirth.
+	processGroups = map[int]bool{}
+	cleanups      []func()
+	// shutdownSignals receives signals handled by HandleShutdown .
+	shutdownSignals chan os.Signal
+)
+
+func trackProcessGroup(pid int) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	processGroups[pid] = true
+}
+
+func trackProcess(pid int) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	processGroups[pid] = false
+}
+
+func untrackProcessGroup(pid int) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	delete(processGroups, pid)
+}
+
+// killProcessGroups kills all process trees of running commands.
+func killProcessGroups() {
+	shutdownMu.Lock()
+	groups := map[int]bool{}
+	for pid, group := range processGroups {
+		groups[pid] = group
+	}
+	shutdownMu.Unlock()
+	for pid, group := range groups {
+		if !group {
+			if err := killProcess(pid); err != nil {
+				logger().Errorf("failed to kill process(%d): %v", pid, err)
+			}
+			continue
+		}
+		if err := killProcessGroup(pid); err != nil {
+			logger().Errorf("failed to kill process group(%d): %v", pid, err)
+		}
+	}
+}
+
+// killProcess kills the process only ( e.g. the application in the foreground process group ).
+func killProcess(pid int) error {
+	if !processExists(pid) {
+		return nil
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// addCleanup registers fn called on both graceful and forced exit ( e.g. removing the control socket ).
+// fn must be idempotent because it may be called after it's done by Close .
+func addCleanup(fn func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	cleanups = append(cleanups, fn)
+}
+
+func runCleanups() {
+	shutdownMu.Lock()
+	fns := cleanups
+	cleanups = nil
+	shutdownMu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// OnShutdown sets fn called for graceful shutdown ( e.g. Reloader.Close ).
+func OnShutdown(fn func() error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownFn = fn
+}
+
+// HandleShutdown handles SIGINT, SIGQUIT and SIGTERM.
+// The first signal starts graceful shutdown by the function of OnShutdown ,
+// and the second signal kills all process trees immediately.
+// In both cases, remaining process trees are killed and registered cleanups run before exiting,
+// so no orphaned child and stale file remain.
+func HandleShutdown() {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	shutdownMu.Lock()
+	shutdownSignals = sig
+	shutdownMu.Unlock()
+	go func() {
+		<-sig
+		shutdownMu.Lock()
+		fn := shutdownFn
+		shutdownMu.Unlock()
+		done := make(chan error, 1)
+		code := 0
+		if fn == nil {
+			// interrupted helper commands ( e.g. rebirth build )
+			code = 1
+			done <- nil
+		} else {
+			logger().Infof("close... ( press Ctrl-C again to force exit )")
+			go func() { done <- fn() }()
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("%+v", err)
+				code = 1
+			}
+		case <-sig:
+			logger().Infof("force exit...")
+			code = 1
+		}
+		Exit(code)
+	}()
+}
+
+// Exit kills remaining process trees and runs registered cleanups ( e.g. restoring the terminal ) before exiting with code.
+func Exit(code int) {
+	killProcessGroups()
+	runCleanups()
+	os.Exit(code)
+}
+
+// requestShutdown starts shutdown handled by HandleShutdown without sending the signal ( e.g. on Windows ).
+func requestShutdown() error {
+	shutdownMu.Lock()
+	sig := shutdownSignals
+	shutdownMu.Unlock()
+	if sig == nil {
+		return xerrors.New("shutdown isn't handled")
+	}
+	select {
+	case sig <- os.Interrupt:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package rebirth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot is the saved state of the dev session. It's written to .rebirth/snapshots/<name>
+// with the binary of the generation and rebirth.yml at the time.
+type Snapshot struct {
+	Name       string            `json:"name"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Generation int               `json:"generation"`
+	Args       []string          `json:"args,omitempty"`
+	ExtraArgs  []string          `json:"extra_args,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Manifest   *Manifest         `json:"manifest"`
+}
+
+func snapshotDir(name string) string {
+	return filepath.Join(cwd, configDir, "snapshots", name)
+}
+
+func validateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return xerrors.Errorf("invalid snapshot name %q. letters, digits, '.', '-' and '_' are available", name)
+	}
+	return nil
+}
+
+// LoadSnapshot loads the saved snapshot by name.
+func LoadSnapshot(name string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(snapshotDir(name), "snapshot.json")
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, xerrors.Errorf("snapshot %s isn't found", name)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", path, err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(file, &snapshot); err != nil {
+		return nil, xerrors.Errorf("failed to decode %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// Snapshots returns saved snapshots in order of creation.
+func Snapshots() ([]*Snapshot, error) {
+	dir := filepath.Join(cwd, configDir, "snapshots")
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", dir, err)
+	}
+	snapshots := []*Snapshot{}
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		snapshot, err := LoadSnapshot(file.Name())
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// SaveSnapshot saves the running generation's binary, run.args, run.env, rebirth.yml and the manifest as the snapshot.
+// The snapshot which has the same name is overwritten.
+func (r *Reloader) SaveSnapshot(name string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if r.isTargetsMode() || r.isObserveMode() {
+		return nil, xerrors.New("snapshot doesn't support targets and observer mode")
+	}
+	r.lockReload()
+	defer r.unlockReload()
+	// the running generation is older than the latest build after rolling back
+	gen := r.runningGeneration
+	r.manifestMu.Lock()
+	manifest := r.manifest
+	if manifest != nil && manifest.Generation != gen {
+		// the latest build failed to restart
+		manifest = r.goodManifest
+	}
+	r.manifestMu.Unlock()
+	if manifest == nil || gen == 0 || !r.artifacts.exists(gen) {
+		return nil, xerrors.New("no running generation to save yet")
+	}
+	snapshot := &Snapshot{
+		Name:       name,
+		CreatedAt:  time.Now(),
+		Generation: gen,
+		ExtraArgs:  r.extraArgs,
+		Manifest:   manifest,
+	}
+	if r.run != nil {
+		snapshot.Args = r.run.Args
+		snapshot.Env = r.run.Env
+	}
+	dir := snapshotDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := copyFile(filepath.Join(dir, "binary"), r.artifacts.path(gen), 0755); err != nil {
+		return nil, xerrors.Errorf("failed to save binary: %w", err)
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		if err := copyFile(filepath.Join(dir, filepath.Base(configPath)), configPath, 0644); err != nil {
+			return nil, xerrors.Errorf("failed to save %s: %w", configPath, err)
+		}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode snapshot: %w", err)
+	}
+	// run.env may have secrets
+	if err := ioutil.WriteFile(filepath.Join(dir, "snapshot.json"), data, 0600); err != nil {
+		return nil, xerrors.Errorf("failed to write snapshot: %w", err)
+	}
+	r.logger.Infof("Saved generation %d as snapshot %s", gen, name)
+	return snapshot, nil
+}
+
+// RestoreSnapshot restarts the application by the binary, run.args and run.env of the snapshot without building.
+// The restored binary becomes a new generation. rebirth.yml is put back if it changed after saving the snapshot
+// ( the current one is backed up to rebirth.yml.bak ), and the other config is applied on the next start of rebirth.
+func (r *Reloader) RestoreSnapshot(name string) (*Snapshot, error) {
+	if r.isTargetsMode() || r.isObserveMode() {
+		return nil, xerrors.New("snapshot doesn't support targets and observer mode")
+	}
+	snapshot, err := LoadSnapshot(name)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load snapshot: %w", err)
+	}
+	dir := snapshotDir(name)
+	r.lockReload()
+	defer r.unlockReload()
+	if err := r.restoreSnapshotConfig(dir); err != nil {
+		return nil, xerrors.Errorf("failed to restore %s: %w", configPath, err)
+	}
+	if r.run == nil {
+		r.run = &Run{}
+	}
+	r.run.Args = snapshot.Args
+	r.run.Env = snapshot.Env
+	r.extraArgs = snapshot.ExtraArgs
+	gen := r.generation + 1
+	if err := r.artifacts.save(gen, filepath.Join(dir, "binary"), r.goodGeneration); err != nil {
+		return nil, xerrors.Errorf("failed to save artifact: %w", err)
+	}
+	r.logger.Infof("Restoring snapshot %s ( generation %d ) as generation %d...", name, snapshot.Generation, gen)
+	if err := r.restart(r.artifacts.path(gen)); err != nil {
+		return nil, xerrors.Errorf("failed to restart by snapshot %s: %w", name, err)
+	}
+	r.generation = gen
+	if snapshot.Manifest != nil {
+		manifest := *snapshot.Manifest
+		manifest.Generation = gen
+		if err := r.saveManifest(&manifest); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+	}
+	if err := r.checkHealth(); err != nil {
+		r.setState(StateFailed, err.Error())
+		return nil, xerrors.Errorf("failed to check health: %w", err)
+	}
+	r.setState(StateRunning, "")
+	r.logger.Infof("Restored snapshot %s", name)
+	return snapshot, nil
+}
+
+func (r *Reloader) restoreSnapshotConfig(dir string) error {
+	saved, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(configPath)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to read saved %s: %w", configPath, err)
+	}
+	current, err := ioutil.ReadFile(configPath)
+	if err == nil && bytes.Equal(saved, current) {
+		return nil
+	}
+	if err == nil {
+		backup := configPath + ".bak"
+		if err := ioutil.WriteFile(backup, current, 0644); err != nil {
+			return xerrors.Errorf("failed to back up %s: %w", configPath, err)
+		}
+		r.logger.Infof("%s is backed up to %s", configPath, backup)
+	}
+	if err := copyFile(configPath, filepath.Join(dir, filepath.Base(configPath)), 0644); err != nil {
+		return xerrors.Errorf("failed to put back %s: %w", configPath, err)
+	}
+	r.logger.Warnf("%s is restored from the snapshot. restart rebirth to apply config other than run.args and run.env", configPath)
+	return nil
+}
+
+func (r *Reloader) handleSnapshot(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeControlResponse(w, nil, xerrors.Errorf("unsupported method %s", req.Method))
+		return
+	}
+	name := req.URL.Query().Get("name")
+	var (
+		snapshot *Snapshot
+		err      error
+	)
+	switch action := req.URL.Query().Get("action"); action {
+	case "save":
+		snapshot, err = r.SaveSnapshot(name)
+	case "restore":
+		snapshot, err = r.RestoreSnapshot(name)
+	default:
+		err = xerrors.Errorf("unknown snapshot action %q. save and restore are available", action)
+	}
+	writeControlResponse(w, snapshot, err)
+}
@@ -0,0 +1,85 @@
+package rebirth
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/rebirth/listener"
+	"golang.org/x/xerrors"
+)
+
+// inheritedSocket is the listening socket opened by rebirth and inherited by each process of the application.
+type inheritedSocket struct {
+	name string
+	addr string
+	file *os.File
+}
+
+// socketAddr resolves the address of run.sockets . A name of run.ports and a port number listen on all interfaces.
+func (r *Reloader) socketAddr(value string) string {
+	if port, exists := r.ports[value]; exists {
+		return fmt.Sprintf(":%d", port)
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		return ":" + value
+	}
+	return value
+}
+
+// openSockets listens run.sockets once for the session. The sockets are passed to the application as inherited fds,
+// so the restarted process can use them without binding the address again.
+func (r *Reloader) openSockets() error {
+	if r.run == nil || len(r.run.Sockets) == 0 || r.isObserveMode() {
+		return nil
+	}
+	if r.isDockerMode() || r.isSSHMode() {
+		return xerrors.New("run.sockets is supported on localhost only")
+	}
+	names := []string{}
+	for name := range r.run.Sockets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addr := r.socketAddr(r.run.Sockets[name])
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return xerrors.Errorf("failed to listen %s for run.sockets.%s: %w", addr, name, err)
+		}
+		file, err := l.(*net.TCPListener).File()
+		// the duplicated fd keeps the socket open
+		l.Close()
+		if err != nil {
+			return xerrors.Errorf("failed to get fd of run.sockets.%s: %w", name, err)
+		}
+		r.sockets = append(r.sockets, &inheritedSocket{name: name, addr: addr, file: file})
+		r.logger.Infof("Listening %s for run.sockets.%s", addr, name)
+	}
+	return nil
+}
+
+// socketFiles returns files inherited by the application and the env telling their fds.
+// Inherited files start from fd 3 in the order of the files.
+func (r *Reloader) socketFiles() ([]*os.File, []string) {
+	if len(r.sockets) == 0 {
+		return nil, nil
+	}
+	files := []*os.File{}
+	fds := []string{}
+	for i, socket := range r.sockets {
+		files = append(files, socket.file)
+		fds = append(fds, fmt.Sprintf("%s=%d", socket.name, i+3))
+	}
+	return files, []string{fmt.Sprintf("%s=%s", listener.EnvName, strings.Join(fds, ","))}
+}
+
+func (r *Reloader) closeSockets() {
+	for _, socket := range r.sockets {
+		socket.file.Close()
+	}
+	r.sockets = nil
+}
@@ -0,0 +1,186 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// unameGOOS and unameGOARCH map `uname -s` and `uname -m` of the remote machine to GOOS and GOARCH.
+var (
+	unameGOOS = map[string]string{
+		"Linux":   "linux",
+		"Darwin":  "darwin",
+		"FreeBSD": "freebsd",
+	}
+	unameGOARCH = map[string]string{
+		"x86_64":  "amd64",
+		"amd64":   "amd64",
+		"aarch64": "arm64",
+		"arm64":   "arm64",
+		"armv7l":  "arm",
+		"armv6l":  "arm",
+		"i686":    "386",
+		"i386":    "386",
+	}
+)
+
+func (h *SSHHost) dir() string {
+	if h.Dir != "" {
+		return h.Dir
+	}
+	return path.Join(defaultRemoteDir, filepath.Base(cwd))
+}
+
+// sshArgs returns options of ssh for host.ssh.key and host.ssh.port .
+func (h *SSHHost) sshArgs() []string {
+	args := []string{}
+	if h.Key != "" {
+		args = append(args, "-i", ExpandPath(h.Key))
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", fmt.Sprint(h.Port))
+	}
+	return args
+}
+
+// command returns ssh command running command on the remote machine.
+func (h *SSHHost) command(command string) []string {
+	args := append([]string{"ssh"}, h.sshArgs()...)
+	return append(args, h.Host, command)
+}
+
+// newSSHHookCommand creates Command running command in host.ssh.dir on the remote machine with env ( e.g. of the application ).
+func (r *Reloader) newSSHHookCommand(command string, env []string) *Command {
+	ssh := r.host.SSH
+	assigns := []string{}
+	for _, kv := range env {
+		assigns = append(assigns, shellQuote(kv))
+	}
+	remote := fmt.Sprintf("cd %s && env %s sh -c %s", shellQuote(ssh.dir()), strings.Join(assigns, " "), shellQuote(command))
+	return newHookCommand(ssh.command(remote)...)
+}
+
+// sshClientAddress returns the address of the local machine seen from the remote machine by $SSH_CLIENT .
+func (r *Reloader) sshClientAddress() (string, error) {
+	ssh := r.host.SSH
+	args := ssh.command("echo $SSH_CLIENT")
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", xerrors.Errorf("failed to get SSH_CLIENT on %s: %w", ssh.Host, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", xerrors.Errorf("SSH_CLIENT isn't set on %s", ssh.Host)
+	}
+	return fields[0], nil
+}
+
+func (r *Reloader) isSSHMode() bool {
+	return r.host != nil && r.host.SSH != nil && r.host.SSH.Host != "" && !r.isWasmMode()
+}
+
+// agentTarget returns the place where the agent runs for messages.
+func (r *Reloader) agentTarget() string {
+	if r.isSSHMode() {
+		return r.host.SSH.Host
+	}
+	return "container"
+}
+
+// setupSSH detects the platform of the remote machine, and sends the agent for it to host.ssh.dir .
+func (r *Reloader) setupSSH() error {
+	ssh := r.host.SSH
+	if r.isUsedDocker() {
+		return xerrors.New("host.ssh can't be used with host.docker")
+	}
+	args := ssh.command("uname -s -m")
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return xerrors.Errorf("failed to get platform of %s: %w", ssh.Host, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return xerrors.Errorf("unexpected output of uname on %s: %s", ssh.Host, string(out))
+	}
+	goos, exists := unameGOOS[fields[0]]
+	if !exists {
+		return xerrors.Errorf("unsupported os %s on %s", fields[0], ssh.Host)
+	}
+	goarch, exists := unameGOARCH[fields[1]]
+	if !exists {
+		return xerrors.Errorf("unsupported architecture %s on %s", fields[1], ssh.Host)
+	}
+	r.sshGOOS = goos
+	r.sshGOARCH = goarch
+	agent, err := r.installAgentForPlatform(fmt.Sprintf("%s-%s", goos, goarch))
+	if err != nil {
+		return xerrors.Errorf("failed to install agent: %w", err)
+	}
+	if err := r.uploadSSH(filepath.Join(cwd, agent)); err != nil {
+		return xerrors.Errorf("failed to upload agent: %w", err)
+	}
+	return nil
+}
+
+// uploadSSH sends file under the project directory to the same relative path under host.ssh.dir by rsync.
+func (r *Reloader) uploadSSH(file string) error {
+	ssh := r.host.SSH
+	rel, err := filepath.Rel(cwd, file)
+	if err != nil {
+		return xerrors.Errorf("failed to get relative path of %s: %w", file, err)
+	}
+	dst := path.Join(ssh.dir(), filepath.ToSlash(rel))
+	if err := NewCommand(ssh.command(fmt.Sprintf("mkdir -p %s", shellQuote(path.Dir(dst))))...).Run(); err != nil {
+		return xerrors.Errorf("failed to create directory on %s: %w", ssh.Host, err)
+	}
+	rsync := []string{"rsync", "-az"}
+	if args := ssh.sshArgs(); len(args) > 0 {
+		rsync = append(rsync, "-e", strings.Join(append([]string{"ssh"}, args...), " "))
+	}
+	rsync = append(rsync, file, fmt.Sprintf("%s:%s", ssh.Host, dst))
+	if err := NewCommand(rsync...).Run(); err != nil {
+		return xerrors.Errorf("failed to send %s: %w", rel, err)
+	}
+	return nil
+}
+
+// startSSHAgent starts the agent on the remote machine by SSH and connects to its stdin/stdout.
+// host.ssh.dir is the working directory of the agent like the project directory on the container.
+func (r *Reloader) startSSHAgent() (*AgentClient, error) {
+	ssh := r.host.SSH
+	agent := filepath.ToSlash(agentPath(fmt.Sprintf("%s-%s", r.sshGOOS, r.sshGOARCH)))
+	args := ssh.command(fmt.Sprintf("cd %s && exec %s", shellQuote(ssh.dir()), shellQuote(agent)))
+	cmd := exec.Command(args[0], args[1:]...)
+	// Ctrl-C is handled by rebirth for stopping the application gracefully
+	setProcessGroup(cmd)
+	cmd.Stderr = os.Stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get stdin of ssh: %w", err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get stdout of ssh: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, xerrors.Errorf("failed to start ssh: %w", err)
+	}
+	client := NewAgentClient(w, out)
+	trackProcessGroup(cmd.Process.Pid)
+	go func() {
+		<-client.done
+		cmd.Wait()
+		untrackProcessGroup(cmd.Process.Pid)
+	}()
+	if err := client.Hello(); err != nil {
+		client.Close()
+		return nil, xerrors.Errorf("failed to handshake with agent on %s: %w", ssh.Host, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,147 @@
+package rebirth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	StateBuilding = "building"
+	StateRunning  = "running"
+	StateFailed   = "failed"
+	StateStopped  = "stopped"
+	// StateObserving is the state of observer mode after the successful build.
+	StateObserving = "observing"
+)
+
+// Status is the state of the running rebirth reported by `rebirth status` .
+// It's written to .rebirth/status.json on each change for shell prompts and status lines ( e.g. tmux ).
+type Status struct {
+	Pid        int            `json:"pid"`
+	Ports      map[string]int `json:"ports,omitempty"`
+	State      string         `json:"state,omitempty"`
+	Generation int            `json:"generation,omitempty"`
+	// Error is the summary of the last error ( e.g. the first build error or the panic message ) if State is failed.
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// Short returns the status in a line ( e.g. `running #3` , `failed #4 main.go:10:2: undefined: foo` ).
+func (s *Status) Short() string {
+	line := s.State
+	if s.Generation > 0 {
+		line = fmt.Sprintf("%s #%d", line, s.Generation)
+	}
+	if s.State == StateFailed && s.Error != "" {
+		line = fmt.Sprintf("%s %s", line, s.Error)
+	}
+	return line
+}
+
+func (r *Reloader) Status() *Status {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return &Status{
+		Pid:        os.Getpid(),
+		Ports:      r.ports,
+		State:      r.state,
+		Generation: r.generation,
+		Error:      r.stateError,
+		UpdatedAt:  r.stateUpdatedAt,
+	}
+}
+
+func (r *Reloader) handleStatus(w http.ResponseWriter, req *http.Request) {
+	writeControlResponse(w, r.Status(), nil)
+}
+
+// setState updates the state and writes the status file.
+func (r *Reloader) setState(state, summary string) {
+	r.statusMu.Lock()
+	r.state = state
+	r.stateError = summary
+	r.stateUpdatedAt = time.Now()
+	r.statusMu.Unlock()
+	if err := writeStatusFile(r.Status()); err != nil {
+		r.logger.Errorf("%v", err)
+	}
+}
+
+func writeStatusFile(status *Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return xerrors.Errorf("failed to encode status: %w", err)
+	}
+	// write by rename for readers not to see partially written file
+	tmp := statusPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, statusPath); err != nil {
+		return xerrors.Errorf("failed to write %s: %w", statusPath, err)
+	}
+	return nil
+}
+
+// ReadStatusFile reads the status written by the running rebirth without the control API.
+// If rebirth isn't running, the state is stopped.
+func ReadStatusFile() (*Status, error) {
+	file, err := ioutil.ReadFile(statusPath)
+	if os.IsNotExist(err) {
+		return &Status{State: StateStopped}, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read %s: %w", statusPath, err)
+	}
+	var status Status
+	if err := json.Unmarshal(file, &status); err != nil {
+		return nil, xerrors.Errorf("failed to decode %s: %w", statusPath, err)
+	}
+	// status file of rebirth that exited abnormally. the pid may be reused by an unrelated process
+	pid, err := runningPid()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get pid of running rebirth: %w", err)
+	}
+	if status.Pid == 0 || status.Pid != pid {
+		return &Status{State: StateStopped}, nil
+	}
+	return &status, nil
+}
+
+// buildErrorSummary returns the first build error in the build output, or the error message.
+func buildErrorSummary(lines []string, err error) string {
+	for _, line := range lines {
+		if buildErrorLocationPattern.MatchString(line) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return errorSummary(err)
+}
+
+// errorSummary returns the message of the innermost error without context of wrapping errors.
+func errorSummary(err error) string {
+	for {
+		wrapped := xerrors.Unwrap(err)
+		if wrapped == nil {
+			return err.Error()
+		}
+		err = wrapped
+	}
+}
+
+// crashSummary returns the panic message ( or the fatal error ) of the crashed application, or the exit status.
+func crashSummary(c *crash) string {
+	for _, line := range strings.Split(panicTrace(c.output), "\n") {
+		if line != "" {
+			return fmt.Sprintf("process(%d) %s", c.pid, line)
+		}
+	}
+	return fmt.Sprintf("process(%d) crashed ( %s )", c.pid, c.status)
+}
@@ -0,0 +1,247 @@
+package rebirth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goccy/rebirth/internal/agent"
+	"golang.org/x/xerrors"
+)
+
+const defaultReloadStrategy = "stop-start"
+
+// ReloadStrategy replaces the running application by the new binary.
+// The strategy is selected by run.strategy .
+type ReloadStrategy interface {
+	Name() string
+	Reload(r *Reloader, binary string) error
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]ReloadStrategy{}
+)
+
+func init() {
+	RegisterReloadStrategy(&stopStartStrategy{})
+	RegisterReloadStrategy(&blueGreenStrategy{})
+	RegisterReloadStrategy(&signalStrategy{name: "exec-handoff", defaultSignal: "SIGUSR2"})
+	RegisterReloadStrategy(&signalStrategy{name: "signal-only", defaultSignal: "SIGHUP"})
+	RegisterReloadStrategy(&containerRestartStrategy{})
+}
+
+// RegisterReloadStrategy registers strategy. The strategy which has the same name is overwritten.
+func RegisterReloadStrategy(strategy ReloadStrategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[strategy.Name()] = strategy
+}
+
+func (r *Reloader) strategy() (ReloadStrategy, error) {
+	name := defaultReloadStrategy
+	// only one Delve server can listen on the address
+	if r.run != nil && r.run.Strategy != "" && !r.isDebugMode() {
+		name = r.run.Strategy
+	}
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, exists := strategies[name]
+	if !exists {
+		return nil, xerrors.Errorf("unknown reload strategy %s", name)
+	}
+	return strategy, nil
+}
+
+func (r *Reloader) reloadSignal(defaultSignal string) string {
+	if r.run != nil && r.run.ReloadSignal != "" {
+		return r.run.ReloadSignal
+	}
+	return defaultSignal
+}
+
+func (r *Reloader) isRunning() bool {
+	if r.agent != nil {
+		res, err := r.agent.Status()
+		return err == nil && res.Running
+	}
+	return r.cmd != nil && r.cmd.IsRunning()
+}
+
+// stopStartStrategy stops the current process and starts the new binary.
+type stopStartStrategy struct{}
+
+func (s *stopStartStrategy) Name() string { return "stop-start" }
+
+func (s *stopStartStrategy) Reload(r *Reloader, binary string) error {
+	if r.agent != nil {
+		if err := r.reloadOnContainer(binary); err != nil {
+			return xerrors.Errorf("failed to reload on container: %w", err)
+		}
+		return nil
+	}
+	if err := r.reload(binary); err != nil {
+		return xerrors.Errorf("failed to reload: %w", err)
+	}
+	return nil
+}
+
+// blueGreenStrategy starts the new binary while the current process is running,
+// and stops the current process after the new one becomes healthy by run.healthcheck .
+type blueGreenStrategy struct{}
+
+func (s *blueGreenStrategy) Name() string { return "blue-green" }
+
+func (s *blueGreenStrategy) Reload(r *Reloader, binary string) error {
+	if r.run == nil || r.run.Healthcheck == nil {
+		return xerrors.New("blue-green strategy requires run.healthcheck")
+	}
+	if r.agent != nil {
+		return s.reloadOnContainer(r, binary)
+	}
+	r.logger.Infof("Starting new process...")
+	next := r.startProcess(binary)
+	// the new process failing to listen ( e.g. without run.sockets or SO_REUSEPORT ) exits within the grace period
+	grace := r.run.gracePeriod()
+	time.Sleep(grace)
+	if !next.IsRunning() {
+		return xerrors.Errorf("new process(%d) exited within %s. keep current process", next.Pid(), grace)
+	}
+	if err := r.run.Healthcheck.waitProcess(next.IsRunning); err != nil {
+		next.Stop()
+		return xerrors.Errorf("new process(%d) isn't healthy. keep current process: %w", next.Pid(), err)
+	}
+	if err := r.stopCurrentProcess(); err != nil {
+		return xerrors.Errorf("failed to stop current process: %w", err)
+	}
+	r.setCmd(next)
+	r.logger.Infof("Switched to new process(%d)", next.Pid())
+	return nil
+}
+
+// reloadOnContainer starts the new binary alongside the current process by the agent,
+// and switches to it after it becomes healthy.
+func (s *blueGreenStrategy) reloadOnContainer(r *Reloader, binary string) error {
+	if !r.isRunning() {
+		return (&stopStartStrategy{}).Reload(r, binary)
+	}
+	path, err := r.agentPath(binary)
+	if err != nil {
+		return xerrors.Errorf("failed to get path on container: %w", err)
+	}
+	r.logger.Infof("Starting new process on %s...", r.agentTarget())
+	grace := r.run.gracePeriod()
+	r.agent.tail.Reset()
+	name, args := r.command(path)
+	started := time.Now()
+	next, err := r.agent.StartNext(name, args, r.runEnv(), grace)
+	if err != nil {
+		return xerrors.Errorf("failed to start new process on container: %w", err)
+	}
+	if !next.Ready {
+		return xerrors.Errorf(
+			"new process(%d) on %s exited with status %d within %s. keep current process",
+			next.Pid, r.agentTarget(), next.ExitStatus, grace,
+		)
+	}
+	alive := func() bool {
+		res, err := r.agent.StatusNext()
+		return err == nil && res.Running && res.Pid == next.Pid
+	}
+	if err := r.run.Healthcheck.waitProcess(alive); err != nil {
+		if _, stopErr := r.agent.StopNext(); stopErr != nil {
+			r.logger.Errorf("failed to stop new process(%d) on %s: %v", next.Pid, r.agentTarget(), stopErr)
+		}
+		return xerrors.Errorf("new process(%d) on %s isn't healthy. keep current process: %w", next.Pid, r.agentTarget(), err)
+	}
+	res, err := r.agent.Switch()
+	if err != nil {
+		return xerrors.Errorf("failed to switch to new process on container: %w", err)
+	}
+	if res.PrevPid != 0 {
+		r.logger.Infof("stopped process(%d) on %s ( exit status %d )", res.PrevPid, r.agentTarget(), res.PrevExitStatus)
+	}
+	r.setAgentProcess(binary, started)
+	r.logger.Infof("Switched to new process(%d) on %s", res.Pid, r.agentTarget())
+	return nil
+}
+
+// signalStrategy puts the new binary to the path of the running binary and sends signal to the process
+// instead of restarting it. exec-handoff expects the process re-executes itself,
+// and signal-only expects the process reloads something by itself.
+type signalStrategy struct {
+	name          string
+	defaultSignal string
+}
+
+func (s *signalStrategy) Name() string { return s.name }
+
+func (s *signalStrategy) Reload(r *Reloader, binary string) error {
+	if !r.isRunning() {
+		return (&stopStartStrategy{}).Reload(r, binary)
+	}
+	if binary != buildPath {
+		if err := copyFile(buildPath, binary, 0755); err != nil {
+			return xerrors.Errorf("failed to put binary to %s: %w", buildPath, err)
+		}
+		if r.isCopyMode() && r.host.SyncBinary == "" {
+			if err := r.copyToContainer(buildPath); err != nil {
+				return xerrors.Errorf("failed to copy binary to container: %w", err)
+			}
+		}
+	}
+	if err := r.sendSignal(r.reloadSignal(s.defaultSignal)); err != nil {
+		return xerrors.Errorf("failed to send run.reload_signal: %w", err)
+	}
+	return nil
+}
+
+// sendSignal sends the signal to the application on localhost or the container.
+func (r *Reloader) sendSignal(sig string) error {
+	if r.agent != nil {
+		res, err := r.agent.Signal(sig)
+		if err != nil {
+			return xerrors.Errorf("failed to send signal on container: %w", err)
+		}
+		r.logger.Infof("Sent %s to process(%d) on %s", sig, res.Pid, r.agentTarget())
+		return nil
+	}
+	signal, err := agent.ParseSignal(sig)
+	if err != nil {
+		return xerrors.Errorf("failed to parse signal: %w", err)
+	}
+	if r.cmd == nil {
+		return xerrors.New("process isn't running")
+	}
+	if err := r.cmd.Signal(signal); err != nil {
+		return xerrors.Errorf("failed to send signal: %w", err)
+	}
+	r.logger.Infof("Sent %s to process(%d)", sig, r.cmd.Pid())
+	return nil
+}
+
+// containerRestartStrategy restarts the container and starts the new binary by a new agent.
+type containerRestartStrategy struct{}
+
+func (s *containerRestartStrategy) Name() string { return "container-restart" }
+
+func (s *containerRestartStrategy) Reload(r *Reloader, binary string) error {
+	if !r.isDockerMode() {
+		return xerrors.New("container-restart strategy requires host.docker")
+	}
+	if r.agent != nil {
+		r.agent.Close()
+		r.setAgent(nil)
+	}
+	r.logger.Infof("Restarting container %s...", r.host.Docker)
+	if err := containerRuntime().Restart(context.Background(), r.host.Docker); err != nil {
+		return xerrors.Errorf("failed to restart container: %w", err)
+	}
+	if err := r.startAgent(); err != nil {
+		return xerrors.Errorf("failed to start agent: %w", err)
+	}
+	if err := r.reloadOnContainer(binary); err != nil {
+		return xerrors.Errorf("failed to reload on container: %w", err)
+	}
+	return nil
+}
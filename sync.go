@@ -0,0 +1,123 @@
+package rebirth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// syncRules returns host.sync with cleaned paths.
+func syncRules(host *Host) []*SyncRule {
+	if host == nil {
+		return nil
+	}
+	rules := []*SyncRule{}
+	for _, rule := range host.Sync {
+		rules = append(rules, &SyncRule{
+			Src: filepath.Clean(rule.Src),
+			Dst: rule.Dst,
+		})
+	}
+	return rules
+}
+
+// containsPath returns true if path is dir or under dir.
+func containsPath(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// matchSyncRule returns the rule whose src contains path.
+func matchSyncRule(rules []*SyncRule, path string) *SyncRule {
+	for _, rule := range rules {
+		if containsPath(rule.Src, path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// containerPath returns the path on the container for path on the host.
+func (rule *SyncRule) containerPath(path string) (string, error) {
+	rel, err := filepath.Rel(rule.Src, filepath.Clean(path))
+	if err != nil {
+		return "", xerrors.Errorf("failed to get relative path of %s: %w", path, err)
+	}
+	return filepath.ToSlash(filepath.Join(rule.Dst, rel)), nil
+}
+
+// splitSyncFiles splits files into files matched host.sync and the others.
+func (r *Reloader) splitSyncFiles(files []string) ([]string, []string) {
+	rules := syncRules(r.host)
+	assets := []string{}
+	others := []string{}
+	for _, file := range files {
+		if r.isSyncEnabled() && matchSyncRule(rules, file) != nil {
+			assets = append(assets, file)
+		} else {
+			others = append(others, file)
+		}
+	}
+	return assets, others
+}
+
+// syncFiles copies files to the container by host.sync rules with the syncer.
+// Removed files are skipped because the rule only propagates the host's files.
+func (r *Reloader) syncFiles(files []string) error {
+	syncer, err := r.syncer()
+	if err != nil {
+		return xerrors.Errorf("failed to get syncer: %w", err)
+	}
+	rules := syncRules(r.host)
+	syncFiles := []*SyncFile{}
+	for _, file := range files {
+		rule := matchSyncRule(rules, file)
+		if rule == nil {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		path, err := rule.containerPath(file)
+		if err != nil {
+			return xerrors.Errorf("failed to get path on container: %w", err)
+		}
+		syncFiles = append(syncFiles, &SyncFile{Src: file, Dst: path, Mode: info.Mode()})
+	}
+	if len(syncFiles) == 0 {
+		return nil
+	}
+	if err := syncer.Sync(syncFiles); err != nil {
+		return xerrors.Errorf("failed to sync by %s syncer: %w", r.syncerName(), err)
+	}
+	for _, file := range syncFiles {
+		r.logger.Infof("Synced %s -> %s", file.Src, file.Dst)
+	}
+	return nil
+}
+
+// syncAll copies all files under src of host.sync to the container.
+func (r *Reloader) syncAll() error {
+	files := []string{}
+	for _, rule := range syncRules(r.host) {
+		if err := filepath.Walk(rule.Src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		}); err != nil {
+			return xerrors.Errorf("failed to walk %s: %w", rule.Src, err)
+		}
+	}
+	if err := r.syncFiles(files); err != nil {
+		return xerrors.Errorf("failed to sync files: %w", err)
+	}
+	return nil
+}
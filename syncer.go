@@ -0,0 +1,166 @@
+package rebirth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultSyncer = "agent"
+
+// SyncFile is a file copied from Src on the host to Dst on the place where the application runs.
+type SyncFile struct {
+	Src  string
+	Dst  string
+	Mode os.FileMode
+}
+
+// Syncer copies artifacts ( assets of host.sync and the binary for host.sync_binary ) to the place where the application runs.
+// The syncer is selected by host.syncer . Plugins can add their own syncer by RegisterSyncer.
+type Syncer interface {
+	Sync(files []*SyncFile) error
+}
+
+// SyncerFactory creates Syncer from host settings.
+type SyncerFactory func(host *Host) (Syncer, error)
+
+var (
+	syncersMu sync.RWMutex
+	syncers   = map[string]SyncerFactory{}
+)
+
+func init() {
+	RegisterSyncer("docker", newDockerSyncer)
+	RegisterSyncer("rsync", newRsyncSyncer)
+}
+
+// RegisterSyncer registers factory of the syncer. The syncer which has the same name is overwritten.
+func RegisterSyncer(name string, factory SyncerFactory) {
+	syncersMu.Lock()
+	defer syncersMu.Unlock()
+	syncers[name] = factory
+}
+
+func (r *Reloader) syncerName() string {
+	if r.host == nil || r.host.Syncer == "" {
+		return defaultSyncer
+	}
+	return r.host.Syncer
+}
+
+// isSyncEnabled returns true if artifacts are copied by the syncer.
+// The agent syncer is used on the container without mounts, and others are used if host.syncer is specified.
+func (r *Reloader) isSyncEnabled() bool {
+	if r.syncerName() == defaultSyncer {
+		return r.isDockerMode()
+	}
+	return true
+}
+
+func (r *Reloader) syncer() (Syncer, error) {
+	r.syncerOnce.Do(func() {
+		name := r.syncerName()
+		if name == defaultSyncer {
+			r.syncerImpl = &agentSyncer{reloader: r}
+			return
+		}
+		syncersMu.RLock()
+		factory, exists := syncers[name]
+		syncersMu.RUnlock()
+		if !exists {
+			r.syncerErr = xerrors.Errorf("unknown syncer %s", name)
+			return
+		}
+		r.syncerImpl, r.syncerErr = factory(r.host)
+	})
+	return r.syncerImpl, r.syncerErr
+}
+
+// syncBinary copies binary to host.sync_binary .
+func (r *Reloader) syncBinary(binary string) error {
+	if r.host == nil || r.host.SyncBinary == "" || !r.isSyncEnabled() {
+		return nil
+	}
+	syncer, err := r.syncer()
+	if err != nil {
+		return xerrors.Errorf("failed to get syncer: %w", err)
+	}
+	if err := syncer.Sync([]*SyncFile{{Src: binary, Dst: r.host.SyncBinary, Mode: 0755}}); err != nil {
+		return xerrors.Errorf("failed to sync binary: %w", err)
+	}
+	return nil
+}
+
+// agentSyncer copies files by the agent on the container.
+type agentSyncer struct {
+	reloader *Reloader
+}
+
+func (s *agentSyncer) Sync(files []*SyncFile) error {
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file.Src)
+		if err != nil {
+			return xerrors.Errorf("failed to read %s: %w", file.Src, err)
+		}
+		if err := s.reloader.agent.Copy(file.Dst, data, file.Mode); err != nil {
+			return xerrors.Errorf("failed to copy %s to %s on container: %w", file.Src, file.Dst, err)
+		}
+	}
+	return nil
+}
+
+// dockerSyncer copies files by docker cp to host.docker .
+// Relative Dst is resolved from the working directory of the container.
+type dockerSyncer struct {
+	container string
+}
+
+func newDockerSyncer(host *Host) (Syncer, error) {
+	if host == nil || host.Docker == "" {
+		return nil, xerrors.New("docker syncer requires host.docker")
+	}
+	return &dockerSyncer{container: host.Docker}, nil
+}
+
+func (s *dockerSyncer) Sync(files []*SyncFile) error {
+	for _, file := range files {
+		if err := copyFileToContainer(s.container, file.Src, file.Dst, file.Mode); err != nil {
+			return xerrors.Errorf("failed to copy %s to %s on container: %w", file.Src, file.Dst, err)
+		}
+	}
+	return nil
+}
+
+// rsyncSyncer copies files by rsync to host.rsync ( e.g. user@host:/app ).
+// Relative Dst is resolved from host.rsync .
+type rsyncSyncer struct {
+	dest string
+}
+
+func newRsyncSyncer(host *Host) (Syncer, error) {
+	if host == nil || host.Rsync == "" {
+		return nil, xerrors.New("rsync syncer requires host.rsync")
+	}
+	return &rsyncSyncer{dest: strings.TrimRight(host.Rsync, "/")}, nil
+}
+
+func (s *rsyncSyncer) Sync(files []*SyncFile) error {
+	for _, file := range files {
+		dst := file.Dst
+		if !path.IsAbs(dst) {
+			dst = fmt.Sprintf("%s/%s", s.dest, dst)
+		} else if idx := strings.Index(s.dest, ":"); idx >= 0 {
+			// keep remote host of host.rsync
+			dst = s.dest[:idx+1] + dst
+		}
+		if err := NewCommand("rsync", "-a", file.Src, dst).Run(); err != nil {
+			return xerrors.Errorf("failed to rsync %s to %s: %w", file.Src, dst, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/rebirth/internal/agent"
+	"golang.org/x/xerrors"
+)
+
+// targetState is the running process of the target and directories of packages built into it.
+type targetState struct {
+	mu   sync.Mutex
+	cmd  *Command
+	dirs map[string]bool
+	// buildTail is the last lines of the output of the last build.
+	buildTail *outputTail
+}
+
+func (t *Target) output(name string) string {
+	if t.Output != "" {
+		return ExpandPath(t.Output)
+	}
+	return filepath.Join(cwd, configDir, "targets", name)
+}
+
+func (r *Reloader) isTargetsMode() bool {
+	return len(r.targets) > 0
+}
+
+func (r *Reloader) targetNames() []string {
+	names := []string{}
+	for name := range r.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runTargets builds and starts all targets. Failures of a target don't prevent starting other targets.
+func (r *Reloader) runTargets() error {
+	if r.isUsedDocker() || r.isSSHMode() || r.isWasmMode() {
+		return xerrors.New("targets support running on localhost only")
+	}
+	for _, name := range r.targetNames() {
+		if r.targets[name].Main == "" {
+			return xerrors.Errorf("targets.%s.main must be specified", name)
+		}
+		if _, _, err := r.targetPrefix(name); err != nil {
+			return xerrors.Errorf("invalid targets.%s.run.output.prefix_color: %w", name, err)
+		}
+	}
+	if r.build.Parallel < 0 {
+		return xerrors.Errorf("build.parallel must be positive: %d", r.build.Parallel)
+	}
+	if err := r.runBuildInitCommands(); err != nil {
+		return xerrors.Errorf("failed to build.init commands: %w", err)
+	}
+	r.reloadTargets(r.targetNames(), nil)
+	return nil
+}
+
+// affectedTargets returns targets whose packages ( including dependencies in the module ) contain the files.
+// If no target has the files ( e.g. go.mod or assets ), all targets are affected.
+func (r *Reloader) affectedTargets(files []string) []string {
+	if len(files) == 0 {
+		return r.targetNames()
+	}
+	affected := []string{}
+	for _, name := range r.targetNames() {
+		state := r.targetStates[name]
+		state.mu.Lock()
+		dirs := state.dirs
+		state.mu.Unlock()
+		if dirs == nil {
+			// packages of the target are unknown until listing them succeeds
+			affected = append(affected, name)
+			continue
+		}
+		for _, file := range files {
+			path, err := filepath.Abs(file)
+			if err != nil {
+				continue
+			}
+			if dirs[filepath.Dir(path)] {
+				affected = append(affected, name)
+				break
+			}
+		}
+	}
+	if len(affected) == 0 {
+		return r.targetNames()
+	}
+	return affected
+}
+
+// reloadTargetFiles rebuilds and restarts targets affected by the changed files.
+func (r *Reloader) reloadTargetFiles(files []string) {
+	r.reloadTargets(r.affectedTargets(files), files)
+}
+
+func (b *Build) parallel() int {
+	if b.Parallel > 0 {
+		return b.Parallel
+	}
+	return runtime.NumCPU()
+}
+
+// acquireBuildSlot waits until the number of running builds is less than build.parallel .
+// The returned function releases the slot. It can be called more than once.
+func (r *Reloader) acquireBuildSlot() func() {
+	r.buildSlotsOnce.Do(func() {
+		r.buildSlots = make(chan struct{}, r.build.parallel())
+	})
+	r.buildSlots <- struct{}{}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-r.buildSlots })
+	}
+}
+
+// reloadTargets rebuilds and restarts targets in parallel up to build.parallel builds at once.
+// Each target is reloaded independently, so a build failure of a target keeps its current process and doesn't affect other targets.
+// build.before runs once before building targets, and build.after runs once after reloading them if any target is built.
+func (r *Reloader) reloadTargets(names, files []string) {
+	r.lockReload()
+	defer r.unlockReload()
+	r.changedFiles = files
+	defer func() { r.changedFiles = nil }()
+	start := time.Now()
+	// targets are built by the same build env except targets.<name>.env
+	if err := r.checkBuildEnvDrift(r.newBuildCommand()); err != nil {
+		r.logger.Errorf("failed to check build env: %v", err)
+		for _, name := range names {
+			r.dashboard.setTargetState(name, StateFailed, err.Error())
+		}
+		return
+	}
+	if err := r.runBuildBeforeCommands(); err != nil {
+		r.logger.Errorf("failed to run build.before commands: %v", err)
+		for _, name := range names {
+			r.dashboard.setTargetState(name, StateFailed, err.Error())
+		}
+		return
+	}
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			if err := r.reloadTarget(name); err != nil {
+				r.targetLogger(name).Errorf("%v", err)
+				r.dashboard.setTargetState(name, StateFailed, buildErrorSummary(r.targetStates[name].buildTail.Lines(), err))
+				errs[i] = err
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	failed := []string{}
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, names[i])
+		}
+	}
+	if len(failed) < len(names) {
+		if err := r.runBuildAfterCommands(); err != nil {
+			r.logger.Errorf("failed to run build.after commands: %v", err)
+		}
+	}
+	if len(names) < 2 {
+		return
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if len(failed) > 0 {
+		r.logger.Errorf("Reloaded %d of %d targets in %s. failed: %s", len(names)-len(failed), len(names), elapsed, strings.Join(failed, ", "))
+		return
+	}
+	r.logger.Infof("Reloaded %d targets in %s", len(names), elapsed)
+}
+
+// targetPrefix returns the prefix of the target's output and its color.
+// The prefix is targets.<name>.run.output.prefix or `[name]` , and colors are assigned in order of names by default.
+func (r *Reloader) targetPrefix(name string) (string, string, error) {
+	prefix := fmt.Sprintf("[%s]", name)
+	color := assignedPrefixColor(r.targetNames(), name)
+	run := r.targets[name].Run
+	if run == nil || run.Output == nil {
+		return prefix, color, nil
+	}
+	if run.Output.Prefix != "" {
+		prefix = run.Output.Prefix
+	}
+	color, err := parseColor(run.Output.PrefixColor, color)
+	if err != nil {
+		return "", "", err
+	}
+	return prefix, color, nil
+}
+
+// targetLogger returns the logger prefixing messages by the name of the target.
+func (r *Reloader) targetLogger(name string) Logger {
+	return WithPrefix(r.logger, fmt.Sprintf("[%s] ", name))
+}
+
+func (r *Reloader) reloadTarget(name string) error {
+	target := r.targets[name]
+	state := r.targetStates[name]
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	release := r.acquireBuildSlot()
+	defer release()
+	r.targetLogger(name).Infof("Building....")
+	r.dashboard.setTargetState(name, StateBuilding, "")
+	output := target.output(name)
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return xerrors.Errorf("failed to create directory for target: %w", err)
+	}
+	gocmd := r.newBuildCommand()
+	gocmd.AddEnv(expandEnv(target.Env))
+	prefix, color, _ := r.targetPrefix(name)
+	if r.build.OutputPrefix != "" {
+		prefix, color = r.build.OutputPrefix, r.buildPrefixColor()
+	}
+	stdout, stderr := r.output.buildOutput(prefix, color)
+	gocmd.SetStdout(stdout)
+	gocmd.SetStderr(stderr)
+	state.buildTail.Reset()
+	gocmd.SetOutputTail(state.buildTail)
+	buildErr := gocmd.Build("-o", output, target.Main)
+	// packages are listed even if the build fails, so fixing them rebuilds the target
+	dirs, err := r.targetPackageDirs(target)
+	if err == nil {
+		state.dirs = dirs
+	}
+	if buildErr != nil {
+		return xerrors.Errorf("failed to build: %w", buildErr)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to get packages of target: %w", err)
+	}
+	release()
+	r.targetLogger(name).Infof("Restarting...")
+	if err := r.stopTarget(name, state); err != nil {
+		return xerrors.Errorf("failed to stop current process: %w", err)
+	}
+	state.cmd = r.startTarget(name, output)
+	r.dashboard.setTargetState(name, StateRunning, "")
+	return nil
+}
+
+// targetPackageDirs returns directories of packages in the module used by the main package of the target.
+func (r *Reloader) targetPackageDirs(target *Target) (map[string]bool, error) {
+	// -e lists packages with errors too ( e.g. on build failures )
+	args := []string{"list", "-e", "-deps", "-f", "{{if not .Standard}}{{.Dir}}{{end}}"}
+	if len(r.build.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(r.build.Tags, ","))
+	}
+	out, err := exec.Command("go", append(args, target.Main)...).Output()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to list dependencies of %s: %w", target.Main, err)
+	}
+	dirs := map[string]bool{}
+	for _, dir := range strings.Split(string(out), "\n") {
+		if dir != "" && containsPath(cwd, dir) {
+			dirs[dir] = true
+		}
+	}
+	return dirs, nil
+}
+
+func (r *Reloader) startTarget(name, binary string) *Command {
+	run := r.targets[name].Run
+	env := r.runEnv()
+	args := []string{binary}
+	if run != nil {
+		for k, v := range run.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+		args = append(args, run.Args...)
+	}
+	cmd := NewCommand(args...)
+	cmd.AddEnv(env)
+	prefix, color, _ := r.targetPrefix(name)
+	cmd.SetOutput(r.output.prefixed(prefix, color))
+	cmd.OnExit(func(err error) {
+		if err == nil || cmd.IsStopped() {
+			return
+		}
+		r.targetLogger(name).Errorf("process(%d) exited ( %s )", cmd.Pid(), err)
+		r.dashboard.setTargetState(name, StateFailed, fmt.Sprintf("process(%d) exited ( %s )", cmd.Pid(), err))
+	})
+	cmd.RunAsync()
+	return cmd
+}
+
+func (r *Reloader) stopTarget(name string, state *targetState) error {
+	if state.cmd == nil {
+		return nil
+	}
+	run := r.targets[name].Run
+	sig, err := agent.ParseSignal(run.stopSignal())
+	if err != nil {
+		return xerrors.Errorf("failed to parse targets.%s.run.stop_signal: %w", name, err)
+	}
+	if err := state.cmd.StopGracefully(sig, run.stopTimeout()); err != nil {
+		return xerrors.Errorf("failed to stop process: %w", err)
+	}
+	state.cmd = nil
+	return nil
+}
+
+// signalTargets sends sig to running processes of all targets ( e.g. for watch.configs ).
+func (r *Reloader) signalTargets(sig string) error {
+	signal, err := agent.ParseSignal(sig)
+	if err != nil {
+		return xerrors.Errorf("failed to parse signal: %w", err)
+	}
+	sent := 0
+	for _, name := range r.targetNames() {
+		state := r.targetStates[name]
+		state.mu.Lock()
+		if state.cmd != nil {
+			if err := state.cmd.Signal(signal); err != nil {
+				state.mu.Unlock()
+				return xerrors.Errorf("failed to send signal to %s: %w", name, err)
+			}
+			r.targetLogger(name).Infof("Sent %s to process(%d)", sig, state.cmd.Pid())
+			sent++
+		}
+		state.mu.Unlock()
+	}
+	if sent == 0 {
+		return xerrors.New("process isn't running")
+	}
+	return nil
+}
+
+// stopTargets stops processes of all targets.
+func (r *Reloader) stopTargets() error {
+	errs := []string{}
+	for _, name := range r.targetNames() {
+		state := r.targetStates[name]
+		state.mu.Lock()
+		if err := r.stopTarget(name, state); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+		}
+		state.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return xerrors.Errorf("failed to stop targets: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
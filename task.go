@@ -0,0 +1,166 @@
+package rebirth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// taskHookPrefix is the prefix of build hooks running the task ( e.g. task:generate ).
+const taskHookPrefix = "task:"
+
+// AllTasks returns tasks defined in tasks and task ( the old name of tasks ). tasks takes precedence over task.
+func (c *Config) AllTasks() map[string]*Task {
+	tasks := map[string]*Task{}
+	for name, task := range c.Task {
+		tasks[name] = task
+	}
+	for name, task := range c.Tasks {
+		tasks[name] = task
+	}
+	return tasks
+}
+
+// TaskNames returns sorted names of tasks.
+func (c *Config) TaskNames() []string {
+	names := []string{}
+	for name := range c.AllTasks() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TaskRunner runs tasks with their dependencies.
+type TaskRunner struct {
+	tasks     map[string]*Task
+	host      *Host
+	buildEnv  map[string]string
+	runEnv    map[string]string
+	completed map[string]bool
+	logger    Logger
+}
+
+// NewTaskRunner creates TaskRunner for tasks of cfg.
+// Tasks on localhost run with build.env in the go context, and tasks on the container run with run.env .
+func NewTaskRunner(cfg *Config) *TaskRunner {
+	return newTaskRunner(cfg.AllTasks(), cfg.Host, cfg.Build, cfg.Run, logger())
+}
+
+func newTaskRunner(tasks map[string]*Task, host *Host, build *Build, run *Run, logger Logger) *TaskRunner {
+	runner := &TaskRunner{
+		tasks:     tasks,
+		host:      host,
+		completed: map[string]bool{},
+		logger:    logger,
+	}
+	if build != nil {
+		runner.buildEnv = build.Env
+	}
+	if run != nil {
+		runner.runEnv = run.Env
+	}
+	return runner
+}
+
+// Plan returns the task name and its dependencies in the order of running.
+func (t *TaskRunner) Plan(name string) ([]string, error) {
+	order := []string{}
+	visited := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for _, p := range path {
+			if p == name {
+				return xerrors.Errorf("circular dependency of tasks: %s", strings.Join(append(path, name), " -> "))
+			}
+		}
+		if visited[name] {
+			return nil
+		}
+		task, exists := t.tasks[name]
+		if !exists {
+			if len(path) > 0 {
+				return xerrors.Errorf("undefined task %s depended by %s", name, path[len(path)-1])
+			}
+			return xerrors.Errorf("undefined task %s", name)
+		}
+		for _, dep := range task.Deps {
+			if err := visit(dep, append(append([]string{}, path...), name)); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		order = append(order, name)
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Run runs the task after its dependencies. Each task runs once by a TaskRunner even if it's depended by multiple tasks.
+func (t *TaskRunner) Run(name string) error {
+	order, err := t.Plan(name)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve dependencies of task %s: %w", name, err)
+	}
+	for _, name := range order {
+		if t.completed[name] {
+			continue
+		}
+		if err := t.runTask(name, t.tasks[name]); err != nil {
+			return xerrors.Errorf("failed to run task %s: %w", name, err)
+		}
+		t.completed[name] = true
+	}
+	return nil
+}
+
+func (t *TaskRunner) isContainerAvailable() bool {
+	if t.host == nil || t.host.Docker == "" {
+		return false
+	}
+	// rebirth itself runs on the container
+	_, err := os.Stat(filepath.Join("/", ".dockerenv"))
+	return err != nil
+}
+
+func (t *TaskRunner) runTask(name string, task *Task) error {
+	if task.Container && (t.host == nil || t.host.Docker == "") {
+		return xerrors.Errorf("tasks.%s.container requires host.docker ( or host.compose_service )", name)
+	}
+	for _, command := range task.Commands {
+		t.logger.Infof("Running: %s ( task %s )", command, name)
+		if task.Container && t.isContainerAvailable() {
+			cmd := NewDockerCommand(t.host.Docker, strings.Split(command, " ")...)
+			cmd.AddEnv(expandEnv(t.runEnv))
+			if err := cmd.Run(); err != nil {
+				return xerrors.Errorf("failed to run command %s on container: %w", command, err)
+			}
+			continue
+		}
+		gocmd := NewGoCommand()
+		env := expandEnv(t.buildEnv)
+		if task.Container {
+			env = expandEnv(t.runEnv)
+		}
+		gocmd.AddEnv(env)
+		if err := gocmd.RunInGoContext(strings.Split(command, " ")...); err != nil {
+			return xerrors.Errorf("failed to run command %s: %w", command, err)
+		}
+	}
+	return nil
+}
+
+func expandEnv(env map[string]string) []string {
+	expanded := []string{}
+	for k, v := range env {
+		expanded = append(expanded, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
+	}
+	return expanded
+}
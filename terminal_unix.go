@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package rebirth
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize relays SIGWINCH to ch on resizing the terminal.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGWINCH)
+}
@@ -0,0 +1,7 @@
+package rebirth
+
+import "os"
+
+// notifyResize does nothing because the console has no signal of resizing.
+// The size is read on starting the dashboard.
+func notifyResize(ch chan<- os.Signal) {}
@@ -0,0 +1,214 @@
+package rebirth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// testValueFlags are flags of go test taking a value as the next argument.
+var testValueFlags = []string{
+	"run", "skip", "bench", "benchtime", "count", "cpu", "parallel", "timeout", "shuffle", "list",
+	"fuzz", "fuzztime", "fuzzminimizetime", "outputdir", "cpuprofile", "memprofile", "memprofilerate",
+	"blockprofile", "blockprofilerate", "mutexprofile", "mutexprofilefraction", "trace",
+	"tags", "ldflags", "gcflags", "asmflags", "gccgoflags", "coverprofile", "covermode", "coverpkg", "o", "exec", "p", "vet",
+	"mod", "modfile", "pkgdir", "overlay", "pgo", "toolexec", "compiler", "installsuffix", "C",
+}
+
+// testBinaryFlags are flags of go test passed to the test binary as -test.* .
+var testBinaryFlags = []string{
+	"v", "run", "skip", "bench", "benchtime", "benchmem", "count", "cpu", "parallel", "timeout",
+	"short", "failfast", "shuffle", "list", "fuzz", "fuzztime", "fuzzminimizetime", "outputdir",
+	"cpuprofile", "memprofile", "memprofilerate", "blockprofile", "blockprofilerate", "mutexprofile", "mutexprofilefraction", "trace",
+}
+
+// isTestArgsFlag returns true for -args , after which arguments are passed to the test binary as they are.
+func isTestArgsFlag(arg string) bool {
+	return arg == "-args" || arg == "--args"
+}
+
+// SplitTestArgs splits arguments of go test into flags ( with their values ) and packages.
+// -args and the arguments after it are regarded as flags.
+func SplitTestArgs(args []string) ([]string, []string) {
+	flags := []string{}
+	pkgs := []string{}
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		if isTestArgsFlag(arg) {
+			flags = append(flags, args[idx:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if !strings.Contains(name, "=") && containsString(testValueFlags, name) && idx+1 < len(args) {
+			idx++
+			flags = append(flags, args[idx])
+		}
+	}
+	return flags, pkgs
+}
+
+// splitTestBinaryFlags splits flags of go test into flags for building test binaries and flags for running them.
+// Arguments after -args are flags for running them as they are.
+func splitTestBinaryFlags(flags []string) ([]string, []string) {
+	buildFlags := []string{}
+	runFlags := []string{}
+	for idx := 0; idx < len(flags); idx++ {
+		if isTestArgsFlag(flags[idx]) {
+			runFlags = append(runFlags, flags[idx+1:]...)
+			break
+		}
+		v := strings.SplitN(strings.TrimLeft(flags[idx], "-"), "=", 2)
+		if !containsString(testBinaryFlags, v[0]) {
+			buildFlags = append(buildFlags, flags[idx])
+			if len(v) == 1 && containsString(testValueFlags, v[0]) && idx+1 < len(flags) {
+				idx++
+				buildFlags = append(buildFlags, flags[idx])
+			}
+			continue
+		}
+		runFlags = append(runFlags, "-test."+strings.Join(v, "="))
+		if len(v) == 1 && containsString(testValueFlags, v[0]) && idx+1 < len(flags) {
+			idx++
+			runFlags = append(runFlags, flags[idx])
+		}
+	}
+	return buildFlags, runFlags
+}
+
+func removeString(values []string, value string) []string {
+	removed := []string{}
+	for _, v := range values {
+		if v != value {
+			removed = append(removed, v)
+		}
+	}
+	return removed
+}
+
+// testOnContainer builds test binaries of pkgs by cross build, and runs each of them in its package directory on the container.
+// If flags have -json , the output is converted by go tool test2json like go test -json .
+func (c *GoCommand) testOnContainer(flags, pkgs []string) error {
+	if len(pkgs) == 0 {
+		pkgs = []string{"."}
+	}
+	jsonOutput := containsString(flags, "-json")
+	if jsonOutput {
+		flags = removeString(flags, "-json")
+		// test2json requires the verbose output
+		flags = append(flags, "-v")
+	}
+	buildFlags, runFlags := splitTestBinaryFlags(flags)
+	listArgs := []string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}"}
+	if len(c.tags) > 0 {
+		listArgs = append(listArgs, "-tags", strings.Join(c.tags, ","))
+	}
+	out, err := exec.Command("go", append(listArgs, pkgs...)...).Output()
+	if err != nil {
+		return xerrors.Errorf("failed to list packages: %w", err)
+	}
+	binDir := filepath.Join(cwd, configDir, "tests")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", binDir, err)
+	}
+	failed := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		v := strings.SplitN(line, "\t", 2)
+		if len(v) != 2 {
+			continue
+		}
+		pkg, dir := v[0], v[1]
+		ok, err := c.testPackageOnContainer(pkg, dir, binDir, buildFlags, runFlags, jsonOutput)
+		if err != nil {
+			return xerrors.Errorf("failed to test %s: %w", pkg, err)
+		}
+		if !ok {
+			failed = append(failed, pkg)
+		}
+	}
+	if len(failed) > 0 {
+		return xerrors.Errorf("tests failed in %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (c *GoCommand) testPackageOnContainer(pkg, dir, binDir string, buildFlags, runFlags []string, jsonOutput bool) (bool, error) {
+	stdout := io.Writer(os.Stdout)
+	if c.stdout != nil {
+		stdout = c.stdout
+	}
+	bin := filepath.Join(binDir, strings.Replace(pkg, "/", "_", -1)+".test")
+	os.Remove(bin)
+	cmd := []string{"go", "test", "-c", "-o", bin}
+	cmd = append(cmd, c.buildFlags()...)
+	cmd = append(cmd, buildFlags...)
+	cmd = append(cmd, pkg)
+	if err := c.run(cmd...); err != nil {
+		// build errors are shown by go test
+		fmt.Fprintf(stdout, "FAIL\t%s [build failed]\n", pkg)
+		return false, nil
+	}
+	if _, err := os.Stat(bin); err != nil {
+		fmt.Fprintf(stdout, "?   \t%s\t[no test files]\n", pkg)
+		return true, nil
+	}
+	// the project directory is the working directory on the container
+	rel, err := filepath.Rel(cwd, dir)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get relative path of %s: %w", dir, err)
+	}
+	relBin, err := filepath.Rel(dir, bin)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get relative path of %s: %w", bin, err)
+	}
+	script := []string{"cd", shellQuote(filepath.ToSlash(rel)), "&&", "exec", shellQuote(filepath.ToSlash(relBin))}
+	for _, flag := range runFlags {
+		script = append(script, shellQuote(flag))
+	}
+	dockerCmd := NewDockerCommand(c.container, "sh", "-c", strings.Join(script, " "))
+	dockerCmd.AddEnv(c.testEnv)
+	var converter *exec.Cmd
+	if jsonOutput {
+		converter = exec.Command("go", "tool", "test2json", "-p", pkg)
+		converter.Stdout = stdout
+		converter.Stderr = os.Stderr
+		w, err := converter.StdinPipe()
+		if err != nil {
+			return false, xerrors.Errorf("failed to pipe to test2json: %w", err)
+		}
+		if err := converter.Start(); err != nil {
+			return false, xerrors.Errorf("failed to start test2json: %w", err)
+		}
+		dockerCmd.SetOutput(w, w)
+		defer func() {
+			w.Close()
+			converter.Wait()
+		}()
+	} else {
+		dockerCmd.SetOutput(stdout, os.Stderr)
+	}
+	if err := dockerCmd.Run(); err != nil {
+		return false, xerrors.Errorf("failed to run on docker container: %w", err)
+	}
+	code, err := dockerCmd.ExitCode()
+	if err != nil {
+		return false, xerrors.Errorf("failed to get exit code: %w", err)
+	}
+	if !jsonOutput {
+		status := "ok  "
+		if code != 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(stdout, "%s\t%s\n", status, pkg)
+	}
+	return code == 0, nil
+}
@@ -0,0 +1,70 @@
+package rebirth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTestArgs(t *testing.T) {
+	tests := []struct {
+		args  string
+		flags []string
+		pkgs  []string
+	}{
+		{args: "", flags: []string{}, pkgs: []string{}},
+		{args: "./...", flags: []string{}, pkgs: []string{"./..."}},
+		{args: "-v ./pkg/a ./pkg/b", flags: []string{"-v"}, pkgs: []string{"./pkg/a", "./pkg/b"}},
+		{args: "-run TestFoo ./...", flags: []string{"-run", "TestFoo"}, pkgs: []string{"./..."}},
+		{args: "-run=TestFoo ./...", flags: []string{"-run=TestFoo"}, pkgs: []string{"./..."}},
+		{args: "./... -count 1 -timeout 30s", flags: []string{"-count", "1", "-timeout", "30s"}, pkgs: []string{"./..."}},
+		{args: "--tags integration -race ./...", flags: []string{"--tags", "integration", "-race"}, pkgs: []string{"./..."}},
+		{args: "-mod vendor -coverprofile cover.out ./...", flags: []string{"-mod", "vendor", "-coverprofile", "cover.out"}, pkgs: []string{"./..."}},
+		{args: "-cpuprofile cpu.out -bench . ./pkg", flags: []string{"-cpuprofile", "cpu.out", "-bench", "."}, pkgs: []string{"./pkg"}},
+		{args: "-fuzz FuzzParse -fuzztime 10s ./pkg", flags: []string{"-fuzz", "FuzzParse", "-fuzztime", "10s"}, pkgs: []string{"./pkg"}},
+		// the value flag at the end has no value
+		{args: "./... -run", flags: []string{"-run"}, pkgs: []string{"./..."}},
+		// arguments after -args are passed to the test binary
+		{args: "./pkg -args -update data", flags: []string{"-args", "-update", "data"}, pkgs: []string{"./pkg"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.args, func(t *testing.T) {
+			flags, pkgs := SplitTestArgs(strings.Fields(test.args))
+			if !reflect.DeepEqual(flags, test.flags) {
+				t.Fatalf("expected flags %q but got %q", test.flags, flags)
+			}
+			if !reflect.DeepEqual(pkgs, test.pkgs) {
+				t.Fatalf("expected packages %q but got %q", test.pkgs, pkgs)
+			}
+		})
+	}
+}
+
+func TestSplitTestBinaryFlags(t *testing.T) {
+	tests := []struct {
+		flags      string
+		buildFlags []string
+		runFlags   []string
+	}{
+		{flags: "", buildFlags: []string{}, runFlags: []string{}},
+		{flags: "-v -race", buildFlags: []string{"-race"}, runFlags: []string{"-test.v"}},
+		{flags: "-run TestFoo -count=1", buildFlags: []string{}, runFlags: []string{"-test.run", "TestFoo", "-test.count=1"}},
+		{flags: "-tags integration -failfast", buildFlags: []string{"-tags", "integration"}, runFlags: []string{"-test.failfast"}},
+		{flags: "--timeout 30s -ldflags=-s", buildFlags: []string{"-ldflags=-s"}, runFlags: []string{"-test.timeout", "30s"}},
+		{flags: "-cpuprofile cpu.out", buildFlags: []string{}, runFlags: []string{"-test.cpuprofile", "cpu.out"}},
+		{flags: "-v -args -update -v", buildFlags: []string{}, runFlags: []string{"-test.v", "-update", "-v"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.flags, func(t *testing.T) {
+			buildFlags, runFlags := splitTestBinaryFlags(strings.Fields(test.flags))
+			if !reflect.DeepEqual(buildFlags, test.buildFlags) {
+				t.Fatalf("expected build flags %q but got %q", test.buildFlags, buildFlags)
+			}
+			if !reflect.DeepEqual(runFlags, test.runFlags) {
+				t.Fatalf("expected run flags %q but got %q", test.runFlags, runFlags)
+			}
+		})
+	}
+}
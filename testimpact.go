@@ -0,0 +1,289 @@
+package rebirth
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// testPackage is a package in the module listed by go list .
+type testPackage struct {
+	ImportPath   string
+	Dir          string
+	Deps         []string
+	TestImports  []string
+	XTestImports []string
+}
+
+// testEvent is an event of go test -json .
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// testResult is the result of a test function.
+type testResult struct {
+	name    string
+	action  string
+	elapsed float64
+}
+
+// testImpact is the reason why a package is selected and the result of go test for it.
+type testImpact struct {
+	pkg     string
+	reason  string
+	cached  bool
+	failed  bool
+	results []*testResult
+}
+
+// TestRunner runs go test for packages affected by changed files and reports the impact of the change.
+type TestRunner struct {
+	gocmd    *GoCommand
+	args     []string
+	explain  bool
+	packages []string
+}
+
+// NewTestRunner creates TestRunner running go test with args ( e.g. -v , -run ) by gocmd .
+// If explain is true, the report shows why each package is selected and which tests ran.
+func NewTestRunner(gocmd *GoCommand, args []string, explain bool) *TestRunner {
+	return &TestRunner{gocmd: gocmd, args: args, explain: explain}
+}
+
+// SetPackages limits packages tested by RunFiles ( default: ./... ).
+func (t *TestRunner) SetPackages(pkgs []string) {
+	t.packages = pkgs
+}
+
+func listTestPackages(patterns ...string) ([]*testPackage, error) {
+	out, err := exec.Command("go", append([]string{"list", "-e", "-json"}, patterns...)...).Output()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to list packages: %w", err)
+	}
+	pkgs := []*testPackage{}
+	dec := json.NewDecoder(strings.NewReader(string(out)))
+	for dec.More() {
+		var pkg testPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, xerrors.Errorf("failed to decode output of go list: %w", err)
+		}
+		pkgs = append(pkgs, &pkg)
+	}
+	return pkgs, nil
+}
+
+// affectedPackages returns packages including changed files and packages importing them ( directly or from tests ).
+// If files is empty, all packages are affected.
+func affectedPackages(pkgs []*testPackage, files []string) []*testImpact {
+	if len(files) == 0 {
+		impacts := []*testImpact{}
+		for _, pkg := range pkgs {
+			impacts = append(impacts, &testImpact{pkg: pkg.ImportPath, reason: "initial run"})
+		}
+		return impacts
+	}
+	changed := map[string][]string{}
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Dir(path)
+		for _, pkg := range pkgs {
+			// files under testdata belong to the package having it
+			if dir == pkg.Dir || strings.HasPrefix(dir, pkg.Dir+string(filepath.Separator)+"testdata") {
+				changed[pkg.ImportPath] = append(changed[pkg.ImportPath], filepath.Base(path))
+			}
+		}
+	}
+	impacts := []*testImpact{}
+	for _, pkg := range pkgs {
+		if names, exists := changed[pkg.ImportPath]; exists {
+			impacts = append(impacts, &testImpact{
+				pkg:    pkg.ImportPath,
+				reason: fmt.Sprintf("changed %s", strings.Join(names, ", ")),
+			})
+			continue
+		}
+		imports := []string{}
+		for _, deps := range [][]string{pkg.Deps, pkg.TestImports, pkg.XTestImports} {
+			for _, dep := range deps {
+				if _, exists := changed[dep]; exists && !containsString(imports, dep) {
+					imports = append(imports, dep)
+				}
+			}
+		}
+		if len(imports) > 0 {
+			sort.Strings(imports)
+			impacts = append(impacts, &testImpact{
+				pkg:    pkg.ImportPath,
+				reason: fmt.Sprintf("imports %s", strings.Join(imports, ", ")),
+			})
+		}
+	}
+	return impacts
+}
+
+// RunFiles runs go test for packages affected by files and prints the impact report.
+// If files is empty, all packages in the module are tested.
+func (t *TestRunner) RunFiles(files []string) error {
+	pkgs, err := listTestPackages("./...")
+	if err != nil {
+		return xerrors.Errorf("failed to get packages: %w", err)
+	}
+	impacts, err := t.filterPackages(affectedPackages(pkgs, files))
+	if err != nil {
+		return xerrors.Errorf("failed to filter packages: %w", err)
+	}
+	if len(impacts) == 0 {
+		logger().Infof("No packages are affected by the change")
+		return nil
+	}
+	byPkg := map[string]*testImpact{}
+	args := append([]string{"-json"}, t.args...)
+	for _, impact := range impacts {
+		byPkg[impact.pkg] = impact
+		args = append(args, impact.pkg)
+	}
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		parseTestEvents(reader, byPkg, containsString(t.args, "-v"))
+	}()
+	t.gocmd.SetStdout(writer)
+	testErr := t.gocmd.Test(args...)
+	writer.Close()
+	wg.Wait()
+	t.report(impacts)
+	if testErr != nil {
+		return xerrors.Errorf("failed to test: %w", testErr)
+	}
+	return nil
+}
+
+// filterPackages returns impacts of packages matched by SetPackages .
+// Changes of other packages are detected too because the matched packages may import them.
+func (t *TestRunner) filterPackages(impacts []*testImpact) ([]*testImpact, error) {
+	if len(t.packages) == 0 {
+		return impacts, nil
+	}
+	pkgs, err := listTestPackages(t.packages...)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get packages: %w", err)
+	}
+	matched := map[string]bool{}
+	for _, pkg := range pkgs {
+		matched[pkg.ImportPath] = true
+	}
+	filtered := []*testImpact{}
+	for _, impact := range impacts {
+		if matched[impact.pkg] {
+			filtered = append(filtered, impact)
+		}
+	}
+	return filtered, nil
+}
+
+// parseTestEvents prints the output of go test -json as the normal output and records results to impacts.
+// go test -json always runs tests verbosely, so output of tests is printed only for failed tests unless verbose is true.
+func parseTestEvents(r io.Reader, impacts map[string]*testImpact, verbose bool) {
+	outputs := map[string][]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// build errors aren't JSON
+			fmt.Println(scanner.Text())
+			continue
+		}
+		key := event.Package + " " + event.Test
+		switch {
+		case event.Action == "output" && (verbose || event.Test == ""):
+			fmt.Print(event.Output)
+		case event.Action == "output":
+			outputs[key] = append(outputs[key], event.Output)
+		case event.Action == "fail":
+			fmt.Print(strings.Join(outputs[key], ""))
+			delete(outputs, key)
+		case event.Action == "pass" || event.Action == "skip":
+			delete(outputs, key)
+		}
+		impact, exists := impacts[event.Package]
+		if !exists {
+			continue
+		}
+		if event.Test == "" {
+			switch {
+			case event.Action == "output" && strings.Contains(event.Output, "(cached)"):
+				impact.cached = true
+			case event.Action == "fail":
+				impact.failed = true
+			}
+			continue
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+			impact.results = append(impact.results, &testResult{
+				name:    event.Test,
+				action:  event.Action,
+				elapsed: event.Elapsed,
+			})
+		}
+	}
+}
+
+func (t *TestRunner) report(impacts []*testImpact) {
+	ran := 0
+	ranPkgs := 0
+	cached := 0
+	for _, impact := range impacts {
+		if impact.cached {
+			cached++
+			continue
+		}
+		if len(impact.results) > 0 {
+			ranPkgs++
+			ran += len(impact.results)
+		}
+	}
+	fmt.Printf(
+		"Test impact: %d affected packages, %d tests ran in %d packages, %d packages skipped due to caching\n",
+		len(impacts), ran, ranPkgs, cached,
+	)
+	if !t.explain {
+		return
+	}
+	for _, impact := range impacts {
+		status := "ran"
+		switch {
+		case impact.cached:
+			status = "cached"
+		case impact.failed:
+			status = "failed"
+		case len(impact.results) == 0:
+			status = "no tests"
+		}
+		fmt.Printf("  %s ( %s ): %s\n", impact.pkg, impact.reason, status)
+		if impact.cached {
+			continue
+		}
+		for _, result := range impact.results {
+			fmt.Printf("    %s %s (%.2fs)\n", result.action, result.name, result.elapsed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rebirth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+// transformBinary runs build.transform ( e.g. upx ) on the built binary.
+// Commands transform the temporary copy of the binary specified by $REBIRTH_BINARY in place,
+// and the transformed binary replaces the built binary only if all commands succeed.
+// So the application is always run and health-checked with the final transformed binary.
+func (r *Reloader) transformBinary(binary string) error {
+	if r.build == nil || len(r.build.Transform) == 0 {
+		return nil
+	}
+	dir := filepath.Join(cwd, configDir, "transform")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("failed to create %s: %w", dir, err)
+	}
+	tmpfile, err := ioutil.TempFile(dir, filepath.Base(binary))
+	if err != nil {
+		return xerrors.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpfile.Close()
+	work := tmpfile.Name()
+	defer os.Remove(work)
+	if err := copyFile(work, binary, 0755); err != nil {
+		return xerrors.Errorf("failed to copy binary: %w", err)
+	}
+	env := []string{fmt.Sprintf("REBIRTH_BINARY=%s", work)}
+	for k, v := range r.build.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, ExpandPath(v)))
+	}
+	for _, command := range r.build.Transform {
+		r.logger.Infof("Running: %s", command)
+		cmd := newHookCommand("sh", "-c", command)
+		cmd.AddEnv(env)
+		if err := cmd.Run(); err != nil {
+			return xerrors.Errorf("failed to run build.transform %s: %w", command, err)
+		}
+	}
+	info, err := os.Stat(work)
+	if err != nil {
+		return xerrors.Errorf("transformed binary isn't found: %w", err)
+	}
+	if info.Size() == 0 {
+		return xerrors.New("transformed binary is empty")
+	}
+	if err := copyFile(binary, work, 0755); err != nil {
+		return xerrors.Errorf("failed to replace binary by the transformed binary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package rebirth
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	triggerActionHook    = "hook"
+	triggerActionRestart = "restart"
+	triggerActionNotify  = "notify"
+
+	defaultTriggerCooldown = 5 * time.Second
+)
+
+// logTrigger fires the action of run.triggers when the application's output matches the pattern.
+type logTrigger struct {
+	cfg      *Trigger
+	pattern  *regexp.Regexp
+	cooldown time.Duration
+	mu       sync.Mutex
+	firedAt  time.Time
+}
+
+func newLogTriggers(triggers []*Trigger) ([]*logTrigger, error) {
+	compiled := []*logTrigger{}
+	for i, trigger := range triggers {
+		pattern, err := regexp.Compile(trigger.Pattern)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid run.triggers[%d].pattern: %w", i, err)
+		}
+		switch trigger.Action {
+		case triggerActionRestart, triggerActionNotify:
+		case triggerActionHook:
+			if trigger.Hook == "" {
+				return nil, xerrors.Errorf("run.triggers[%d].hook must be specified for hook action", i)
+			}
+		default:
+			return nil, xerrors.Errorf("unknown run.triggers[%d].action %q. hook, restart or notify is available", i, trigger.Action)
+		}
+		compiled = append(compiled, &logTrigger{
+			cfg:      trigger,
+			pattern:  pattern,
+			cooldown: parseDurationOr(trigger.Cooldown, defaultTriggerCooldown),
+		})
+	}
+	return compiled, nil
+}
+
+// match returns true if line matches the pattern and the trigger isn't in cooldown.
+func (t *logTrigger) match(line string) bool {
+	if !t.pattern.MatchString(line) {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.firedAt) < t.cooldown {
+		return false
+	}
+	t.firedAt = now
+	return true
+}
+
+// watchOutputTriggers makes the application's output fire run.triggers .
+func (r *Reloader) watchOutputTriggers() error {
+	if r.run == nil || len(r.run.Triggers) == 0 {
+		return nil
+	}
+	triggers, err := newLogTriggers(r.run.Triggers)
+	if err != nil {
+		return xerrors.Errorf("failed to compile triggers: %w", err)
+	}
+	r.output.tee(func() io.Writer {
+		return &lineWriter{fn: func(line []byte) {
+			text := strings.TrimRight(string(line), "\r\n")
+			for _, trigger := range triggers {
+				if trigger.match(text) {
+					// the action may stop the application writing this line
+					go r.fireTrigger(trigger.cfg, text)
+				}
+			}
+		}}
+	})
+	return nil
+}
+
+func (r *Reloader) fireTrigger(trigger *Trigger, line string) {
+	switch trigger.Action {
+	case triggerActionRestart:
+		r.logger.Infof("Restarting by trigger %q", trigger.Pattern)
+		if err := r.reloadFor(false, false); err != nil {
+			r.logger.Errorf("%v", err)
+		}
+	case triggerActionHook:
+		r.logger.Infof("Running: %s", trigger.Hook)
+		cmd := newHookCommand("sh", "-c", trigger.Hook)
+		cmd.AddEnv(append(r.runEnv(), fmt.Sprintf("REBIRTH_TRIGGER_LINE=%s", line)))
+		if err := cmd.Run(); err != nil {
+			r.logger.Errorf("failed to run trigger hook: %v", err)
+		}
+	case triggerActionNotify:
+		message := trigger.Message
+		if message == "" {
+			message = line
+		}
+		fmt.Printf("%s[rebirth] %s%s\n", colorYellow, message, colorReset)
+	}
+}
@@ -0,0 +1,362 @@
+package rebirth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	serviceRunning = "running"
+	serviceExited  = "exited"
+	serviceFailed  = "failed"
+	serviceSkipped = "skipped"
+)
+
+type serviceResult struct {
+	name      string
+	buildTime time.Duration
+	status    string
+	port      int
+	err       error
+}
+
+func servicePath(name string) string {
+	return filepath.Join(configDir, "services", name)
+}
+
+func (r *Reloader) serviceNames() []string {
+	names := []string{}
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceDeps returns services which must be started before the service.
+// They are services in depends_on and the previous service in startup_order .
+func (r *Reloader) serviceDeps(name string) []string {
+	deps := append([]string{}, r.services[name].DependsOn...)
+	for i, ordered := range r.startupOrder {
+		if ordered == name && i > 0 {
+			deps = append(deps, r.startupOrder[i-1])
+		}
+	}
+	return deps
+}
+
+// serviceLevels groups services by startup order.
+// Services in the same level don't depend on each other, so they are started in parallel.
+// If names are specified, only them and their dependencies are included.
+func (r *Reloader) serviceLevels(names []string) ([][]string, error) {
+	for _, name := range r.startupOrder {
+		if _, exists := r.services[name]; !exists {
+			return nil, xerrors.Errorf("undefined service %s in startup_order", name)
+		}
+	}
+	if len(names) == 0 {
+		for name := range r.services {
+			names = append(names, name)
+		}
+	}
+	levels := map[string]int{}
+	visiting := map[string]bool{}
+	var visit func(name string) (int, error)
+	visit = func(name string) (int, error) {
+		if level, exists := levels[name]; exists {
+			return level, nil
+		}
+		if _, exists := r.services[name]; !exists {
+			return 0, xerrors.Errorf("undefined service %s", name)
+		}
+		if visiting[name] {
+			return 0, xerrors.Errorf("circular dependency detected at service %s", name)
+		}
+		visiting[name] = true
+		level := 0
+		for _, dep := range r.serviceDeps(name) {
+			depLevel, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			if depLevel+1 > level {
+				level = depLevel + 1
+			}
+		}
+		visiting[name] = false
+		levels[name] = level
+		return level, nil
+	}
+	for _, name := range names {
+		if _, err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	grouped := [][]string{}
+	for name, level := range levels {
+		for len(grouped) <= level {
+			grouped = append(grouped, []string{})
+		}
+		grouped[level] = append(grouped[level], name)
+	}
+	for _, group := range grouped {
+		sort.Strings(group)
+	}
+	return grouped, nil
+}
+
+// Up builds and starts services in startup order. Independent services are started in parallel.
+// Builds of all services, build.tools and wait_for run concurrently for shortening time to the first run.
+// If names are specified, only them and their dependencies are started.
+// Services keep running until ctx is canceled, and they are stopped by Close on cancellation.
+func (r *Reloader) Up(ctx context.Context, names []string) error {
+	if len(r.services) == 0 {
+		return xerrors.New("services must be specified for `rebirth up`")
+	}
+	if r.isDockerMode() {
+		return xerrors.New("`rebirth up` doesn't support host.docker and host.compose_service")
+	}
+	levels, err := r.serviceLevels(names)
+	if err != nil {
+		return xerrors.Errorf("failed to resolve startup order: %w", err)
+	}
+	if err := r.lockPidFile(); err != nil {
+		return xerrors.Errorf("failed to lock pid file: %w", err)
+	}
+	var outputCfg *Output
+	if r.run != nil {
+		outputCfg = r.run.Output
+	}
+	output, err := newAppOutput(outputCfg)
+	if err != nil {
+		return xerrors.Errorf("failed to create output: %w", err)
+	}
+	r.output = output
+	if err := r.runBuildInitCommands(); err != nil {
+		return xerrors.Errorf("failed to build.init commands: %w", err)
+	}
+	// build.tools , builds and wait_for of all services run concurrently
+	tools := runPending(r.installTools)
+	builds := map[string]*pending{}
+	waits := map[string]*pending{}
+	for _, level := range levels {
+		for _, name := range level {
+			name := name
+			builds[name] = runPending(func() error { return r.buildService(name) })
+			waits[name] = runPending(func() error { return r.waitForService(name) })
+		}
+	}
+	if _, err := tools.wait(); err != nil {
+		return xerrors.Errorf("failed to install build.tools: %w", err)
+	}
+	results := []*serviceResult{}
+	failed := false
+	for _, level := range levels {
+		levelResults := make([]*serviceResult, len(level))
+		if failed {
+			for i, name := range level {
+				levelResults[i] = &serviceResult{name: name, status: serviceSkipped}
+			}
+			results = append(results, levelResults...)
+			continue
+		}
+		var wg sync.WaitGroup
+		for i, name := range level {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				levelResults[i] = r.startService(name, builds[name], waits[name])
+			}(i, name)
+		}
+		wg.Wait()
+		for _, result := range levelResults {
+			if result.err != nil {
+				failed = true
+			}
+		}
+		results = append(results, levelResults...)
+	}
+	printServiceResults(results)
+	for _, result := range results {
+		if result.err != nil {
+			return xerrors.Errorf("failed to start service %s: %w", result.name, result.err)
+		}
+	}
+	<-ctx.Done()
+	return r.Close()
+}
+
+// pending is the result of the function running in background.
+type pending struct {
+	done    chan struct{}
+	elapsed time.Duration
+	err     error
+}
+
+func runPending(fn func() error) *pending {
+	p := &pending{done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		start := time.Now()
+		p.err = fn()
+		p.elapsed = time.Since(start)
+	}()
+	return p
+}
+
+// wait waits for the function and returns the elapsed time and the error.
+func (p *pending) wait() (time.Duration, error) {
+	<-p.done
+	return p.elapsed, p.err
+}
+
+func (r *Reloader) installTools() error {
+	for _, cmd := range r.build.Tools {
+		r.logger.Infof("Running: %s", cmd)
+		if err := r.runBuildHookCommandInGoContext(cmd); err != nil {
+			return xerrors.Errorf("failed to run command in build.tools: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Reloader) buildService(name string) error {
+	service := r.services[name]
+	if service.Main == "" {
+		return xerrors.Errorf("services.%s.main must be specified", name)
+	}
+	path := filepath.Join(cwd, servicePath(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return xerrors.Errorf("failed to create directory for service: %w", err)
+	}
+	release := r.acquireBuildSlot()
+	defer release()
+	r.logger.Infof("Building %s....", name)
+	if err := r.newBuildCommand().Build("-o", path, service.Main); err != nil {
+		return xerrors.Errorf("failed to build: %w", err)
+	}
+	return nil
+}
+
+// waitForService waits until all wait_for of the service become ready.
+func (r *Reloader) waitForService(name string) error {
+	for _, dep := range r.services[name].WaitFor {
+		target := dep.HTTP
+		if target == "" {
+			target = dep.TCP
+		}
+		r.logger.Infof("Waiting for %s ( %s )....", target, name)
+		if err := dep.wait(); err != nil {
+			return xerrors.Errorf("%s isn't ready: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// startService starts the service after its build and wait_for are finished.
+func (r *Reloader) startService(name string, build, wait *pending) *serviceResult {
+	result := &serviceResult{name: name, status: serviceFailed}
+	service := r.services[name]
+	buildTime, err := build.wait()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.buildTime = buildTime
+	if _, err := wait.wait(); err != nil {
+		result.err = xerrors.Errorf("failed to wait for dependencies: %w", err)
+		return result
+	}
+	env := r.runEnv()
+	for k, v := range service.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	if service.Port != "" {
+		port, err := servicePort(service.Port)
+		if err != nil {
+			result.err = xerrors.Errorf("invalid services.%s.port: %w", name, err)
+			return result
+		}
+		result.port = port
+		env = append(env, fmt.Sprintf("PORT=%d", port))
+	}
+	path := filepath.Join(cwd, servicePath(name))
+	cmd := NewCommand(append([]string{path}, service.Args...)...)
+	cmd.AddEnv(env)
+	cmd.SetOutput(r.output.prefixed(fmt.Sprintf("[%s]", name), assignedPrefixColor(r.serviceNames(), name)))
+	exited := make(chan error, 1)
+	cmd.OnExit(func(err error) {
+		exited <- err
+	})
+	r.servicesMu.Lock()
+	r.serviceCmds[name] = cmd
+	r.servicesMu.Unlock()
+	cmd.RunAsync()
+	select {
+	case err := <-exited:
+		if err != nil {
+			result.err = xerrors.Errorf("exited within %s: %w", r.run.gracePeriod(), err)
+			return result
+		}
+		result.status = serviceExited
+	case <-time.After(r.run.gracePeriod()):
+		result.status = serviceRunning
+	}
+	return result
+}
+
+func servicePort(value string) (int, error) {
+	if value == autoPort {
+		return freePort()
+	}
+	return strconv.Atoi(value)
+}
+
+// stopServices stops services started by Up.
+func (r *Reloader) stopServices() error {
+	r.servicesMu.Lock()
+	defer r.servicesMu.Unlock()
+	errs := []string{}
+	for name, cmd := range r.serviceCmds {
+		if err := cmd.Stop(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	r.serviceCmds = map[string]*Command{}
+	if len(errs) > 0 {
+		return xerrors.Errorf("failed to stop services: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func printServiceResults(results []*serviceResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tBUILD\tSTATUS\tPORT")
+	for _, result := range results {
+		buildTime := "-"
+		if result.buildTime > 0 {
+			buildTime = result.buildTime.Round(time.Millisecond).String()
+		}
+		port := "-"
+		if result.port > 0 {
+			port = strconv.Itoa(result.port)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.name, buildTime, result.status, port)
+	}
+	w.Flush()
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Printf("%s: %s\n", result.name, result.err)
+		}
+	}
+}
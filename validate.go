@@ -0,0 +1,509 @@
+package rebirth
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+	"github.com/goccy/rebirth/internal/agent"
+)
+
+// ConfigError is a problem of the config found by Config.Validate at Path ( e.g. run.strategy , targets.api.main ).
+type ConfigError struct {
+	Path    string
+	Message string
+
+	// keys are keys ( or indexes of sequences ) from the root to the value for locating it in the source.
+	keys []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ConfigErrors are all problems found by Config.Validate .
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type configValidator struct {
+	errs ConfigErrors
+}
+
+// add records the problem of the value at keys. Indexes of sequences are passed as int.
+func (v *configValidator) add(msg string, keys ...interface{}) {
+	path := ""
+	strKeys := []string{}
+	for _, key := range keys {
+		switch key := key.(type) {
+		case int:
+			path += fmt.Sprintf("[%d]", key)
+			strKeys = append(strKeys, fmt.Sprintf("[%d]", key))
+		default:
+			if path != "" {
+				path += "."
+			}
+			path += fmt.Sprint(key)
+			strKeys = append(strKeys, fmt.Sprint(key))
+		}
+	}
+	v.errs = append(v.errs, &ConfigError{Path: path, Message: msg, keys: strKeys})
+}
+
+func (v *configValidator) addf(keys []interface{}, format string, args ...interface{}) {
+	v.add(fmt.Sprintf(format, args...), keys...)
+}
+
+func (v *configValidator) required(value string, keys ...interface{}) {
+	if value == "" {
+		v.add("must be specified", keys...)
+	}
+}
+
+func (v *configValidator) exclusive(names []string, values []bool, keys ...interface{}) {
+	specified := []string{}
+	for i, value := range values {
+		if value {
+			specified = append(specified, names[i])
+		}
+	}
+	if len(specified) > 1 {
+		v.addf(keys, "%s can't be specified together", strings.Join(specified, " and "))
+	}
+}
+
+func (v *configValidator) duration(value string, keys ...interface{}) {
+	if value == "" {
+		return
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		v.addf(keys, "invalid duration %q ( e.g. 500ms, 5s, 1m )", value)
+	}
+}
+
+func (v *configValidator) signal(value string, keys ...interface{}) {
+	if value == "" {
+		return
+	}
+	if _, err := agent.ParseSignal(value); err != nil {
+		v.addf(keys, "unsupported signal %s", value)
+	}
+}
+
+func (v *configValidator) oneOf(value string, available []string, keys ...interface{}) {
+	if value == "" {
+		return
+	}
+	for _, name := range available {
+		if value == name {
+			return
+		}
+	}
+	v.addf(keys, "unknown value %s. %s are available", value, strings.Join(available, ", "))
+}
+
+// Validate checks settings which can't be checked by decoding, instead of failing later at runtime:
+// mutually exclusive options, required fields per mode, and values of enums, durations and signals.
+// The returned error is ConfigErrors .
+func (c *Config) Validate() error {
+	v := &configValidator{}
+	if host := c.Host; host != nil {
+		v.exclusive(
+			[]string{"docker", "compose_service", "ssh"},
+			[]bool{host.Docker != "", host.ComposeService != "", host.SSH != nil},
+			"host",
+		)
+		if host.DockerImage != "" && host.Docker == "" {
+			v.add("requires host.docker as the name of the created container", "host", "docker_image")
+		}
+		if host.Runtime != "" {
+			containerRuntimesMu.Lock()
+			_, exists := containerRuntimes[host.Runtime]
+			containerRuntimesMu.Unlock()
+			if !exists {
+				v.addf([]interface{}{"host", "runtime"}, "unknown container runtime %s", host.Runtime)
+			}
+		}
+		if host.SSH != nil {
+			v.required(host.SSH.Host, "host", "ssh", "host")
+		}
+		for i, rule := range host.Sync {
+			v.required(rule.Src, "host", "sync", i, "src")
+			v.required(rule.Dst, "host", "sync", i, "dst")
+		}
+	}
+	if build := c.Build; build != nil {
+		if build.Parallel < 0 {
+			v.addf([]interface{}{"build", "parallel"}, "must be positive: %d", build.Parallel)
+		}
+		v.duration(build.Yield, "build", "yield")
+		v.oneOf(build.Compiler, []string{"gc", "gccgo", "tinygo"}, "build", "compiler")
+		if remote := build.Remote; remote != nil {
+			if remote.SSH == "" && remote.Docker == "" {
+				v.add("ssh or docker must be specified", "build", "remote")
+			}
+			v.exclusive([]string{"ssh", "docker"}, []bool{remote.SSH != "", remote.Docker != ""}, "build", "remote")
+		}
+		for _, name := range sortedKeys(build.Companions) {
+			v.required(build.Companions[name].Main, "build", "companions", name, "main")
+		}
+		if build.Main != "" && len(c.Targets) > 0 {
+			v.add("can't be specified with targets. Use targets.<name>.main instead", "build", "main")
+		}
+		if host := c.Host; build.Output != "" && host != nil && (host.Docker != "" || host.ComposeService != "" || host.SSH != nil) {
+			// the binary is run by the relative path from the project directory on the host
+			if rel, err := filepath.Rel(cwd, build.output()); err != nil || strings.HasPrefix(rel, "..") {
+				v.add("must be under the project directory for running it on host", "build", "output")
+			}
+		}
+	}
+	c.validateRun(v, c.Run, "run")
+	if run := c.Run; run != nil {
+		switch run.Strategy {
+		case "blue-green":
+			if run.Healthcheck == nil {
+				v.add("blue-green requires run.healthcheck", "run", "strategy")
+			}
+		case "container-restart":
+			if c.Host == nil || c.Host.Docker == "" {
+				v.add("container-restart requires host.docker", "run", "strategy")
+			}
+		}
+		if run.Idle != nil && run.Idle.Container && (c.Host == nil || c.Host.Docker == "") {
+			v.add("requires host.docker", "run", "idle", "container")
+		}
+	}
+	if watch := c.Watch; watch != nil {
+		v.duration(watch.Debounce, "watch", "debounce")
+		v.duration(watch.PollInterval, "watch", "poll_interval")
+		for i, cfg := range watch.Configs {
+			if len(cfg.Files) == 0 {
+				v.add("must be specified", "watch", "configs", i, "files")
+			}
+			if cfg.Signal == "" && cfg.HTTP == "" {
+				v.add("signal or http must be specified", "watch", "configs", i)
+			}
+			v.exclusive([]string{"signal", "http"}, []bool{cfg.Signal != "", cfg.HTTP != ""}, "watch", "configs", i)
+			v.signal(cfg.Signal, "watch", "configs", i, "signal")
+		}
+		if rule := watch.ReloadOnly; rule != nil {
+			if len(rule.Files) == 0 {
+				v.add("must be specified", "watch", "reload_only", "files")
+			}
+			if rule.Signal == "" && rule.HTTP == "" {
+				v.add("signal or http must be specified", "watch", "reload_only")
+			}
+			v.exclusive([]string{"signal", "http"}, []bool{rule.Signal != "", rule.HTTP != ""}, "watch", "reload_only")
+			v.signal(rule.Signal, "watch", "reload_only", "signal")
+		}
+		if watch.Migrations != nil {
+			v.required(watch.Migrations.Dir, "watch", "migrations", "dir")
+			v.required(watch.Migrations.Hook, "watch", "migrations", "hook")
+		}
+		for i, window := range watch.Freeze {
+			if _, err := parseFreezeWindow(window); err != nil {
+				v.add(err.Error(), "watch", "freeze", i)
+			}
+		}
+	}
+	if proxy := c.Proxy; proxy != nil {
+		v.required(proxy.Listen, "proxy", "listen")
+		v.required(proxy.Target, "proxy", "target")
+		v.duration(proxy.Timeout, "proxy", "timeout")
+	}
+	if len(c.Targets) > 0 && c.Wasm != nil {
+		v.add("can't be specified with wasm", "targets")
+	}
+	if c.Run != nil && c.Run.Command != "" && c.Wasm != nil {
+		v.add("can't be specified with wasm", "run", "command")
+	}
+	for _, name := range sortedKeys(c.Targets) {
+		target := c.Targets[name]
+		v.required(target.Main, "targets", name, "main")
+		c.validateRun(v, target.Run, "targets", name, "run")
+		if target.Run != nil && target.Run.Command != "" {
+			v.add("isn't supported for targets", "targets", name, "run", "command")
+		}
+	}
+	for _, name := range sortedKeys(c.Services) {
+		service := c.Services[name]
+		v.required(service.Main, "services", name, "main")
+		for i, dep := range service.DependsOn {
+			if _, exists := c.Services[dep]; !exists {
+				v.addf([]interface{}{"services", name, "depends_on", i}, "unknown service %s", dep)
+			}
+		}
+		for i, check := range service.WaitFor {
+			validateHealthcheck(v, check, "services", name, "wait_for", i)
+		}
+	}
+	for i, name := range c.StartupOrder {
+		if _, exists := c.Services[name]; !exists {
+			v.addf([]interface{}{"startup_order", i}, "unknown service %s", name)
+		}
+	}
+	if c.Log != nil {
+		if _, err := ParseLogLevel(c.Log.Level); err != nil {
+			v.add(err.Error(), "log", "level")
+		}
+		v.oneOf(c.Log.Format, []string{LogFormatText, LogFormatJSON}, "log", "format")
+	}
+	if c.Notify != nil {
+		for i, event := range c.Notify.On {
+			v.oneOf(event, []string{"failure", "recovery"}, "notify", "on", i)
+		}
+	}
+	allTasks := c.AllTasks()
+	for _, tasks := range []struct {
+		key   string
+		tasks map[string]*Task
+	}{{"task", c.Task}, {"tasks", c.Tasks}} {
+		for _, name := range sortedKeys(tasks.tasks) {
+			for i, dep := range tasks.tasks[name].Deps {
+				if _, exists := allTasks[dep]; !exists {
+					v.addf([]interface{}{tasks.key, name, "deps", i}, "unknown task %s", dep)
+				}
+			}
+		}
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+func (c *Config) validateRun(v *configValidator, run *Run, keys ...interface{}) {
+	if run == nil {
+		return
+	}
+	at := func(key ...interface{}) []interface{} {
+		return append(append([]interface{}{}, keys...), key...)
+	}
+	if run.Command != "" {
+		if words, err := splitShellWords(run.Command); err != nil {
+			v.addf(at("command"), "invalid command: %v", err)
+		} else if len(words) == 0 {
+			v.add("must have the program", at("command")...)
+		}
+	}
+	v.duration(run.GracePeriod, at("grace_period")...)
+	v.duration(run.StopTimeout, at("stop_timeout")...)
+	v.duration(run.RestartBackoff, at("restart_backoff")...)
+	v.duration(run.RestartMaxBackoff, at("restart_max_backoff")...)
+	v.signal(run.StopSignal, at("stop_signal")...)
+	v.signal(run.ReloadSignal, at("reload_signal")...)
+	v.oneOf(run.Restart, []string{"never", "on-failure", "always"}, at("restart")...)
+	if run.Strategy != "" {
+		strategiesMu.RLock()
+		_, exists := strategies[run.Strategy]
+		names := []string{}
+		for name := range strategies {
+			names = append(names, name)
+		}
+		strategiesMu.RUnlock()
+		if !exists {
+			sort.Strings(names)
+			v.addf(at("strategy"), "unknown reload strategy %s. %s are available", run.Strategy, strings.Join(names, ", "))
+		}
+	}
+	if run.Healthcheck != nil {
+		validateHealthcheck(v, run.Healthcheck, at("healthcheck")...)
+	}
+	if run.Idle != nil {
+		v.duration(run.Idle.Timeout, at("idle", "timeout")...)
+	}
+	if run.Output != nil {
+		v.oneOf(run.Output.Color, []string{"auto", "always", "never"}, at("output", "color")...)
+	}
+	for i, trigger := range run.Triggers {
+		v.required(trigger.Pattern, at("triggers", i, "pattern")...)
+		if _, err := regexp.Compile(trigger.Pattern); err != nil {
+			v.addf(at("triggers", i, "pattern"), "invalid regexp: %v", err)
+		}
+		v.oneOf(trigger.Action, []string{"hook", "restart", "notify"}, at("triggers", i, "action")...)
+		if trigger.Action == "hook" {
+			v.required(trigger.Hook, at("triggers", i, "hook")...)
+		}
+		v.duration(trigger.Cooldown, at("triggers", i, "cooldown")...)
+	}
+}
+
+func validateHealthcheck(v *configValidator, check *Healthcheck, keys ...interface{}) {
+	if check.HTTP == "" && check.TCP == "" {
+		v.add("http or tcp must be specified", keys...)
+	}
+	v.exclusive([]string{"http", "tcp"}, []bool{check.HTTP != "", check.TCP != ""}, keys...)
+	at := func(key string) []interface{} {
+		return append(append([]interface{}{}, keys...), key)
+	}
+	v.duration(check.Interval, at("interval")...)
+	v.duration(check.Timeout, at("timeout")...)
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]*Target:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*Service:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*Companion:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*Task:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var yamlErrorPositionPattern = regexp.MustCompile(`^\[(\d+):(\d+)\] (.*)`)
+
+// formatYAMLError formats the decode error with the source around the position.
+// The source is rendered from src because the printer of go-yaml may fail for some tokens.
+func formatYAMLError(src []byte, err error) string {
+	msg := yaml.FormatError(err, false, false)
+	matched := yamlErrorPositionPattern.FindStringSubmatch(msg)
+	if matched == nil {
+		return msg
+	}
+	line, _ := strconv.Atoi(matched[1])
+	column, _ := strconv.Atoi(matched[2])
+	return fmt.Sprintf("[%d:%d] %s\n%s", line, column, matched[3], sourceAround(src, line, column))
+}
+
+// formatConfigErrors formats errors by Config.Validate with the source of each value in order of lines.
+func formatConfigErrors(src []byte, errs ConfigErrors) string {
+	var root ast.Node
+	if file, err := parser.ParseBytes(src, 0); err == nil && len(file.Docs) > 0 {
+		root = file.Docs[0].Body
+	}
+	type located struct {
+		line int
+		msg  string
+	}
+	msgs := []*located{}
+	for _, e := range errs {
+		tk := nodeToken(lookupNode(root, e.keys))
+		if tk == nil {
+			msgs = append(msgs, &located{msg: e.Error()})
+			continue
+		}
+		pos := tk.Position
+		msgs = append(msgs, &located{
+			line: pos.Line,
+			msg:  fmt.Sprintf("[%d:%d] %s\n%s", pos.Line, pos.Column, e.Error(), sourceAround(src, pos.Line, pos.Column)),
+		})
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].line < msgs[j].line })
+	lines := []string{}
+	for _, msg := range msgs {
+		lines = append(lines, msg.msg)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nodeToken returns the token of node for showing its position. The first key is used for mappings ( e.g. elements of sequences ).
+func nodeToken(node ast.Node) *token.Token {
+	switch n := unwrapNode(node).(type) {
+	case nil:
+		return nil
+	case *ast.MappingNode:
+		if len(n.Values) > 0 {
+			return n.Values[0].Key.GetToken()
+		}
+		return n.GetToken()
+	case *ast.MappingValueNode:
+		return n.Key.GetToken()
+	default:
+		return n.GetToken()
+	}
+}
+
+// lookupNode returns the key node of the value at keys, or the nearest parent if the value isn't written in the source.
+func lookupNode(node ast.Node, keys []string) ast.Node {
+	var found ast.Node
+	for _, key := range keys {
+		node = unwrapNode(node)
+		var next ast.Node
+		switch n := node.(type) {
+		case *ast.MappingNode:
+			for _, value := range n.Values {
+				if value.Key.GetToken() != nil && value.Key.GetToken().Value == key {
+					found, next = value.Key, value.Value
+					break
+				}
+			}
+		case *ast.MappingValueNode:
+			if n.Key.GetToken() != nil && n.Key.GetToken().Value == key {
+				found, next = n.Key, n.Value
+			}
+		case *ast.SequenceNode:
+			if strings.HasPrefix(key, "[") {
+				idx, err := strconv.Atoi(strings.Trim(key, "[]"))
+				if err == nil && idx < len(n.Values) {
+					found, next = n.Values[idx], n.Values[idx]
+				}
+			}
+		}
+		if next == nil {
+			return found
+		}
+		node = next
+	}
+	return found
+}
+
+func unwrapNode(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+// sourceAround returns lines of src around line with the marker of column like the printer of go-yaml.
+func sourceAround(src []byte, line, column int) string {
+	lines := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	var b strings.Builder
+	for num := line - 3; num <= line+1; num++ {
+		if num < 1 || num > len(lines) {
+			continue
+		}
+		marker := " "
+		if num == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %2d | %s\n", marker, num, lines[num-1])
+		if num == line {
+			fmt.Fprintf(&b, "%s^\n", strings.Repeat(" ", len("> 00 | ")+column-1))
+		}
+	}
+	return b.String()
+}
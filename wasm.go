@@ -0,0 +1,136 @@
+package rebirth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const defaultWasmListen = ":8080"
+
+// wasmIndex is served if wasm.dir doesn't have index.html .
+const wasmIndex = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<script src="/wasm_exec.js"></script>
+<script>
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch("/main.wasm"), go.importObject).then((result) => {
+  go.run(result.instance);
+});
+</script>
+</head>
+<body>
+</body>
+</html>
+`
+
+func (r *Reloader) isWasmMode() bool {
+	return r.wasm != nil
+}
+
+func (w *Wasm) listen() string {
+	if w.Listen == "" {
+		return defaultWasmListen
+	}
+	return w.Listen
+}
+
+// wasmExecPath returns path of wasm_exec.js for the compiler.
+func (r *Reloader) wasmExecPath() (string, error) {
+	if r.build.Compiler == compilerTinyGo {
+		root, err := commandOutput("tinygo", "env", "TINYGOROOT")
+		if err != nil {
+			return "", xerrors.Errorf("failed to get TINYGOROOT: %w", err)
+		}
+		return filepath.Join(root, "targets", "wasm_exec.js"), nil
+	}
+	root, err := commandOutput("go", "env", "GOROOT")
+	if err != nil {
+		return "", xerrors.Errorf("failed to get GOROOT: %w", err)
+	}
+	for _, dir := range []string{"lib", "misc"} {
+		path := filepath.Join(root, dir, "wasm", "wasm_exec.js")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", xerrors.Errorf("wasm_exec.js isn't found in %s", root)
+}
+
+// serveWasm serves wasm_exec.js, the compiled module and static files in wasm.dir .
+// Browsers are reloaded by the live reload channel on each rebuild.
+func (r *Reloader) serveWasm() error {
+	execPath, err := r.wasmExecPath()
+	if err != nil {
+		return xerrors.Errorf("failed to get wasm_exec.js: %w", err)
+	}
+	listener, err := net.Listen("tcp", r.wasm.listen())
+	if err != nil {
+		return xerrors.Errorf("failed to listen %s: %w", r.wasm.listen(), err)
+	}
+	mux := http.NewServeMux()
+	r.liveReload.register(mux)
+	mux.HandleFunc("/wasm_exec.js", func(w http.ResponseWriter, req *http.Request) {
+		http.ServeFile(w, req, execPath)
+	})
+	mux.HandleFunc("/main.wasm", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/wasm")
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, req, buildPath)
+	})
+	mux.HandleFunc("/", r.handleWasmStatic)
+	go http.Serve(listener, mux)
+	r.logger.Infof("Serving wasm on http://%s", listener.Addr())
+	return nil
+}
+
+func (r *Reloader) handleWasmStatic(w http.ResponseWriter, req *http.Request) {
+	dir := r.wasm.Dir
+	if req.URL.Path != "/" && req.URL.Path != "/index.html" {
+		if dir == "" {
+			http.NotFound(w, req)
+			return
+		}
+		http.FileServer(http.Dir(dir)).ServeHTTP(w, req)
+		return
+	}
+	index := []byte(wasmIndex)
+	if dir != "" {
+		if file, err := ioutil.ReadFile(filepath.Join(dir, "index.html")); err == nil {
+			index = file
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(injectLiveReload(index))
+}
+
+// injectLiveReload inserts the live reload script to the html.
+func injectLiveReload(html []byte) []byte {
+	script := []byte(fmt.Sprintf(`<script src="%s"></script>`, liveReloadScriptPath))
+	idx := bytes.LastIndex(bytes.ToLower(html), []byte("</body>"))
+	if idx < 0 {
+		return append(html, script...)
+	}
+	injected := append([]byte{}, html[:idx]...)
+	injected = append(injected, script...)
+	return append(injected, html[idx:]...)
+}
+
+func commandOutput(args ...string) (string, error) {
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", xerrors.Errorf("failed to run %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
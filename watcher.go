@@ -1,8 +1,6 @@
 package rebirth
 
 import (
-	"context"
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -15,64 +13,180 @@ import (
 	"gopkg.in/fsnotify.v1"
 )
 
-type state int
-
-const (
-	idleState state = iota
-	busyState
-)
-
 type Watcher struct {
-	goWatcher  *fsnotify.Watcher
-	eventCh    chan struct{}
-	callback   func()
-	watchState state
-	mu         sync.Mutex
-	cfg        *Watch
+	goWatcher *fsnotify.Watcher
+	eventCh   chan struct{}
+	callback  func([]string)
+	changed   []string
+	mu        sync.Mutex
+	cfg       *Watch
+	files     map[string]struct{}
+	// done is closed by Close for stopping goroutines of the watcher.
+	done      chan struct{}
+	closeOnce sync.Once
+	// selfConfig watches rebirth.yml for reloading the config of rebirth.
+	selfConfig bool
+	// patternsMu guards cfg , assetDirs and generateInputs replaced by UpdateConfig .
+	patternsMu sync.RWMutex
+	assetDirs  []string
+	// generateInputs are build.generate_inputs watched for running go generate.
+	generateInputs []string
+
+	countMu       sync.Mutex
+	fileCount     int
+	fileCountedAt time.Time
 }
 
 const (
-	defaultRoot = "."
+	defaultRoot     = "."
+	defaultDebounce = 2000 * time.Millisecond
+	// fileCountTTL is the duration for reusing the number of watched files counted by walking.
+	fileCountTTL = 10 * time.Second
 )
 
 func NewWatcher(cfg *Config) *Watcher {
 	return &Watcher{
-		eventCh:    make(chan struct{}, 1),
-		watchState: idleState,
-		cfg:        cfg.Watch,
+		eventCh:        make(chan struct{}, 1),
+		done:           make(chan struct{}),
+		cfg:            cfg.Watch,
+		assetDirs:      assetDirs(cfg),
+		generateInputs: generateInputs(cfg),
 	}
 }
 
-func (w *Watcher) addEvent(event fsnotify.Event) {
+func generateInputs(cfg *Config) []string {
+	if cfg.Build == nil || !cfg.Build.Generate {
+		return nil
+	}
+	return cfg.Build.GenerateInputs
+}
+
+// WatchSelfConfig watches rebirth.yml in addition to files of the application.
+func (w *Watcher) WatchSelfConfig() {
+	w.selfConfig = true
+}
+
+// UpdateConfig applies watch patterns ( e.g. watch.include ) of the reloaded config to the following events.
+// watch.root , watch.ignore and polling are applied only on starting.
+func (w *Watcher) UpdateConfig(cfg *Config) {
+	w.patternsMu.Lock()
+	defer w.patternsMu.Unlock()
+	w.cfg = cfg.Watch
+	w.assetDirs = assetDirs(cfg)
+	w.generateInputs = generateInputs(cfg)
+}
+
+// SetFiles watches only the files instead of walking from watch.root .
+// The files are watched regardless of their extension.
+func (w *Watcher) SetFiles(files []string) {
+	w.files = map[string]struct{}{}
+	for _, file := range files {
+		w.files[filepath.Clean(file)] = struct{}{}
+	}
+}
+
+func (w *Watcher) isTargetEvent(event fsnotify.Event) bool {
+	if w.files != nil {
+		_, exists := w.files[filepath.Clean(event.Name)]
+		return exists
+	}
+	// the config file may be a dot file ( e.g. .rebirth.yml )
+	if w.selfConfig && isSelfConfig(event.Name) {
+		return true
+	}
 	name := filepath.Base(event.Name)
 	if strings.HasPrefix(name, "#") {
-		return
+		return false
 	}
 	if strings.HasPrefix(name, ".") {
-		return
+		return false
+	}
+	w.patternsMu.RLock()
+	defer w.patternsMu.RUnlock()
+	relPath := w.relPath(event.Name)
+	if w.cfg != nil && matchAnyGlob(w.cfg.Exclude, relPath) {
+		return false
+	}
+	if w.cfg != nil && matchAnyGlob(w.cfg.Include, relPath) {
+		// included files are watched regardless of their extension
+		return true
+	}
+	if matchAnyGlob(w.generateInputs, relPath) {
+		return true
+	}
+	if w.cfg.configReload(relPath) != nil {
+		return true
+	}
+	for _, dir := range w.assetDirs {
+		if containsPath(dir, event.Name) {
+			// assets are watched regardless of their extension
+			return true
+		}
 	}
 	if filepath.Ext(name) != ".go" {
-		return
+		return false
 	}
 	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	return true
+}
+
+// debounce returns the window for coalescing a burst of events into a single reload.
+func (w *Watcher) debounce() time.Duration {
+	if w.cfg == nil {
+		return defaultDebounce
+	}
+	return parseDurationOr(w.cfg.Debounce, defaultDebounce)
+}
+
+// relPath returns path relative to watch.root for matching watch.include and watch.exclude .
+func (w *Watcher) relPath(path string) string {
+	return relPathFrom(w.root(), path)
+}
+
+func relPathFrom(root, path string) string {
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return rel
+}
+
+// assetDirs returns directories of non-go files which trigger reloading.
+// They are src of host.sync and watch.migrations.dir .
+func assetDirs(cfg *Config) []string {
+	dirs := []string{}
+	for _, rule := range syncRules(cfg.Host) {
+		dirs = append(dirs, rule.Src)
+	}
+	if cfg.Watch != nil && cfg.Watch.Migrations != nil && cfg.Watch.Migrations.Dir != "" {
+		dirs = append(dirs, cfg.Watch.Migrations.Dir)
+	}
+	return dirs
+}
+
+func (w *Watcher) addEvent(event fsnotify.Event) {
+	if !w.isTargetEvent(event) {
 		return
 	}
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.watchState = busyState
+	w.changed = append(w.changed, event.Name)
 	w.eventCh <- struct{}{}
 }
 
 func (w *Watcher) root() string {
-	if w.cfg == nil {
-		return defaultRoot
-	}
-	root := w.cfg.Root
-	if root == "" {
+	return watchRoot(w.cfg)
+}
+
+// watchRoot returns watch.root ( default: . ).
+func watchRoot(cfg *Watch) string {
+	if cfg == nil || cfg.Root == "" {
 		return defaultRoot
 	}
-	return root
+	return cfg.Root
 }
 
 func (w *Watcher) ignorePaths() []string {
@@ -92,10 +206,35 @@ func (w *Watcher) ignorePaths() []string {
 }
 
 func (w *Watcher) watchPaths() []string {
-	ignorePaths := w.ignorePaths()
+	if w.files != nil {
+		return w.fileDirs()
+	}
 	pathMap := map[string]struct{}{}
-	filepath.Walk(w.root(), func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() {
+	for _, path := range w.dirsUnder(w.root()) {
+		pathMap[path] = struct{}{}
+	}
+	for _, dir := range w.assetDirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				pathMap[path] = struct{}{}
+			}
+			return nil
+		})
+	}
+	paths := []string{w.root()}
+	for path := range pathMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// dirsUnder returns directories for watching under root.
+func (w *Watcher) dirsUnder(root string) []string {
+	ignorePaths := w.ignorePaths()
+	dirs := []string{}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
 			return nil
 		}
 		if strings.HasPrefix(path, ".") {
@@ -106,15 +245,106 @@ func (w *Watcher) watchPaths() []string {
 				return nil
 			}
 		}
-		pathMap[path] = struct{}{}
+		if w.cfg != nil && path != w.root() && matchAnyGlob(w.cfg.Exclude, w.relPath(path)) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
 		return nil
 	})
-	paths := []string{w.root()}
-	for path := range pathMap {
-		paths = append(paths, path)
+	return dirs
+}
+
+// addCreatedDir watches the directory created after starting ( e.g. by git checkout ).
+// Files created before watching it are regarded as changed.
+func (w *Watcher) addCreatedDir(dir string) {
+	if w.files != nil {
+		return
 	}
-	sort.Strings(paths)
-	return paths
+	for _, path := range w.dirsUnder(filepath.Clean(dir)) {
+		if err := w.goWatcher.Add(path); err != nil {
+			log.Printf("%+v", err)
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(path, "*"))
+		for _, match := range matches {
+			w.addEvent(fsnotify.Event{Name: match, Op: fsnotify.Create})
+		}
+	}
+}
+
+// handleEvent handles the file system event.
+// Editors save atomically by writing to a temporary file and renaming it to the original name.
+// It generates Rename ( or Remove ) of the original name and Create of the same name,
+// so they are regarded as modification and collected in the same burst.
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	switch {
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.addCreatedDir(event.Name)
+			return
+		}
+		w.addEvent(event)
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		w.addEvent(event)
+	case event.Op&fsnotify.Remove == fsnotify.Remove:
+		w.addEvent(event)
+	case event.Op&fsnotify.Rename == fsnotify.Rename:
+		w.addEvent(event)
+	}
+}
+
+// uniqueFiles removes duplicated files keeping the order.
+func uniqueFiles(files []string) []string {
+	seen := map[string]struct{}{}
+	unique := []string{}
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if _, exists := seen[file]; exists {
+			continue
+		}
+		seen[file] = struct{}{}
+		unique = append(unique, file)
+	}
+	return unique
+}
+
+func (w *Watcher) fileDirs() []string {
+	dirMap := map[string]struct{}{}
+	for file := range w.files {
+		dirMap[filepath.Dir(file)] = struct{}{}
+	}
+	dirs := []string{}
+	for dir := range dirMap {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// WatchedFiles returns the number of files triggering reloads under the watched directories.
+// The number is cached for fileCountTTL because counting walks the directories.
+func (w *Watcher) WatchedFiles() int {
+	w.countMu.Lock()
+	defer w.countMu.Unlock()
+	if time.Since(w.fileCountedAt) < fileCountTTL {
+		return w.fileCount
+	}
+	count := 0
+	if w.files != nil {
+		count = len(w.files)
+	} else {
+		for _, dir := range w.watchPaths() {
+			matches, _ := filepath.Glob(filepath.Join(dir, "*"))
+			for _, path := range matches {
+				if info, err := os.Stat(path); err == nil && !info.IsDir() && w.isTargetEvent(fsnotify.Event{Name: path}) {
+					count++
+				}
+			}
+		}
+	}
+	w.fileCount = count
+	w.fileCountedAt = time.Now()
+	return count
 }
 
 func (w *Watcher) fileNumForWatching(paths []string) int {
@@ -126,8 +356,20 @@ func (w *Watcher) fileNumForWatching(paths []string) int {
 	return fileNum
 }
 
-func (w *Watcher) Run(callback func()) error {
+// Run starts watching. callback is called with the changed files after a burst of events.
+func (w *Watcher) Run(callback func([]string)) error {
 	w.callback = callback
+	if w.isPolling() {
+		w.runPolling()
+	} else if err := w.runEventWatcher(); err != nil {
+		return err
+	}
+	go w.runDebouncer()
+	return nil
+}
+
+// runEventWatcher watches file system events by fsnotify.
+func (w *Watcher) runEventWatcher() error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return xerrors.Errorf("failed to create fsnotify instance: %w", err)
@@ -135,7 +377,7 @@ func (w *Watcher) Run(callback func()) error {
 	watchPaths := w.watchPaths()
 	fileNum := w.fileNumForWatching(watchPaths)
 	for _, path := range watchPaths {
-		fmt.Printf("Watching %s\n", path)
+		logger().Infof("Watching %s", path)
 		if err := watcher.Add(path); err != nil {
 			return xerrors.Errorf(
 				"failed to add path %s. current total watching file number is %d: %w",
@@ -145,54 +387,75 @@ func (w *Watcher) Run(callback func()) error {
 			)
 		}
 	}
+	w.goWatcher = watcher
 	go func() {
 		defer w.recoverRuntimeError()
 		for {
 			select {
-			case event := <-watcher.Events:
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					w.addEvent(event)
-				} else if event.Op&fsnotify.Write == fsnotify.Write {
-					w.addEvent(event)
-				} else if event.Op&fsnotify.Write == fsnotify.Remove {
-					w.addEvent(event)
-				} else if event.Op&fsnotify.Write == fsnotify.Rename {
-					w.addEvent(event)
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				w.handleEvent(event)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
 				}
-			case err := <-watcher.Errors:
 				log.Printf("%+v", err)
+			case <-w.done:
+				return
 			}
 		}
 	}()
-	w.goWatcher = watcher
+	return nil
+}
 
-	go func() {
+// runDebouncer calls callback with the changed files after a burst of events.
+// The burst continues while events arrive within the debounce window.
+func (w *Watcher) runDebouncer() {
+	for {
+		select {
+		case <-w.eventCh:
+		case <-w.done:
+			return
+		}
+		timer := time.NewTimer(w.debounce())
+	burst:
 		for {
-			switch w.watchState {
-			case idleState:
-				time.Sleep(10 * time.Millisecond)
-			case busyState:
-				func() {
-					ctx, cancel := context.WithTimeout(context.Background(), 2000*time.Millisecond)
-					defer cancel()
-					select {
-					case <-w.eventCh:
-						// receive event. continue busy phase
-					case <-ctx.Done():
-						// end busy phase.
-						w.mu.Lock()
-						defer w.mu.Unlock()
-						w.callback()
-						if len(w.eventCh) > 0 {
-							// exists event. receive it for escaping blocking
-							<-w.eventCh
-						}
-						w.watchState = idleState
-					}
-				}()
+			select {
+			case <-w.eventCh:
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(w.debounce())
+			case <-timer.C:
+				break burst
+			case <-w.done:
+				timer.Stop()
+				return
 			}
 		}
-	}()
+		w.mu.Lock()
+		changed := uniqueFiles(w.changed)
+		w.changed = nil
+		w.callback(changed)
+		if len(w.eventCh) > 0 {
+			// exists event. receive it for escaping blocking
+			<-w.eventCh
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Close stops watching files and goroutines of the watcher.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	if w.goWatcher == nil {
+		return nil
+	}
+	if err := w.goWatcher.Close(); err != nil {
+		return xerrors.Errorf("failed to close fsnotify instance: %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,129 @@
+package rebirth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+)
+
+const (
+	defaultBuildYield  = 30 * time.Second
+	buildYieldInterval = 500 * time.Millisecond
+)
+
+// contendingGoSubcommands are go subcommands using the build cache and CPU heavily.
+// go run isn't included because it keeps running the program after building it ( e.g. go run ./cmd/x of another service ).
+var contendingGoSubcommands = []string{"build", "test", "install", "vet"}
+
+// goProcess is the user's go command running in the module.
+type goProcess struct {
+	pid        int
+	subcommand string
+}
+
+func (b *Build) yield() time.Duration {
+	return parseDurationOr(b.Yield, defaultBuildYield)
+}
+
+// isDescendant returns true if the process is started by rebirth ( e.g. go build of rebirth ).
+func isDescendant(processes map[int]ps.Process, pid int) bool {
+	self := os.Getpid()
+	for i := 0; i < len(processes) && pid > 1; i++ {
+		if pid == self {
+			return true
+		}
+		process, exists := processes[pid]
+		if !exists {
+			return false
+		}
+		pid = process.PPid()
+	}
+	return false
+}
+
+// goSubcommand returns the subcommand of the go command and whether it runs in dir.
+// Arguments and the working directory are known by /proc . If it isn't available ( e.g. on macOS ),
+// the go command is regarded as running outside dir not to delay every build by unrelated go commands.
+func goSubcommand(pid int, dir string) (string, bool) {
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "", false
+	}
+	wd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err != nil {
+		return "", false
+	}
+	if wd != dir && !strings.HasPrefix(wd, dir+string(filepath.Separator)) {
+		return "", false
+	}
+	args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	for _, arg := range args[1:] {
+		if len(arg) > 0 && arg[0] != '-' {
+			return string(arg), true
+		}
+	}
+	return "", true
+}
+
+// runningGoProcesses returns the user's go build / test running in the module.
+func runningGoProcesses() []*goProcess {
+	processes, err := ps.Processes()
+	if err != nil {
+		return nil
+	}
+	byPid := map[int]ps.Process{}
+	for _, process := range processes {
+		byPid[process.Pid()] = process
+	}
+	found := []*goProcess{}
+	for _, process := range processes {
+		if process.Executable() != "go" || isDescendant(byPid, process.Pid()) {
+			continue
+		}
+		subcommand, inModule := goSubcommand(process.Pid(), cwd)
+		if !inModule {
+			continue
+		}
+		if subcommand != "" && !containsString(contendingGoSubcommands, subcommand) {
+			continue
+		}
+		found = append(found, &goProcess{pid: process.Pid(), subcommand: subcommand})
+	}
+	return found
+}
+
+// yieldToGoCommands waits for the user's go build / test in the same module up to build.yield ,
+// so background rebuilds don't fight interactive go commands for the build cache and CPU.
+func (r *Reloader) yieldToGoCommands() {
+	timeout := r.build.yield()
+	if timeout <= 0 {
+		return
+	}
+	processes := runningGoProcesses()
+	if len(processes) == 0 {
+		return
+	}
+	names := []string{}
+	for _, process := range processes {
+		name := "go"
+		if process.subcommand != "" {
+			name = fmt.Sprintf("go %s", process.subcommand)
+		}
+		names = append(names, fmt.Sprintf("%s ( pid %d )", name, process.pid))
+	}
+	r.logger.Infof("Waiting for %s in the module...", strings.Join(names, ", "))
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(buildYieldInterval)
+		if len(runningGoProcesses()) == 0 {
+			return
+		}
+	}
+	r.logger.Infof("go commands are still running after %s. building anyway", timeout)
+}